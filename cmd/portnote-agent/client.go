@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hitushen/portnotepro/internal/scanner"
+)
+
+var (
+	// errLeaseLost 表示服务端已收回任务租约（超时重新排队或任务已结束）。
+	errLeaseLost    = errors.New("lease lost")
+	errUnauthorized = errors.New("token rejected by server")
+)
+
+type registration struct {
+	ID           int64  `json:"id"`
+	Name         string `json:"name"`
+	Zone         string `json:"zone"`
+	PollSeconds  int    `json:"pollSeconds"`
+	LeaseSeconds int    `json:"leaseSeconds"`
+}
+
+// client 封装服务端 /agent/v1 接口。
+type client struct {
+	base  string
+	token string
+	http  *http.Client
+}
+
+func newClient(base, token, caFile string) (*client, error) {
+	base = strings.TrimRight(strings.TrimSpace(base), "/")
+	if !strings.HasPrefix(base, "https://") && !strings.HasPrefix(base, "http://") {
+		return nil, fmt.Errorf("server URL must start with https:// or http://")
+	}
+	tlsConfig, err := loadCA(caFile)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+	return &client{
+		base:  base + "/agent/v1",
+		token: token,
+		http:  &http.Client{Transport: transport, Timeout: time.Minute},
+	}, nil
+}
+
+func (c *client) register(ctx context.Context, version, hostname string) (*registration, error) {
+	var info registration
+	_, err := c.do(ctx, "/register", map[string]string{"version": version, "hostname": hostname}, &info)
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// lease 领取一个任务，服务端没有任务时返回 nil。
+func (c *client) lease(ctx context.Context) (*scanner.AgentJob, error) {
+	var job scanner.AgentJob
+	status, err := c.do(ctx, "/jobs/lease", nil, &job)
+	if err != nil || status == http.StatusNoContent {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (c *client) heartbeat(ctx context.Context, jobID int64) error {
+	_, err := c.do(ctx, fmt.Sprintf("/jobs/%d/heartbeat", jobID), nil, nil)
+	return err
+}
+
+func (c *client) result(ctx context.Context, jobID int64, res scanner.AgentResult) error {
+	_, err := c.do(ctx, fmt.Sprintf("/jobs/%d/result", jobID), res, nil)
+	return err
+}
+
+// do 发送 POST 请求并解析 JSON 响应，409 返回 errLeaseLost，401/403 返回 errUnauthorized。
+func (c *client) do(ctx context.Context, path string, body, out interface{}) (int, error) {
+	var reader io.Reader = http.NoBody
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.base+path, reader)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "portnote-agent/"+version)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusConflict:
+		return resp.StatusCode, errLeaseLost
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return resp.StatusCode, fmt.Errorf("%w: %s", errUnauthorized, readMessage(resp.Body))
+	case resp.StatusCode >= 300:
+		return resp.StatusCode, fmt.Errorf("%s: %s", resp.Status, readMessage(resp.Body))
+	}
+	if out != nil && resp.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, fmt.Errorf("decode %s: %w", path, err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+func readMessage(r io.Reader) string {
+	data, _ := io.ReadAll(io.LimitReader(r, 4096))
+	var body struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(data, &body) == nil {
+		if body.Message != "" {
+			return body.Message
+		}
+		if body.Error != "" {
+			return body.Error
+		}
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,254 @@
+// portnote-agent 在私有网络内执行扫描任务：向服务端注册，领取所在区域主机的扫描任务，
+// 在本地运行扫描引擎并把结果回传给服务端。
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hitushen/portnotepro/internal/scanner"
+	"github.com/hitushen/portnotepro/internal/targets"
+)
+
+// version 可在构建时通过 -ldflags "-X main.version=..." 指定。
+var version = "dev"
+
+func main() {
+	fs := flag.NewFlagSet("portnote-agent", flag.ExitOnError)
+	serverURL := fs.String("server", os.Getenv("PORTNOTE_SERVER"), "server base URL, e.g. https://portnote.example.com (env PORTNOTE_SERVER)")
+	token := fs.String("token", os.Getenv("PORTNOTE_AGENT_TOKEN"), "agent token (env PORTNOTE_AGENT_TOKEN)")
+	rate := fs.Int("rate", intEnv("PORTNOTE_SCAN_RATE", 10000), "packets per second shared by all running scans (env PORTNOTE_SCAN_RATE)")
+	timeout := fs.Duration("timeout", durationEnv("PORTNOTE_SCAN_TIMEOUT", 2*time.Second), "per-port probe timeout (env PORTNOTE_SCAN_TIMEOUT)")
+	concurrency := fs.Int("concurrency", intEnv("PORTNOTE_AGENT_CONCURRENCY", 2), "scan jobs run at the same time (env PORTNOTE_AGENT_CONCURRENCY)")
+	caFile := fs.String("ca", os.Getenv("PORTNOTE_AGENT_CA"), "PEM file with extra CA certificates for the server (env PORTNOTE_AGENT_CA)")
+	resolver := fs.String("dns-resolver", os.Getenv("PORTNOTE_DNS_RESOLVER"), "DNS server host:port used to resolve targets (env PORTNOTE_DNS_RESOLVER)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: portnote-agent -server URL -token TOKEN [flags]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(os.Args[1:])
+
+	if *serverURL == "" || *token == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *concurrency <= 0 || *rate <= 0 || *timeout <= 0 {
+		log.Fatal("concurrency, rate and timeout must be positive")
+	}
+	if err := targets.SetResolver(*resolver); err != nil {
+		log.Fatalf("dns resolver: %v", err)
+	}
+	client, err := newClient(*serverURL, *token, *caFile)
+	if err != nil {
+		log.Fatalf("client: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	info, err := register(ctx, client)
+	if err != nil {
+		// 只有收到退出信号时 register 才会返回错误。
+		log.Printf("stopped before registration: %v", err)
+		return
+	}
+	log.Printf("registered as %q (zone %s), polling every %ds", info.Name, info.Zone, info.PollSeconds)
+
+	a := &agent{
+		client: client,
+		engine: scanner.NewEngine(*timeout, *rate),
+		poll:   time.Duration(info.PollSeconds) * time.Second,
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < *concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.work(ctx)
+		}()
+	}
+	<-ctx.Done()
+	log.Println("shutting down, unfinished jobs will be requeued by the server when their lease expires")
+	wg.Wait()
+}
+
+type agent struct {
+	client *client
+	engine *scanner.Engine
+	poll   time.Duration
+}
+
+// work 循环领取并执行任务，没有任务或请求失败时等待一个轮询间隔。
+func (a *agent) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, err := a.client.lease(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("lease job: %v", err)
+			}
+		} else if job != nil {
+			a.run(ctx, job)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(a.poll):
+		}
+	}
+}
+
+// run 执行一个任务：期间每隔三分之一租约续约一次，租约被收回时放弃扫描。
+func (a *agent) run(ctx context.Context, job *scanner.AgentJob) {
+	log.Printf("job %d: %s scan of %s (attempt %d)", job.ID, job.Kind, job.Request.Address, job.Attempt)
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	lease := time.Duration(job.Lease) * time.Second
+	if lease <= 0 {
+		lease = time.Minute
+	}
+	var lost bool
+	var mu sync.Mutex
+	go func() {
+		ticker := time.NewTicker(lease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-jobCtx.Done():
+				return
+			case <-ticker.C:
+			}
+			err := a.client.heartbeat(jobCtx, job.ID)
+			if errors.Is(err, errLeaseLost) {
+				log.Printf("job %d: lease lost, abandoning scan", job.ID)
+				mu.Lock()
+				lost = true
+				mu.Unlock()
+				cancel()
+				return
+			}
+			if err != nil && jobCtx.Err() == nil {
+				log.Printf("job %d: heartbeat: %v", job.ID, err)
+			}
+		}
+	}()
+
+	started := time.Now()
+	result, err := a.engine.Scan(jobCtx, job.Request)
+	mu.Lock()
+	abandoned := lost
+	mu.Unlock()
+	if abandoned || ctx.Err() != nil {
+		return
+	}
+	cancel()
+
+	res := scanner.AgentResult{Result: result}
+	if err != nil {
+		se := scanner.ClassifyError(err)
+		res.Error, res.ErrorKind, res.Transient = err.Error(), se.Kind, se.Transient
+		log.Printf("job %d: failed after %s (%s): %v", job.ID, time.Since(started).Round(time.Second), se.Kind, err)
+	} else {
+		log.Printf("job %d: %d open ports in %s", job.ID, len(result.Open), time.Since(started).Round(time.Second))
+	}
+	a.submit(ctx, job.ID, res)
+}
+
+// submit 回传结果，网络错误时重试，直到租约被收回或收到退出信号。
+func (a *agent) submit(ctx context.Context, jobID int64, res scanner.AgentResult) {
+	delay := 2 * time.Second
+	for {
+		err := a.client.result(ctx, jobID, res)
+		if err == nil {
+			return
+		}
+		if errors.Is(err, errLeaseLost) {
+			log.Printf("job %d: lease lost before the result was accepted", jobID)
+			return
+		}
+		log.Printf("job %d: submit result: %v (retrying in %s)", jobID, err, delay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > time.Minute {
+			delay = time.Minute
+		}
+	}
+}
+
+// register 向服务端注册，失败时按指数退避重试直到成功或收到退出信号。
+func register(ctx context.Context, c *client) (*registration, error) {
+	hostname, _ := os.Hostname()
+	delay := 2 * time.Second
+	for {
+		info, err := c.register(ctx, version, hostname)
+		if err == nil {
+			if info.PollSeconds <= 0 {
+				info.PollSeconds = 10
+			}
+			return info, nil
+		}
+		if errors.Is(err, errUnauthorized) {
+			log.Fatalf("register: %v", err)
+		}
+		log.Printf("register: %v (retrying in %s)", err, delay)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > time.Minute {
+			delay = time.Minute
+		}
+	}
+}
+
+func loadCA(path string) (*tls.Config, error) {
+	if path == "" {
+		return nil, nil
+	}
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("%s: no certificates found", path)
+	}
+	return &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}, nil
+}
+
+func intEnv(key string, fallback int) int {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return fallback
+}
+
+func durationEnv(key string, fallback time.Duration) time.Duration {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// apiError 为服务端返回的错误响应。
+type apiError struct {
+	Status  int
+	Message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.Status, http.StatusText(e.Status), e.Message)
+}
+
+// client 使用 API 令牌访问服务端 /api 接口。
+type client struct {
+	base  string
+	token string
+	http  *http.Client
+}
+
+func newClient(base, token string) (*client, error) {
+	base = strings.TrimRight(strings.TrimSpace(base), "/")
+	if base == "" {
+		return nil, errors.New("server not configured: run `portnote login` or pass -server")
+	}
+	if !strings.HasPrefix(base, "https://") && !strings.HasPrefix(base, "http://") {
+		return nil, fmt.Errorf("server URL must start with https:// or http://")
+	}
+	return &client{
+		base:  base,
+		token: token,
+		http: &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+			// 未登录的请求会被重定向到登录页，直接返回 302 便于给出明确提示。
+			return http.ErrUseLastResponse
+		}},
+	}, nil
+}
+
+// do 发送请求并把 JSON 响应解析到 out，body 非 nil 时以 JSON 编码发送。
+func (c *client) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	resp, err := c.send(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *client) send(ctx context.Context, method, path string, query url.Values, body interface{}) (*http.Response, error) {
+	if c.token == "" {
+		return nil, errors.New("not logged in: run `portnote login` or pass -token")
+	}
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	target := c.base + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		return nil, readAPIError(resp)
+	}
+	return resp, nil
+}
+
+func readAPIError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	var body struct {
+		Error string `json:"error"`
+	}
+	msg := strings.TrimSpace(string(data))
+	if json.Unmarshal(data, &body) == nil && body.Error != "" {
+		msg = body.Error
+	}
+	if resp.StatusCode == http.StatusFound {
+		msg = "not authenticated, run `portnote login`"
+	}
+	return &apiError{Status: resp.StatusCode, Message: msg}
+}
+
+// event 为 /api/events 推送的 SSE 消息。
+type event struct {
+	Type    string                 `json:"type"`
+	HostID  int64                  `json:"hostId"`
+	PortID  int64                  `json:"portId"`
+	Payload map[string]interface{} `json:"payload"`
+}
+
+// events 订阅 /api/events，连接建立后才返回，调用方随后触发的事件不会遗漏。ctx 结束时关闭通道。
+func (c *client) events(ctx context.Context) (<-chan event, error) {
+	resp, err := c.send(ctx, http.MethodGet, "/api/events", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan event, 64)
+	go func() {
+		defer close(ch)
+		defer resp.Body.Close()
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 64*1024), 1<<20)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if !strings.HasPrefix(line, "data: ") {
+				continue
+			}
+			var ev event
+			if json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev) != nil {
+				continue
+			}
+			select {
+			case ch <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+var csrfFieldPattern = regexp.MustCompile(`name="csrf_token" value="([^"]*)"`)
+
+// login 使用用户名与密码登录网页会话，再以该会话创建一个 API 令牌。
+func login(ctx context.Context, base, username, password, tokenName string) (string, int64, error) {
+	base = strings.TrimRight(strings.TrimSpace(base), "/")
+	jar, _ := cookiejar.New(nil)
+	hc := &http.Client{
+		Jar:     jar,
+		Timeout: 30 * time.Second,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	get := func(path string) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		return hc.Do(req)
+	}
+
+	resp, err := get("/login")
+	if err != nil {
+		return "", 0, err
+	}
+	page, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	resp.Body.Close()
+	m := csrfFieldPattern.FindSubmatch(page)
+	if m == nil {
+		return "", 0, fmt.Errorf("%s/login does not look like a PortNote login page", base)
+	}
+	csrfToken := html.UnescapeString(string(m[1]))
+
+	form := url.Values{"csrf_token": {csrfToken}, "username": {username}, "password": {password}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, base+"/login", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Referer", base+"/login")
+	resp, err = hc.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != "/" {
+		return "", 0, errors.New("login failed: invalid username or password")
+	}
+
+	data, _ := json.Marshal(map[string]string{"name": tokenName})
+	req, err = http.NewRequestWithContext(ctx, http.MethodPost, base+"/api/me/tokens", bytes.NewReader(data))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-CSRF-Token", csrfToken)
+	req.Header.Set("Referer", base+"/")
+	resp, err = hc.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, readAPIError(resp)
+	}
+	var created struct {
+		ID    int64  `json:"id"`
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return "", 0, err
+	}
+	return created.Token, created.ID, nil
+}
@@ -0,0 +1,582 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// host 与 port 为表格输出需要的字段，JSON 输出使用服务端原始对象。
+type host struct {
+	ID                  int64      `json:"id"`
+	Name                string     `json:"name"`
+	Address             string     `json:"address"`
+	Scanning            bool       `json:"scanning"`
+	OpenCount           int        `json:"openCount"`
+	HiddenCount         int        `json:"hiddenCount"`
+	Zone                string     `json:"zone"`
+	LastScanAt          *time.Time `json:"lastScanAt"`
+	LastScanErrorKind   string     `json:"lastScanErrorKind"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+}
+
+type port struct {
+	ID                int64      `json:"id"`
+	HostID            int64      `json:"hostId"`
+	HostName          string     `json:"hostName"`
+	Number            int        `json:"number"`
+	Status            string     `json:"status"`
+	Fingerprint       string     `json:"fingerprint"`
+	UserFingerprint   string     `json:"userFingerprint"`
+	FingerprintLocked bool       `json:"fingerprintLocked"`
+	Note              string     `json:"note"`
+	Hidden            bool       `json:"hidden"`
+	LastChecked       *time.Time `json:"lastChecked"`
+}
+
+func cmdLogin(a *app, ctx context.Context, args []string) error {
+	fs := a.flags("login")
+	user := fs.String("user", os.Getenv("USER"), "username")
+	passwordStdin := fs.Bool("password-stdin", false, "read the password from standard input")
+	name := fs.String("name", "", "name of the created API token (default portnote-cli@HOSTNAME)")
+	rest, err := a.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return usagef("unexpected argument %q", rest[0])
+	}
+	if a.server == "" {
+		return usagef("-server is required for the first login")
+	}
+	if *name == "" {
+		hostname, _ := os.Hostname()
+		*name = "portnote-cli@" + hostname
+	}
+	stdin := bufio.NewReader(os.Stdin)
+	if *user == "" {
+		fmt.Fprint(a.stderr, "Username: ")
+		line, _ := stdin.ReadString('\n')
+		*user = strings.TrimSpace(line)
+	}
+	var password string
+	if !*passwordStdin && term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprintf(a.stderr, "Password for %s: ", *user)
+		raw, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(a.stderr)
+		if err != nil {
+			return err
+		}
+		password = string(raw)
+	} else {
+		line, _ := stdin.ReadString('\n')
+		password = strings.TrimRight(line, "\r\n")
+	}
+
+	token, tokenID, err := login(ctx, a.server, *user, password, *name)
+	if err != nil {
+		return err
+	}
+	// 换了服务端或重新登录时吊销旧令牌，失败不影响本次登录。
+	if a.cfg.TokenID != 0 && a.cfg.Token != "" && a.cfg.Server == strings.TrimRight(a.server, "/") {
+		if old, err := newClient(a.cfg.Server, a.cfg.Token); err == nil {
+			_ = old.do(ctx, http.MethodDelete, fmt.Sprintf("/api/me/tokens/%d", a.cfg.TokenID), nil, nil, nil)
+		}
+	}
+	a.cfg.Server = strings.TrimRight(a.server, "/")
+	a.cfg.Token, a.cfg.TokenID = token, tokenID
+	if err := saveConfig(a.cfgPath, a.cfg); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stderr, "logged in to %s as %s, token saved to %s\n", a.cfg.Server, *user, a.cfgPath)
+	return nil
+}
+
+func cmdLogout(a *app, ctx context.Context, args []string) error {
+	fs := a.flags("logout")
+	if rest, err := a.parse(fs, args); err != nil {
+		return err
+	} else if len(rest) > 0 {
+		return usagef("unexpected argument %q", rest[0])
+	}
+	if a.cfg.Token == "" {
+		fmt.Fprintln(a.stderr, "not logged in")
+		return nil
+	}
+	if a.cfg.TokenID != 0 {
+		c, err := newClient(a.cfg.Server, a.cfg.Token)
+		if err != nil {
+			return err
+		}
+		err = c.do(ctx, http.MethodDelete, fmt.Sprintf("/api/me/tokens/%d", a.cfg.TokenID), nil, nil, nil)
+		var ae *apiError
+		// 令牌已被吊销时同样视为成功。
+		if err != nil && !(errors.As(err, &ae) && (ae.Status == http.StatusUnauthorized || ae.Status == http.StatusNotFound)) {
+			return fmt.Errorf("revoke token: %w", err)
+		}
+	}
+	a.cfg.Token, a.cfg.TokenID = "", 0
+	if err := saveConfig(a.cfgPath, a.cfg); err != nil {
+		return err
+	}
+	fmt.Fprintln(a.stderr, "logged out")
+	return nil
+}
+
+func cmdHosts(a *app, ctx context.Context, args []string) error {
+	fs := a.flags("hosts")
+	labels := fs.String("labels", "", "label query, e.g. 'env=prod AND NOT team=ops'")
+	group := fs.Int64("group", 0, "only hosts in this group (including subgroups)")
+	rest, err := a.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return usagef("unexpected argument %q", rest[0])
+	}
+	c, err := a.client()
+	if err != nil {
+		return err
+	}
+	query := url.Values{}
+	if *labels != "" {
+		query.Set("labels", *labels)
+	}
+	if *group > 0 {
+		query.Set("group", strconv.FormatInt(*group, 10))
+	}
+	raw, hosts, err := listHosts(ctx, c, query)
+	if err != nil {
+		return err
+	}
+	t := &table{headers: []string{"ID", "NAME", "ADDRESS", "OPEN", "HIDDEN", "ZONE", "LAST SCAN", "STATE"}, raw: raw}
+	for _, h := range hosts {
+		state := "ok"
+		switch {
+		case h.Scanning:
+			state = "scanning"
+		case h.ConsecutiveFailures > 0:
+			state = fmt.Sprintf("failed: %s (x%d)", h.LastScanErrorKind, h.ConsecutiveFailures)
+		case h.LastScanAt == nil:
+			state = "never scanned"
+		}
+		t.add(strconv.FormatInt(h.ID, 10), h.Name, h.Address, strconv.Itoa(h.OpenCount), strconv.Itoa(h.HiddenCount),
+			orDash(h.Zone), formatTime(h.LastScanAt), state)
+	}
+	return a.render(t)
+}
+
+func cmdPorts(a *app, ctx context.Context, args []string) error {
+	fs := a.flags("ports")
+	status := fs.String("status", "open", "port status: open, closed, filtered, unreachable or all")
+	q := fs.String("q", "", "search port number, note and fingerprint")
+	labels := fs.String("labels", "", "label query")
+	number := fs.Int("number", 0, "exact port number")
+	portRange := fs.String("range", "", "port range, e.g. 8000-8999")
+	hidden := fs.Bool("hidden", false, "include hidden ports")
+	rest, err := a.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 1 {
+		return usagef("expected at most one HOST")
+	}
+	c, err := a.client()
+	if err != nil {
+		return err
+	}
+	query := url.Values{}
+	var target *host
+	if len(rest) == 1 {
+		if target, err = findHost(ctx, c, rest[0]); err != nil {
+			return err
+		}
+		query.Set("hostId", strconv.FormatInt(target.ID, 10))
+		query.Set("sort", "number")
+	}
+	if *status != "all" {
+		query.Set("status", *status)
+	}
+	if !*hidden {
+		query.Set("hidden", "0")
+	}
+	for key, value := range map[string]string{"q": *q, "labels": *labels, "range": *portRange} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	if *number > 0 {
+		query.Set("number", strconv.Itoa(*number))
+	}
+	raw, ports, err := searchPorts(ctx, c, query)
+	if err != nil {
+		return err
+	}
+	t := &table{headers: []string{"HOST", "PORT", "STATUS", "SERVICE", "NOTE", "HIDDEN", "LAST CHECKED"}, raw: raw}
+	if target != nil {
+		t.headers = t.headers[1:]
+	}
+	for _, p := range ports {
+		row := []string{p.HostName, strconv.Itoa(p.Number), p.Status, orDash(p.Fingerprint), orDash(p.Note),
+			strconv.FormatBool(p.Hidden), formatTime(p.LastChecked)}
+		if target != nil {
+			row = row[1:]
+		}
+		t.add(row...)
+	}
+	return a.render(t)
+}
+
+func cmdPort(a *app, ctx context.Context, args []string) error {
+	fs := a.flags("port")
+	note := fs.String("note", "", "note for the added port (default: well-known service name)")
+	fingerprint := fs.String("fingerprint", "", "fingerprint for the added port; locks it against scanner updates")
+	rest, err := a.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 3 || (rest[0] != "add" && rest[0] != "rm") {
+		return usagef("expected `add HOST NUMBER` or `rm HOST NUMBER`")
+	}
+	number, err := parsePortNumber(rest[2])
+	if err != nil {
+		return usagef("%v", err)
+	}
+	c, err := a.client()
+	if err != nil {
+		return err
+	}
+	h, err := findHost(ctx, c, rest[1])
+	if err != nil {
+		return err
+	}
+	if rest[0] == "rm" {
+		p, err := findPort(ctx, c, h, number)
+		if err != nil {
+			return err
+		}
+		if err := c.do(ctx, http.MethodDelete, fmt.Sprintf("/api/ports/%d", p.ID), nil, nil, nil); err != nil {
+			return err
+		}
+		fmt.Fprintf(a.stderr, "removed port %d from %s\n", number, h.Name)
+		return nil
+	}
+
+	var raw json.RawMessage
+	body := map[string]interface{}{"number": number, "note": *note, "fingerprint": *fingerprint}
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/api/hosts/%d/ports", h.ID), nil, body, &raw); err != nil {
+		return err
+	}
+	var p port
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return err
+	}
+	t := &table{headers: []string{"ID", "PORT", "STATUS", "SERVICE", "NOTE"}, raw: raw}
+	t.add(strconv.FormatInt(p.ID, 10), strconv.Itoa(p.Number), p.Status, orDash(p.Fingerprint), orDash(p.Note))
+	return a.render(t)
+}
+
+func cmdNote(a *app, ctx context.Context, args []string) error {
+	fs := a.flags("note")
+	rest, err := a.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) < 3 {
+		return usagef("expected HOST PORT TEXT")
+	}
+	number, err := parsePortNumber(rest[1])
+	if err != nil {
+		return usagef("%v", err)
+	}
+	text := strings.TrimSpace(strings.Join(rest[2:], " "))
+	if text == "" {
+		return usagef("note must not be empty")
+	}
+	c, err := a.client()
+	if err != nil {
+		return err
+	}
+	h, err := findHost(ctx, c, rest[0])
+	if err != nil {
+		return err
+	}
+	p, err := findPort(ctx, c, h, number)
+	if err != nil {
+		return err
+	}
+	// 保留人工标注的指纹与锁定状态，只修改备注。
+	body := map[string]interface{}{"note": text, "fingerprint": p.UserFingerprint, "locked": p.FingerprintLocked}
+	if err := c.do(ctx, http.MethodPut, fmt.Sprintf("/api/ports/%d", p.ID), nil, body, nil); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stderr, "updated note of %s:%d\n", h.Name, number)
+	return nil
+}
+
+func cmdSuggest(a *app, ctx context.Context, args []string) error {
+	fs := a.flags("suggest")
+	start := fs.Int("start", 1024, "lowest port to consider")
+	rest, err := a.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 {
+		return usagef("expected HOST")
+	}
+	c, err := a.client()
+	if err != nil {
+		return err
+	}
+	h, err := findHost(ctx, c, rest[0])
+	if err != nil {
+		return err
+	}
+	var res struct {
+		Port int `json:"port"`
+	}
+	query := url.Values{"start": {strconv.Itoa(*start)}}
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/hosts/%d/unused_port", h.ID), query, nil, &res); err != nil {
+		return err
+	}
+	t := &table{headers: []string{"HOST", "PORT"}, raw: map[string]interface{}{"hostId": h.ID, "host": h.Name, "port": res.Port}}
+	t.add(h.Name, strconv.Itoa(res.Port))
+	return a.render(t)
+}
+
+// scanOutcome 为一台主机的扫描结果。
+type scanOutcome struct {
+	HostID    int64  `json:"hostId"`
+	Host      string `json:"host"`
+	Status    string `json:"status"`
+	Changed   bool   `json:"changed"`
+	OpenPorts int    `json:"openPorts"`
+	Error     string `json:"error,omitempty"`
+	ErrorKind string `json:"errorKind,omitempty"`
+}
+
+func cmdScan(a *app, ctx context.Context, args []string) error {
+	fs := a.flags("scan")
+	wait := fs.Bool("wait", false, "wait until the scans finish (follows /api/events)")
+	timeout := fs.Duration("timeout", 30*time.Minute, "give up waiting after this long")
+	rest, err := a.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) == 0 {
+		return usagef("expected at least one HOST")
+	}
+	c, err := a.client()
+	if err != nil {
+		return err
+	}
+	var hosts []*host
+	for _, ref := range rest {
+		h, err := findHost(ctx, c, ref)
+		if err != nil {
+			return err
+		}
+		hosts = append(hosts, h)
+	}
+
+	var events <-chan event
+	if *wait {
+		waitCtx, cancel := context.WithTimeout(ctx, *timeout)
+		defer cancel()
+		ctx = waitCtx
+		// 先订阅事件再触发扫描，避免错过很快结束的扫描。
+		if events, err = c.events(ctx); err != nil {
+			return fmt.Errorf("subscribe to events: %w", err)
+		}
+	}
+	pending := map[int64]*scanOutcome{}
+	var outcomes []*scanOutcome
+	for _, h := range hosts {
+		var res struct {
+			Status string `json:"status"`
+		}
+		if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/api/hosts/%d/scan", h.ID), nil, nil, &res); err != nil {
+			return fmt.Errorf("%s: %w", h.Name, err)
+		}
+		o := &scanOutcome{HostID: h.ID, Host: h.Name, Status: res.Status}
+		outcomes = append(outcomes, o)
+		pending[h.ID] = o
+		fmt.Fprintf(a.stderr, "%s: %s\n", h.Name, res.Status)
+	}
+
+	failed := false
+	for len(pending) > 0 && events != nil {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("gave up waiting for %d scan(s): %w", len(pending), ctx.Err())
+		case ev, ok := <-events:
+			if !ok {
+				if ctx.Err() != nil {
+					return fmt.Errorf("gave up waiting for %d scan(s): %w", len(pending), ctx.Err())
+				}
+				return errors.New("event stream closed before the scans finished")
+			}
+			o := pending[ev.HostID]
+			if o == nil {
+				continue
+			}
+			switch ev.Type {
+			case "host_deleted":
+				o.Status, o.Error = "failed", "host deleted"
+				failed = true
+				delete(pending, ev.HostID)
+			case "host_scanned":
+				if retrying, _ := ev.Payload["retrying"].(bool); retrying {
+					fmt.Fprintf(a.stderr, "%s: attempt failed (%v), retrying at %v\n", o.Host, ev.Payload["errorKind"], ev.Payload["nextAttemptAt"])
+					continue
+				}
+				o.Changed, _ = ev.Payload["changed"].(bool)
+				if success, _ := ev.Payload["success"].(bool); success {
+					o.Status = "done"
+				} else {
+					o.Status = "failed"
+					o.Error, _ = ev.Payload["error"].(string)
+					o.ErrorKind, _ = ev.Payload["errorKind"].(string)
+					failed = true
+				}
+				delete(pending, ev.HostID)
+			}
+		}
+	}
+	if !*wait {
+		return nil
+	}
+
+	_, latest, err := listHosts(ctx, c, nil)
+	if err != nil {
+		return err
+	}
+	for _, o := range outcomes {
+		for _, h := range latest {
+			if h.ID == o.HostID {
+				o.OpenPorts = h.OpenCount
+			}
+		}
+	}
+	t := &table{headers: []string{"HOST", "RESULT", "CHANGED", "OPEN", "ERROR"}, raw: outcomes}
+	for _, o := range outcomes {
+		msg := o.Error
+		if o.ErrorKind != "" {
+			msg = o.ErrorKind + ": " + msg
+		}
+		t.add(o.Host, o.Status, strconv.FormatBool(o.Changed), strconv.Itoa(o.OpenPorts), orDash(msg))
+	}
+	if err := a.render(t); err != nil {
+		return err
+	}
+	if failed {
+		return errors.New("some scans failed")
+	}
+	return nil
+}
+
+func listHosts(ctx context.Context, c *client, query url.Values) (json.RawMessage, []host, error) {
+	var raw json.RawMessage
+	if err := c.do(ctx, http.MethodGet, "/api/hosts", query, nil, &raw); err != nil {
+		return nil, nil, err
+	}
+	var hosts []host
+	if err := json.Unmarshal(raw, &hosts); err != nil {
+		return nil, nil, err
+	}
+	return raw, hosts, nil
+}
+
+// findHost 按 ID、名称或地址查找主机。
+func findHost(ctx context.Context, c *client, ref string) (*host, error) {
+	_, hosts, err := listHosts(ctx, c, nil)
+	if err != nil {
+		return nil, err
+	}
+	if id, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		for i := range hosts {
+			if hosts[i].ID == id {
+				return &hosts[i], nil
+			}
+		}
+	}
+	var matches []*host
+	for i := range hosts {
+		if strings.EqualFold(hosts[i].Name, ref) || strings.EqualFold(hosts[i].Address, ref) {
+			matches = append(matches, &hosts[i])
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("host %q not found", ref)
+	case 1:
+		return matches[0], nil
+	}
+	return nil, fmt.Errorf("host %q is ambiguous (%d matches), use the host ID", ref, len(matches))
+}
+
+// searchPorts 分页读取 /api/ports/search 的全部结果。
+func searchPorts(ctx context.Context, c *client, query url.Values) ([]json.RawMessage, []port, error) {
+	const pageSize = 200
+	var raws []json.RawMessage
+	var ports []port
+	query.Set("pageSize", strconv.Itoa(pageSize))
+	for page := 1; ; page++ {
+		query.Set("page", strconv.Itoa(page))
+		var res struct {
+			Ports      []json.RawMessage `json:"ports"`
+			Pagination struct {
+				Total int `json:"total"`
+			} `json:"pagination"`
+		}
+		if err := c.do(ctx, http.MethodGet, "/api/ports/search", query, nil, &res); err != nil {
+			return nil, nil, err
+		}
+		for _, raw := range res.Ports {
+			var p port
+			if err := json.Unmarshal(raw, &p); err != nil {
+				return nil, nil, err
+			}
+			raws = append(raws, raw)
+			ports = append(ports, p)
+		}
+		if len(res.Ports) < pageSize || len(ports) >= res.Pagination.Total {
+			break
+		}
+	}
+	if raws == nil {
+		raws = []json.RawMessage{}
+	}
+	return raws, ports, nil
+}
+
+// findPort 查找主机上的端口，包含隐藏与非开放的端口。
+func findPort(ctx context.Context, c *client, h *host, number int) (*port, error) {
+	query := url.Values{"hostId": {strconv.FormatInt(h.ID, 10)}, "number": {strconv.Itoa(number)}}
+	_, ports, err := searchPorts(ctx, c, query)
+	if err != nil {
+		return nil, err
+	}
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("port %d not found on %s", number, h.Name)
+	}
+	return &ports[0], nil
+}
+
+func parsePortNumber(raw string) (int, error) {
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 || n > 65535 {
+		return 0, fmt.Errorf("invalid port number %q", raw)
+	}
+	return n, nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// cliConfig 保存在 ~/.config/portnote/config.json，login 写入服务端地址与令牌。
+type cliConfig struct {
+	Server string `json:"server"`
+	Token  string `json:"token,omitempty"`
+	// TokenID 为 login 创建的令牌 ID，logout 时用于吊销。
+	TokenID int64 `json:"tokenId,omitempty"`
+	// Output 为默认输出格式：table、json 或 csv。
+	Output string `json:"output,omitempty"`
+}
+
+// configPath 返回配置文件路径，PORTNOTE_CLI_CONFIG 可覆盖默认位置。
+// 各平台统一使用 $XDG_CONFIG_HOME/portnote，未设置时为 ~/.config/portnote（不使用 macOS、Windows 的系统配置目录）。
+func configPath() (string, error) {
+	if p := os.Getenv("PORTNOTE_CLI_CONFIG"); p != "" {
+		return p, nil
+	}
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "portnote", "config.json"), nil
+}
+
+// loadConfig 读取配置文件，文件不存在时返回空配置。
+func loadConfig(path string) (*cliConfig, error) {
+	cfg := &cliConfig{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// saveConfig 写入配置文件，文件包含令牌，权限为 0600。
+func saveConfig(path string, cfg *cliConfig) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,197 @@
+// portnote 为 PortNoteProMax 的命令行客户端：查看主机与端口、维护端口与备注、触发扫描并等待完成。
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+type command struct {
+	usage string
+	help  string
+	run   func(a *app, ctx context.Context, args []string) error
+}
+
+var commands = map[string]command{
+	"login":   {"login [-server URL] [-user NAME] [-password-stdin]", "log in and save an API token", cmdLogin},
+	"logout":  {"logout", "revoke the saved API token", cmdLogout},
+	"hosts":   {"hosts [-labels EXPR] [-group ID]", "list hosts", cmdHosts},
+	"ports":   {"ports [HOST] [-status open|closed|filtered|unreachable|all] [-q TEXT] [-labels EXPR] [-number N] [-range A-B] [-hidden]", "list ports of one host or of all hosts", cmdPorts},
+	"port":    {"port add HOST NUMBER [-note TEXT] [-fingerprint TEXT] | port rm HOST NUMBER", "add or remove a port", cmdPort},
+	"note":    {"note HOST PORT TEXT...", "set the note of a port", cmdNote},
+	"scan":    {"scan HOST... [-wait] [-timeout 30m]", "trigger full scans, optionally waiting for them to finish", cmdScan},
+	"suggest": {"suggest HOST [-start 1024]", "suggest an unused port on a host", cmdSuggest},
+}
+
+// app 保存全局选项。优先级：命令行参数 > 环境变量（PORTNOTE_SERVER、PORTNOTE_TOKEN）> 配置文件。
+type app struct {
+	cfgPath string
+	cfg     *cliConfig
+	server  string
+	token   string
+	output  string
+	// cmdUsage 为当前子命令的用法说明。
+	cmdUsage string
+	stdout   io.Writer
+	stderr   io.Writer
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	a := &app{stdout: os.Stdout, stderr: os.Stderr}
+	path, err := configPath()
+	if err != nil {
+		fmt.Fprintf(a.stderr, "portnote: %v\n", err)
+		return 1
+	}
+	a.cfgPath = path
+	if a.cfg, err = loadConfig(path); err != nil {
+		fmt.Fprintf(a.stderr, "portnote: %v\n", err)
+		return 1
+	}
+	a.server = firstNonEmpty(os.Getenv("PORTNOTE_SERVER"), a.cfg.Server)
+	a.token = firstNonEmpty(os.Getenv("PORTNOTE_TOKEN"), a.cfg.Token)
+	a.output = firstNonEmpty(a.cfg.Output, outputTable)
+
+	fs := flag.NewFlagSet("portnote", flag.ContinueOnError)
+	a.globalFlags(fs)
+	fs.Usage = func() { a.usage(fs.Output()) }
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() == 0 {
+		a.usage(a.stderr)
+		return 2
+	}
+	name := fs.Arg(0)
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(a.stderr, "portnote: unknown command %q\n\n", name)
+		a.usage(a.stderr)
+		return 2
+	}
+
+	a.cmdUsage = cmd.usage
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := cmd.run(a, ctx, fs.Args()[1:]); err != nil {
+		var ue *usageError
+		if errors.As(err, &ue) {
+			fmt.Fprintf(a.stderr, "portnote %s: %s\nusage: portnote %s\n", name, ue.msg, cmd.usage)
+			return 2
+		}
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		if errors.Is(err, errUsageShown) {
+			return 2
+		}
+		fmt.Fprintf(a.stderr, "portnote %s: %v\n", name, err)
+		return 1
+	}
+	return 0
+}
+
+func (a *app) globalFlags(fs *flag.FlagSet) {
+	fs.StringVar(&a.server, "server", a.server, "server base URL (env PORTNOTE_SERVER)")
+	fs.StringVar(&a.token, "token", a.token, "API token (env PORTNOTE_TOKEN)")
+	fs.StringVar(&a.output, "o", a.output, "output format: table, json or csv")
+}
+
+func (a *app) usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: portnote [-server URL] [-token TOKEN] [-o table|json|csv] COMMAND [ARGS]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-8s %s\n", name, commands[name].help)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "HOST is a host ID, name or address. Configuration: %s\n", a.cfgPath)
+}
+
+// flags 创建子命令的参数集，全局参数在子命令之后同样可用。
+func (a *app) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("portnote "+name, flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	a.globalFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: portnote %s\n", a.cmdUsage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse 解析子命令参数，允许参数与位置参数交错（如 `ports web1 -status all`），`--` 之后的内容均为位置参数。
+func (a *app) parse(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional, rest []string
+	for i, arg := range args {
+		if arg == "--" {
+			args, rest = args[:i], args[i+1:]
+			break
+		}
+	}
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			// 参数集已打印错误与用法。
+			return nil, errUsageShown
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if err := validOutput(a.output); err != nil {
+		return nil, &usageError{err.Error()}
+	}
+	return append(positional, rest...), nil
+}
+
+func (a *app) client() (*client, error) {
+	return newClient(a.server, a.token)
+}
+
+func (a *app) render(t *table) error {
+	return render(a.stdout, a.output, t)
+}
+
+var errUsageShown = errors.New("invalid arguments")
+
+type usageError struct{ msg string }
+
+func (e *usageError) Error() string { return e.msg }
+
+func usagef(format string, args ...interface{}) error {
+	return &usageError{fmt.Sprintf(format, args...)}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputCSV   = "csv"
+)
+
+func validOutput(format string) error {
+	switch format {
+	case outputTable, outputJSON, outputCSV:
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q (table, json or csv)", format)
+}
+
+// table 为表格 / CSV 输出的数据，raw 为 JSON 输出时原样打印的服务端响应。
+type table struct {
+	headers []string
+	rows    [][]string
+	raw     interface{}
+}
+
+func (t *table) add(cells ...string) {
+	t.rows = append(t.rows, cells)
+}
+
+// render 按格式输出：table 为对齐的文本列，csv 带表头，json 输出服务端原始对象。
+func render(w io.Writer, format string, t *table) error {
+	switch format {
+	case outputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(t.raw)
+	case outputCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(t.headers); err != nil {
+			return err
+		}
+		for _, row := range t.rows {
+			cells := make([]string, len(row))
+			for i, cell := range row {
+				// 表格中的占位符 "-" 在 CSV 中输出为空值。
+				if cell != "-" {
+					cells[i] = cell
+				}
+			}
+			if err := cw.Write(cells); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(t.headers, "\t"))
+		for _, row := range t.rows {
+			cells := make([]string, len(row))
+			for i, cell := range row {
+				// 表格中换行与制表符会破坏对齐。
+				cells[i] = strings.NewReplacer("\n", " ", "\t", " ").Replace(cell)
+			}
+			fmt.Fprintln(tw, strings.Join(cells, "\t"))
+		}
+		return tw.Flush()
+	}
+}
+
+// formatTime 在表格中显示本地时间，零值显示为 "-"。
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/hitushen/portnotepro/internal/backup"
+	"github.com/hitushen/portnotepro/internal/store"
+)
+
+func newBackupCmd(a *app) *cobra.Command {
+	return &cobra.Command{
+		Use:   "backup [file]",
+		Short: "Write a database snapshot to file, or to the backup directory with rotation",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			cfg, err := a.loadConfig()
+			if err != nil {
+				return err
+			}
+			st, err := store.Open(cfg.DSN())
+			if err != nil {
+				return fmt.Errorf("store: %w", err)
+			}
+			defer st.Close()
+			ctx := context.Background()
+			if err := st.CheckSchema(ctx); err != nil {
+				return fmt.Errorf("backup: %w", err)
+			}
+
+			// 指定文件时写到该路径，否则写入备份目录并按保留份数清理。
+			if len(args) == 1 {
+				if err := st.Backup(ctx, args[0]); err != nil {
+					return fmt.Errorf("backup: %w", err)
+				}
+				fmt.Printf("backup written to %s\n", args[0])
+				return nil
+			}
+			manager := backup.NewManager(st, cfg.BackupDir, cfg.BackupKeep)
+			info, err := manager.Create(ctx)
+			if err != nil {
+				return fmt.Errorf("backup: %w", err)
+			}
+			fmt.Printf("backup written to %s/%s (%d bytes)\n", manager.Dir(), info.Name, info.Size)
+			return nil
+		},
+	}
+}
+
+func newRestoreCmd(a *app) *cobra.Command {
+	return &cobra.Command{
+		Use:   "restore <file>",
+		Short: "Replace the SQLite database with a backup (stop the server first)",
+		Long: "Check the backup's schema version and integrity, then replace the database file.\n" +
+			"The previous database is kept next to it. Stop the server before restoring.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			cfg, err := a.loadConfig()
+			if err != nil {
+				return err
+			}
+			if cfg.DatabaseURL != "" {
+				return fmt.Errorf("restore: %w", store.ErrBackupUnsupported)
+			}
+			ctx := context.Background()
+			version, err := store.InspectBackup(ctx, args[0])
+			if err != nil {
+				return fmt.Errorf("restore: %w", err)
+			}
+			previous, err := backup.Restore(ctx, args[0], cfg.DBPath)
+			if err != nil {
+				return fmt.Errorf("restore: %w", err)
+			}
+			fmt.Printf("restored %s (schema version %d) to %s\n", args[0], version, cfg.DBPath)
+			if previous != "" {
+				fmt.Printf("previous database kept at %s\n", previous)
+			}
+			if latest := store.LatestSchemaVersion(store.DialectSQLite); version < latest {
+				fmt.Printf("pending migrations will be applied on next start (%d -> %d)\n", version, latest)
+			}
+			return nil
+		},
+	}
+}
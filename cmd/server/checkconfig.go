@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+func newCheckConfigCmd(a *app) *cobra.Command {
+	var quiet bool
+	cmd := &cobra.Command{
+		Use:   "check-config",
+		Short: "Validate the configuration and print the effective settings",
+		Long: "Validate the configuration file, environment and --set overrides, listing every invalid setting.\n" +
+			"On success the effective value and source of each setting are printed; secrets are masked.\n" +
+			"Settings marked reload are applied by `kill -HUP` without a restart.",
+		Args: cobra.NoArgs,
+		RunE: func(*cobra.Command, []string) error {
+			cfg, err := a.loadConfig()
+			if err != nil {
+				return err
+			}
+			if quiet {
+				return nil
+			}
+			file := cfg.File
+			if file == "" {
+				file = "(none)"
+			}
+			fmt.Printf("config file: %s\n\n", file)
+			tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "KEY\tVALUE\tSOURCE\tRELOAD")
+			for _, s := range cfg.Settings() {
+				reload := ""
+				if s.Reloadable {
+					reload = "yes"
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", s.Key, s.Value, s.Source, reload)
+			}
+			return tw.Flush()
+		},
+	}
+	cmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "only report errors")
+	return cmd
+}
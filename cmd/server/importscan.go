@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/hitushen/portnotepro/internal/realtime"
+	"github.com/hitushen/portnotepro/internal/scanimport"
+	"github.com/hitushen/portnotepro/internal/scanner"
+	"github.com/hitushen/portnotepro/internal/targets"
+)
+
+func newImportScanCmd(a *app) *cobra.Command {
+	var format string
+	var noCreate bool
+	cmd := &cobra.Command{
+		Use:   "import-scan <file>",
+		Short: "Import nmap XML, masscan JSON/list or naabu JSONL output",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			data, err := os.ReadFile(args[0])
+			if err != nil {
+				return fmt.Errorf("import-scan: %w", err)
+			}
+			results, detected, err := scanimport.Parse(format, data)
+			if err != nil {
+				return fmt.Errorf("import-scan: %w", err)
+			}
+
+			cfg, st, err := a.openStore()
+			if err != nil {
+				return err
+			}
+			defer st.Close()
+			if err := targets.SetResolver(cfg.DNSResolver); err != nil {
+				return err
+			}
+			policy, err := targets.NewPolicy(cfg.ScanAllow, cfg.ScanDeny)
+			if err != nil {
+				return err
+			}
+			manager := scanner.NewManager(st, cfg.ScanTimeout, 1, realtime.NewBroker())
+			defer manager.Close()
+			manager.SetBasePolicy(policy)
+
+			run, err := manager.ImportResults(context.Background(), detected, results, scanner.ImportOptions{CreateHosts: !noCreate})
+			if err != nil {
+				return fmt.Errorf("import-scan: %w", err)
+			}
+			// 打印本次导入的变化。
+			fmt.Printf("source %s: %d results, %d skipped\n", run.Source, run.Results, run.Skipped)
+			fmt.Printf("hosts: %d matched, %d created\n", run.HostsMatched, run.HostsCreated)
+			fmt.Printf("ports: %d created, %d opened, %d closed, %d service changes\n", run.PortsCreated, run.PortsOpened, run.PortsClosed, run.ServicesChanged)
+			for _, h := range run.Hosts {
+				for _, c := range h.Changes {
+					detail := c.Service
+					if c.Previous != "" {
+						detail = c.Previous + " -> " + c.Service
+					}
+					fmt.Printf("  %s:%d %s %s\n", h.Name, c.Number, c.Change, detail)
+				}
+			}
+			for _, w := range run.Warnings {
+				fmt.Fprintf(os.Stderr, "warning: %s\n", w)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", scanimport.FormatAuto, "input format: auto, nmap, masscan, masscan-list, naabu")
+	cmd.Flags().BoolVar(&noCreate, "no-create", false, "skip results whose address matches no existing host")
+	return cmd
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/hitushen/portnotepro/internal/models"
+	"github.com/hitushen/portnotepro/internal/realtime"
+	"github.com/hitushen/portnotepro/internal/scanner"
+	"github.com/hitushen/portnotepro/internal/store"
+	"github.com/hitushen/portnotepro/internal/targets"
+)
+
+func newExportCmd(a *app) *cobra.Command {
+	return &cobra.Command{
+		Use:   "export [file]",
+		Short: "Export hosts and ports as an inventory JSON document (stdout by default)",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			_, st, err := a.openStore()
+			if err != nil {
+				return err
+			}
+			defer st.Close()
+			inv, err := st.ExportInventory(context.Background())
+			if err != nil {
+				return fmt.Errorf("export: %w", err)
+			}
+			var w io.Writer = os.Stdout
+			if len(args) == 1 {
+				f, err := os.Create(args[0])
+				if err != nil {
+					return fmt.Errorf("export: %w", err)
+				}
+				defer f.Close()
+				w = f
+			}
+			// 与接口导出相同，缩进输出便于纳入 git 后逐行比对。
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(inv); err != nil {
+				return fmt.Errorf("export: %w", err)
+			}
+			if len(args) == 1 {
+				fmt.Fprintf(os.Stderr, "exported %d hosts to %s\n", len(inv.Hosts), args[0])
+			}
+			return nil
+		},
+	}
+}
+
+func newImportCmd(a *app) *cobra.Command {
+	var mode, conflict string
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   "import <file|->",
+		Short: "Import an inventory JSON document produced by export",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			if mode != store.ImportMerge && mode != store.ImportReplace {
+				return fmt.Errorf("--mode must be merge or replace")
+			}
+			if conflict != "skip" && conflict != "overwrite" {
+				return fmt.Errorf("--conflict must be skip or overwrite")
+			}
+			var r io.Reader = os.Stdin
+			if args[0] != "-" {
+				f, err := os.Open(args[0])
+				if err != nil {
+					return fmt.Errorf("import: %w", err)
+				}
+				defer f.Close()
+				r = f
+			}
+			var inv models.Inventory
+			if err := json.NewDecoder(r).Decode(&inv); err != nil {
+				return fmt.Errorf("import: %w", err)
+			}
+
+			cfg, st, err := a.openStore()
+			if err != nil {
+				return err
+			}
+			defer st.Close()
+			// 与接口导入一样按扫描策略校验新写入的地址。
+			if err := targets.SetResolver(cfg.DNSResolver); err != nil {
+				return err
+			}
+			policy, err := targets.NewPolicy(cfg.ScanAllow, cfg.ScanDeny)
+			if err != nil {
+				return err
+			}
+			manager := scanner.NewManager(st, cfg.ScanTimeout, 1, realtime.NewBroker())
+			defer manager.Close()
+			manager.SetBasePolicy(policy)
+
+			report, err := st.ImportInventory(context.Background(), &inv, store.ImportOptions{
+				Mode:      mode,
+				Overwrite: conflict == "overwrite",
+				DryRun:    dryRun,
+				CheckTarget: func(ctx context.Context, address string) error {
+					_, err := manager.CheckTarget(ctx, 0, address)
+					return err
+				},
+			})
+			if err != nil {
+				return fmt.Errorf("import: %w", err)
+			}
+			if report.DryRun {
+				fmt.Println("dry run, nothing was changed")
+			}
+			fmt.Printf("mode %s: hosts %d created, %d updated, %d deleted, %d skipped; ports %d created, %d updated\n",
+				report.Mode, report.HostsCreated, report.HostsUpdated, report.HostsDeleted, report.HostsSkipped,
+				report.PortsCreated, report.PortsUpdated)
+			for _, c := range report.Conflicts {
+				fmt.Printf("  conflict %s %v: %s\n", c.Host, c.Fields, c.Resolution)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&mode, "mode", store.ImportMerge, "merge into existing hosts, or replace all hosts")
+	cmd.Flags().StringVar(&conflict, "conflict", "skip", "for merge: skip or overwrite hosts that differ")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "preview the changes without writing them")
+	return cmd
+}
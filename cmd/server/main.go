@@ -2,39 +2,155 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/spf13/cobra"
+
 	"github.com/hitushen/portnotepro/internal/config"
 	"github.com/hitushen/portnotepro/internal/server"
 	"github.com/hitushen/portnotepro/internal/store"
 )
 
+// app 保存全局参数：配置文件路径与命令行覆盖的配置项。
+type app struct {
+	configPath string
+	sets       []string
+	dbPath     string
+	dbURL      string
+	addr       string
+}
+
 func main() {
-	cfg, err := config.Load()
+	if err := newRootCmd().Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "server: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func newRootCmd() *cobra.Command {
+	a := &app{}
+	root := &cobra.Command{
+		Use:   "server",
+		Short: "PortNoteProMax server and administration commands",
+		Long: "PortNoteProMax server. Without a subcommand it runs `serve`.\n\n" +
+			"Configuration is read from --config (or PORTNOTE_CONFIG, default ./config.yaml if present),\n" +
+			"then overridden by PORTNOTE_* environment variables and finally by --set key=value.",
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return a.serve(cmd.Context())
+		},
+	}
+	root.CompletionOptions.DisableDefaultCmd = true
+	flags := root.PersistentFlags()
+	flags.StringVarP(&a.configPath, "config", "c", "", "config file (.yaml, .yml or .toml)")
+	flags.StringArrayVar(&a.sets, "set", nil, "override a setting, e.g. --set scan.concurrency=20 (repeatable)")
+	flags.StringVar(&a.dbPath, "db", "", "SQLite database path (database.path)")
+	flags.StringVar(&a.dbURL, "database-url", "", "PostgreSQL connection URL (database.url)")
+	root.Flags().StringVar(&a.addr, "addr", "", "HTTP listen address (http.addr)")
+
+	serve := &cobra.Command{
+		Use:   "serve",
+		Short: "Run the HTTP server (SIGHUP reloads the configuration)",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return a.serve(cmd.Context())
+		},
+	}
+	serve.Flags().StringVar(&a.addr, "addr", "", "HTTP listen address (http.addr)")
+
+	root.AddCommand(
+		serve,
+		newCheckConfigCmd(a),
+		newMigrateCmd(a),
+		newUserCmd(a),
+		newBackupCmd(a),
+		newRestoreCmd(a),
+		newExportCmd(a),
+		newImportCmd(a),
+		newImportScanCmd(a),
+		newScanCmd(a),
+	)
+	return root
+}
+
+// options 把 --set 与专用参数转换为配置覆盖项。
+func (a *app) options() (config.Options, error) {
+	opts := config.Options{Path: a.configPath, Overrides: map[string]string{}}
+	for _, set := range a.sets {
+		key, value, ok := strings.Cut(set, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return opts, fmt.Errorf("--set %q: expected key=value", set)
+		}
+		opts.Overrides[strings.TrimSpace(key)] = value
+	}
+	for key, value := range map[string]string{"database.path": a.dbPath, "database.url": a.dbURL, "http.addr": a.addr} {
+		if value != "" {
+			opts.Overrides[key] = value
+		}
+	}
+	return opts, nil
+}
+
+// loadConfig 读取并校验配置。
+func (a *app) loadConfig() (*config.Config, error) {
+	opts, err := a.options()
 	if err != nil {
-		log.Fatalf("config: %v", err)
+		return nil, err
 	}
+	return config.LoadWith(opts)
+}
 
-	st, err := store.New(cfg.DBPath)
+// openStore 打开数据库并执行待处理的迁移。
+func (a *app) openStore() (*config.Config, *store.Store, error) {
+	cfg, err := a.loadConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	st, err := store.New(cfg.DSN())
 	if err != nil {
-		log.Fatalf("store: %v", err)
+		return nil, nil, fmt.Errorf("store: %w", err)
+	}
+	return cfg, st, nil
+}
+
+func (a *app) serve(ctx context.Context) error {
+	cfg, err := a.loadConfig()
+	if err != nil {
+		return err
+	}
+	if cfg.File != "" {
+		log.Printf("loaded configuration from %s", cfg.File)
+	}
+
+	st, err := store.New(cfg.DSN())
+	if err != nil {
+		return fmt.Errorf("store: %w", err)
 	}
 	defer st.Close()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	initCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
-	if err := st.EnsureAdmin(ctx, cfg.AdminUser, cfg.AdminPassword); err != nil {
-		log.Fatalf("ensure admin: %v", err)
+	created, err := st.EnsureAdmin(initCtx, cfg.AdminUser, cfg.AdminPassword)
+	if err != nil {
+		return fmt.Errorf("ensure admin: %w", err)
+	}
+	if created {
+		log.Printf("created initial admin user %q", cfg.AdminUser)
 	}
 
 	srv, err := server.New(cfg, st)
 	if err != nil {
-		log.Fatalf("server init: %v", err)
+		return fmt.Errorf("server init: %w", err)
 	}
 	defer srv.Close()
 
@@ -42,18 +158,31 @@ func main() {
 		Addr:    cfg.Addr,
 		Handler: srv.Handler(),
 	}
-
+	errCh := make(chan error, 1)
 	go func() {
 		log.Printf("PortNoteProMax listening on %s", cfg.Addr)
-		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("http server: %v", err)
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- err
 		}
 	}()
 
-	// 优雅地关闭服务
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
-	<-stop
+	// SIGHUP 重新加载配置，SIGINT / SIGTERM 优雅地关闭服务。
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(signals)
+	current := cfg
+wait:
+	for {
+		select {
+		case err := <-errCh:
+			return fmt.Errorf("http server: %w", err)
+		case sig := <-signals:
+			if sig != syscall.SIGHUP {
+				break wait
+			}
+			current = a.reload(srv, current)
+		}
+	}
 	log.Println("shutting down...")
 
 	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 10*time.Second)
@@ -61,4 +190,29 @@ func main() {
 	if err := httpServer.Shutdown(shutdownCtx); err != nil {
 		log.Printf("shutdown error: %v", err)
 	}
+	return nil
+}
+
+// reload 重新读取配置并应用可热加载的配置项，返回新的生效配置。配置有误时保留当前配置。
+func (a *app) reload(srv *server.Server, current *config.Config) *config.Config {
+	next, err := a.loadConfig()
+	if err != nil {
+		log.Printf("[config] reload failed, keeping the current configuration: %v", err)
+		return current
+	}
+	reloadable, restart := config.Changes(current, next)
+	if len(restart) > 0 {
+		log.Printf("[config] changes to %s take effect after a restart", strings.Join(restart, ", "))
+	}
+	merged := current.WithReloadable(next)
+	if err := srv.Reload(merged); err != nil {
+		log.Printf("[config] reload failed, keeping the current configuration: %v", err)
+		return current
+	}
+	if len(reloadable) > 0 {
+		log.Printf("[config] reloaded: %s", strings.Join(reloadable, ", "))
+	} else {
+		log.Printf("[config] reloaded, no changes")
+	}
+	return merged
 }
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/hitushen/portnotepro/internal/store"
+)
+
+func newMigrateCmd(a *app) *cobra.Command {
+	status := func(*cobra.Command, []string) error {
+		cfg, err := a.loadConfig()
+		if err != nil {
+			return err
+		}
+		return migrateStatus(cfg.DSN())
+	}
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Show or apply database schema migrations (default: status)",
+		Args:  cobra.NoArgs,
+		RunE:  status,
+	}
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "status",
+			Short: "List applied and pending migrations",
+			Args:  cobra.NoArgs,
+			RunE:  status,
+		},
+		&cobra.Command{
+			Use:   "up",
+			Short: "Apply pending migrations",
+			Args:  cobra.NoArgs,
+			RunE: func(*cobra.Command, []string) error {
+				cfg, err := a.loadConfig()
+				if err != nil {
+					return err
+				}
+				return migrateUp(cfg.DSN())
+			},
+		},
+	)
+	return cmd
+}
+
+// migrateUp 执行待处理的迁移并逐条打印。
+func migrateUp(dsn string) error {
+	st, err := store.Open(dsn)
+	if err != nil {
+		return fmt.Errorf("store: %w", err)
+	}
+	defer st.Close()
+	applied, err := st.Migrate(context.Background())
+	for _, m := range applied {
+		fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+	}
+	if err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	if len(applied) == 0 {
+		fmt.Println("schema is up to date")
+	}
+	return nil
+}
+
+// migrateStatus 打印当前结构版本与每个迁移的执行状态，数据库版本比程序新时返回错误。
+func migrateStatus(dsn string) error {
+	st, err := store.Open(dsn)
+	if err != nil {
+		return fmt.Errorf("store: %w", err)
+	}
+	defer st.Close()
+	ctx := context.Background()
+
+	states, err := st.MigrationStatus(ctx)
+	if err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	version, err := st.SchemaVersion(ctx)
+	if err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	fmt.Printf("database: %s (%s)\nschema version: %d (binary supports %d)\n\n", redactDSN(dsn), st.Dialect(), version, store.LatestSchemaVersion(st.Dialect()))
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, s := range states {
+		status, at := "pending", ""
+		if s.Applied {
+			status, at = "applied", s.AppliedAt.Local().Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(tw, "%04d\t%s\t%s\t%s\n", s.Version, s.Name, status, at)
+	}
+	_ = tw.Flush()
+	if err := st.CheckSchema(ctx); err != nil {
+		fmt.Println()
+		return err
+	}
+	return nil
+}
+
+// redactDSN 隐藏连接串中的密码，SQLite 路径原样返回。
+func redactDSN(dsn string) string {
+	if store.DialectForDSN(dsn) != store.DialectPostgres {
+		return dsn
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "postgres://..."
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/hitushen/portnotepro/internal/models"
+	"github.com/hitushen/portnotepro/internal/realtime"
+	"github.com/hitushen/portnotepro/internal/scanner"
+	"github.com/hitushen/portnotepro/internal/store"
+	"github.com/hitushen/portnotepro/internal/targets"
+)
+
+func newScanCmd(a *app) *cobra.Command {
+	var asJSON, hidden bool
+	cmd := &cobra.Command{
+		Use:   "scan <host>",
+		Short: "Run a full scan of one host now and print its ports",
+		Long: "Scan all 65535 ports of a host (ID, name or address) in this process, record the results like\n" +
+			"a manual scan and print the host's ports. Queue, retries and agents are bypassed and no alerts\n" +
+			"are delivered. Fails if the host is already being scanned or is assigned to an agent.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, st, err := a.openStore()
+			if err != nil {
+				return err
+			}
+			defer st.Close()
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			host, err := findHost(ctx, st, args[0])
+			if err != nil {
+				return err
+			}
+			if host.Zone != "" || host.AgentID != nil {
+				return fmt.Errorf("host %s is scanned by a remote agent, trigger the scan from the web UI or API", host.Name)
+			}
+			if err := targets.SetResolver(cfg.DNSResolver); err != nil {
+				return err
+			}
+			policy, err := targets.NewPolicy(cfg.ScanAllow, cfg.ScanDeny)
+			if err != nil {
+				return err
+			}
+			manager := scanner.NewManager(st, cfg.ScanTimeout, 1, realtime.NewBroker())
+			defer manager.Close()
+			manager.SetBasePolicy(policy)
+			manager.SetRateBudget(cfg.ScanRate)
+			manager.SetMissThreshold(cfg.ScanMissThreshold)
+
+			fmt.Fprintf(os.Stderr, "scanning %s (%s)...\n", host.Name, host.Address)
+			start := time.Now()
+			result, changed, err := manager.ScanNow(ctx, host.ID)
+			if err != nil {
+				return fmt.Errorf("scan %s: %w", host.Name, err)
+			}
+			ports, err := st.ListPorts(ctx, host.ID, hidden)
+			if err != nil {
+				return err
+			}
+			if asJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(map[string]interface{}{"host": host, "result": result, "changed": changed, "ports": ports})
+			}
+
+			summary := fmt.Sprintf("%s: %d open ports in %s", host.Name, len(result.Open), time.Since(start).Truncate(time.Second))
+			if len(result.Addresses) > 0 {
+				summary += ", resolved to " + strings.Join(result.Addresses, ",")
+			}
+			if !changed {
+				summary += ", no changes"
+			}
+			fmt.Printf("%s\n\n", summary)
+			tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "PORT\tSTATUS\tSERVICE\tNOTE")
+			for _, p := range ports {
+				fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", p.Number, p.Status, p.Fingerprint, strings.ReplaceAll(p.Note, "\n", " "))
+			}
+			return tw.Flush()
+		},
+	}
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the scan result and ports as JSON")
+	cmd.Flags().BoolVar(&hidden, "hidden", false, "include hidden ports")
+	return cmd
+}
+
+// findHost 按 ID、名称或地址查找主机。
+func findHost(ctx context.Context, st *store.Store, ref string) (*models.Host, error) {
+	if id, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		host, err := st.GetHost(ctx, id)
+		if err == nil {
+			return host, nil
+		}
+	}
+	hosts, err := st.ListHosts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var matches []models.Host
+	for _, h := range hosts {
+		if strings.EqualFold(h.Name, ref) || strings.EqualFold(h.Address, ref) {
+			matches = append(matches, h)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no host matches %q", ref)
+	case 1:
+		return &matches[0], nil
+	}
+	return nil, errors.New("several hosts match " + strconv.Quote(ref) + ", use the host ID")
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/hitushen/portnotepro/internal/store"
+)
+
+// minPasswordLength 为通过命令行设置密码时的最短长度。
+const minPasswordLength = 8
+
+func newUserCmd(a *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "user",
+		Short: "Manage login accounts",
+	}
+	var passwordStdin bool
+	add := &cobra.Command{
+		Use:   "add <username>",
+		Short: "Create a user (password prompted, or read from stdin with --password-stdin)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			username := strings.TrimSpace(args[0])
+			if username == "" {
+				return errors.New("username must not be empty")
+			}
+			password, err := readNewPassword(username, passwordStdin)
+			if err != nil {
+				return err
+			}
+			return a.withStore(func(ctx context.Context, st *store.Store) error {
+				if _, err := st.CreateUser(ctx, username, password); err != nil {
+					return fmt.Errorf("user add: %w", err)
+				}
+				fmt.Printf("user %s created\n", username)
+				return nil
+			})
+		},
+	}
+	add.Flags().BoolVar(&passwordStdin, "password-stdin", false, "read the password from standard input")
+
+	passwd := &cobra.Command{
+		Use:   "passwd <username>",
+		Short: "Set a user's password",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			password, err := readNewPassword(args[0], passwordStdin)
+			if err != nil {
+				return err
+			}
+			return a.withStore(func(ctx context.Context, st *store.Store) error {
+				if err := st.SetUserPassword(ctx, args[0], password); err != nil {
+					return userErr("user passwd", args[0], err)
+				}
+				fmt.Printf("password of %s updated\n", args[0])
+				return nil
+			})
+		},
+	}
+	passwd.Flags().BoolVar(&passwordStdin, "password-stdin", false, "read the password from standard input")
+
+	setDisabled := func(disabled bool) func(*cobra.Command, []string) error {
+		return func(cmd *cobra.Command, args []string) error {
+			return a.withStore(func(ctx context.Context, st *store.Store) error {
+				if err := st.SetUserDisabled(ctx, args[0], disabled); err != nil {
+					return userErr("user "+cmd.Name(), args[0], err)
+				}
+				fmt.Printf("user %s %sd\n", args[0], cmd.Name())
+				return nil
+			})
+		}
+	}
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List users",
+		Args:  cobra.NoArgs,
+		RunE: func(*cobra.Command, []string) error {
+			return a.withStore(func(ctx context.Context, st *store.Store) error {
+				users, err := st.ListUsers(ctx)
+				if err != nil {
+					return err
+				}
+				tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+				fmt.Fprintln(tw, "ID\tUSERNAME\tSTATUS\tCREATED")
+				for _, u := range users {
+					status := "active"
+					if u.Disabled {
+						status = "disabled"
+					}
+					fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", u.ID, u.Username, status, u.CreatedAt.Local().Format("2006-01-02 15:04"))
+				}
+				return tw.Flush()
+			})
+		},
+	}
+
+	cmd.AddCommand(add, passwd,
+		&cobra.Command{
+			Use:   "disable <username>",
+			Short: "Disable a user; their sessions and API tokens stop working immediately",
+			Args:  cobra.ExactArgs(1),
+			RunE:  setDisabled(true),
+		},
+		&cobra.Command{
+			Use:   "enable <username>",
+			Short: "Re-enable a disabled user",
+			Args:  cobra.ExactArgs(1),
+			RunE:  setDisabled(false),
+		},
+		list,
+	)
+	return cmd
+}
+
+// withStore 打开数据库执行 fn，结束后关闭。
+func (a *app) withStore(fn func(ctx context.Context, st *store.Store) error) error {
+	_, st, err := a.openStore()
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	return fn(context.Background(), st)
+}
+
+func userErr(op, username string, err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%s: no user named %q", op, username)
+	}
+	return fmt.Errorf("%s: %w", op, err)
+}
+
+// readNewPassword 在终端中提示输入两次新密码；--password-stdin 或标准输入不是终端时读取第一行。
+func readNewPassword(username string, fromStdin bool) (string, error) {
+	var password string
+	if !fromStdin && term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprintf(os.Stderr, "New password for %s: ", username)
+		first, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprint(os.Stderr, "Repeat password: ")
+		second, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		if string(first) != string(second) {
+			return "", errors.New("passwords do not match")
+		}
+		password = string(first)
+	} else {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", errors.New("no password on standard input")
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+	if len(password) < minPasswordLength {
+		return "", fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+	return password, nil
+}
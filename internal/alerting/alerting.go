@@ -0,0 +1,568 @@
+// Package alerting 将扫描事件按规则归类为告警，并可靠地投递到通知目标（webhook、邮件），另按用户偏好发送邮件摘要。
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hitushen/portnotepro/internal/models"
+	"github.com/hitushen/portnotepro/internal/realtime"
+	"github.com/hitushen/portnotepro/internal/store"
+)
+
+const (
+	// pollInterval 为投递循环检查到期记录的间隔，新告警入队时会立即唤醒。
+	pollInterval = 2 * time.Second
+	// dispatchBatch 为每轮最多取出的到期记录数，sendConcurrency 为并发发送数。
+	dispatchBatch   = 50
+	sendConcurrency = 4
+	// maxBackoff 为重试间隔上限，historyRetention 为已结束投递记录与事件历史的保留时长。
+	maxBackoff       = time.Hour
+	historyRetention = 30 * 24 * time.Hour
+)
+
+// Options 控制投递的重试与超时，SMTP 为邮件目标与摘要使用的发信配置。
+type Options struct {
+	MaxAttempts int
+	Timeout     time.Duration
+	Backoff     time.Duration
+	SMTP        SMTPConfig
+}
+
+// Engine 观察实时事件、匹配告警规则并写入投递队列，后台循环负责发送与重试。
+// 投递记录持久化在数据库中，进程重启后未完成的投递会继续。
+type Engine struct {
+	store     store.Storage
+	optsMu    sync.RWMutex
+	opts      Options
+	mailer    *mailer
+	notifiers map[string]notifier
+
+	mu     sync.Mutex
+	events []realtime.Event
+
+	eventCh      chan struct{}
+	wakeCh       chan struct{}
+	stopCh       chan struct{}
+	wg           sync.WaitGroup
+	shutdownOnce sync.Once
+}
+
+// NewEngine 创建告警引擎，需调用 Start 后才开始工作。
+func NewEngine(st store.Storage, opts Options) *Engine {
+	client := &http.Client{Timeout: opts.Timeout}
+	m := &mailer{cfg: opts.SMTP, timeout: opts.Timeout}
+	chat := &chatNotifier{client: client}
+	return &Engine{
+		store:  st,
+		opts:   opts,
+		mailer: m,
+		notifiers: map[string]notifier{
+			models.TargetWebhook:  &webhookSender{client: client},
+			models.TargetEmail:    m,
+			models.TargetSlack:    chat,
+			models.TargetDiscord:  chat,
+			models.TargetTeams:    chat,
+			models.TargetTelegram: chat,
+		},
+		eventCh: make(chan struct{}, 1),
+		wakeCh:  make(chan struct{}, 1),
+		stopCh:  make(chan struct{}),
+	}
+}
+
+// Start 注册事件观察者并启动规则匹配与投递循环。
+func (e *Engine) Start(broker *realtime.Broker) {
+	if err := e.store.ResetSendingAlertDeliveries(context.Background()); err != nil {
+		log.Printf("[alerting] reset in-flight deliveries failed: %v", err)
+	}
+	broker.Observe(e.observe)
+	e.wg.Add(2)
+	go e.eventLoop()
+	go e.dispatchLoop()
+}
+
+// Close 停止后台循环，正在发送的请求会等待完成。
+func (e *Engine) Close() {
+	e.shutdownOnce.Do(func() {
+		close(e.stopCh)
+	})
+	e.wg.Wait()
+}
+
+// SetOptions 在运行中更新重试策略与 SMTP 配置；Timeout 只在创建时生效。
+func (e *Engine) SetOptions(opts Options) {
+	e.optsMu.Lock()
+	e.opts.MaxAttempts, e.opts.Backoff, e.opts.SMTP = opts.MaxAttempts, opts.Backoff, opts.SMTP
+	e.optsMu.Unlock()
+	e.mailer.setConfig(opts.SMTP)
+}
+
+func (e *Engine) options() Options {
+	e.optsMu.RLock()
+	defer e.optsMu.RUnlock()
+	return e.opts
+}
+
+// EmailEnabled 报告是否配置了 SMTP。
+func (e *Engine) EmailEnabled() bool {
+	return e.mailer.enabled()
+}
+
+// Wake 让投递循环立即检查到期记录，如手动重试后。
+func (e *Engine) Wake() {
+	signal(e.wakeCh)
+}
+
+// SendTest 向目标发送一条测试告警（不重试），返回写入投递日志的记录。
+func (e *Engine) SendTest(ctx context.Context, target *models.AlertTarget) (*models.AlertDelivery, error) {
+	payload, err := json.Marshal(models.Alert{
+		Event:      models.AlertTest,
+		Severity:   models.SeverityInfo,
+		OccurredAt: time.Now().UTC(),
+		Details:    map[string]interface{}{"message": "PortNote test alert for target " + target.Name},
+	})
+	if err != nil {
+		return nil, err
+	}
+	d := &models.AlertDelivery{
+		TargetID: target.ID,
+		Event:    models.AlertTest,
+		DedupKey: "test:" + strconv.FormatInt(target.ID, 10),
+		Payload:  payload,
+	}
+	if d.ID, err = e.store.EnqueueAlertDelivery(ctx, d); err != nil {
+		return nil, err
+	}
+	if ok, err := e.store.ClaimAlertDelivery(ctx, d.ID); err != nil || !ok {
+		// 已被投递循环取走，结果以投递日志为准。
+		return e.store.GetAlertDelivery(ctx, d.ID)
+	}
+	e.attempt(ctx, d, target, false)
+	return e.store.GetAlertDelivery(ctx, d.ID)
+}
+
+// observe 在 Publish 时同步调用，只做入队，规则匹配在 eventLoop 中进行。
+func (e *Engine) observe(evt realtime.Event) {
+	if _, ok := classify(evt); !ok {
+		return
+	}
+	e.mu.Lock()
+	e.events = append(e.events, evt)
+	e.mu.Unlock()
+	signal(e.eventCh)
+}
+
+func (e *Engine) eventLoop() {
+	defer e.wg.Done()
+	for {
+		select {
+		case <-e.stopCh:
+			return
+		case <-e.eventCh:
+		}
+		e.mu.Lock()
+		batch := e.events
+		e.events = nil
+		e.mu.Unlock()
+		queued := false
+		for _, evt := range batch {
+			n, err := e.process(context.Background(), evt)
+			if err != nil {
+				log.Printf("[alerting] process event %s host=%d failed: %v", evt.Type, evt.HostID, err)
+			}
+			queued = queued || n > 0
+		}
+		if queued {
+			e.Wake()
+		}
+	}
+}
+
+// classify 将实时事件归类为告警类型，不产生告警的事件返回 false。
+func classify(evt realtime.Event) (string, bool) {
+	switch evt.Type {
+	case "port_created":
+		return models.AlertPortCreated, true
+	case "port_status":
+		status, previous := payloadString(evt, "status"), payloadString(evt, "previous")
+		// previous 为空表示新建端口，已由 port_created 覆盖。
+		if status == models.PortStatusOpen && previous != "" && previous != models.PortStatusOpen {
+			return models.AlertPortOpened, true
+		}
+		// filtered 与 unreachable 同样视为端口不再开放。
+		if previous == models.PortStatusOpen && status != models.PortStatusOpen && status != models.PortStatusUnknown {
+			return models.AlertPortClosed, true
+		}
+	case "host_scanned":
+		// 仍会重试的临时性失败不告警，重试用尽后再告警。
+		retrying, _ := payloadValue(evt, "retrying").(bool)
+		if success, ok := payloadValue(evt, "success").(bool); ok && !success && !retrying {
+			return models.AlertScanFailed, true
+		}
+	case "compliance_changed":
+		switch payloadString(evt, "status") {
+		case models.ComplianceUnexpectedOpen, models.ComplianceExpectedMissing:
+			return models.AlertDrift, true
+		}
+	}
+	return "", false
+}
+
+// process 记录事件历史，再为事件匹配全部启用的规则，冷却期外的写入投递队列，返回入队数量。
+func (e *Engine) process(ctx context.Context, evt realtime.Event) (int, error) {
+	kind, ok := classify(evt)
+	if !ok {
+		return 0, nil
+	}
+	host, err := e.store.GetHost(ctx, evt.HostID)
+	if err != nil {
+		// 主机已被删除，事件不再有意义。
+		return 0, nil
+	}
+	alert := models.Alert{
+		Event:      kind,
+		OccurredAt: time.Now().UTC(),
+		Host:       &models.AlertHost{ID: host.ID, Name: host.Name, Address: host.Address},
+	}
+	var ports []int
+	if evt.PortID > 0 {
+		port, err := e.store.GetPort(ctx, evt.PortID)
+		if err != nil {
+			return 0, fmt.Errorf("load port %d: %w", evt.PortID, err)
+		}
+		alert.Port = &models.AlertPort{
+			ID:       port.ID,
+			Number:   port.Number,
+			Status:   port.Status,
+			Previous: payloadString(evt, "previous"),
+			Service:  port.DetectedService,
+			Note:     port.Note,
+		}
+		ports = []int{port.Number}
+	}
+	record := &models.AlertEvent{Event: kind, HostID: host.ID, OccurredAt: alert.OccurredAt}
+	if alert.Port != nil {
+		record.Port = alert.Port.Number
+	}
+	switch kind {
+	case models.AlertScanFailed:
+		record.Detail = payloadString(evt, "error")
+		alert.Details = map[string]interface{}{"error": record.Detail}
+	case models.AlertDrift:
+		unexpected, _ := payloadValue(evt, "unexpected").([]int)
+		missing, _ := payloadValue(evt, "missing").([]int)
+		record.Detail = payloadString(evt, "status")
+		alert.Details = map[string]interface{}{
+			"status":     payloadString(evt, "status"),
+			"previous":   payloadString(evt, "previous"),
+			"source":     payloadString(evt, "source"),
+			"unexpected": unexpected,
+			"missing":    missing,
+		}
+		ports = append(append(ports, unexpected...), missing...)
+	}
+	if err := e.store.RecordAlertEvent(ctx, record); err != nil {
+		log.Printf("[alerting] record event %s host=%d failed: %v", kind, host.ID, err)
+	}
+
+	rules, err := e.store.ActiveAlertRules(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var candidates []models.AlertRule
+	for _, rule := range rules {
+		if containsString(rule.Events, kind) {
+			candidates = append(candidates, rule)
+		}
+	}
+
+	dedupKey := kind + ":" + strconv.FormatInt(evt.HostID, 10)
+	if evt.PortID > 0 {
+		dedupKey += ":" + strconv.FormatInt(evt.PortID, 10)
+	}
+
+	var targets map[int64]*models.AlertTarget
+	if len(candidates) > 0 {
+		list, err := e.store.ListAlertTargets(ctx)
+		if err != nil {
+			return 0, err
+		}
+		targets = make(map[int64]*models.AlertTarget, len(list))
+		for i := range list {
+			targets[list[i].ID] = &list[i]
+		}
+	}
+
+	queued := 0
+	groupHosts := make(map[int64]map[int64]bool)
+	baseSeverity := defaultSeverity(kind, payloadString(evt, "status"))
+	for _, rule := range candidates {
+		matched, err := e.matches(ctx, rule, kind, evt.HostID, ports, groupHosts)
+		if err != nil {
+			log.Printf("[alerting] rule %d match failed: %v", rule.ID, err)
+			continue
+		}
+		if !matched {
+			continue
+		}
+		alert.Severity = baseSeverity
+		if rule.Severity != "" {
+			alert.Severity = rule.Severity
+		}
+		if target, ok := targets[rule.TargetID]; ok {
+			routed, err := e.routes(ctx, target, alert.Severity, evt.HostID, groupHosts)
+			if err != nil {
+				log.Printf("[alerting] target %d routing failed: %v", target.ID, err)
+				continue
+			}
+			if !routed {
+				continue
+			}
+		}
+		if rule.Cooldown > 0 {
+			last, ok, err := e.store.LastAlertDeliveryAt(ctx, rule.ID, dedupKey)
+			if err != nil {
+				log.Printf("[alerting] rule %d cooldown lookup failed: %v", rule.ID, err)
+				continue
+			}
+			if ok && time.Since(last) < time.Duration(rule.Cooldown)*time.Second {
+				continue
+			}
+		}
+		alert.Rule = &models.AlertRuleRef{ID: rule.ID, Name: rule.Name}
+		payload, err := json.Marshal(alert)
+		if err != nil {
+			return queued, err
+		}
+		if _, err := e.store.EnqueueAlertDelivery(ctx, &models.AlertDelivery{
+			RuleID:   rule.ID,
+			TargetID: rule.TargetID,
+			Event:    kind,
+			DedupKey: dedupKey,
+			Payload:  payload,
+		}); err != nil {
+			log.Printf("[alerting] enqueue delivery rule=%d failed: %v", rule.ID, err)
+			continue
+		}
+		queued++
+	}
+	return queued, nil
+}
+
+// routes 判断目标的渠道路由：告警级别不低于目标的最低级别，且目标限定分组时主机属于该分组（含子分组）。
+func (e *Engine) routes(ctx context.Context, target *models.AlertTarget, severity string, hostID int64, groupHosts map[int64]map[int64]bool) (bool, error) {
+	if SeverityRank(severity) < SeverityRank(target.MinSeverity) {
+		return false, nil
+	}
+	if target.GroupID == 0 {
+		return true, nil
+	}
+	return e.inGroup(ctx, target.GroupID, hostID, groupHosts)
+}
+
+// matches 判断规则的主机、分组与端口过滤条件。扫描失败不涉及端口，不受端口条件限制。
+func (e *Engine) matches(ctx context.Context, rule models.AlertRule, kind string, hostID int64, ports []int, groupHosts map[int64]map[int64]bool) (bool, error) {
+	if rule.HostID > 0 && rule.HostID != hostID {
+		return false, nil
+	}
+	if rule.GroupID > 0 {
+		member, err := e.inGroup(ctx, rule.GroupID, hostID, groupHosts)
+		if err != nil || !member {
+			return false, err
+		}
+	}
+	if rule.Ports == "" || kind == models.AlertScanFailed {
+		return true, nil
+	}
+	allowed, err := store.ParsePorts(rule.Ports)
+	if err != nil {
+		return false, err
+	}
+	for _, want := range allowed {
+		for _, p := range ports {
+			if p == want {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// inGroup 判断主机是否属于分组（含子分组），成员列表在一次事件处理内缓存。
+func (e *Engine) inGroup(ctx context.Context, groupID, hostID int64, groupHosts map[int64]map[int64]bool) (bool, error) {
+	members, ok := groupHosts[groupID]
+	if !ok {
+		ids, err := e.store.GroupHostIDs(ctx, groupID, true)
+		if err != nil {
+			return false, err
+		}
+		members = make(map[int64]bool, len(ids))
+		for _, id := range ids {
+			members[id] = true
+		}
+		groupHosts[groupID] = members
+	}
+	return members[hostID], nil
+}
+
+func (e *Engine) dispatchLoop() {
+	defer e.wg.Done()
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	hourly := time.NewTicker(time.Hour)
+	defer hourly.Stop()
+	e.dispatch()
+	e.sendDueDigests(context.Background())
+	for {
+		select {
+		case <-e.stopCh:
+			return
+		case <-hourly.C:
+			e.prune(context.Background())
+			e.sendDueDigests(context.Background())
+		case <-ticker.C:
+			e.dispatch()
+		case <-e.wakeCh:
+			e.dispatch()
+		}
+	}
+}
+
+// prune 清理过期的投递记录与事件历史。
+func (e *Engine) prune(ctx context.Context) {
+	before := time.Now().Add(-historyRetention)
+	if n, err := e.store.PruneAlertDeliveries(ctx, before); err != nil {
+		log.Printf("[alerting] prune deliveries failed: %v", err)
+	} else if n > 0 {
+		log.Printf("[alerting] pruned %d old deliveries", n)
+	}
+	if _, err := e.store.PruneAlertEvents(ctx, before); err != nil {
+		log.Printf("[alerting] prune events failed: %v", err)
+	}
+}
+
+// dispatch 发送一批到期的投递，批次取满时再次唤醒以继续处理。
+func (e *Engine) dispatch() {
+	ctx := context.Background()
+	due, err := e.store.DueAlertDeliveries(ctx, time.Now(), dispatchBatch)
+	if err != nil {
+		log.Printf("[alerting] load due deliveries failed: %v", err)
+		return
+	}
+	sem := make(chan struct{}, sendConcurrency)
+	var wg sync.WaitGroup
+	for i := range due {
+		d := &due[i]
+		ok, err := e.store.ClaimAlertDelivery(ctx, d.ID)
+		if err != nil || !ok {
+			continue
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			target, err := e.store.GetAlertTarget(ctx, d.TargetID)
+			if err != nil {
+				d.Attempts++
+				d.Status = models.DeliveryFailed
+				d.LastError = "target not found"
+				if err := e.store.FinishAlertDelivery(ctx, d); err != nil {
+					log.Printf("[alerting] update delivery %d failed: %v", d.ID, err)
+				}
+				return
+			}
+			e.attempt(ctx, d, target, true)
+		}()
+	}
+	wg.Wait()
+	if len(due) == dispatchBatch {
+		e.Wake()
+	}
+}
+
+// attempt 按目标类型发送一次并记录结果。retry 为 false 时失败即终止。
+func (e *Engine) attempt(ctx context.Context, d *models.AlertDelivery, target *models.AlertTarget, retry bool) {
+	now := time.Now().UTC()
+	d.Attempts++
+	d.ResponseCode = 0
+	var err error
+	if n, ok := e.notifiers[target.Kind]; !ok {
+		err = permanentError{fmt.Errorf("unsupported target kind %q", target.Kind)}
+	} else if !target.Enabled {
+		err = permanentError{errors.New("target disabled")}
+	} else {
+		d.ResponseCode, err = n.notify(ctx, target, d, now)
+	}
+	var perm permanentError
+	switch {
+	case err == nil:
+		d.Status = models.DeliveryDelivered
+		d.LastError = ""
+		d.DeliveredAt = &now
+	case !retry || errors.As(err, &perm) || d.Attempts >= e.options().MaxAttempts:
+		d.Status = models.DeliveryFailed
+		d.LastError = err.Error()
+	default:
+		d.Status = models.DeliveryPending
+		d.LastError = err.Error()
+		d.NextAttemptAt = now.Add(e.backoff(d.Attempts))
+	}
+	if d.Status != models.DeliveryDelivered {
+		log.Printf("[alerting] delivery %d to %s attempt %d: %s (%s)", d.ID, target.Name, d.Attempts, d.Status, d.LastError)
+	}
+	if err := e.store.FinishAlertDelivery(ctx, d); err != nil {
+		log.Printf("[alerting] update delivery %d failed: %v", d.ID, err)
+	}
+}
+
+// backoff 返回第 attempts 次失败后的重试间隔：Backoff·2^(attempts-1)，最长 1 小时。
+func (e *Engine) backoff(attempts int) time.Duration {
+	wait := e.options().Backoff
+	for i := 1; i < attempts && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > maxBackoff {
+		wait = maxBackoff
+	}
+	return wait
+}
+
+func payloadValue(evt realtime.Event, key string) interface{} {
+	payload, ok := evt.Payload.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	return payload[key]
+}
+
+func payloadString(evt realtime.Event, key string) string {
+	s, _ := payloadValue(evt, key).(string)
+	return s
+}
+
+func containsString(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,160 @@
+package alerting
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/hitushen/portnotepro/internal/models"
+)
+
+// DigestPeriod 返回摘要频率对应的统计周期，off 或未知取值返回 0。
+func DigestPeriod(digest string) time.Duration {
+	switch digest {
+	case models.DigestDaily:
+		return 24 * time.Hour
+	case models.DigestWeekly:
+		return 7 * 24 * time.Hour
+	}
+	return 0
+}
+
+// BuildDigest 汇总 [since, until) 内的事件，groupID 非零时只包含该分组（含子分组）的主机。
+func (e *Engine) BuildDigest(ctx context.Context, period string, groupID int64, since, until time.Time) (*models.Digest, error) {
+	events, err := e.store.AlertEventsBetween(ctx, since, until)
+	if err != nil {
+		return nil, err
+	}
+	var members map[int64]bool
+	if groupID > 0 {
+		ids, err := e.store.GroupHostIDs(ctx, groupID, true)
+		if err != nil {
+			return nil, err
+		}
+		members = make(map[int64]bool, len(ids))
+		for _, id := range ids {
+			members[id] = true
+		}
+	}
+
+	digest := &models.Digest{Period: period, Since: since.UTC(), Until: until.UTC(), Hosts: []models.DigestHost{}}
+	hosts := make(map[int64]*models.DigestHost)
+	var order []int64
+	for _, ev := range events {
+		if members != nil && !members[ev.HostID] {
+			continue
+		}
+		h, ok := hosts[ev.HostID]
+		if !ok {
+			h = &models.DigestHost{HostID: ev.HostID, Name: ev.HostName, Address: ev.HostAddress, Opened: []int{}, Closed: []int{}}
+			hosts[ev.HostID] = h
+			order = append(order, ev.HostID)
+		}
+		switch ev.Event {
+		case models.AlertPortCreated, models.AlertPortOpened:
+			h.Opened = appendUnique(h.Opened, ev.Port)
+		case models.AlertPortClosed:
+			h.Closed = appendUnique(h.Closed, ev.Port)
+		case models.AlertScanFailed:
+			h.ScanFailures++
+			h.LastError = ev.Detail
+		}
+	}
+	for _, id := range order {
+		h := hosts[id]
+		if len(h.Opened) == 0 && len(h.Closed) == 0 && h.ScanFailures == 0 {
+			continue
+		}
+		sort.Ints(h.Opened)
+		sort.Ints(h.Closed)
+		digest.Opened += len(h.Opened)
+		digest.Closed += len(h.Closed)
+		digest.ScanFailures += h.ScanFailures
+		digest.Hosts = append(digest.Hosts, *h)
+	}
+	sort.Slice(digest.Hosts, func(i, j int) bool { return digest.Hosts[i].Name < digest.Hosts[j].Name })
+	return digest, nil
+}
+
+// SendDigest 立即向订阅者发送截至当前、长度为一个周期的摘要（不影响定时摘要的进度），用于预览。
+func (e *Engine) SendDigest(ctx context.Context, sub *models.NotificationSubscription) (*models.Digest, error) {
+	period := DigestPeriod(sub.Digest)
+	if period == 0 {
+		period = DigestPeriod(models.DigestDaily)
+	}
+	until := time.Now().UTC()
+	digest, err := e.BuildDigest(ctx, periodName(period), sub.GroupID, until.Add(-period), until)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.mailer.sendDigest(ctx, sub.Email, digest); err != nil {
+		return nil, err
+	}
+	return digest, nil
+}
+
+// sendDueDigests 发送到期的定时摘要。统计区间从上次发送（或订阅创建）时起，没有变化时不发邮件但同样推进进度。
+func (e *Engine) sendDueDigests(ctx context.Context) {
+	if !e.mailer.enabled() {
+		return
+	}
+	subs, err := e.store.DigestSubscriptions(ctx)
+	if err != nil {
+		log.Printf("[alerting] load digest subscriptions failed: %v", err)
+		return
+	}
+	now := time.Now().UTC()
+	for i := range subs {
+		sub := &subs[i]
+		period := DigestPeriod(sub.Digest)
+		since := sub.CreatedAt
+		if sub.LastDigestAt != nil {
+			since = *sub.LastDigestAt
+		}
+		if period == 0 || now.Sub(since) < period {
+			continue
+		}
+		digest, err := e.BuildDigest(ctx, sub.Digest, sub.GroupID, since, now)
+		if err != nil {
+			log.Printf("[alerting] build digest user=%d failed: %v", sub.UserID, err)
+			continue
+		}
+		if len(digest.Hosts) > 0 {
+			if err := e.mailer.sendDigest(ctx, sub.Email, digest); err != nil {
+				// 不推进进度，下一轮重试。
+				log.Printf("[alerting] send digest user=%d failed: %v", sub.UserID, err)
+				continue
+			}
+		}
+		if err := e.store.MarkDigestSent(ctx, sub.UserID, now); err != nil {
+			log.Printf("[alerting] mark digest user=%d failed: %v", sub.UserID, err)
+		}
+	}
+}
+
+func periodName(period time.Duration) string {
+	if period >= DigestPeriod(models.DigestWeekly) {
+		return models.DigestWeekly
+	}
+	return models.DigestDaily
+}
+
+func appendUnique(list []int, v int) []int {
+	for _, item := range list {
+		if item == v {
+			return list
+		}
+	}
+	return append(list, v)
+}
+
+// ValidDigest 校验摘要频率取值。
+func ValidDigest(digest string) error {
+	switch digest {
+	case models.DigestOff, models.DigestDaily, models.DigestWeekly:
+		return nil
+	}
+	return fmt.Errorf("digest must be one of %s, %s, %s", models.DigestOff, models.DigestDaily, models.DigestWeekly)
+}
@@ -0,0 +1,329 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/hitushen/portnotepro/internal/models"
+	"github.com/hitushen/portnotepro/internal/store"
+)
+
+// SMTP 连接的加密方式。
+const (
+	SMTPStartTLS = "starttls"
+	SMTPTLS      = "tls"
+	SMTPNone     = "none"
+)
+
+// ErrSMTPDisabled 表示未配置 SMTP 服务器。
+var ErrSMTPDisabled = errors.New("smtp is not configured")
+
+// SMTPConfig 为发信服务器配置，Host 为空时不发送邮件。
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	// TLS 为 starttls（默认）、tls（隐式 TLS，通常为 465 端口）或 none。
+	TLS string
+}
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+var templateFuncs = map[string]interface{}{"ports": formatPortList}
+
+var (
+	htmlTemplates = htmltemplate.Must(htmltemplate.New("").Funcs(templateFuncs).ParseFS(templateFS, "templates/*.html.tmpl"))
+	textTemplates = texttemplate.Must(texttemplate.New("").Funcs(templateFuncs).ParseFS(templateFS, "templates/*.txt.tmpl"))
+)
+
+// ParseRecipients 解析 email 目标的地址列表（可带 mailto: 前缀，逗号分隔），返回规范化后的地址。
+func ParseRecipients(raw string) ([]string, error) {
+	raw = strings.TrimSpace(raw)
+	if len(raw) >= 7 && strings.EqualFold(raw[:7], "mailto:") {
+		raw = raw[7:]
+	}
+	list, err := mail.ParseAddressList(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipients: %w", err)
+	}
+	addrs := make([]string, len(list))
+	for i, a := range list {
+		addrs[i] = a.Address
+	}
+	return addrs, nil
+}
+
+type mailer struct {
+	mu      sync.RWMutex
+	cfg     SMTPConfig
+	timeout time.Duration
+}
+
+// config 返回当前发信配置，重新加载配置时可能被替换。
+func (m *mailer) config() SMTPConfig {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.cfg
+}
+
+func (m *mailer) setConfig(cfg SMTPConfig) {
+	m.mu.Lock()
+	m.cfg = cfg
+	m.mu.Unlock()
+}
+
+func (m *mailer) enabled() bool {
+	return m.config().Host != ""
+}
+
+func (m *mailer) notify(ctx context.Context, target *models.AlertTarget, d *models.AlertDelivery, _ time.Time) (int, error) {
+	return 0, m.sendAlert(ctx, target, d)
+}
+
+// sendAlert 将告警渲染为邮件发送给目标的全部收件人。
+func (m *mailer) sendAlert(ctx context.Context, target *models.AlertTarget, d *models.AlertDelivery) error {
+	to, err := ParseRecipients(target.URL)
+	if err != nil {
+		return permanentError{err}
+	}
+	var alert models.Alert
+	if err := json.Unmarshal(d.Payload, &alert); err != nil {
+		return permanentError{err}
+	}
+	data := struct {
+		Subject string
+		Alert   models.Alert
+		Details []detail
+	}{alertSubject(alert), alert, sortedDetails(alert.Details)}
+	text, html, err := render("alert", data)
+	if err != nil {
+		return permanentError{err}
+	}
+	return m.send(ctx, to, data.Subject, text, html)
+}
+
+// sendDigest 渲染并发送摘要邮件。
+func (m *mailer) sendDigest(ctx context.Context, to string, digest *models.Digest) error {
+	data := struct{ Digest *models.Digest }{digest}
+	text, html, err := render("digest", data)
+	if err != nil {
+		return err
+	}
+	subject := fmt.Sprintf("[PortNote] %s digest: %d opened, %d closed, %d failed scans",
+		digest.Period, digest.Opened, digest.Closed, digest.ScanFailures)
+	return m.send(ctx, []string{to}, subject, text, html)
+}
+
+func render(name string, data interface{}) (string, string, error) {
+	var text, html bytes.Buffer
+	if err := textTemplates.ExecuteTemplate(&text, name+".txt.tmpl", data); err != nil {
+		return "", "", err
+	}
+	if err := htmlTemplates.ExecuteTemplate(&html, name+".html.tmpl", data); err != nil {
+		return "", "", err
+	}
+	return text.String(), html.String(), nil
+}
+
+// send 通过 SMTP 发送 multipart/alternative 邮件。服务器以 5xx 拒绝时返回 permanentError。
+func (m *mailer) send(ctx context.Context, to []string, subject, text, html string) error {
+	cfg := m.config()
+	if cfg.Host == "" {
+		return permanentError{ErrSMTPDisabled}
+	}
+	msg, err := buildMessage(cfg.From, to, subject, text, html)
+	if err != nil {
+		return permanentError{err}
+	}
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return permanentError{err}
+	}
+
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	dialer := &net.Dialer{Timeout: m.timeout}
+	var conn net.Conn
+	if cfg.TLS == SMTPTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: cfg.Host})
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	_ = conn.SetDeadline(time.Now().Add(m.timeout))
+	client, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if cfg.TLS == SMTPStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return permanentError{errors.New("smtp server does not support STARTTLS")}
+		}
+		if err := client.StartTLS(&tls.Config{ServerName: cfg.Host}); err != nil {
+			return err
+		}
+	}
+	if cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)); err != nil {
+			return classifySMTP(err)
+		}
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return classifySMTP(err)
+	}
+	for _, rcpt := range to {
+		if err := client.Rcpt(rcpt); err != nil {
+			return classifySMTP(err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return classifySMTP(err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return classifySMTP(err)
+	}
+	return client.Quit()
+}
+
+// classifySMTP 将 5xx 响应视为不可重试的错误。
+func classifySMTP(err error) error {
+	var tpErr *textproto.Error
+	if errors.As(err, &tpErr) && tpErr.Code >= 500 {
+		return permanentError{err}
+	}
+	return err
+}
+
+func buildMessage(from string, to []string, subject, text, html string) ([]byte, error) {
+	boundary, err := randomToken(12)
+	if err != nil {
+		return nil, err
+	}
+	msgID, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	domain := "portnote.local"
+	if addr, err := mail.ParseAddress(from); err == nil {
+		if _, host, ok := strings.Cut(addr.Address, "@"); ok {
+			domain = host
+		}
+	}
+
+	var buf bytes.Buffer
+	header := func(k, v string) { fmt.Fprintf(&buf, "%s: %s\r\n", k, v) }
+	header("From", from)
+	header("To", strings.Join(to, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", "<"+msgID+"@"+domain+">")
+	header("MIME-Version", "1.0")
+	header("Content-Type", `multipart/alternative; boundary="`+boundary+`"`)
+	buf.WriteString("\r\n")
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", html},
+	} {
+		fmt.Fprintf(&buf, "--%s\r\nContent-Type: %s\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n", boundary, part.contentType)
+		qp := quotedprintable.NewWriter(&buf)
+		if _, err := qp.Write([]byte(strings.ReplaceAll(part.body, "\n", "\r\n"))); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+		buf.WriteString("\r\n")
+	}
+	fmt.Fprintf(&buf, "--%s--\r\n", boundary)
+	return buf.Bytes(), nil
+}
+
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// alertSubject 生成告警邮件标题，如 "[PortNote] port_opened: db01 (10.0.0.5) port 3306"。
+func alertSubject(a models.Alert) string {
+	subject := "[PortNote] " + a.Event
+	if a.Host != nil {
+		subject += ": " + a.Host.Name
+		if a.Host.Address != "" && a.Host.Address != a.Host.Name {
+			subject += " (" + a.Host.Address + ")"
+		}
+	}
+	if a.Port != nil {
+		subject += " port " + strconv.Itoa(a.Port.Number)
+	}
+	return subject
+}
+
+type detail struct {
+	Key   string
+	Value string
+}
+
+func sortedDetails(details map[string]interface{}) []detail {
+	list := make([]detail, 0, len(details))
+	for k, v := range details {
+		var value string
+		switch val := v.(type) {
+		case []interface{}:
+			parts := make([]string, len(val))
+			for i, item := range val {
+				parts[i] = fmt.Sprint(item)
+			}
+			value = strings.Join(parts, ", ")
+		default:
+			value = fmt.Sprint(val)
+		}
+		if value == "" {
+			continue
+		}
+		list = append(list, detail{Key: k, Value: value})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+	return list
+}
+
+// formatPortList 将端口列表压缩为 "22, 80-82" 形式，空列表显示为 "–"。
+func formatPortList(ports []int) string {
+	if len(ports) == 0 {
+		return "–"
+	}
+	return strings.ReplaceAll(store.FormatPorts(ports), ",", ", ")
+}
@@ -0,0 +1,352 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hitushen/portnotepro/internal/models"
+)
+
+// sentMail 为 SMTP 接收端收到的一封邮件。
+type sentMail struct {
+	from string
+	to   []string
+	msg  *mail.Message
+	text string
+}
+
+// subject 返回解码后的标题。
+func (m sentMail) subject(t *testing.T) string {
+	t.Helper()
+	subject, err := new(mime.WordDecoder).DecodeHeader(m.msg.Header.Get("Subject"))
+	if err != nil {
+		t.Fatalf("decode subject %q: %v", m.msg.Header.Get("Subject"), err)
+	}
+	return subject
+}
+
+// smtpSink 为进程内的 SMTP 接收端（不加密、不要求认证），前 fail 封邮件以 451 拒绝。
+type smtpSink struct {
+	ln net.Listener
+
+	mu   sync.Mutex
+	fail int
+	mail []sentMail
+}
+
+func startSMTPSink(t *testing.T) *smtpSink {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &smtpSink{ln: ln}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(t, conn)
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+// config 返回指向该接收端的发信配置。
+func (s *smtpSink) config() SMTPConfig {
+	addr := s.ln.Addr().(*net.TCPAddr)
+	return SMTPConfig{Host: addr.IP.String(), Port: addr.Port, From: "PortNote <portnote@example.com>", TLS: SMTPNone}
+}
+
+func (s *smtpSink) received() []sentMail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]sentMail(nil), s.mail...)
+}
+
+func (s *smtpSink) serve(t *testing.T, conn net.Conn) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+	tp := textproto.NewConn(conn)
+	_ = tp.PrintfLine("220 portnote-test ESMTP")
+	var from string
+	var to []string
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			_ = tp.PrintfLine("250 portnote-test")
+		case "MAIL":
+			from, to = smtpPath(arg), nil
+			_ = tp.PrintfLine("250 2.1.0 ok")
+		case "RCPT":
+			to = append(to, smtpPath(arg))
+			_ = tp.PrintfLine("250 2.1.5 ok")
+		case "DATA":
+			_ = tp.PrintfLine("354 end data with <CR><LF>.<CR><LF>")
+			data, err := io.ReadAll(tp.DotReader())
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			reject := s.fail > 0
+			if reject {
+				s.fail--
+			}
+			s.mu.Unlock()
+			if reject {
+				_ = tp.PrintfLine("451 4.3.0 try again later")
+				continue
+			}
+			msg, err := mail.ReadMessage(bytes.NewReader(data))
+			if err != nil {
+				t.Errorf("unparseable message: %v", err)
+				_ = tp.PrintfLine("554 5.6.0 bad message")
+				continue
+			}
+			text := plainTextPart(t, msg)
+			s.mu.Lock()
+			s.mail = append(s.mail, sentMail{from: from, to: to, msg: msg, text: text})
+			s.mu.Unlock()
+			_ = tp.PrintfLine("250 2.0.0 queued")
+		case "RSET", "NOOP":
+			_ = tp.PrintfLine("250 2.0.0 ok")
+		case "QUIT":
+			_ = tp.PrintfLine("221 2.0.0 bye")
+			return
+		default:
+			_ = tp.PrintfLine("502 5.5.2 command not recognized")
+		}
+	}
+}
+
+// plainTextPart 返回 multipart 邮件中解码后的 text/plain 正文。
+func plainTextPart(t *testing.T, msg *mail.Message) string {
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Errorf("content type: %v", err)
+		return ""
+	}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := mr.NextRawPart()
+		if err != nil {
+			t.Errorf("no text/plain part: %v", err)
+			return ""
+		}
+		if strings.HasPrefix(part.Header.Get("Content-Type"), "text/plain") {
+			body, _ := io.ReadAll(quotedprintable.NewReader(part))
+			return strings.ReplaceAll(string(body), "\r\n", "\n")
+		}
+	}
+}
+
+// smtpPath 从 "FROM:<a@b>" / "TO:<a@b>" 中取出地址。
+func smtpPath(arg string) string {
+	_, path, _ := strings.Cut(arg, ":")
+	path = strings.TrimSpace(path)
+	if i := strings.Index(path, ">"); i >= 0 {
+		path = path[:i]
+	}
+	return strings.TrimPrefix(path, "<")
+}
+
+func TestEmailAlertRecipientsAndSubject(t *testing.T) {
+	ctx := context.Background()
+	sink := startSMTPSink(t)
+	f := newAlertFixture(t, "", "", Options{MaxAttempts: 3, Backoff: time.Minute, SMTP: sink.config()})
+	if err := f.store.UpdateHost(ctx, f.hostID, "数据库-01", "10.0.0.5", false); err != nil {
+		t.Fatal(err)
+	}
+	f.target.Kind = models.TargetEmail
+	f.target.URL = "mailto:ops@example.com, Security <sec@example.com>"
+	if err := f.store.UpdateAlertTarget(ctx, f.target); err != nil {
+		t.Fatal(err)
+	}
+	d := f.raise(t)
+	f.engine.dispatch()
+
+	if logged := f.delivery(t, d.ID); logged.Status != models.DeliveryDelivered {
+		t.Fatalf("delivery = %+v", logged)
+	}
+	sent := sink.received()
+	if len(sent) != 1 {
+		t.Fatalf("sink got %d messages, want 1", len(sent))
+	}
+	m := sent[0]
+	if want := []string{"ops@example.com", "sec@example.com"}; !reflect.DeepEqual(m.to, want) {
+		t.Errorf("envelope recipients = %v, want %v", m.to, want)
+	}
+	if m.from != "portnote@example.com" {
+		t.Errorf("envelope sender = %q", m.from)
+	}
+	if got := m.msg.Header.Get("To"); got != "ops@example.com, sec@example.com" {
+		t.Errorf("To header = %q", got)
+	}
+
+	raw := m.msg.Header.Get("Subject")
+	if !strings.HasPrefix(raw, "=?utf-8?q?") {
+		t.Errorf("subject %q is not Q-encoded", raw)
+	}
+	if want := "[PortNote] port_created: 数据库-01 (10.0.0.5) port 3306"; m.subject(t) != want {
+		t.Errorf("subject = %q, want %q", m.subject(t), want)
+	}
+	if !strings.Contains(m.text, "数据库-01") {
+		t.Errorf("text body does not mention the host:\n%s", m.text)
+	}
+}
+
+func TestEmailAlertRetriesTemporaryRejection(t *testing.T) {
+	ctx := context.Background()
+	sink := startSMTPSink(t)
+	sink.fail = 1
+	f := newAlertFixture(t, "", "", Options{MaxAttempts: 3, Backoff: time.Millisecond, SMTP: sink.config()})
+	f.target.Kind = models.TargetEmail
+	f.target.URL = "ops@example.com"
+	if err := f.store.UpdateAlertTarget(ctx, f.target); err != nil {
+		t.Fatal(err)
+	}
+	d := f.raise(t)
+
+	f.engine.dispatch()
+	if logged := f.delivery(t, d.ID); logged.Status != models.DeliveryPending || !strings.Contains(logged.LastError, "451") {
+		t.Fatalf("after 451: %+v", logged)
+	}
+	f.dispatchWhenDue(t, d.ID)
+	if logged := f.delivery(t, d.ID); logged.Status != models.DeliveryDelivered || logged.Attempts != 2 {
+		t.Errorf("after retry: %+v", logged)
+	}
+	if n := len(sink.received()); n != 1 {
+		t.Errorf("sink accepted %d messages, want 1", n)
+	}
+}
+
+func TestDigestBatching(t *testing.T) {
+	ctx := context.Background()
+	sink := startSMTPSink(t)
+	f := newAlertFixture(t, "", "", Options{MaxAttempts: 3, Backoff: time.Minute, SMTP: sink.config()})
+	st := f.store
+	webID, err := st.CreateHost(ctx, "web-1", "10.0.0.8", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	quietID, err := st.CreateHost(ctx, "quiet-1", "10.0.0.9", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC()
+	for _, e := range []models.AlertEvent{
+		{Event: models.AlertPortCreated, HostID: f.hostID, Port: 3306},
+		{Event: models.AlertPortOpened, HostID: f.hostID, Port: 22},
+		{Event: models.AlertPortOpened, HostID: f.hostID, Port: 22},
+		{Event: models.AlertPortClosed, HostID: webID, Port: 8080},
+		{Event: models.AlertScanFailed, HostID: webID, Detail: "timeout"},
+		{Event: models.AlertScanFailed, HostID: webID, Detail: "host unreachable"},
+		// 早于统计区间的事件不计入。
+		{Event: models.AlertPortOpened, HostID: quietID, Port: 443, OccurredAt: now.Add(-48 * time.Hour)},
+	} {
+		if e.OccurredAt.IsZero() {
+			e.OccurredAt = now.Add(-time.Hour)
+		}
+		if err := st.RecordAlertEvent(ctx, &e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	aliceID, err := st.CreateUser(ctx, "alice", "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobID, err := st.CreateUser(ctx, "bob", "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sub := range []models.NotificationSubscription{
+		{UserID: aliceID, Email: "alice@example.com", Digest: models.DigestDaily},
+		{UserID: bobID, Email: "bob@example.com", Digest: models.DigestWeekly},
+	} {
+		if err := st.SetSubscription(ctx, &sub); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// alice 的上一期摘要在 25 小时前，本期已到期；bob 的周摘要尚未到期。
+	if err := st.MarkDigestSent(ctx, aliceID, now.Add(-25*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.MarkDigestSent(ctx, bobID, now.Add(-25*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	f.engine.sendDueDigests(ctx)
+	sent := sink.received()
+	if len(sent) != 1 {
+		t.Fatalf("sink got %d messages, want a single digest", len(sent))
+	}
+	m := sent[0]
+	if !reflect.DeepEqual(m.to, []string{"alice@example.com"}) {
+		t.Errorf("digest recipients = %v", m.to)
+	}
+	if want := "[PortNote] daily digest: 2 opened, 1 closed, 2 failed scans"; m.subject(t) != want {
+		t.Errorf("subject = %q, want %q", m.subject(t), want)
+	}
+	for _, want := range []string{
+		"db-1 (10.0.0.5)\n  opened:       22, 3306\n  closed:       –\n  failed scans: 0",
+		"web-1 (10.0.0.8)\n  opened:       –\n  closed:       8080\n  failed scans: 2 (last: host unreachable)",
+	} {
+		if !strings.Contains(m.text, want) {
+			t.Errorf("digest body missing %q:\n%s", want, m.text)
+		}
+	}
+	if strings.Contains(m.text, "quiet-1") {
+		t.Errorf("digest includes events from before the period:\n%s", m.text)
+	}
+
+	// 进度已推进，同一周期内不会重复发送。
+	f.engine.sendDueDigests(ctx)
+	if n := len(sink.received()); n != 1 {
+		t.Errorf("second run sent %d messages in total, want 1", n)
+	}
+}
+
+func TestParseRecipients(t *testing.T) {
+	cases := []struct {
+		raw     string
+		want    []string
+		wantErr bool
+	}{
+		{raw: "ops@example.com", want: []string{"ops@example.com"}},
+		{raw: "MAILTO:ops@example.com,sec@example.com", want: []string{"ops@example.com", "sec@example.com"}},
+		{raw: " mailto:Ops Team <ops@example.com>, sec@example.com ", want: []string{"ops@example.com", "sec@example.com"}},
+		{raw: "mailto:", wantErr: true},
+		{raw: "not an address", wantErr: true},
+	}
+	for _, c := range cases {
+		got, err := ParseRecipients(c.raw)
+		if (err != nil) != c.wantErr {
+			t.Errorf("ParseRecipients(%q) error = %v, wantErr %v", c.raw, err, c.wantErr)
+			continue
+		}
+		if !c.wantErr && !reflect.DeepEqual(got, c.want) {
+			t.Errorf("ParseRecipients(%q) = %v, want %v", c.raw, got, c.want)
+		}
+	}
+}
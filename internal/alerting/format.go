@@ -0,0 +1,303 @@
+package alerting
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hitushen/portnotepro/internal/models"
+)
+
+// SeverityRank 返回严重级别的顺序，未知取值为 -1。
+func SeverityRank(severity string) int {
+	switch severity {
+	case models.SeverityInfo:
+		return 0
+	case models.SeverityWarning:
+		return 1
+	case models.SeverityCritical:
+		return 2
+	}
+	return -1
+}
+
+// defaultSeverity 为告警类型的默认级别：基线检出意外开放端口为 critical，新开放端口、扫描失败与其余偏差为 warning，
+// 端口关闭为 info。规则可覆盖。
+func defaultSeverity(kind, complianceStatus string) string {
+	switch kind {
+	case models.AlertDrift:
+		if complianceStatus == models.ComplianceUnexpectedOpen {
+			return models.SeverityCritical
+		}
+		return models.SeverityWarning
+	case models.AlertPortCreated, models.AlertPortOpened, models.AlertScanFailed:
+		return models.SeverityWarning
+	}
+	return models.SeverityInfo
+}
+
+// fact 为聊天消息中的一个字段。
+type fact struct {
+	Name  string
+	Value string
+}
+
+// alertTitle 返回告警的一句话描述，如 "Port 3306 opened on db01"。
+func alertTitle(a models.Alert) string {
+	host := "unknown host"
+	if a.Host != nil {
+		host = a.Host.Name
+	}
+	port := ""
+	if a.Port != nil {
+		port = strconv.Itoa(a.Port.Number)
+	}
+	switch a.Event {
+	case models.AlertPortCreated:
+		return "New open port " + port + " on " + host
+	case models.AlertPortOpened:
+		return "Port " + port + " opened on " + host
+	case models.AlertPortClosed:
+		return "Port " + port + " closed on " + host
+	case models.AlertScanFailed:
+		return "Scan failed on " + host
+	case models.AlertDrift:
+		return "Baseline drift on " + host
+	case models.AlertTest:
+		return "PortNote test alert"
+	}
+	return a.Event + " on " + host
+}
+
+// alertFacts 将告警展开为有序字段列表，各渠道格式共用。
+func alertFacts(a models.Alert) []fact {
+	var facts []fact
+	if a.Host != nil {
+		value := a.Host.Name
+		if a.Host.Address != "" && a.Host.Address != a.Host.Name {
+			value += " (" + a.Host.Address + ")"
+		}
+		facts = append(facts, fact{"Host", value})
+	}
+	if p := a.Port; p != nil {
+		value := strconv.Itoa(p.Number) + " " + p.Status
+		if p.Previous != "" {
+			value = strconv.Itoa(p.Number) + " " + p.Previous + " → " + p.Status
+		}
+		facts = append(facts, fact{"Port", value})
+		if p.Service != "" {
+			facts = append(facts, fact{"Service", p.Service})
+		}
+		if p.Note != "" && p.Note != p.Service {
+			facts = append(facts, fact{"Note", p.Note})
+		}
+	}
+	for _, d := range sortedDetails(a.Details) {
+		facts = append(facts, fact{strings.ToUpper(d.Key[:1]) + d.Key[1:], d.Value})
+	}
+	if a.Rule != nil {
+		facts = append(facts, fact{"Rule", a.Rule.Name})
+	}
+	facts = append(facts, fact{"Severity", a.Severity})
+	return facts
+}
+
+// severityColor 返回各级别的展示颜色（RGB）。
+func severityColor(severity string) int {
+	switch severity {
+	case models.SeverityCritical:
+		return 0xD32F2F
+	case models.SeverityWarning:
+		return 0xF9A825
+	}
+	return 0x1976D2
+}
+
+// formatChat 按渠道类型渲染告警，返回实际请求地址与 JSON 请求体。
+func formatChat(kind, targetURL string, a models.Alert) (string, []byte, error) {
+	var payload interface{}
+	switch kind {
+	case models.TargetSlack:
+		payload = slackPayload(a)
+	case models.TargetDiscord:
+		payload = discordPayload(a)
+	case models.TargetTeams:
+		payload = teamsPayload(a)
+	case models.TargetTelegram:
+		endpoint, chatID, err := TelegramEndpoint(targetURL)
+		if err != nil {
+			return "", nil, err
+		}
+		targetURL = endpoint
+		payload = telegramPayload(chatID, a)
+	default:
+		return "", nil, fmt.Errorf("unsupported chat target %q", kind)
+	}
+	body, err := json.Marshal(payload)
+	return targetURL, body, err
+}
+
+// slackPayload 渲染 Slack incoming webhook 消息：带级别颜色的 attachment 内含 Block Kit 字段。
+func slackPayload(a models.Alert) map[string]interface{} {
+	title := alertTitle(a)
+	fields := []map[string]interface{}{}
+	for _, f := range alertFacts(a) {
+		fields = append(fields, map[string]interface{}{"type": "mrkdwn", "text": "*" + slackEscape(f.Name) + "*\n" + slackEscape(f.Value)})
+	}
+	blocks := []map[string]interface{}{
+		{"type": "header", "text": map[string]interface{}{"type": "plain_text", "text": title}},
+	}
+	// Slack 每个 section 最多 10 个字段。
+	for len(fields) > 0 {
+		n := len(fields)
+		if n > 10 {
+			n = 10
+		}
+		blocks = append(blocks, map[string]interface{}{"type": "section", "fields": fields[:n]})
+		fields = fields[n:]
+	}
+	blocks = append(blocks, map[string]interface{}{
+		"type":     "context",
+		"elements": []map[string]interface{}{{"type": "mrkdwn", "text": "PortNote · " + a.OccurredAt.UTC().Format(time.RFC1123)}},
+	})
+	return map[string]interface{}{
+		"text": title,
+		"attachments": []map[string]interface{}{
+			{"color": fmt.Sprintf("#%06X", severityColor(a.Severity)), "blocks": blocks},
+		},
+	}
+}
+
+// discordPayload 渲染 Discord webhook 消息（单个 embed）。
+func discordPayload(a models.Alert) map[string]interface{} {
+	fields := []map[string]interface{}{}
+	for _, f := range alertFacts(a) {
+		fields = append(fields, map[string]interface{}{"name": f.Name, "value": truncate(f.Value, 1024), "inline": len(f.Value) <= 40})
+	}
+	return map[string]interface{}{
+		"username": "PortNote",
+		"embeds": []map[string]interface{}{{
+			"title":     truncate(alertTitle(a), 256),
+			"color":     severityColor(a.Severity),
+			"fields":    fields,
+			"timestamp": a.OccurredAt.UTC().Format(time.RFC3339),
+			"footer":    map[string]interface{}{"text": "PortNote · " + a.Event},
+		}},
+	}
+}
+
+// teamsPayload 渲染 Microsoft Teams 消息（Adaptive Card，适用于 Workflows / incoming webhook）。
+func teamsPayload(a models.Alert) map[string]interface{} {
+	facts := []map[string]interface{}{}
+	for _, f := range alertFacts(a) {
+		facts = append(facts, map[string]interface{}{"title": f.Name, "value": f.Value})
+	}
+	color := "Accent"
+	switch a.Severity {
+	case models.SeverityCritical:
+		color = "Attention"
+	case models.SeverityWarning:
+		color = "Warning"
+	}
+	return map[string]interface{}{
+		"type": "message",
+		"attachments": []map[string]interface{}{{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content": map[string]interface{}{
+				"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+				"type":    "AdaptiveCard",
+				"version": "1.4",
+				"body": []map[string]interface{}{
+					{"type": "TextBlock", "text": alertTitle(a), "weight": "Bolder", "size": "Medium", "color": color, "wrap": true},
+					{"type": "FactSet", "facts": facts},
+					{"type": "TextBlock", "text": "PortNote · " + a.OccurredAt.UTC().Format(time.RFC1123), "isSubtle": true, "size": "Small"},
+				},
+			},
+		}},
+	}
+}
+
+// telegramPayload 渲染 Telegram Bot API sendMessage 请求（HTML 格式）。
+func telegramPayload(chatID string, a models.Alert) map[string]interface{} {
+	var b strings.Builder
+	b.WriteString("<b>" + html.EscapeString(alertTitle(a)) + "</b>\n")
+	for _, f := range alertFacts(a) {
+		b.WriteString("\n<b>" + html.EscapeString(f.Name) + ":</b> " + html.EscapeString(f.Value))
+	}
+	return map[string]interface{}{
+		"chat_id":                  chatID,
+		"text":                     truncate(b.String(), 4096),
+		"parse_mode":               "HTML",
+		"disable_web_page_preview": true,
+	}
+}
+
+// TelegramEndpoint 从 telegram 目标地址中拆出 sendMessage 地址与 chat_id。
+func TelegramEndpoint(raw string) (string, string, error) {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", "", errors.New("telegram url must be https://api.telegram.org/bot<token>/sendMessage?chat_id=<id>")
+	}
+	chatID := u.Query().Get("chat_id")
+	if chatID == "" {
+		return "", "", errors.New("telegram url must include chat_id")
+	}
+	u.RawQuery = ""
+	return u.String(), chatID, nil
+}
+
+// NormalizeTarget 校验通知目标的类型、地址与最低级别，补齐默认值并把邮件目标的地址改写为规范形式。
+// 接口与配置文件声明的目标共用此校验。
+func NormalizeTarget(t *models.AlertTarget) error {
+	t.Name = strings.TrimSpace(t.Name)
+	t.URL = strings.TrimSpace(t.URL)
+	if t.Kind == "" {
+		t.Kind = models.TargetWebhook
+	}
+	if t.Name == "" {
+		return errors.New("name required")
+	}
+	switch t.Kind {
+	case models.TargetWebhook, models.TargetSlack, models.TargetDiscord, models.TargetTeams:
+		u, err := url.Parse(t.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("url must be an absolute http(s) URL")
+		}
+	case models.TargetEmail:
+		// 邮件目标的 URL 为 mailto: 加逗号分隔的收件人。
+		recipients, err := ParseRecipients(t.URL)
+		if err != nil {
+			return err
+		}
+		t.URL = "mailto:" + strings.Join(recipients, ",")
+	case models.TargetTelegram:
+		if _, _, err := TelegramEndpoint(t.URL); err != nil {
+			return err
+		}
+	default:
+		return errors.New("kind must be one of: " + strings.Join(models.TargetKinds, ", "))
+	}
+	if t.MinSeverity == "" {
+		t.MinSeverity = models.SeverityInfo
+	}
+	if SeverityRank(t.MinSeverity) < 0 {
+		return errors.New("minSeverity must be one of: " + strings.Join(models.Severities, ", "))
+	}
+	return nil
+}
+
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+func truncate(s string, n int) string {
+	if len([]rune(s)) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
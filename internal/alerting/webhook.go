@@ -0,0 +1,131 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hitushen/portnotepro/internal/models"
+)
+
+// webhook 请求头。签名覆盖 "<timestamp>.<body>"，接收方应同时校验时间戳以防重放。
+const (
+	HeaderEvent     = "X-PortNote-Event"
+	HeaderDelivery  = "X-PortNote-Delivery"
+	HeaderTimestamp = "X-PortNote-Timestamp"
+	HeaderSignature = "X-PortNote-Signature"
+)
+
+// maxErrorBody 为失败时记录到投递日志的响应体长度上限。
+const maxErrorBody = 200
+
+// Sign 返回 webhook 签名：sha256=hex(HMAC-SHA256(secret, timestamp + "." + body))。
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify 以常量时间比较签名，供接收方校验请求。
+func Verify(secret, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// notifier 将一条投递发送到某类目标，返回 HTTP 响应码（没有 HTTP 响应时为 0）。
+// 重试也不会成功的错误包装为 permanentError。
+type notifier interface {
+	notify(ctx context.Context, target *models.AlertTarget, d *models.AlertDelivery, now time.Time) (int, error)
+}
+
+// webhookSender 投递通用 webhook：请求体即告警 JSON，配置了 secret 时附带签名。
+type webhookSender struct {
+	client *http.Client
+}
+
+func (w *webhookSender) notify(ctx context.Context, target *models.AlertTarget, d *models.AlertDelivery, now time.Time) (int, error) {
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	header := http.Header{}
+	header.Set(HeaderEvent, d.Event)
+	header.Set(HeaderDelivery, strconv.FormatInt(d.ID, 10))
+	header.Set(HeaderTimestamp, timestamp)
+	if target.Secret != "" {
+		header.Set(HeaderSignature, Sign(target.Secret, timestamp, d.Payload))
+	}
+	return postJSON(ctx, w.client, target.URL, d.Payload, header)
+}
+
+// chatNotifier 将告警渲染为 Slack、Discord、Teams 或 Telegram 的原生消息格式后投递。
+type chatNotifier struct {
+	client *http.Client
+}
+
+func (c *chatNotifier) notify(ctx context.Context, target *models.AlertTarget, d *models.AlertDelivery, _ time.Time) (int, error) {
+	var alert models.Alert
+	if err := json.Unmarshal(d.Payload, &alert); err != nil {
+		return 0, permanentError{err}
+	}
+	endpoint, body, err := formatChat(target.Kind, target.URL, alert)
+	if err != nil {
+		return 0, permanentError{err}
+	}
+	return postJSON(ctx, c.client, endpoint, body, nil)
+}
+
+// postJSON 以 JSON POST 请求体，2xx 视为成功，返回响应状态码（网络错误时为 0）。
+// 渠道地址常含令牌，错误信息中不带 URL。
+func postJSON(ctx context.Context, client *http.Client, endpoint string, body []byte, header http.Header) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, permanentError{errors.New("invalid target url")}
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "PortNote-Webhook/1.0")
+	resp, err := client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return 0, urlErr.Err
+		}
+		return 0, err
+	}
+	defer resp.Body.Close()
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.StatusCode, nil
+	}
+	err = fmt.Errorf("unexpected status %d", resp.StatusCode)
+	if msg := strings.TrimSpace(string(snippet)); msg != "" {
+		err = fmt.Errorf("unexpected status %d: %s", resp.StatusCode, msg)
+	}
+	// 除 408、429 外的 4xx 重试也不会成功。
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != 408 && resp.StatusCode != 429 {
+		return resp.StatusCode, permanentError{err}
+	}
+	return resp.StatusCode, err
+}
+
+// permanentError 表示重试也不会成功的投递错误。
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+
+func (e permanentError) Unwrap() error { return e.err }
@@ -0,0 +1,301 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hitushen/portnotepro/internal/models"
+	"github.com/hitushen/portnotepro/internal/realtime"
+	"github.com/hitushen/portnotepro/internal/store"
+)
+
+// receivedRequest 为 webhook 接收端收到的一次请求。
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver 为基于 httptest 的 webhook 接收端，依次按 statuses 返回状态码，用完后返回 200。
+type receiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	requests []receivedRequest
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	t.Helper()
+	rc := &receiver{statuses: statuses}
+	rc.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rc.mu.Lock()
+		rc.requests = append(rc.requests, receivedRequest{header: r.Header.Clone(), body: body})
+		status := http.StatusOK
+		if len(rc.statuses) > 0 {
+			status, rc.statuses = rc.statuses[0], rc.statuses[1:]
+		}
+		rc.mu.Unlock()
+		w.WriteHeader(status)
+		if status != http.StatusOK {
+			_, _ = io.WriteString(w, "receiver says no")
+		}
+	}))
+	t.Cleanup(rc.Close)
+	return rc
+}
+
+func (rc *receiver) received() []receivedRequest {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]receivedRequest(nil), rc.requests...)
+}
+
+// alertFixture 为一台带端口的主机、一个 webhook 目标与一条 port_created 规则。
+type alertFixture struct {
+	engine *Engine
+	store  *store.Store
+	target *models.AlertTarget
+	rule   *models.AlertRule
+	hostID int64
+	portID int64
+}
+
+func newAlertFixture(t *testing.T, url, secret string, opts Options) *alertFixture {
+	t.Helper()
+	ctx := context.Background()
+	st, err := store.New(filepath.Join(t.TempDir(), "portnote.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { st.Close() })
+
+	f := &alertFixture{store: st}
+	if f.hostID, err = st.CreateHost(ctx, "db-1", "10.0.0.5", false); err != nil {
+		t.Fatal(err)
+	}
+	if f.portID, err = st.CreatePort(ctx, f.hostID, 3306, "mysql", "", false); err != nil {
+		t.Fatal(err)
+	}
+	f.target = &models.AlertTarget{Name: "ops", Kind: models.TargetWebhook, URL: url, Secret: secret, Enabled: true}
+	if f.target.ID, err = st.CreateAlertTarget(ctx, f.target); err != nil {
+		t.Fatal(err)
+	}
+	f.rule = &models.AlertRule{Name: "db exposed", Events: []string{models.AlertPortCreated}, Ports: "3306", TargetID: f.target.ID, Enabled: true}
+	if f.rule.ID, err = st.CreateAlertRule(ctx, f.rule); err != nil {
+		t.Fatal(err)
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	f.engine = NewEngine(st, opts)
+	return f
+}
+
+// raise 让引擎处理一条 port_created 事件，返回写入的投递记录。
+func (f *alertFixture) raise(t *testing.T) *models.AlertDelivery {
+	t.Helper()
+	ctx := context.Background()
+	n, err := f.engine.process(ctx, realtime.Event{Type: "port_created", HostID: f.hostID, PortID: f.portID})
+	if err != nil || n != 1 {
+		t.Fatalf("process port_created = %d, %v; want 1 delivery queued", n, err)
+	}
+	list, err := f.store.ListAlertDeliveries(ctx, store.DeliveryFilter{RuleID: f.rule.ID})
+	if err != nil || len(list) != 1 {
+		t.Fatalf("deliveries = %v, %v", list, err)
+	}
+	return &list[0]
+}
+
+func (f *alertFixture) delivery(t *testing.T, id int64) *models.AlertDelivery {
+	t.Helper()
+	d, err := f.store.GetAlertDelivery(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// dispatchWhenDue 等到投递的重试时间到期后执行一轮发送。
+func (f *alertFixture) dispatchWhenDue(t *testing.T, id int64) {
+	t.Helper()
+	if wait := time.Until(f.delivery(t, id).NextAttemptAt); wait > 0 {
+		time.Sleep(wait + 5*time.Millisecond)
+	}
+	f.engine.dispatch()
+}
+
+func TestWebhookSignedDelivery(t *testing.T) {
+	rc := newReceiver(t)
+	f := newAlertFixture(t, rc.URL, "s3cret", Options{MaxAttempts: 3, Backoff: time.Minute})
+	d := f.raise(t)
+	f.engine.dispatch()
+
+	reqs := rc.received()
+	if len(reqs) != 1 {
+		t.Fatalf("receiver got %d requests, want 1", len(reqs))
+	}
+	req := reqs[0]
+	timestamp := req.header.Get(HeaderTimestamp)
+	if got := req.header.Get(HeaderSignature); got != Sign("s3cret", timestamp, req.body) {
+		t.Errorf("signature %q does not match the body", got)
+	}
+	if !Verify("s3cret", timestamp, req.body, req.header.Get(HeaderSignature)) {
+		t.Error("Verify rejected a genuine signature")
+	}
+	if Verify("wrong", timestamp, req.body, req.header.Get(HeaderSignature)) {
+		t.Error("Verify accepted a signature made with another secret")
+	}
+	if got := req.header.Get(HeaderEvent); got != models.AlertPortCreated {
+		t.Errorf("%s = %q", HeaderEvent, got)
+	}
+	if got := req.header.Get(HeaderDelivery); got != strconv.FormatInt(d.ID, 10) {
+		t.Errorf("%s = %q, want %d", HeaderDelivery, got, d.ID)
+	}
+	var alert models.Alert
+	if err := json.Unmarshal(req.body, &alert); err != nil {
+		t.Fatal(err)
+	}
+	if alert.Port == nil || alert.Port.Number != 3306 || alert.Rule == nil || alert.Rule.ID != f.rule.ID {
+		t.Errorf("alert body = %s", req.body)
+	}
+
+	logged := f.delivery(t, d.ID)
+	if logged.Status != models.DeliveryDelivered || logged.Attempts != 1 || logged.ResponseCode != 200 || logged.DeliveredAt == nil {
+		t.Errorf("delivery log entry = %+v", logged)
+	}
+}
+
+func TestWebhookWithoutSecretIsUnsigned(t *testing.T) {
+	rc := newReceiver(t)
+	f := newAlertFixture(t, rc.URL, "", Options{MaxAttempts: 3, Backoff: time.Minute})
+	f.raise(t)
+	f.engine.dispatch()
+
+	reqs := rc.received()
+	if len(reqs) != 1 || reqs[0].header.Get(HeaderSignature) != "" {
+		t.Errorf("unsigned target sent %d requests with signature %q", len(reqs), reqs[0].header.Get(HeaderSignature))
+	}
+}
+
+func TestWebhookRetriesServerErrors(t *testing.T) {
+	rc := newReceiver(t, http.StatusInternalServerError, http.StatusBadGateway)
+	backoff := 20 * time.Millisecond
+	f := newAlertFixture(t, rc.URL, "", Options{MaxAttempts: 5, Backoff: backoff})
+	d := f.raise(t)
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		before := time.Now()
+		f.dispatchWhenDue(t, d.ID)
+		logged := f.delivery(t, d.ID)
+		if logged.Status != models.DeliveryPending || logged.Attempts != attempt || logged.ResponseCode < 500 {
+			t.Fatalf("after attempt %d: %+v", attempt, logged)
+		}
+		if logged.LastError == "" {
+			t.Errorf("after attempt %d: no error recorded", attempt)
+		}
+		// 第 n 次失败后等待 Backoff·2^(n-1)。
+		want := backoff << (attempt - 1)
+		if wait := logged.NextAttemptAt.Sub(before); wait < want-5*time.Millisecond || wait > want+time.Second {
+			t.Errorf("after attempt %d next attempt in %s, want about %s", attempt, wait, want)
+		}
+	}
+
+	// 未到重试时间时不会发送。
+	f.engine.dispatch()
+	if n := len(rc.received()); n != 2 {
+		t.Fatalf("receiver got %d requests before the retry was due, want 2", n)
+	}
+
+	f.dispatchWhenDue(t, d.ID)
+	logged := f.delivery(t, d.ID)
+	if logged.Status != models.DeliveryDelivered || logged.Attempts != 3 || logged.LastError != "" {
+		t.Errorf("after recovery: %+v", logged)
+	}
+}
+
+func TestWebhookGivesUpAfterMaxAttempts(t *testing.T) {
+	rc := newReceiver(t, 503, 503, 503, 503, 503)
+	f := newAlertFixture(t, rc.URL, "", Options{MaxAttempts: 3, Backoff: time.Millisecond})
+	d := f.raise(t)
+
+	for i := 0; i < 3; i++ {
+		f.dispatchWhenDue(t, d.ID)
+	}
+	logged := f.delivery(t, d.ID)
+	if logged.Status != models.DeliveryFailed || logged.Attempts != 3 || logged.ResponseCode != 503 {
+		t.Fatalf("after max attempts: %+v", logged)
+	}
+	f.engine.dispatch()
+	if n := len(rc.received()); n != 3 {
+		t.Errorf("receiver got %d requests, want 3", n)
+	}
+
+	failed, err := f.store.ListAlertDeliveries(context.Background(), store.DeliveryFilter{Status: models.DeliveryFailed})
+	if err != nil || len(failed) != 1 || failed[0].ID != d.ID {
+		t.Errorf("failed deliveries = %+v, %v", failed, err)
+	}
+
+	// 手动重试会重新投递一次。
+	if ok, err := f.store.RetryAlertDelivery(context.Background(), d.ID); err != nil || !ok {
+		t.Fatalf("retry = %v, %v", ok, err)
+	}
+	f.engine.dispatch()
+	if logged := f.delivery(t, d.ID); logged.Attempts != 4 || len(rc.received()) != 4 {
+		t.Errorf("manual retry: attempts %d, requests %d", logged.Attempts, len(rc.received()))
+	}
+}
+
+func TestWebhookClientErrorIsPermanent(t *testing.T) {
+	rc := newReceiver(t, http.StatusBadRequest)
+	f := newAlertFixture(t, rc.URL, "", Options{MaxAttempts: 5, Backoff: time.Millisecond})
+	d := f.raise(t)
+	f.engine.dispatch()
+
+	logged := f.delivery(t, d.ID)
+	if logged.Status != models.DeliveryFailed || logged.Attempts != 1 || logged.ResponseCode != 400 {
+		t.Errorf("after 400: %+v", logged)
+	}
+	if want := "unexpected status 400: receiver says no"; logged.LastError != want {
+		t.Errorf("last error = %q, want %q", logged.LastError, want)
+	}
+}
+
+func TestWebhookCooldown(t *testing.T) {
+	rc := newReceiver(t)
+	f := newAlertFixture(t, rc.URL, "", Options{MaxAttempts: 3, Backoff: time.Minute})
+	f.rule.Cooldown = 3600
+	if err := f.store.UpdateAlertRule(context.Background(), f.rule); err != nil {
+		t.Fatal(err)
+	}
+	f.raise(t)
+	n, err := f.engine.process(context.Background(), realtime.Event{Type: "port_created", HostID: f.hostID, PortID: f.portID})
+	if err != nil || n != 0 {
+		t.Errorf("second event within the cooldown queued %d deliveries, %v", n, err)
+	}
+}
+
+func TestBackoffSchedule(t *testing.T) {
+	e := &Engine{opts: Options{Backoff: 30 * time.Second}}
+	cases := map[int]time.Duration{
+		1:  30 * time.Second,
+		2:  time.Minute,
+		3:  2 * time.Minute,
+		7:  32 * time.Minute,
+		8:  time.Hour,
+		20: time.Hour,
+	}
+	for attempts, want := range cases {
+		if got := e.backoff(attempts); got != want {
+			t.Errorf("backoff(%d) = %s, want %s", attempts, got, want)
+		}
+	}
+}
@@ -2,8 +2,10 @@ package auth
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"net/http"
+	"strings"
 
 	"github.com/gorilla/sessions"
 	"github.com/hitushen/portnotepro/internal/store"
@@ -13,12 +15,12 @@ const sessionName = "portnote_auth"
 
 // Manager 负责处理登录会话。
 type Manager struct {
-	store  *store.Store
+	store  store.Storage
 	cookie sessions.Store
 }
 
 // NewManager 使用提供的会话密钥创建 Manager。
-func NewManager(store *store.Store, sessionKey []byte) *Manager {
+func NewManager(store store.Storage, sessionKey []byte) *Manager {
 	cookieStore := sessions.NewCookieStore(sessionKey)
 	cookieStore.Options = &sessions.Options{
 		Path:     "/",
@@ -51,8 +53,11 @@ func (m *Manager) Logout(w http.ResponseWriter, r *http.Request) error {
 	return session.Save(r, w)
 }
 
-// RequireUser 提取当前登录用户的 ID。
+// RequireUser 提取当前登录用户的 ID，API 令牌认证的请求从上下文读取。
 func (m *Manager) RequireUser(w http.ResponseWriter, r *http.Request) (int64, error) {
+	if userID, ok := UserFromContext(r.Context()); ok {
+		return userID, nil
+	}
 	session, err := m.cookie.Get(r, sessionName)
 	if err != nil {
 		return 0, err
@@ -65,15 +70,43 @@ func (m *Manager) RequireUser(w http.ResponseWriter, r *http.Request) (int64, er
 	return userID, nil
 }
 
-// Middleware 确保请求具备已登录用户。
+// Middleware 确保请求具备已登录用户。带 Bearer 令牌的请求按 API 令牌认证，失败时返回 401 而不是跳转登录页。
 func (m *Manager) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token, ok := BearerToken(r); ok {
+			user, err := m.store.UserByAPIToken(r.Context(), token)
+			if err != nil {
+				status := http.StatusUnauthorized
+				if !errors.Is(err, sql.ErrNoRows) {
+					status = http.StatusInternalServerError
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(status)
+				_, _ = w.Write([]byte(`{"error":"invalid api token"}`))
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(ContextWithUser(r.Context(), user.ID)))
+			return
+		}
 		session, err := m.cookie.Get(r, sessionName)
 		if err != nil {
 			http.Redirect(w, r, "/login", http.StatusFound)
 			return
 		}
-		if _, ok := session.Values["user_id"]; !ok {
+		userID := toInt64(session.Values["user_id"])
+		if userID == 0 {
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return
+		}
+		// 用户被停用或删除后，已有会话随即失效。
+		active, err := m.store.UserActive(r.Context(), userID)
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if !active {
+			session.Options.MaxAge = -1
+			_ = session.Save(r, w)
 			http.Redirect(w, r, "/login", http.StatusFound)
 			return
 		}
@@ -81,6 +114,16 @@ func (m *Manager) Middleware(next http.Handler) http.Handler {
 	})
 }
 
+// BearerToken 返回 Authorization 头中的 Bearer 令牌。
+func BearerToken(r *http.Request) (string, bool) {
+	const prefix = "Bearer "
+	header := r.Header.Get("Authorization")
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", false
+	}
+	return strings.TrimSpace(header[len(prefix):]), true
+}
+
 // Username 获取用户名以供界面展示。
 func (m *Manager) Username(r *http.Request) string {
 	session, err := m.cookie.Get(r, sessionName)
@@ -0,0 +1,291 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hitushen/portnotepro/internal/store"
+)
+
+// ErrNotFound 表示请求的备份文件不存在或名称不合法。
+var ErrNotFound = errors.New("backup not found")
+
+const (
+	filePrefix = "portnote-"
+	fileSuffix = ".db"
+	// timeLayout 精确到毫秒且定长，文件名按字典序即按时间排序。
+	timeLayout = "20060102-150405.000"
+)
+
+// Info 描述备份目录中的一个快照文件。
+type Info struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Manager 负责在备份目录中生成快照、按保留份数清理旧文件，并可定时执行。
+type Manager struct {
+	store store.Storage
+	dir   string
+	keep  int
+
+	mu           sync.Mutex
+	stopCh       chan struct{}
+	wg           sync.WaitGroup
+	shutdownOnce sync.Once
+}
+
+// NewManager 创建备份管理器，keep <= 0 表示不清理旧备份。
+func NewManager(st store.Storage, dir string, keep int) *Manager {
+	return &Manager{
+		store:  st,
+		dir:    dir,
+		keep:   keep,
+		stopCh: make(chan struct{}),
+	}
+}
+
+// Dir 返回备份目录。
+func (m *Manager) Dir() string {
+	return m.dir
+}
+
+// Create 生成一份新的快照，先写入临时文件再改名，避免列表中出现不完整的备份。
+func (m *Manager) Create(ctx context.Context) (*Info, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create backup dir: %w", err)
+	}
+	final := uniquePath(filepath.Join(m.dir, filePrefix+time.Now().UTC().Format(timeLayout)), fileSuffix)
+	name := filepath.Base(final)
+	partial := final + ".partial"
+	_ = os.Remove(partial)
+
+	if err := m.store.Backup(ctx, partial); err != nil {
+		_ = os.Remove(partial)
+		return nil, err
+	}
+	if err := os.Rename(partial, final); err != nil {
+		_ = os.Remove(partial)
+		return nil, err
+	}
+	info, err := os.Stat(final)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.prune(); err != nil {
+		log.Printf("[backup] prune failed: %v", err)
+	}
+	return &Info{Name: name, Size: info.Size(), CreatedAt: info.ModTime().UTC()}, nil
+}
+
+// List 按时间倒序返回备份目录中的快照，目录不存在时返回空列表。
+func (m *Manager) List() ([]Info, error) {
+	entries, err := os.ReadDir(m.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Info{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	list := []Info{}
+	for _, entry := range entries {
+		if entry.IsDir() || !validName(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		list = append(list, Info{Name: entry.Name(), Size: info.Size(), CreatedAt: info.ModTime().UTC()})
+	}
+	// 文件名含 UTC 时间戳与同一秒内的序号，据此倒序即为生成顺序倒序。
+	sort.Slice(list, func(i, j int) bool {
+		ti, ni := nameOrder(list[i].Name)
+		tj, nj := nameOrder(list[j].Name)
+		if ti != tj {
+			return ti > tj
+		}
+		return ni > nj
+	})
+	return list, nil
+}
+
+// Path 返回指定备份的完整路径，名称不合法或文件不存在时返回 ErrNotFound。
+func (m *Manager) Path(name string) (string, error) {
+	if !validName(name) {
+		return "", ErrNotFound
+	}
+	full := filepath.Join(m.dir, name)
+	if !fileExists(full) {
+		return "", ErrNotFound
+	}
+	return full, nil
+}
+
+// Start 按 interval 周期执行备份，interval <= 0 时不启动。
+func (m *Manager) Start(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	if m.store.Dialect() != store.DialectSQLite {
+		log.Printf("[backup] scheduled backups disabled: %v", store.ErrBackupUnsupported)
+		return
+	}
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stopCh:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+				info, err := m.Create(ctx)
+				cancel()
+				if err != nil {
+					log.Printf("[backup] scheduled backup failed: %v", err)
+					continue
+				}
+				log.Printf("[backup] created %s (%d bytes)", info.Name, info.Size)
+			}
+		}
+	}()
+}
+
+// Close 停止定时备份。
+func (m *Manager) Close() {
+	m.shutdownOnce.Do(func() {
+		close(m.stopCh)
+	})
+	m.wg.Wait()
+}
+
+func (m *Manager) prune() error {
+	if m.keep <= 0 {
+		return nil
+	}
+	list, err := m.List()
+	if err != nil {
+		return err
+	}
+	for _, info := range list[min(m.keep, len(list)):] {
+		if err := os.Remove(filepath.Join(m.dir, info.Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Restore 校验备份文件后替换 dbPath，原数据库改名保留并返回其路径（原文件不存在时为空）。
+// 调用前必须停止服务，否则运行中的进程仍会持有旧文件。
+func Restore(ctx context.Context, src, dbPath string) (string, error) {
+	if _, err := store.InspectBackup(ctx, src); err != nil {
+		return "", err
+	}
+
+	tmp := dbPath + ".restoring"
+	if err := copyFile(src, tmp); err != nil {
+		_ = os.Remove(tmp)
+		return "", fmt.Errorf("copy backup: %w", err)
+	}
+
+	var previous string
+	if fileExists(dbPath) {
+		previous = uniquePath(dbPath+".pre-restore-"+time.Now().UTC().Format("20060102-150405"), "")
+		if err := os.Rename(dbPath, previous); err != nil {
+			_ = os.Remove(tmp)
+			return "", fmt.Errorf("keep current database: %w", err)
+		}
+	}
+	// 旧库残留的 WAL/SHM 文件会与新库冲突，一并移走。
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if fileExists(dbPath + suffix) {
+			if previous != "" {
+				_ = os.Rename(dbPath+suffix, previous+suffix)
+			} else {
+				_ = os.Remove(dbPath + suffix)
+			}
+		}
+	}
+	if err := os.Rename(tmp, dbPath); err != nil {
+		if previous != "" {
+			_ = os.Rename(previous, dbPath)
+		}
+		_ = os.Remove(tmp)
+		return "", fmt.Errorf("swap database: %w", err)
+	}
+	return previous, nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// uniquePath 返回 base+suffix，已存在时依次尝试 base-1+suffix、base-2+suffix……
+func uniquePath(base, suffix string) string {
+	path := base + suffix
+	for i := 1; fileExists(path) || fileExists(path+".partial"); i++ {
+		path = fmt.Sprintf("%s-%d%s", base, i, suffix)
+	}
+	return path
+}
+
+// nameOrder 拆出备份文件名中的时间戳与同一毫秒内的序号，例如 portnote-20240101-120000.000-2.db 为 ("20240101-120000.000", 2)。
+func nameOrder(name string) (string, int) {
+	stamp := strings.TrimSuffix(strings.TrimPrefix(name, filePrefix), fileSuffix)
+	if len(stamp) <= len(timeLayout) {
+		return stamp, 0
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(stamp[len(timeLayout):], "-"))
+	if err != nil {
+		return stamp, 0
+	}
+	return stamp[:len(timeLayout)], n
+}
+
+func validName(name string) bool {
+	return strings.HasPrefix(name, filePrefix) && strings.HasSuffix(name, fileSuffix) &&
+		filepath.Base(name) == name && !strings.Contains(name, "..")
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -1,82 +1,231 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
-	"strconv"
+	"reflect"
+	"sort"
 	"strings"
 	"time"
 )
 
+// DefaultPath 为未指定 --config 与 PORTNOTE_CONFIG 时读取的配置文件，不存在时只使用默认值与环境变量。
+const DefaultPath = "config.yaml"
+
 // Config 汇总服务运行时所需的全部配置。
 type Config struct {
-	Addr            string
-	AdminUser       string
-	AdminPassword   string
-	SessionKey      []byte
-	CSRFKey         []byte
-	DBPath          string
+	Addr          string
+	AdminUser     string
+	AdminPassword string
+	SessionKey    []byte
+	CSRFKey       []byte
+	DBPath        string
+	// DatabaseURL 为 PostgreSQL 连接串（postgres://...），设置后优先于 DBPath。
+	DatabaseURL     string
 	ScanTimeout     time.Duration
 	ScanConcurrency int
+	// ScanRate 为所有并发扫描共享的发包速率预算（每秒），ScanPerHost 为单台主机同时执行的扫描任务数上限。
+	ScanRate    int
+	ScanPerHost int
+	// ScanRetries 为扫描任务最多执行的次数（含首次），ScanRetryBackoff 为临时性失败后首次重试的等待时长（此后每次翻倍，最长 30 分钟）。
+	ScanRetries      int
+	ScanRetryBackoff time.Duration
+	// ScanMissThreshold 为开放端口连续多少次扫描未被发现后才改为关闭 / 过滤 / 不可达。
+	ScanMissThreshold int
+	// AgentLease 为远程 agent 领取扫描任务的租约时长，agent 超过租约未续约时任务重新排队。
+	AgentLease time.Duration
+	// InstanceID 标识本服务端实例，记录在本地执行的扫描任务上，为空时使用主机名；共用数据库的实例必须各不相同。
+	InstanceID string
+	// DNSResolver 为可选的自定义 DNS 服务器（host:port），为空时使用系统解析器。
+	DNSResolver string
+	// BackupDir 为备份文件目录；BackupInterval 大于 0 时定时备份，BackupKeep 为保留份数（<=0 不清理）。
+	BackupDir      string
+	BackupInterval time.Duration
+	BackupKeep     int
+	// ScanAllow 非空时启用白名单模式，仅允许扫描这些网段内的地址。
+	ScanAllow []string
+	// ScanDeny 为始终禁止扫描的网段、IP 或主机名（支持 *.example.com）。
+	ScanDeny []string
+	// AlertMaxAttempts 为单条告警的最大投递次数，AlertTimeout 为单次请求超时，
+	// AlertBackoff 为首次重试间隔（此后每次翻倍，最长 1 小时）。
+	AlertMaxAttempts int
+	AlertTimeout     time.Duration
+	AlertBackoff     time.Duration
+	// AlertTargets 为配置文件中声明的通知目标。
+	AlertTargets []AlertTarget
+	// SMTPHost 非空时启用邮件通知；SMTPTLS 为 starttls、tls 或 none。
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUser     string
+	SMTPPassword string
+	SMTPFrom     string
+	SMTPTLS      string
+	// MetricsToken 非空时 /metrics 也接受 Authorization: Bearer <token>，否则需要登录会话或个人 API 令牌。
+	MetricsToken string
+
+	// File 为实际读取的配置文件路径，未使用配置文件时为空。
+	File string
+	// sources 记录每个配置项的来源：default、配置文件路径、env NAME 或 flag。
+	sources map[string]string
 }
 
-// Load 从环境变量构建配置，并提供合理的默认值。
+// AlertTarget 为配置文件中声明的通知目标。启动与重新加载时按名称创建或更新同名目标，
+// 从文件中删除的目标不会被删除，需在界面中处理。
+type AlertTarget struct {
+	Name        string
+	Kind        string
+	URL         string
+	Secret      string
+	MinSeverity string
+	Enabled     bool
+}
+
+// Options 指定配置文件与命令行覆盖项。
+type Options struct {
+	// Path 为配置文件路径，支持 .yaml / .yml / .toml；为空时依次使用 PORTNOTE_CONFIG 与 DefaultPath。
+	Path string
+	// Overrides 为命令行给出的配置项，键为 scan.concurrency 这样的配置键，优先于环境变量。
+	Overrides map[string]string
+}
+
+// Load 从默认位置的配置文件与环境变量构建配置。
 func Load() (*Config, error) {
-	cfg := &Config{
-		Addr:            getenv("PORTNOTE_HTTP_ADDR", ":8080"),
-		AdminUser:       getenv("PORTNOTE_ADMIN_USER", "admin"),
-		AdminPassword:   getenv("PORTNOTE_ADMIN_PASS", "admin123"),
-		SessionKey:      []byte(getenv("PORTNOTE_SESSION_KEY", "0123456789abcdef0123456789abcdef")),
-		CSRFKey:         []byte(getenv("PORTNOTE_CSRF_KEY", "abcdef0123456789abcdef0123456789")),
-		DBPath:          getenv("PORTNOTE_DB_PATH", "data/portnote.db"),
-		ScanTimeout:     durationEnv("PORTNOTE_SCAN_TIMEOUT", 2*time.Second),
-		ScanConcurrency: intEnv("PORTNOTE_SCAN_CONCURRENCY", 50),
+	return LoadWith(Options{})
+}
+
+// LoadWith 按 默认值 < 配置文件 < 环境变量 < 命令行 的优先级构建配置并校验。
+// 存在问题时返回 *ValidationError，列出所有有问题的配置项。
+func LoadWith(opts Options) (*Config, error) {
+	cfg := &Config{sources: make(map[string]string, len(fields))}
+	var problems []FieldError
+	for _, f := range fields {
+		if f.def != "" {
+			if err := setValue(f.ptr(cfg), f.def); err != nil {
+				panic(fmt.Sprintf("config: bad default for %s: %v", f.key, err))
+			}
+		}
+		cfg.sources[f.key] = "default"
 	}
 
-	if len(cfg.SessionKey) < 32 {
-		return nil, fmt.Errorf("session key must be at least 32 bytes, got %d", len(cfg.SessionKey))
+	path, explicit := opts.Path, true
+	if path == "" {
+		path = strings.TrimSpace(os.Getenv("PORTNOTE_CONFIG"))
 	}
-	if len(cfg.CSRFKey) < 32 {
-		return nil, fmt.Errorf("csrf key must be at least 32 bytes, got %d", len(cfg.CSRFKey))
+	if path == "" {
+		path, explicit = DefaultPath, false
 	}
-	if cfg.AdminUser == "" || cfg.AdminPassword == "" {
-		return nil, fmt.Errorf("admin credentials must not be empty")
+	values, err := readFile(path)
+	switch {
+	case err == nil:
+		cfg.File = path
+		problems = append(problems, cfg.applyFile(values, "", path)...)
+	case !explicit && errors.Is(err, fs.ErrNotExist):
+	default:
+		return nil, fmt.Errorf("config file: %w", err)
 	}
-	if cfg.ScanConcurrency <= 0 {
-		return nil, fmt.Errorf("scan concurrency must be positive")
+
+	for _, f := range fields {
+		if f.env == "" {
+			continue
+		}
+		raw := strings.TrimSpace(os.Getenv(f.env))
+		if raw == "" {
+			continue
+		}
+		source := "env " + f.env
+		cfg.sources[f.key] = source
+		if err := setValue(f.ptr(cfg), raw); err != nil {
+			problems = append(problems, f.problem(source, err, raw))
+		}
 	}
 
+	keys := make([]string, 0, len(opts.Overrides))
+	for key := range opts.Overrides {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		raw := opts.Overrides[key]
+		f, ok := lookupField(key)
+		if !ok || f.fileOnly {
+			problems = append(problems, FieldError{Key: key, Source: "flag", Msg: "unknown setting"})
+			continue
+		}
+		cfg.sources[f.key] = "flag"
+		if err := setValue(f.ptr(cfg), raw); err != nil {
+			problems = append(problems, f.problem("flag", err, raw))
+		}
+	}
+
+	cfg.normalize()
+	problems = append(problems, cfg.validate()...)
+	if len(problems) > 0 {
+		return nil, &ValidationError{Errors: problems}
+	}
 	return cfg, nil
 }
 
-func getenv(key, fallback string) string {
-	value := strings.TrimSpace(os.Getenv(key))
-	if value == "" {
-		return fallback
+// DSN 返回存储连接串：设置了 DatabaseURL 时使用 PostgreSQL，否则为 SQLite 文件路径。
+func (c *Config) DSN() string {
+	if c.DatabaseURL != "" {
+		return c.DatabaseURL
 	}
-	return value
+	return c.DBPath
 }
 
-func durationEnv(key string, fallback time.Duration) time.Duration {
-	val := strings.TrimSpace(os.Getenv(key))
-	if val == "" {
-		return fallback
+// Setting 为一个配置项的生效值，用于 check-config 输出。
+type Setting struct {
+	Key    string
+	Env    string
+	Value  string
+	Source string
+	// Reloadable 表示收到 SIGHUP 重新加载配置时无需重启即可生效。
+	Reloadable bool
+}
+
+// Settings 按配置键顺序返回全部配置项，密钥类取值以 ****** 显示。
+func (c *Config) Settings() []Setting {
+	list := make([]Setting, 0, len(fields))
+	for _, f := range fields {
+		value := formatValue(f.ptr(c))
+		if f.secret && value != "" {
+			value = "******"
+		}
+		list = append(list, Setting{Key: f.key, Env: f.env, Value: value, Source: c.sources[f.key], Reloadable: f.reload})
 	}
-	parsed, err := time.ParseDuration(val)
-	if err != nil {
-		return fallback
+	return list
+}
+
+// Changes 比较两份配置，分别返回可热加载与需要重启才能生效的已变化配置键。
+func Changes(old, next *Config) (reloadable, restart []string) {
+	for _, f := range fields {
+		if reflect.DeepEqual(reflect.ValueOf(f.ptr(old)).Elem().Interface(), reflect.ValueOf(f.ptr(next)).Elem().Interface()) {
+			continue
+		}
+		if f.reload {
+			reloadable = append(reloadable, f.key)
+		} else {
+			restart = append(restart, f.key)
+		}
 	}
-	return parsed
+	return reloadable, restart
 }
 
-func intEnv(key string, fallback int) int {
-	val := strings.TrimSpace(os.Getenv(key))
-	if val == "" {
-		return fallback
+// WithReloadable 返回 c 的副本，其中可热加载的配置项取自 next，其余保持不变。
+func (c *Config) WithReloadable(next *Config) *Config {
+	cp := *c
+	cp.sources = make(map[string]string, len(c.sources))
+	for k, v := range c.sources {
+		cp.sources[k] = v
 	}
-	n, err := strconv.Atoi(val)
-	if err != nil {
-		return fallback
+	for _, f := range fields {
+		if !f.reload {
+			continue
+		}
+		reflect.ValueOf(f.ptr(&cp)).Elem().Set(reflect.ValueOf(f.ptr(next)).Elem())
+		cp.sources[f.key] = next.sources[f.key]
 	}
-	return n
+	return &cp
 }
@@ -0,0 +1,149 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// field 描述一个配置项：key 为配置文件中的键（按 . 分节），env 为对应的环境变量，def 为文本形式的默认值。
+// reload 为 true 的配置项在 SIGHUP 时重新加载，secret 的取值不在输出中显示，fileOnly 只能在配置文件中设置。
+type field struct {
+	key      string
+	env      string
+	def      string
+	reload   bool
+	secret   bool
+	fileOnly bool
+	ptr      func(c *Config) interface{}
+}
+
+var fields = []field{
+	{key: "http.addr", env: "PORTNOTE_HTTP_ADDR", def: ":8080", ptr: func(c *Config) interface{} { return &c.Addr }},
+	{key: "admin.user", env: "PORTNOTE_ADMIN_USER", def: "admin", ptr: func(c *Config) interface{} { return &c.AdminUser }},
+	{key: "admin.password", env: "PORTNOTE_ADMIN_PASS", def: "admin123", secret: true, ptr: func(c *Config) interface{} { return &c.AdminPassword }},
+	{key: "security.session_key", env: "PORTNOTE_SESSION_KEY", def: "0123456789abcdef0123456789abcdef", secret: true, ptr: func(c *Config) interface{} { return &c.SessionKey }},
+	{key: "security.csrf_key", env: "PORTNOTE_CSRF_KEY", def: "abcdef0123456789abcdef0123456789", secret: true, ptr: func(c *Config) interface{} { return &c.CSRFKey }},
+	{key: "database.path", env: "PORTNOTE_DB_PATH", def: "data/portnote.db", ptr: func(c *Config) interface{} { return &c.DBPath }},
+	{key: "database.url", env: "PORTNOTE_DATABASE_URL", secret: true, ptr: func(c *Config) interface{} { return &c.DatabaseURL }},
+
+	{key: "scan.timeout", env: "PORTNOTE_SCAN_TIMEOUT", def: "2s", ptr: func(c *Config) interface{} { return &c.ScanTimeout }},
+	{key: "scan.concurrency", env: "PORTNOTE_SCAN_CONCURRENCY", def: "50", reload: true, ptr: func(c *Config) interface{} { return &c.ScanConcurrency }},
+	{key: "scan.rate", env: "PORTNOTE_SCAN_RATE", def: "10000", reload: true, ptr: func(c *Config) interface{} { return &c.ScanRate }},
+	{key: "scan.per_host", env: "PORTNOTE_SCAN_PER_HOST", def: "1", reload: true, ptr: func(c *Config) interface{} { return &c.ScanPerHost }},
+	{key: "scan.retries", env: "PORTNOTE_SCAN_RETRIES", def: "3", reload: true, ptr: func(c *Config) interface{} { return &c.ScanRetries }},
+	{key: "scan.retry_backoff", env: "PORTNOTE_SCAN_RETRY_BACKOFF", def: "30s", reload: true, ptr: func(c *Config) interface{} { return &c.ScanRetryBackoff }},
+	{key: "scan.miss_threshold", env: "PORTNOTE_SCAN_MISS_THRESHOLD", def: "2", reload: true, ptr: func(c *Config) interface{} { return &c.ScanMissThreshold }},
+	{key: "scan.allow", env: "PORTNOTE_SCAN_ALLOW", reload: true, ptr: func(c *Config) interface{} { return &c.ScanAllow }},
+	{key: "scan.deny", env: "PORTNOTE_SCAN_DENY", reload: true, ptr: func(c *Config) interface{} { return &c.ScanDeny }},
+	{key: "scan.dns_resolver", env: "PORTNOTE_DNS_RESOLVER", ptr: func(c *Config) interface{} { return &c.DNSResolver }},
+	{key: "agent.lease", env: "PORTNOTE_AGENT_LEASE", def: "2m", reload: true, ptr: func(c *Config) interface{} { return &c.AgentLease }},
+	{key: "scan.instance_id", env: "PORTNOTE_INSTANCE_ID", ptr: func(c *Config) interface{} { return &c.InstanceID }},
+
+	{key: "backup.dir", env: "PORTNOTE_BACKUP_DIR", def: "data/backups", ptr: func(c *Config) interface{} { return &c.BackupDir }},
+	{key: "backup.interval", env: "PORTNOTE_BACKUP_INTERVAL", def: "0s", ptr: func(c *Config) interface{} { return &c.BackupInterval }},
+	{key: "backup.keep", env: "PORTNOTE_BACKUP_KEEP", def: "7", ptr: func(c *Config) interface{} { return &c.BackupKeep }},
+
+	{key: "alert.max_attempts", env: "PORTNOTE_ALERT_MAX_ATTEMPTS", def: "6", reload: true, ptr: func(c *Config) interface{} { return &c.AlertMaxAttempts }},
+	{key: "alert.timeout", env: "PORTNOTE_ALERT_TIMEOUT", def: "10s", ptr: func(c *Config) interface{} { return &c.AlertTimeout }},
+	{key: "alert.backoff", env: "PORTNOTE_ALERT_BACKOFF", def: "30s", reload: true, ptr: func(c *Config) interface{} { return &c.AlertBackoff }},
+	{key: "alert.targets", reload: true, fileOnly: true, ptr: func(c *Config) interface{} { return &c.AlertTargets }},
+
+	{key: "smtp.host", env: "PORTNOTE_SMTP_HOST", reload: true, ptr: func(c *Config) interface{} { return &c.SMTPHost }},
+	{key: "smtp.port", env: "PORTNOTE_SMTP_PORT", def: "587", reload: true, ptr: func(c *Config) interface{} { return &c.SMTPPort }},
+	{key: "smtp.user", env: "PORTNOTE_SMTP_USER", reload: true, ptr: func(c *Config) interface{} { return &c.SMTPUser }},
+	{key: "smtp.password", env: "PORTNOTE_SMTP_PASS", reload: true, secret: true, ptr: func(c *Config) interface{} { return &c.SMTPPassword }},
+	{key: "smtp.from", env: "PORTNOTE_SMTP_FROM", reload: true, ptr: func(c *Config) interface{} { return &c.SMTPFrom }},
+	{key: "smtp.tls", env: "PORTNOTE_SMTP_TLS", def: "starttls", reload: true, ptr: func(c *Config) interface{} { return &c.SMTPTLS }},
+
+	{key: "metrics.token", env: "PORTNOTE_METRICS_TOKEN", reload: true, secret: true, ptr: func(c *Config) interface{} { return &c.MetricsToken }},
+}
+
+func lookupField(key string) (field, bool) {
+	for _, f := range fields {
+		if f.key == key {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+// isSection 判断 key 是否为若干配置项的上级分节（如 scan）。
+func isSection(key string) bool {
+	for _, f := range fields {
+		if strings.HasPrefix(f.key, key+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// problem 生成取值无法解析时的错误，密钥类配置不回显原值。
+func (f field) problem(source string, err error, raw string) FieldError {
+	msg := err.Error()
+	if !f.secret {
+		msg = fmt.Sprintf("%s, got %q", msg, raw)
+	}
+	return FieldError{Key: f.key, Env: f.env, Source: source, Msg: msg}
+}
+
+// setValue 把文本形式的取值写入配置字段；列表以逗号分隔。
+func setValue(p interface{}, raw string) error {
+	raw = strings.TrimSpace(raw)
+	switch v := p.(type) {
+	case *string:
+		*v = raw
+	case *[]byte:
+		*v = []byte(raw)
+	case *int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("must be an integer")
+		}
+		*v = n
+	case *time.Duration:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("must be a duration such as 30s or 5m")
+		}
+		*v = d
+	case *[]string:
+		*v = splitList(raw)
+	default:
+		return fmt.Errorf("can only be set in the config file")
+	}
+	return nil
+}
+
+func formatValue(p interface{}) string {
+	switch v := p.(type) {
+	case *string:
+		return *v
+	case *[]byte:
+		return string(*v)
+	case *int:
+		return strconv.Itoa(*v)
+	case *time.Duration:
+		return v.String()
+	case *[]string:
+		return strings.Join(*v, ",")
+	case *[]AlertTarget:
+		names := make([]string, len(*v))
+		for i, t := range *v {
+			names[i] = t.Name
+		}
+		return strings.Join(names, ",")
+	}
+	return fmt.Sprint(p)
+}
+
+func splitList(raw string) []string {
+	var result []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// readFile 按扩展名解析 YAML（.yaml、.yml）或 TOML（.toml）配置文件。
+func readFile(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &values); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	case ".toml":
+		if _, err := toml.Decode(string(data), &values); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("%s: unsupported config format, use .yaml, .yml or .toml", path)
+	}
+	return values, nil
+}
+
+// applyFile 把配置文件中的取值写入配置，返回未知键与无法解析的取值。
+func (c *Config) applyFile(values map[string]interface{}, prefix, source string) []FieldError {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var problems []FieldError
+	for _, k := range keys {
+		key := prefix + k
+		value := values[k]
+		f, ok := lookupField(key)
+		if !ok {
+			if sub, isMap := value.(map[string]interface{}); isMap && isSection(key) {
+				problems = append(problems, c.applyFile(sub, key+".", source)...)
+				continue
+			}
+			problems = append(problems, FieldError{Key: key, Source: source, Msg: "unknown setting"})
+			continue
+		}
+		c.sources[f.key] = source
+		if targets, isTargets := f.ptr(c).(*[]AlertTarget); isTargets {
+			problems = append(problems, decodeTargets(value, targets, f.key, source)...)
+			continue
+		}
+		raw, err := fileScalar(value)
+		if err != nil {
+			problems = append(problems, FieldError{Key: f.key, Env: f.env, Source: source, Msg: err.Error()})
+			continue
+		}
+		if err := setValue(f.ptr(c), raw); err != nil {
+			problems = append(problems, f.problem(source, err, raw))
+		}
+	}
+	return problems
+}
+
+// fileScalar 把文件中的单个取值转为文本，列表转为逗号分隔的形式。
+func fileScalar(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case int, int64, uint64, float64, bool:
+		return fmt.Sprint(v), nil
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			s, err := fileScalar(item)
+			if err != nil || strings.Contains(s, ",") {
+				return "", fmt.Errorf("must be a list of plain values")
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	case map[string]interface{}:
+		return "", fmt.Errorf("must be a single value, not a table")
+	}
+	return "", fmt.Errorf("unsupported value %v", value)
+}
+
+// decodeTargets 解析 alert.targets 列表，每项为包含 name、kind、url 等字段的表。
+func decodeTargets(value interface{}, out *[]AlertTarget, key, source string) []FieldError {
+	var items []map[string]interface{}
+	switch v := value.(type) {
+	case nil:
+	case []map[string]interface{}:
+		items = v
+	case []interface{}:
+		for i, item := range v {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				return []FieldError{{Key: fmt.Sprintf("%s[%d]", key, i), Source: source, Msg: "must be a table with name, kind and url"}}
+			}
+			items = append(items, m)
+		}
+	default:
+		return []FieldError{{Key: key, Source: source, Msg: "must be a list of targets"}}
+	}
+
+	var problems []FieldError
+	list := make([]AlertTarget, 0, len(items))
+	for i, item := range items {
+		t := AlertTarget{Enabled: true}
+		names := make([]string, 0, len(item))
+		for name := range item {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			itemKey := fmt.Sprintf("%s[%d].%s", key, i, name)
+			if name == "enabled" {
+				enabled, ok := item[name].(bool)
+				if !ok {
+					problems = append(problems, FieldError{Key: itemKey, Source: source, Msg: "must be true or false"})
+				}
+				t.Enabled = enabled
+				continue
+			}
+			var dst *string
+			switch name {
+			case "name":
+				dst = &t.Name
+			case "kind":
+				dst = &t.Kind
+			case "url":
+				dst = &t.URL
+			case "secret":
+				dst = &t.Secret
+			case "min_severity":
+				dst = &t.MinSeverity
+			default:
+				problems = append(problems, FieldError{Key: itemKey, Source: source, Msg: "unknown setting"})
+				continue
+			}
+			s, isString := item[name].(string)
+			if !isString && item[name] != nil {
+				problems = append(problems, FieldError{Key: itemKey, Source: source, Msg: "must be a string"})
+				continue
+			}
+			*dst = s
+		}
+		list = append(list, t)
+	}
+	*out = list
+	return problems
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/hitushen/portnotepro/internal/alerting"
+	"github.com/hitushen/portnotepro/internal/models"
+	"github.com/hitushen/portnotepro/internal/targets"
+)
+
+// FieldError 描述一个有问题的配置项，Source 为取值来源（配置文件路径、env NAME、flag 或 default）。
+type FieldError struct {
+	Key    string
+	Env    string
+	Source string
+	Msg    string
+}
+
+func (e FieldError) String() string {
+	where := e.Source
+	if e.Env != "" && !strings.HasPrefix(e.Source, "env ") {
+		where += ", env " + e.Env
+	}
+	return fmt.Sprintf("%s (%s): %s", e.Key, where, e.Msg)
+}
+
+// ValidationError 汇总配置中的全部问题，而不是遇到第一个就返回。
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		lines[i] = "  " + fe.String()
+	}
+	return "invalid configuration:\n" + strings.Join(lines, "\n")
+}
+
+// normalize 统一取值形式：SMTP TLS 转小写，DNS 服务器未写端口时默认使用 53。
+func (c *Config) normalize() {
+	c.SMTPTLS = strings.ToLower(c.SMTPTLS)
+	if c.DNSResolver != "" {
+		if _, _, err := net.SplitHostPort(c.DNSResolver); err != nil {
+			c.DNSResolver = net.JoinHostPort(strings.Trim(c.DNSResolver, "[]"), "53")
+		}
+	}
+}
+
+// validate 检查取值范围与相互依赖，返回所有有问题的配置项。
+func (c *Config) validate() []FieldError {
+	var problems []FieldError
+	add := func(key, format string, args ...interface{}) {
+		f, _ := lookupField(key)
+		problems = append(problems, FieldError{Key: key, Env: f.env, Source: c.sources[key], Msg: fmt.Sprintf(format, args...)})
+	}
+
+	if len(c.SessionKey) < 32 {
+		add("security.session_key", "must be at least 32 bytes, got %d", len(c.SessionKey))
+	}
+	if len(c.CSRFKey) < 32 {
+		add("security.csrf_key", "must be at least 32 bytes, got %d", len(c.CSRFKey))
+	}
+	if c.AdminUser == "" {
+		add("admin.user", "must not be empty")
+	}
+	if c.AdminPassword == "" {
+		add("admin.password", "must not be empty")
+	}
+	if c.DatabaseURL != "" {
+		lower := strings.ToLower(c.DatabaseURL)
+		if !strings.HasPrefix(lower, "postgres://") && !strings.HasPrefix(lower, "postgresql://") {
+			add("database.url", "must start with postgres:// or postgresql://")
+		}
+	}
+
+	positive := []struct {
+		key string
+		n   int
+	}{
+		{"scan.concurrency", c.ScanConcurrency},
+		{"scan.rate", c.ScanRate},
+		{"scan.per_host", c.ScanPerHost},
+		{"scan.retries", c.ScanRetries},
+		{"scan.miss_threshold", c.ScanMissThreshold},
+		{"alert.max_attempts", c.AlertMaxAttempts},
+	}
+	for _, p := range positive {
+		if p.n <= 0 {
+			add(p.key, "must be positive, got %d", p.n)
+		}
+	}
+	durations := []struct {
+		key string
+		d   time.Duration
+	}{
+		{"scan.timeout", c.ScanTimeout},
+		{"scan.retry_backoff", c.ScanRetryBackoff},
+		{"alert.timeout", c.AlertTimeout},
+		{"alert.backoff", c.AlertBackoff},
+	}
+	for _, p := range durations {
+		if p.d <= 0 {
+			add(p.key, "must be positive, got %s", p.d)
+		}
+	}
+	if c.AgentLease < 30*time.Second {
+		add("agent.lease", "must be at least 30s, got %s", c.AgentLease)
+	}
+	if c.BackupInterval < 0 {
+		add("backup.interval", "must not be negative")
+	}
+
+	switch c.SMTPTLS {
+	case "starttls", "tls", "none":
+	default:
+		add("smtp.tls", "must be starttls, tls or none, got %q", c.SMTPTLS)
+	}
+	if c.SMTPHost != "" {
+		if c.SMTPPort <= 0 || c.SMTPPort > 65535 {
+			add("smtp.port", "must be between 1 and 65535, got %d", c.SMTPPort)
+		}
+		if _, err := mail.ParseAddress(c.SMTPFrom); err != nil {
+			add("smtp.from", "must be an email address when smtp.host is set: %v", err)
+		}
+	}
+
+	if _, err := targets.NewPolicy(c.ScanAllow, nil); err != nil {
+		add("scan.allow", "%v", err)
+	}
+	if _, err := targets.NewPolicy(nil, c.ScanDeny); err != nil {
+		add("scan.deny", "%v", err)
+	}
+
+	seen := make(map[string]bool, len(c.AlertTargets))
+	for i := range c.AlertTargets {
+		t := &c.AlertTargets[i]
+		key := fmt.Sprintf("alert.targets[%d]", i)
+		candidate := models.AlertTarget{Name: t.Name, Kind: t.Kind, URL: t.URL, MinSeverity: t.MinSeverity}
+		if err := alerting.NormalizeTarget(&candidate); err != nil {
+			add("alert.targets", "%s: %v", key, err)
+			continue
+		}
+		t.Name, t.Kind, t.URL, t.MinSeverity = candidate.Name, candidate.Kind, candidate.URL, candidate.MinSeverity
+		if seen[t.Name] {
+			add("alert.targets", "%s: duplicate name %q", key, t.Name)
+		}
+		seen[t.Name] = true
+		if t.Kind == models.TargetEmail && c.SMTPHost == "" {
+			add("alert.targets", "%s: email targets require smtp.host", key)
+		}
+	}
+	return problems
+}
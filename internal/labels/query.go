@@ -0,0 +1,292 @@
+package labels
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// ErrSyntax 表示标签查询语法错误。
+var ErrSyntax = errors.New("invalid label query")
+
+var keyPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.\-/]{0,62}$`)
+
+// reservedKeys 为查询中的关键字与主机、端口查询的内置字段名（不区分大小写）。
+// 查询时这些键名会被解析为运算符或内置字段，同名标签永远无法被筛选，因此不允许创建。
+// 新增 Scope.Fields 字段时需同步加入。
+var reservedKeys = map[string]bool{
+	"and": true, "or": true, "not": true,
+	"name": true, "address": true, "autoscan": true, "scanning": true,
+	"number": true, "status": true, "hidden": true, "fingerprint": true, "service": true, "note": true,
+}
+
+// ValidateKey 校验标签键：字母或数字开头，可包含 _ . - /，最长 63 个字符，且不能是保留字（见 Reserved）。
+func ValidateKey(key string) error {
+	if !keyPattern.MatchString(key) {
+		return fmt.Errorf("invalid label key %q", key)
+	}
+	if Reserved(key) {
+		return fmt.Errorf("label key %q is reserved by the label query syntax", key)
+	}
+	return nil
+}
+
+// Reserved 判断键名是否为查询关键字（AND / OR / NOT）或内置字段名。
+func Reserved(key string) bool {
+	return reservedKeys[strings.ToLower(key)]
+}
+
+// Normalize 清理并校验一组标签，返回去除首尾空白后的副本。
+func Normalize(in map[string]string) (map[string]string, error) {
+	out := make(map[string]string, len(in))
+	for k, v := range in {
+		k = strings.TrimSpace(k)
+		v = strings.TrimSpace(v)
+		if err := ValidateKey(k); err != nil {
+			return nil, err
+		}
+		if len(v) > 256 {
+			return nil, fmt.Errorf("label %q value too long", k)
+		}
+		out[k] = v
+	}
+	return out, nil
+}
+
+// Scope 描述查询编译到 SQL 时的上下文。
+type Scope struct {
+	// Match 为 labels 表中与当前记录关联的条件，例如 "l.port_id = ports.id"。
+	Match string
+	// Fields 将内置字段名映射到列表达式，例如 status -> ports.status。
+	Fields map[string]string
+	// BoolFields 中的字段按 true/false 比较并存储为 0/1。
+	BoolFields map[string]bool
+}
+
+// Compile 将形如 `owner=payments AND (status=open OR exposure!=internal)` 的查询编译为 SQL 条件。
+// 支持 AND / OR / NOT、括号、= 与 != 比较，以及仅写键名时的“存在该标签”判断。
+// 键名命中 Scope.Fields 时比较内置字段，否则比较标签。
+func Compile(query string, scope Scope) (string, []interface{}, error) {
+	tokens, err := tokenize(query)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %v", ErrSyntax, err)
+	}
+	if len(tokens) == 0 {
+		return "", nil, nil
+	}
+	p := &parser{tokens: tokens, scope: scope}
+	sql, err := p.parseOr()
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %v", ErrSyntax, err)
+	}
+	if p.pos < len(p.tokens) {
+		return "", nil, fmt.Errorf("%w: unexpected %q", ErrSyntax, p.tokens[p.pos].text)
+	}
+	return sql, p.args, nil
+}
+
+type tokenKind int
+
+const (
+	tokWord tokenKind = iota
+	tokString
+	tokEq
+	tokNeq
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func tokenize(input string) ([]token, error) {
+	var tokens []token
+	runes := []rune(input)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{tokLParen, "("})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{tokRParen, ")"})
+			i++
+		case r == '=':
+			tokens = append(tokens, token{tokEq, "="})
+			i++
+		case r == '!' && i+1 < len(runes) && runes[i+1] == '=':
+			tokens = append(tokens, token{tokNeq, "!="})
+			i += 2
+		case r == '"' || r == '\'':
+			quote := r
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(runes) && runes[j] != quote; j++ {
+				if runes[j] == '\\' && j+1 < len(runes) {
+					j++
+				}
+				sb.WriteRune(runes[j])
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, token{tokString, sb.String()})
+			i = j + 1
+		default:
+			j := i
+			for j < len(runes) && !unicode.IsSpace(runes[j]) && !strings.ContainsRune(`()="'!`, runes[j]) {
+				j++
+			}
+			if j == i {
+				return nil, fmt.Errorf("unexpected %q", string(r))
+			}
+			tokens = append(tokens, token{tokWord, string(runes[i:j])})
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	scope  Scope
+	args   []interface{}
+}
+
+func (p *parser) peekKeyword(word string) bool {
+	if p.pos >= len(p.tokens) {
+		return false
+	}
+	t := p.tokens[p.pos]
+	return t.kind == tokWord && strings.EqualFold(t.text, word)
+}
+
+func (p *parser) parseOr() (string, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return "", err
+	}
+	for p.peekKeyword("OR") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return "", err
+		}
+		left = "(" + left + " OR " + right + ")"
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (string, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return "", err
+	}
+	for p.peekKeyword("AND") {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return "", err
+		}
+		left = "(" + left + " AND " + right + ")"
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (string, error) {
+	if p.peekKeyword("NOT") {
+		p.pos++
+		inner, err := p.parseUnary()
+		if err != nil {
+			return "", err
+		}
+		return "NOT " + inner, nil
+	}
+	if p.pos < len(p.tokens) && p.tokens[p.pos].kind == tokLParen {
+		p.pos++
+		inner, err := p.parseOr()
+		if err != nil {
+			return "", err
+		}
+		if p.pos >= len(p.tokens) || p.tokens[p.pos].kind != tokRParen {
+			return "", fmt.Errorf("missing )")
+		}
+		p.pos++
+		return "(" + inner + ")", nil
+	}
+	return p.parseCondition()
+}
+
+func (p *parser) parseCondition() (string, error) {
+	if p.pos >= len(p.tokens) {
+		return "", fmt.Errorf("unexpected end of query")
+	}
+	keyTok := p.tokens[p.pos]
+	if keyTok.kind != tokWord && keyTok.kind != tokString {
+		return "", fmt.Errorf("unexpected %q", keyTok.text)
+	}
+	p.pos++
+	key := keyTok.text
+
+	if p.pos >= len(p.tokens) || (p.tokens[p.pos].kind != tokEq && p.tokens[p.pos].kind != tokNeq) {
+		if _, ok := p.scope.Fields[strings.ToLower(key)]; ok {
+			return "", fmt.Errorf("field %q requires a value", key)
+		}
+		if err := ValidateKey(key); err != nil {
+			return "", err
+		}
+		p.args = append(p.args, key)
+		return "EXISTS (SELECT 1 FROM labels l WHERE " + p.scope.Match + " AND l.key = ?)", nil
+	}
+	negate := p.tokens[p.pos].kind == tokNeq
+	p.pos++
+	if p.pos >= len(p.tokens) || (p.tokens[p.pos].kind != tokWord && p.tokens[p.pos].kind != tokString) {
+		return "", fmt.Errorf("missing value for %q", key)
+	}
+	value := p.tokens[p.pos].text
+	p.pos++
+
+	op := "="
+	if negate {
+		op = "!="
+	}
+	if column, ok := p.scope.Fields[strings.ToLower(key)]; ok {
+		if p.scope.BoolFields[strings.ToLower(key)] {
+			b, err := parseBool(value)
+			if err != nil {
+				return "", fmt.Errorf("field %q: %w", key, err)
+			}
+			p.args = append(p.args, b)
+		} else {
+			p.args = append(p.args, value)
+		}
+		return column + " " + op + " ?", nil
+	}
+
+	if err := ValidateKey(key); err != nil {
+		return "", err
+	}
+	p.args = append(p.args, key, value)
+	cond := "EXISTS (SELECT 1 FROM labels l WHERE " + p.scope.Match + " AND l.key = ? AND l.value = ?)"
+	if negate {
+		return "NOT " + cond, nil
+	}
+	return cond, nil
+}
+
+func parseBool(v string) (int, error) {
+	switch strings.ToLower(v) {
+	case "1", "true", "yes":
+		return 1, nil
+	case "0", "false", "no":
+		return 0, nil
+	}
+	return 0, fmt.Errorf("invalid boolean %q", v)
+}
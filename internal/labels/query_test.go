@@ -0,0 +1,94 @@
+package labels
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+var testScope = Scope{
+	Match:      "l.port_id = ports.id",
+	Fields:     map[string]string{"status": "ports.status", "hidden": "ports.hidden"},
+	BoolFields: map[string]bool{"hidden": true},
+}
+
+const (
+	hasKey   = "EXISTS (SELECT 1 FROM labels l WHERE l.port_id = ports.id AND l.key = ?)"
+	hasValue = "EXISTS (SELECT 1 FROM labels l WHERE l.port_id = ports.id AND l.key = ? AND l.value = ?)"
+)
+
+func TestCompile(t *testing.T) {
+	cases := []struct {
+		query string
+		sql   string
+		args  []interface{}
+	}{
+		{"", "", nil},
+		{"   ", "", nil},
+		{"owner=payments", hasValue, []interface{}{"owner", "payments"}},
+		{"owner != payments", "NOT " + hasValue, []interface{}{"owner", "payments"}},
+		{"pci", hasKey, []interface{}{"pci"}},
+		{`owner="team a"`, hasValue, []interface{}{"owner", "team a"}},
+		{`owner='it\'s'`, hasValue, []interface{}{"owner", "it's"}},
+		{`"k8s.io/app"=web`, hasValue, []interface{}{"k8s.io/app", "web"}},
+		{"Status=open", "ports.status = ?", []interface{}{"open"}},
+		{"hidden!=yes", "ports.hidden != ?", []interface{}{1}},
+		// AND 优先于 OR，NOT 只作用于紧随其后的条件。
+		{"a OR b AND c", "(" + hasKey + " OR (" + hasKey + " AND " + hasKey + "))", []interface{}{"a", "b", "c"}},
+		{"a AND b OR c", "((" + hasKey + " AND " + hasKey + ") OR " + hasKey + ")", []interface{}{"a", "b", "c"}},
+		{"NOT a AND b", "(NOT " + hasKey + " AND " + hasKey + ")", []interface{}{"a", "b"}},
+		{"not not a", "NOT NOT " + hasKey, []interface{}{"a"}},
+		{"(a or b) and c", "(((" + hasKey + " OR " + hasKey + ")) AND " + hasKey + ")", []interface{}{"a", "b", "c"}},
+		{"NOT (a=1 OR status=open)", "NOT ((" + hasValue + " OR ports.status = ?))", []interface{}{"a", "1", "open"}},
+	}
+	for _, c := range cases {
+		t.Run(c.query, func(t *testing.T) {
+			sql, args, err := Compile(c.query, testScope)
+			if err != nil {
+				t.Fatalf("Compile(%q): %v", c.query, err)
+			}
+			if sql != c.sql {
+				t.Errorf("Compile(%q) sql\n got %s\nwant %s", c.query, sql, c.sql)
+			}
+			if !reflect.DeepEqual(args, c.args) {
+				t.Errorf("Compile(%q) args = %#v, want %#v", c.query, args, c.args)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, query := range []string{
+		"!",
+		"owner!",
+		`owner="payments`,
+		"owner AND",
+		"OR owner",
+		"owner=",
+		"(owner",
+		"owner)",
+		"()",
+		"status",
+		"hidden=maybe",
+		"-owner=x",
+		"AND",
+	} {
+		if sql, _, err := Compile(query, testScope); !errors.Is(err, ErrSyntax) {
+			t.Errorf("Compile(%q) = %q, %v; want a syntax error", query, sql, err)
+		}
+	}
+}
+
+func TestValidateKey(t *testing.T) {
+	for _, key := range []string{"owner", "k8s.io/app", "team-a", "9lives", "statuses"} {
+		if err := ValidateKey(key); err != nil {
+			t.Errorf("ValidateKey(%q) = %v", key, err)
+		}
+	}
+	// 保留字作为标签键时无法被查询，创建时即拒绝。
+	for _, key := range []string{"", "-x", "a b", "status", "Hidden", "and", "OR", "not", "service", "autoscan"} {
+		if err := ValidateKey(key); err == nil {
+			t.Errorf("ValidateKey(%q) accepted", key)
+		}
+	}
+}
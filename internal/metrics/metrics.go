@@ -0,0 +1,301 @@
+// Package metrics 以 Prometheus 文本格式（0.0.4）输出进程指标，只实现本项目用到的计数器、直方图与采集时计算的仪表。
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets 为请求耗时的默认直方图分桶（秒）。
+var DefBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Default 为进程级注册表，包级指标注册在此，/metrics 输出它。
+var Default = NewRegistry()
+
+// Registry 保存一组指标，按名称排序输出。
+type Registry struct {
+	mu         sync.Mutex
+	collectors map[string]collector
+}
+
+type collector interface {
+	write(ctx context.Context, w *bufio.Writer) error
+}
+
+// NewRegistry 创建空注册表。
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]collector)}
+}
+
+// register 注册指标，同名指标会被替换（便于组件重建后重新注册采集函数）。
+func (r *Registry) register(name string, c collector) {
+	r.mu.Lock()
+	r.collectors[name] = c
+	r.mu.Unlock()
+}
+
+// ServeHTTP 输出全部指标。单个指标采集失败时跳过该指标并记录日志，不影响其他指标。
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	collectors := make(map[string]collector, len(r.collectors))
+	for name, c := range r.collectors {
+		collectors[name] = c
+	}
+	r.mu.Unlock()
+	sort.Strings(names)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	out := bufio.NewWriter(w)
+	for _, name := range names {
+		if err := collectors[name].write(req.Context(), out); err != nil {
+			log.Printf("[metrics] collect %s failed: %v", name, err)
+		}
+	}
+	_ = out.Flush()
+}
+
+// desc 为指标的名称、说明、类型与标签名。
+type desc struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+}
+
+func (d *desc) header(w *bufio.Writer) {
+	w.WriteString("# HELP " + d.name + " " + strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(d.help) + "\n")
+	w.WriteString("# TYPE " + d.name + " " + d.typ + "\n")
+}
+
+// sample 输出一行样本，extra 为附加标签（如直方图的 le）。
+func (d *desc) sample(w *bufio.Writer, suffix string, values []string, extra string, v float64) {
+	w.WriteString(d.name + suffix)
+	if len(d.labels) > 0 || extra != "" {
+		w.WriteByte('{')
+		for i, label := range d.labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(label + `="` + escapeLabel(values[i]) + `"`)
+		}
+		if extra != "" {
+			if len(d.labels) > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(extra)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteString(" " + formatFloat(v) + "\n")
+}
+
+// key 校验标签值个数并返回序列键。
+func (d *desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic("metrics: " + d.name + ": expected " + strconv.Itoa(len(d.labels)) + " label values, got " + strconv.Itoa(len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// Counter 为只增计数器，可带标签。
+type Counter struct {
+	desc
+	mu     sync.Mutex
+	series map[string]*counterSeries
+}
+
+type counterSeries struct {
+	values []string
+	value  float64
+}
+
+// NewCounter 在 Default 上注册计数器。
+func NewCounter(name, help string, labels ...string) *Counter {
+	return Default.NewCounter(name, help, labels...)
+}
+
+// NewCounter 注册计数器。
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{desc: desc{name, help, "counter", labels}, series: make(map[string]*counterSeries)}
+	r.register(name, c)
+	return c
+}
+
+// Inc 将对应标签值的计数加一。
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add 将对应标签值的计数加 v（v 不能为负）。
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+	key := c.key(labelValues)
+	c.mu.Lock()
+	s, ok := c.series[key]
+	if !ok {
+		s = &counterSeries{values: append([]string(nil), labelValues...)}
+		c.series[key] = s
+	}
+	s.value += v
+	c.mu.Unlock()
+}
+
+func (c *Counter) write(_ context.Context, w *bufio.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.header(w)
+	for _, key := range sortedKeys(c.series) {
+		s := c.series[key]
+		c.sample(w, "", s.values, "", s.value)
+	}
+	return nil
+}
+
+// Histogram 为累积分桶直方图，可带标签。
+type Histogram struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	values []string
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram 在 Default 上注册直方图，buckets 为升序的上界。
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	return Default.NewHistogram(name, help, buckets, labels...)
+}
+
+// NewHistogram 注册直方图。
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{
+		desc:    desc{name, help, "histogram", labels},
+		buckets: append([]float64(nil), buckets...),
+		series:  make(map[string]*histogramSeries),
+	}
+	sort.Float64s(h.buckets)
+	r.register(name, h)
+	return h
+}
+
+// Observe 记录一次观测值。
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	key := h.key(labelValues)
+	h.mu.Lock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{values: append([]string(nil), labelValues...), counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += v
+	h.mu.Unlock()
+}
+
+func (h *Histogram) write(_ context.Context, w *bufio.Writer) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.header(w)
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += s.counts[i]
+			h.sample(w, "_bucket", s.values, `le="`+formatFloat(upper)+`"`, float64(cumulative))
+		}
+		h.sample(w, "_bucket", s.values, `le="+Inf"`, float64(s.count))
+		h.sample(w, "_sum", s.values, "", s.sum)
+		h.sample(w, "_count", s.values, "", float64(s.count))
+	}
+	return nil
+}
+
+// Sample 为采集函数返回的一组样本，LabelValues 与注册时的标签名一一对应。
+type Sample struct {
+	LabelValues []string
+	Value       float64
+}
+
+// funcCollector 在每次采集时调用 fn 计算样本。
+type funcCollector struct {
+	desc
+	fn func(ctx context.Context) ([]Sample, error)
+}
+
+func (f *funcCollector) write(ctx context.Context, w *bufio.Writer) error {
+	samples, err := f.fn(ctx)
+	if err != nil {
+		return err
+	}
+	f.header(w)
+	for _, s := range samples {
+		f.key(s.LabelValues)
+		f.sample(w, "", s.LabelValues, "", s.Value)
+	}
+	return nil
+}
+
+// GaugeFunc 注册一个采集时由 fn 计算的仪表。
+func (r *Registry) GaugeFunc(name, help string, fn func() float64) {
+	r.register(name, &funcCollector{desc{name, help, "gauge", nil}, func(context.Context) ([]Sample, error) {
+		return []Sample{{Value: fn()}}, nil
+	}})
+}
+
+// CounterFunc 注册一个采集时由 fn 读取的计数器（fn 的返回值应单调递增）。
+func (r *Registry) CounterFunc(name, help string, fn func() float64) {
+	r.register(name, &funcCollector{desc{name, help, "counter", nil}, func(context.Context) ([]Sample, error) {
+		return []Sample{{Value: fn()}}, nil
+	}})
+}
+
+// GaugeVecFunc 注册一组带标签的仪表，每次采集时由 fn 生成全部样本（如查询数据库）。
+func (r *Registry) GaugeVecFunc(name, help string, labels []string, fn func(ctx context.Context) ([]Sample, error)) {
+	r.register(name, &funcCollector{desc{name, help, "gauge", labels}, fn})
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
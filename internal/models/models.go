@@ -1,45 +1,579 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // User 表示已认证的账户信息。
 type User struct {
 	ID           int64     `json:"id"`
 	Username     string    `json:"username"`
 	PasswordHash string    `json:"-"`
+	Disabled     bool      `json:"disabled"`
 	CreatedAt    time.Time `json:"createdAt"`
 }
 
+// APIToken 为用户的个人 API 令牌，令牌本身只在创建时返回一次。
+type APIToken struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
 // Host 表示被追踪端口的目标主机。
 type Host struct {
-	ID        int64     `json:"id"`
-	Name      string    `json:"name"`
-	Address   string    `json:"address"`
-	AutoScan  bool      `json:"autoScan"`
-	Scanning  bool      `json:"scanning"`
-	OpenCount int       `json:"openCount"`
-	HiddenCount int     `json:"hiddenCount"`
+	ID          int64             `json:"id"`
+	Name        string            `json:"name"`
+	Address     string            `json:"address"`
+	AutoScan    bool              `json:"autoScan"`
+	Scanning    bool              `json:"scanning"`
+	OpenCount   int               `json:"openCount"`
+	HiddenCount int               `json:"hiddenCount"`
+	GroupIDs    []int64           `json:"groupIds"`
+	Labels      map[string]string `json:"labels"`
+	// Compliance 为最近一次基线评估的状态，未适用基线时为空。
+	Compliance string `json:"compliance,omitempty"`
+	// LastScanAt 为最近一次扫描结束的时间；最近一次失败时 LastScanError / LastScanErrorKind 记录原因（见 ScanFailure* 常量），
+	// ConsecutiveFailures 为连续失败次数，成功后清零。
+	LastScanAt          *time.Time `json:"lastScanAt,omitempty"`
+	LastScanError       string     `json:"lastScanError,omitempty"`
+	LastScanErrorKind   string     `json:"lastScanErrorKind,omitempty"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	// Zone 非空时主机由该网络区域的 agent 扫描，AgentID 指定时只由该 agent 扫描。
+	Zone      string    `json:"zone,omitempty"`
+	AgentID   *int64    `json:"agentId,omitempty"`
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 }
 
+// HostGroup 表示主机分组（环境、团队等），可通过 ParentID 嵌套。
+type HostGroup struct {
+	ID            int64     `json:"id"`
+	Name          string    `json:"name"`
+	Description   string    `json:"description"`
+	ParentID      int64     `json:"parentId,omitempty"`
+	HostCount     int       `json:"hostCount"`
+	OpenPortCount int       `json:"openPortCount"`
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// GroupStats 汇总分组内的端口数量。
+type GroupStats struct {
+	GroupID     int64            `json:"groupId"`
+	HostCount   int              `json:"hostCount"`
+	OpenPorts   int              `json:"openPorts"`
+	ClosedPorts int              `json:"closedPorts"`
+	HiddenPorts int              `json:"hiddenPorts"`
+	Hosts       []HostPortCounts `json:"hosts"`
+}
+
+// HostPortCounts 为单台主机的端口数量统计。
+type HostPortCounts struct {
+	HostID int64  `json:"hostId"`
+	Name   string `json:"name"`
+	Open   int    `json:"open"`
+	Closed int    `json:"closed"`
+	Hidden int    `json:"hidden"`
+}
+
+// HostAddress 记录主机名解析得到的某个 IP 及其出现时间。
+type HostAddress struct {
+	Address   string    `json:"address"`
+	Active    bool      `json:"active"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
 // Port 用于存储单个端口的元数据。
 type Port struct {
+	ID          int64  `json:"id"`
+	HostID      int64  `json:"hostId"`
+	Number      int    `json:"number"`
+	Note        string `json:"note"`
+	Fingerprint string `json:"fingerprint"`
+	// DetectedService 为扫描器识别的服务，UserFingerprint 为人工标注。
+	// FingerprintLocked 为 true 时扫描结果不会覆盖 Fingerprint。
+	DetectedService   string            `json:"detectedService"`
+	UserFingerprint   string            `json:"userFingerprint"`
+	FingerprintLocked bool              `json:"fingerprintLocked"`
+	Labels            map[string]string `json:"labels"`
+	Hidden            bool              `json:"hidden"`
+	Status            string            `json:"status"`
+	// MissedScans 为开放端口连续未被扫描发现的次数，达到阈值前状态保持 open。
+	MissedScans int       `json:"missedScans"`
+	LastChecked time.Time `json:"lastChecked"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// PortMatch 为跨主机端口检索的结果，附带所属主机信息。
+type PortMatch struct {
+	Port
+	HostName    string `json:"hostName"`
+	HostAddress string `json:"hostAddress"`
+}
+
+// SearchResults 为全文检索结果，Highlights 与 Snippet 中的匹配词以 <mark> 包裹且已做 HTML 转义。
+type SearchResults struct {
+	Hosts []HostHit `json:"hosts"`
+	Ports []PortHit `json:"ports"`
+}
+
+// HostHit 为命中的主机。
+type HostHit struct {
+	ID         int64             `json:"id"`
+	Name       string            `json:"name"`
+	Address    string            `json:"address"`
+	Highlights map[string]string `json:"highlights"`
+	Rank       float64           `json:"rank"`
+}
+
+// PortHit 为命中的端口，Rank 越小越相关（bm25）。
+type PortHit struct {
+	ID          int64             `json:"id"`
+	HostID      int64             `json:"hostId"`
+	HostName    string            `json:"hostName"`
+	Number      int               `json:"number"`
+	Status      string            `json:"status"`
+	Hidden      bool              `json:"hidden"`
+	Note        string            `json:"note"`
+	Fingerprint string            `json:"fingerprint"`
+	Highlights  map[string]string `json:"highlights"`
+	Snippet     string            `json:"snippet"`
+	Rank        float64           `json:"rank"`
+}
+
+// ServiceChange 记录扫描器识别到的服务变化。
+type ServiceChange struct {
+	ID         int64     `json:"id"`
+	PortID     int64     `json:"portId"`
+	Previous   string    `json:"previous"`
+	Service    string    `json:"service"`
+	DetectedAt time.Time `json:"detectedAt"`
+}
+
+// Exclusion 描述一条扫描排除规则，HostID 为 0 时对全部主机生效。
+type Exclusion struct {
+	ID        int64     `json:"id"`
+	HostID    int64     `json:"hostId,omitempty"`
+	Kind      string    `json:"kind"`
+	Value     string    `json:"value"`
+	Note      string    `json:"note"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// InventoryVersion 为当前导出文档的格式版本。
+const InventoryVersion = 1
+
+// Inventory 为可在实例间迁移、可纳入版本管理的完整资产清单，主机按名称、端口按端口号排序。
+type Inventory struct {
+	Version    int             `json:"version"`
+	ExportedAt time.Time       `json:"exportedAt"`
+	Hosts      []InventoryHost `json:"hosts"`
+}
+
+// InventoryHost 为清单中的主机，以名称作为跨实例的唯一标识。
+type InventoryHost struct {
+	Name     string            `json:"name"`
+	Address  string            `json:"address"`
+	AutoScan bool              `json:"autoScan"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Ports    []InventoryPort   `json:"ports"`
+}
+
+// InventoryPort 为清单中的端口。
+type InventoryPort struct {
+	Number            int               `json:"number"`
+	Note              string            `json:"note"`
+	Fingerprint       string            `json:"fingerprint"`
+	DetectedService   string            `json:"detectedService,omitempty"`
+	UserFingerprint   string            `json:"userFingerprint,omitempty"`
+	FingerprintLocked bool              `json:"fingerprintLocked,omitempty"`
+	Hidden            bool              `json:"hidden"`
+	Status            string            `json:"status,omitempty"`
+	LastChecked       *time.Time        `json:"lastChecked,omitempty"`
+	Labels            map[string]string `json:"labels,omitempty"`
+}
+
+// ImportReport 汇总一次清单导入的结果；DryRun 为 true 时仅为预览，数据未改动。
+type ImportReport struct {
+	Mode         string           `json:"mode"`
+	DryRun       bool             `json:"dryRun"`
+	HostsCreated int              `json:"hostsCreated"`
+	HostsUpdated int              `json:"hostsUpdated"`
+	HostsDeleted int              `json:"hostsDeleted"`
+	HostsSkipped int              `json:"hostsSkipped"`
+	PortsCreated int              `json:"portsCreated"`
+	PortsUpdated int              `json:"portsUpdated"`
+	Conflicts    []ImportConflict `json:"conflicts"`
+}
+
+// ImportConflict 描述同名主机与导入内容不一致的字段及处理方式（skipped / overwritten），
+// 地址被扫描策略拒绝的主机记为 blocked 并跳过。
+type ImportConflict struct {
+	Host       string   `json:"host"`
+	Fields     []string `json:"fields"`
+	Resolution string   `json:"resolution"`
+}
+
+// ScanRun 汇总一次外部扫描结果导入带来的变化。
+type ScanRun struct {
+	Source          string        `json:"source"`
+	StartedAt       time.Time     `json:"startedAt"`
+	FinishedAt      time.Time     `json:"finishedAt"`
+	Results         int           `json:"results"`
+	Skipped         int           `json:"skipped"`
+	HostsMatched    int           `json:"hostsMatched"`
+	HostsCreated    int           `json:"hostsCreated"`
+	PortsCreated    int           `json:"portsCreated"`
+	PortsOpened     int           `json:"portsOpened"`
+	PortsClosed     int           `json:"portsClosed"`
+	ServicesChanged int           `json:"servicesChanged"`
+	Hosts           []ScanRunHost `json:"hosts"`
+	Warnings        []string      `json:"warnings"`
+}
+
+// ScanRunHost 为导入中涉及的主机及其端口变化，Changes 仅包含有变化的端口。
+type ScanRunHost struct {
+	HostID  int64        `json:"hostId"`
+	Name    string       `json:"name"`
+	Address string       `json:"address"`
+	Created bool         `json:"created"`
+	Changes []PortChange `json:"changes"`
+}
+
+// PortChange 描述单个端口的变化：created / opened / closed / service。
+type PortChange struct {
+	PortID   int64  `json:"portId"`
+	Number   int    `json:"number"`
+	Change   string `json:"change"`
+	Status   string `json:"status"`
+	Service  string `json:"service,omitempty"`
+	Previous string `json:"previous,omitempty"`
+}
+
+// PortStatus 定义端口状态枚举。closed 为连接被拒绝，filtered 为探测无响应，
+// unreachable 为网络或主机不可达。
+const (
+	PortStatusUnknown     = "unknown"
+	PortStatusOpen        = "open"
+	PortStatusClosed      = "closed"
+	PortStatusFiltered    = "filtered"
+	PortStatusUnreachable = "unreachable"
+)
+
+// 基线合规状态：同时存在两类偏差时以 unexpected_open 为准。
+const (
+	ComplianceCompliant       = "compliant"
+	ComplianceUnexpectedOpen  = "unexpected_open"
+	ComplianceExpectedMissing = "expected_missing"
+)
+
+// PortBaseline 声明主机或分组预期开放的端口，HostID 与 GroupID 恰好一个非零。
+// Ports 为规范化后的端口列表，如 22,443,8000-8010。
+type PortBaseline struct {
+	ID        int64     `json:"id"`
+	HostID    int64     `json:"hostId,omitempty"`
+	GroupID   int64     `json:"groupId,omitempty"`
+	Ports     string    `json:"ports"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// HostCompliance 为单台主机的基线评估结果。Source 为 host 或 group，表示基线来自主机自身还是所属分组。
+type HostCompliance struct {
+	HostID     int64     `json:"hostId"`
+	Name       string    `json:"name"`
+	Address    string    `json:"address"`
+	Status     string    `json:"status"`
+	Source     string    `json:"source"`
+	Expected   string    `json:"expected"`
+	Unexpected []int     `json:"unexpected"`
+	Missing    []int     `json:"missing"`
+	CheckedAt  time.Time `json:"checkedAt"`
+}
+
+// ComplianceReport 汇总全部适用基线的主机，Summary 按状态计数。
+type ComplianceReport struct {
+	GeneratedAt time.Time        `json:"generatedAt"`
+	Summary     map[string]int   `json:"summary"`
+	Hosts       []HostCompliance `json:"hosts"`
+}
+
+// 告警类型，由扫描事件归类得到。port_opened / port_closed 仅在已有端口状态由其他状态变为 open、由 open 变为 closed 时触发。
+const (
+	AlertPortCreated = "port_created"
+	AlertPortOpened  = "port_opened"
+	AlertPortClosed  = "port_closed"
+	AlertScanFailed  = "scan_failed"
+	AlertDrift       = "drift"
+	AlertTest        = "test"
+)
+
+// AlertKinds 为规则可订阅的告警类型。
+var AlertKinds = []string{AlertPortCreated, AlertPortOpened, AlertPortClosed, AlertScanFailed, AlertDrift}
+
+// 告警投递状态。
+const (
+	DeliveryPending   = "pending"
+	DeliverySending   = "sending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// AlertTarget 为告警通知目标（渠道），Kind 见 Target* 常量。Secret 不随接口返回，HasSecret 表示是否已配置签名密钥。
+// MinSeverity 与 GroupID 为渠道自身的路由条件：低于该级别或不属于该分组（含子分组）主机的告警不会投递到此渠道。
+type AlertTarget struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Kind        string    `json:"kind"`
+	URL         string    `json:"url"`
+	Secret      string    `json:"-"`
+	HasSecret   bool      `json:"hasSecret"`
+	MinSeverity string    `json:"minSeverity"`
+	GroupID     int64     `json:"groupId,omitempty"`
+	Enabled     bool      `json:"enabled"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// AlertRule 描述何种事件通知到哪个目标。Ports、HostID、GroupID 为可选过滤条件，
+// Cooldown 秒内同一规则对同一对象（主机或端口）的同类告警只投递一次；Severity 为空时按告警类型取默认级别。
+type AlertRule struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Events    []string  `json:"events"`
+	Ports     string    `json:"ports"`
+	HostID    int64     `json:"hostId,omitempty"`
+	GroupID   int64     `json:"groupId,omitempty"`
+	Cooldown  int       `json:"cooldownSeconds"`
+	Severity  string    `json:"severity,omitempty"`
+	TargetID  int64     `json:"targetId"`
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// AlertDelivery 为一次告警投递及其重试状态，Payload 为发送的告警内容。
+type AlertDelivery struct {
+	ID            int64           `json:"id"`
+	RuleID        int64           `json:"ruleId,omitempty"`
+	TargetID      int64           `json:"targetId"`
+	Event         string          `json:"event"`
+	DedupKey      string          `json:"dedupKey"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	ResponseCode  int             `json:"responseCode,omitempty"`
+	LastError     string          `json:"lastError,omitempty"`
+	NextAttemptAt time.Time       `json:"nextAttemptAt"`
+	CreatedAt     time.Time       `json:"createdAt"`
+	DeliveredAt   *time.Time      `json:"deliveredAt,omitempty"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// Alert 为投递给通知目标的告警内容。
+type Alert struct {
+	Event      string                 `json:"event"`
+	Severity   string                 `json:"severity"`
+	OccurredAt time.Time              `json:"occurredAt"`
+	Rule       *AlertRuleRef          `json:"rule,omitempty"`
+	Host       *AlertHost             `json:"host,omitempty"`
+	Port       *AlertPort             `json:"port,omitempty"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+}
+
+// AlertRuleRef 为告警中引用的规则。
+type AlertRuleRef struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// AlertHost 为告警涉及的主机。
+type AlertHost struct {
+	ID      int64  `json:"id"`
+	Name    string `json:"name"`
+	Address string `json:"address"`
+}
+
+// AlertPort 为告警涉及的端口，Previous 为变化前的状态。
+type AlertPort struct {
+	ID       int64  `json:"id"`
+	Number   int    `json:"number"`
+	Status   string `json:"status"`
+	Previous string `json:"previous,omitempty"`
+	Service  string `json:"service,omitempty"`
+	Note     string `json:"note,omitempty"`
+}
+
+// 通知目标类型。email 目标的 URL 为 mailto:a@example.com,b@example.com；
+// telegram 目标的 URL 为 https://api.telegram.org/bot<token>/sendMessage?chat_id=<id>，其余为对应平台的 incoming webhook 地址。
+const (
+	TargetWebhook  = "webhook"
+	TargetEmail    = "email"
+	TargetSlack    = "slack"
+	TargetDiscord  = "discord"
+	TargetTeams    = "teams"
+	TargetTelegram = "telegram"
+)
+
+// TargetKinds 为支持的通知目标类型。
+var TargetKinds = []string{TargetWebhook, TargetEmail, TargetSlack, TargetDiscord, TargetTeams, TargetTelegram}
+
+// 告警严重级别，由低到高。
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Severities 为全部严重级别，由低到高。
+var Severities = []string{SeverityInfo, SeverityWarning, SeverityCritical}
+
+// 邮件摘要频率。
+const (
+	DigestOff    = "off"
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// AlertEvent 为告警事件历史中的一条记录，Port 为 0 表示与端口无关（如扫描失败）。
+type AlertEvent struct {
 	ID          int64     `json:"id"`
+	Event       string    `json:"event"`
 	HostID      int64     `json:"hostId"`
-	Number      int       `json:"number"`
-	Note        string    `json:"note"`
-	Fingerprint string    `json:"fingerprint"`
-	Hidden      bool      `json:"hidden"`
-	Status      string    `json:"status"`
-	LastChecked time.Time `json:"lastChecked"`
+	HostName    string    `json:"hostName"`
+	HostAddress string    `json:"hostAddress"`
+	Port        int       `json:"port,omitempty"`
+	Detail      string    `json:"detail,omitempty"`
+	OccurredAt  time.Time `json:"occurredAt"`
+}
+
+// NotificationSubscription 为用户的邮件通知偏好，GroupID 非零时摘要只包含该分组（含子分组）的主机。
+type NotificationSubscription struct {
+	UserID       int64      `json:"userId"`
+	Email        string     `json:"email"`
+	Digest       string     `json:"digest"`
+	GroupID      int64      `json:"groupId,omitempty"`
+	LastDigestAt *time.Time `json:"lastDigestAt,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+}
+
+// Digest 汇总一个周期内各主机新开放 / 关闭的端口与失败的扫描。
+type Digest struct {
+	Period       string       `json:"period"`
+	Since        time.Time    `json:"since"`
+	Until        time.Time    `json:"until"`
+	Opened       int          `json:"opened"`
+	Closed       int          `json:"closed"`
+	ScanFailures int          `json:"scanFailures"`
+	Hosts        []DigestHost `json:"hosts"`
+}
+
+// DigestHost 为摘要中单个主机的变化，LastError 为周期内最后一次扫描失败的原因。
+type DigestHost struct {
+	HostID       int64  `json:"hostId"`
+	Name         string `json:"name"`
+	Address      string `json:"address"`
+	Opened       []int  `json:"opened"`
+	Closed       []int  `json:"closed"`
+	ScanFailures int    `json:"scanFailures"`
+	LastError    string `json:"lastError,omitempty"`
+}
+
+// 扫描任务类型：full 为 1-65535 全范围扫描，ports 为指定端口的定向复查。
+const (
+	ScanJobFull  = "full"
+	ScanJobPorts = "ports"
+)
+
+// 扫描任务状态。
+const (
+	ScanJobQueued  = "queued"
+	ScanJobRunning = "running"
+	ScanJobDone    = "done"
+	ScanJobFailed  = "failed"
+)
+
+// 扫描任务优先级，由高到低：交互式定向复查、手动全范围扫描、定时扫描。
+const (
+	ScanPriorityInteractive = "interactive"
+	ScanPriorityManual      = "manual"
+	ScanPriorityScheduled   = "scheduled"
+)
+
+// ScanPriorityRank 返回优先级的排序值（越大越先执行），未知取值视为定时扫描。
+func ScanPriorityRank(priority string) int {
+	switch priority {
+	case ScanPriorityInteractive:
+		return 2
+	case ScanPriorityManual:
+		return 1
+	}
+	return 0
+}
+
+// ScanPriorityName 为 ScanPriorityRank 的逆映射。
+func ScanPriorityName(rank int) string {
+	switch {
+	case rank >= 2:
+		return ScanPriorityInteractive
+	case rank == 1:
+		return ScanPriorityManual
+	}
+	return ScanPriorityScheduled
+}
+
+// ScanJob 为持久化的扫描任务，进程重启后未完成的任务会重新排队。Ports 仅对 ports 类型有效。
+type ScanJob struct {
+	ID         int64      `json:"id"`
+	HostID     int64      `json:"hostId"`
+	HostName   string     `json:"hostName"`
+	Kind       string     `json:"kind"`
+	Priority   string     `json:"priority"`
+	Ports      []int      `json:"ports,omitempty"`
+	Status     string     `json:"status"`
+	Attempts   int        `json:"attempts"`
+	Error      string     `json:"error,omitempty"`
+	ErrorKind  string     `json:"errorKind,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	// NextAttemptAt 为临时性失败后的重试时间。
+	NextAttemptAt *time.Time `json:"nextAttemptAt,omitempty"`
+	// AgentID 为领取任务的 agent，Owner 为本地执行任务的服务端实例，LeaseExpiresAt 为租约到期时间。
+	AgentID        *int64     `json:"agentId,omitempty"`
+	Owner          string     `json:"owner,omitempty"`
+	LeaseExpiresAt *time.Time `json:"leaseExpiresAt,omitempty"`
+}
+
+// Agent 为部署在私有网络中的远程扫描节点，领取所在区域主机的扫描任务。
+type Agent struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Zone       string     `json:"zone"`
+	Disabled   bool       `json:"disabled"`
+	Version    string     `json:"version,omitempty"`
+	Hostname   string     `json:"hostname,omitempty"`
+	RemoteAddr string     `json:"remoteAddr,omitempty"`
+	LastSeenAt *time.Time `json:"lastSeenAt,omitempty"`
+	// Online 表示 agent 最近仍在拉取任务，RunningJobs 为其持有租约的任务数。
+	Online      bool      `json:"online"`
+	RunningJobs int       `json:"runningJobs"`
 	CreatedAt   time.Time `json:"createdAt"`
 	UpdatedAt   time.Time `json:"updatedAt"`
 }
 
-// PortStatus 定义端口状态枚举。
+// 扫描失败分类。dns、timeout、unreachable 视为临时性失败，会按退避重试。
 const (
-	PortStatusUnknown = "unknown"
-	PortStatusOpen    = "open"
-	PortStatusClosed  = "closed"
+	ScanFailureDNS         = "dns"
+	ScanFailurePermission  = "permission"
+	ScanFailureTimeout     = "timeout"
+	ScanFailureUnreachable = "unreachable"
+	ScanFailureRunnerInit  = "runner_init"
+	ScanFailureBlocked     = "blocked"
+	ScanFailureOther       = "other"
 )
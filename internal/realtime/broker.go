@@ -3,14 +3,17 @@ package realtime
 import (
 	"encoding/json"
 	"sync"
+	"sync/atomic"
 )
 
 // Event 描述 SSE 推送时的消息载荷。
 type Event struct {
-	Type    string      `json:"type"`
-	HostID  int64       `json:"hostId,omitempty"`
-	PortID  int64       `json:"portId,omitempty"`
-	Payload interface{} `json:"payload,omitempty"`
+	Type   string `json:"type"`
+	HostID int64  `json:"hostId,omitempty"`
+	PortID int64  `json:"portId,omitempty"`
+	// Labels 为事件主体（端口或主机）的标签，便于订阅方按标签过滤。
+	Labels  map[string]string `json:"labels,omitempty"`
+	Payload interface{}       `json:"payload,omitempty"`
 }
 
 // Broker 负责向实时订阅者（SSE 客户端）分发事件。
@@ -18,6 +21,10 @@ type Broker struct {
 	mu       sync.RWMutex
 	clients  map[chan []byte]struct{}
 	shutdown chan struct{}
+	// observers 为进程内的事件观察者（如告警），与 SSE 订阅者不同，不会被丢弃消息。
+	observers []func(Event)
+	// dropped 为因订阅者处理过慢而丢弃的消息数。
+	dropped atomic.Uint64
 }
 
 // NewBroker 创建一个新的 Broker 实例。
@@ -48,6 +55,13 @@ func (b *Broker) Subscribe() (<-chan []byte, func()) {
 	return ch, cleanup
 }
 
+// Observe 注册进程内观察者，Publish 时同步调用，fn 必须快速返回。
+func (b *Broker) Observe(fn func(Event)) {
+	b.mu.Lock()
+	b.observers = append(b.observers, fn)
+	b.mu.Unlock()
+}
+
 // Publish 将事件广播给所有订阅者。
 func (b *Broker) Publish(evt Event) {
 	data, err := json.Marshal(evt)
@@ -56,11 +70,27 @@ func (b *Broker) Publish(evt Event) {
 	}
 	b.mu.RLock()
 	defer b.mu.RUnlock()
+	for _, fn := range b.observers {
+		fn(evt)
+	}
 	for ch := range b.clients {
 		select {
 		case ch <- data:
 		default:
 			// 如果订阅者处理过慢则丢弃消息，避免阻塞。
+			b.dropped.Add(1)
 		}
 	}
 }
+
+// Subscribers 返回当前的 SSE 订阅者数量。
+func (b *Broker) Subscribers() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.clients)
+}
+
+// Dropped 返回累计丢弃的消息数。
+func (b *Broker) Dropped() uint64 {
+	return b.dropped.Load()
+}
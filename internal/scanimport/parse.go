@@ -0,0 +1,337 @@
+// Package scanimport 解析外部扫描器（nmap、masscan、naabu）的输出，转换为统一的端口结果。
+package scanimport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 支持的输入格式。
+const (
+	FormatAuto        = "auto"
+	FormatNmap        = "nmap"
+	FormatMasscan     = "masscan"
+	FormatMasscanList = "masscan-list"
+	FormatNaabu       = "naabu"
+)
+
+// ErrUnknownFormat 表示无法识别输入格式。
+var ErrUnknownFormat = errors.New("unrecognized scan output format")
+
+// 端口状态，与各扫描器输出中的取值一致。
+const (
+	StateOpen     = "open"
+	StateClosed   = "closed"
+	StateFiltered = "filtered"
+)
+
+// Result 为单个端口的扫描结果。Hostname 为扫描时使用的域名（若有），Address 为 IP。
+type Result struct {
+	Address  string
+	Hostname string
+	Port     int
+	Protocol string
+	State    string
+	Service  string
+}
+
+// Parse 按 format 解析 data，format 为空或 auto 时自动识别，返回实际使用的格式。
+func Parse(format string, data []byte) ([]Result, string, error) {
+	if format == "" || format == FormatAuto {
+		format = Detect(data)
+		if format == "" {
+			return nil, "", ErrUnknownFormat
+		}
+	}
+	var (
+		results []Result
+		err     error
+	)
+	switch format {
+	case FormatNmap:
+		results, err = parseNmap(data)
+	case FormatMasscan:
+		results, err = parseMasscanJSON(data)
+	case FormatMasscanList:
+		results, err = parseMasscanList(data)
+	case FormatNaabu:
+		results, err = parseNaabu(data)
+	default:
+		return nil, "", fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+	if err != nil {
+		return nil, format, fmt.Errorf("parse %s: %w", format, err)
+	}
+	return results, format, nil
+}
+
+// Detect 根据内容特征猜测格式，无法识别时返回空字符串。
+func Detect(data []byte) string {
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case len(trimmed) == 0:
+		return ""
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		if bytes.Contains(trimmed, []byte("<nmaprun")) {
+			return FormatNmap
+		}
+		return ""
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		return FormatMasscan
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		// masscan 的 -oJ 在部分版本中省略外层数组，逐行对象带有 ports 字段。
+		first, _, _ := bytes.Cut(trimmed, []byte("\n"))
+		if bytes.Contains(first, []byte(`"ports"`)) {
+			return FormatMasscan
+		}
+		return FormatNaabu
+	case bytes.HasPrefix(trimmed, []byte("#masscan")), bytes.HasPrefix(trimmed, []byte("open ")), bytes.HasPrefix(trimmed, []byte("closed ")):
+		return FormatMasscanList
+	}
+	return ""
+}
+
+type nmapRun struct {
+	Hosts []struct {
+		Status struct {
+			State string `xml:"state,attr"`
+		} `xml:"status"`
+		Addresses []struct {
+			Addr     string `xml:"addr,attr"`
+			AddrType string `xml:"addrtype,attr"`
+		} `xml:"address"`
+		Hostnames []struct {
+			Name string `xml:"name,attr"`
+			Type string `xml:"type,attr"`
+		} `xml:"hostnames>hostname"`
+		Ports []struct {
+			Protocol string `xml:"protocol,attr"`
+			PortID   int    `xml:"portid,attr"`
+			State    struct {
+				State string `xml:"state,attr"`
+			} `xml:"state"`
+			Service struct {
+				Name    string `xml:"name,attr"`
+				Product string `xml:"product,attr"`
+				Version string `xml:"version,attr"`
+			} `xml:"service"`
+		} `xml:"ports>port"`
+	} `xml:"host"`
+}
+
+func parseNmap(data []byte) ([]Result, error) {
+	var run nmapRun
+	if err := xml.Unmarshal(data, &run); err != nil {
+		return nil, err
+	}
+	var results []Result
+	for _, h := range run.Hosts {
+		var address, hostname string
+		for _, a := range h.Addresses {
+			if a.AddrType == "ipv4" || a.AddrType == "ipv6" {
+				address = a.Addr
+				break
+			}
+		}
+		// 优先使用命令行给出的域名（type=user），其次是反向解析结果。
+		for _, hn := range h.Hostnames {
+			if hn.Type == "user" || hostname == "" {
+				hostname = hn.Name
+			}
+		}
+		for _, p := range h.Ports {
+			service := p.Service.Name
+			if p.Service.Product != "" {
+				service = strings.TrimSpace(p.Service.Product + " " + p.Service.Version)
+			}
+			results = append(results, Result{
+				Address:  address,
+				Hostname: hostname,
+				Port:     p.PortID,
+				Protocol: p.Protocol,
+				State:    normalizeState(p.State.State),
+				Service:  service,
+			})
+		}
+	}
+	return results, nil
+}
+
+type masscanRecord struct {
+	IP    string `json:"ip"`
+	Ports []struct {
+		Port    int    `json:"port"`
+		Proto   string `json:"proto"`
+		Status  string `json:"status"`
+		Service struct {
+			Name   string `json:"name"`
+			Banner string `json:"banner"`
+		} `json:"service"`
+	} `json:"ports"`
+}
+
+func parseMasscanJSON(data []byte) ([]Result, error) {
+	trimmed := bytes.TrimSpace(data)
+	var records []masscanRecord
+	if bytes.HasPrefix(trimmed, []byte("[")) {
+		// 旧版 masscan 在最后一个元素后留有逗号，先去掉再解析。
+		body := bytes.TrimSpace(bytes.TrimSuffix(bytes.TrimPrefix(trimmed, []byte("[")), []byte("]")))
+		body = bytes.TrimSuffix(body, []byte(","))
+		if err := json.Unmarshal(append(append([]byte("["), body...), ']'), &records); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := eachLine(trimmed, func(line []byte) error {
+			line = bytes.TrimSuffix(line, []byte(","))
+			var rec masscanRecord
+			if err := json.Unmarshal(line, &rec); err != nil {
+				return err
+			}
+			records = append(records, rec)
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	var results []Result
+	for _, rec := range records {
+		for _, p := range rec.Ports {
+			// 横幅记录（service 非空）单独成行，只携带服务名称。
+			state := normalizeState(p.Status)
+			if state == "" && p.Service.Name != "" {
+				state = StateOpen
+			}
+			results = append(results, Result{
+				Address:  rec.IP,
+				Port:     p.Port,
+				Protocol: p.Proto,
+				State:    state,
+				Service:  p.Service.Name,
+			})
+		}
+	}
+	return mergeBanners(results), nil
+}
+
+// parseMasscanList 解析 masscan -oL 输出：`open tcp 80 1.2.3.4 1700000000` 与 `banner tcp 80 1.2.3.4 ts http ...`。
+func parseMasscanList(data []byte) ([]Result, error) {
+	var results []Result
+	err := eachLine(data, func(line []byte) error {
+		fields := strings.Fields(string(line))
+		if len(fields) < 4 {
+			return fmt.Errorf("malformed line %q", line)
+		}
+		port, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return fmt.Errorf("malformed port in line %q", line)
+		}
+		r := Result{Address: fields[3], Port: port, Protocol: fields[1]}
+		if fields[0] == "banner" {
+			if len(fields) < 6 {
+				return nil
+			}
+			r.State = StateOpen
+			r.Service = fields[5]
+		} else {
+			r.State = normalizeState(fields[0])
+		}
+		results = append(results, r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mergeBanners(results), nil
+}
+
+type naabuRecord struct {
+	Host     string `json:"host"`
+	IP       string `json:"ip"`
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol"`
+}
+
+// parseNaabu 解析 naabu -json 的逐行输出，naabu 只输出开放端口。
+func parseNaabu(data []byte) ([]Result, error) {
+	var results []Result
+	err := eachLine(data, func(line []byte) error {
+		var rec naabuRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return err
+		}
+		address, hostname := rec.IP, rec.Host
+		if address == "" {
+			address, hostname = rec.Host, ""
+		}
+		if hostname == address {
+			hostname = ""
+		}
+		results = append(results, Result{Address: address, Hostname: hostname, Port: rec.Port, Protocol: rec.Protocol, State: StateOpen})
+		return nil
+	})
+	return results, err
+}
+
+// eachLine 逐行处理非空且非 # 注释的行，错误中带上行号。
+func eachLine(data []byte, fn func(line []byte) error) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		if err := fn(line); err != nil {
+			return fmt.Errorf("line %d: %w", n, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// mergeBanners 将同一端口的多条记录合并为一条，保留状态与首个服务名称。
+func mergeBanners(results []Result) []Result {
+	type key struct {
+		address, protocol string
+		port              int
+	}
+	index := make(map[key]int)
+	var merged []Result
+	for _, r := range results {
+		k := key{r.Address, r.Protocol, r.Port}
+		i, ok := index[k]
+		if !ok {
+			index[k] = len(merged)
+			merged = append(merged, r)
+			continue
+		}
+		if merged[i].Service == "" {
+			merged[i].Service = r.Service
+		}
+		if r.State != "" && r.Service == "" {
+			merged[i].State = r.State
+		}
+	}
+	return merged
+}
+
+func normalizeState(state string) string {
+	state = strings.ToLower(strings.TrimSpace(state))
+	switch {
+	case state == StateOpen:
+		return StateOpen
+	case state == StateClosed:
+		return StateClosed
+	case strings.Contains(state, "filtered"):
+		return StateFiltered
+	}
+	return ""
+}
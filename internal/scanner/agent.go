@@ -0,0 +1,122 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/hitushen/portnotepro/internal/models"
+)
+
+// defaultAgentLease 为 agent 领取任务的默认租约时长，agent 每隔三分之一租约续约一次。
+const defaultAgentLease = 2 * time.Minute
+
+// AgentJob 为下发给 agent 的任务。
+type AgentJob struct {
+	ID      int64       `json:"id"`
+	HostID  int64       `json:"hostId"`
+	Kind    string      `json:"kind"`
+	Attempt int         `json:"attempt"`
+	Lease   int         `json:"leaseSeconds"`
+	Request ScanRequest `json:"request"`
+}
+
+// AgentResult 为 agent 回传的执行结果。Error 非空时表示失败，ErrorKind 与 Transient 为 agent 端的分类。
+type AgentResult struct {
+	Result    *ScanResult `json:"result,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	ErrorKind string      `json:"errorKind,omitempty"`
+	Transient bool        `json:"transient,omitempty"`
+}
+
+// SetAgentLease 设置 agent 领取任务的租约时长。
+func (m *Manager) SetAgentLease(d time.Duration) {
+	if d > 0 {
+		m.agentLease.Store(int64(d))
+	}
+}
+
+// AgentLease 返回 agent 任务的租约时长。
+func (m *Manager) AgentLease() time.Duration {
+	return time.Duration(m.agentLease.Load())
+}
+
+// LeaseJob 为 agent 领取一个任务并生成扫描参数，没有可执行的任务时返回 nil。
+// 无需扫描的任务（定向复查的端口均已删除）或无法生成参数的任务在服务端直接结束，继续领取下一个。
+func (m *Manager) LeaseJob(ctx context.Context, agent *models.Agent) (*AgentJob, error) {
+	lease := m.AgentLease()
+	for {
+		job, err := m.store.LeaseScanJob(ctx, agent, int(m.perHost.Load()), time.Now().Add(lease))
+		if err != nil || job == nil {
+			return nil, err
+		}
+		req, err := m.prepare(ctx, job)
+		if err != nil || req == nil {
+			if _, rerr := m.store.ReleaseScanLease(ctx, job.ID, agent.ID); rerr != nil {
+				return nil, rerr
+			}
+			m.complete(job, &ScanResult{}, err)
+			continue
+		}
+		log.Printf("[scanner] leased scan job=%d host=%d kind=%s to agent=%s", job.ID, job.HostID, job.Kind, agent.Name)
+		return &AgentJob{
+			ID:      job.ID,
+			HostID:  job.HostID,
+			Kind:    job.Kind,
+			Attempt: job.Attempts,
+			Lease:   int(lease / time.Second),
+			Request: *req,
+		}, nil
+	}
+}
+
+// ExtendLease 为 agent 持有的任务续约，租约已被收回时返回 false，agent 应放弃该任务。
+func (m *Manager) ExtendLease(ctx context.Context, agentID, jobID int64) (bool, error) {
+	return m.store.ExtendScanLease(ctx, jobID, agentID, time.Now().Add(m.AgentLease()))
+}
+
+// SubmitResult 记录 agent 回传的结果，租约已被收回（任务已重新排队或结束）时返回 false。
+func (m *Manager) SubmitResult(ctx context.Context, agentID, jobID int64, res AgentResult) (bool, error) {
+	job, err := m.store.ReleaseScanLease(ctx, jobID, agentID)
+	if err != nil || job == nil {
+		return false, err
+	}
+	var scanErr error
+	if res.Error != "" {
+		scanErr = &ScanError{Kind: validFailureKind(res.ErrorKind), Transient: res.Transient, Err: errors.New(res.Error)}
+	} else if res.Result == nil {
+		scanErr = &ScanError{Kind: models.ScanFailureOther, Err: errors.New("agent returned no result")}
+	}
+	m.complete(job, res.Result, scanErr)
+	return true, nil
+}
+
+// expireLeases 收回到期的租约（agent 或已失联的实例），按临时性超时失败处理（重试或结束任务）。
+func (m *Manager) expireLeases() {
+	jobs, err := m.store.ExpireScanLeases(context.Background(), time.Now())
+	if err != nil {
+		log.Printf("[scanner] expire scan leases failed: %v", err)
+	}
+	for i := range jobs {
+		job := &jobs[i]
+		reason := "agent lease expired"
+		if job.AgentID == nil {
+			reason = "instance " + job.Owner + " lease expired"
+		}
+		m.complete(job, nil, &ScanError{
+			Kind:      models.ScanFailureTimeout,
+			Transient: true,
+			Err:       errors.New(reason),
+		})
+	}
+}
+
+func validFailureKind(kind string) string {
+	switch kind {
+	case models.ScanFailureDNS, models.ScanFailurePermission, models.ScanFailureTimeout, models.ScanFailureUnreachable,
+		models.ScanFailureRunnerInit, models.ScanFailureBlocked:
+		return kind
+	}
+	return models.ScanFailureOther
+}
@@ -0,0 +1,86 @@
+package scanner
+
+import (
+	"context"
+	"sync"
+)
+
+const (
+	// naabuRate 为单个 naabu 运行的发包速率上限（每秒）。
+	naabuRate = 3000
+	// minScanRate 为一次扫描至少要分到的速率，预算不足时等待其他扫描释放。
+	minScanRate = 100
+)
+
+// rateBudget 在并发的 naabu 运行之间分配全局发包速率（pps），已分配的总和不超过 total。
+// 每次运行在开始时按当前空闲额度与公平份额取得固定速率，结束时归还。
+type rateBudget struct {
+	mu      sync.Mutex
+	total   int
+	used    int
+	holders int
+	// released 在额度变化时关闭并替换，用于唤醒等待者。
+	released chan struct{}
+}
+
+func newRateBudget(total int) *rateBudget {
+	return &rateBudget{total: total, released: make(chan struct{})}
+}
+
+// acquire 预留不超过 want 的速率：取空闲额度与 total/(持有者+1) 中的较小者，
+// 空闲额度不足 minScanRate 时阻塞直到有扫描归还或 ctx 结束。
+func (b *rateBudget) acquire(ctx context.Context, want int) (int, error) {
+	for {
+		b.mu.Lock()
+		avail := b.total - b.used
+		if avail >= minScanRate || (b.holders == 0 && avail > 0) {
+			grant := want
+			if grant > avail {
+				grant = avail
+			}
+			if fair := b.total / (b.holders + 1); grant > fair && fair >= minScanRate {
+				grant = fair
+			}
+			b.used += grant
+			b.holders++
+			b.mu.Unlock()
+			return grant, nil
+		}
+		wait := b.released
+		b.mu.Unlock()
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+}
+
+// release 归还 acquire 取得的速率。
+func (b *rateBudget) release(rate int) {
+	b.mu.Lock()
+	b.used -= rate
+	b.holders--
+	b.signal()
+	b.mu.Unlock()
+}
+
+// setTotal 调整总预算，已分配的额度保持不变，直到对应扫描结束。
+func (b *rateBudget) setTotal(total int) {
+	b.mu.Lock()
+	b.total = total
+	b.signal()
+	b.mu.Unlock()
+}
+
+// inUse 返回已分配的速率总和。
+func (b *rateBudget) inUse() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used
+}
+
+func (b *rateBudget) signal() {
+	close(b.released)
+	b.released = make(chan struct{})
+}
@@ -0,0 +1,40 @@
+package scanner
+
+import (
+	"context"
+	"log"
+
+	"github.com/hitushen/portnotepro/internal/models"
+	"github.com/hitushen/portnotepro/internal/realtime"
+)
+
+// EvaluateCompliance 按主机当前端口状态重新评估基线，结果变化时推送 compliance_changed 事件。
+func (m *Manager) EvaluateCompliance(ctx context.Context, hostID int64) {
+	result, previous, changed, err := m.store.EvaluateCompliance(ctx, hostID)
+	if err != nil {
+		log.Printf("[scanner] compliance evaluation failed host=%d err=%v", hostID, err)
+		return
+	}
+	if !changed {
+		return
+	}
+	payload := map[string]interface{}{
+		"previous": previous,
+		"status":   "",
+	}
+	if result != nil {
+		payload["status"] = result.Status
+		payload["source"] = result.Source
+		payload["expected"] = result.Expected
+		payload["unexpected"] = result.Unexpected
+		payload["missing"] = result.Missing
+		if result.Status != models.ComplianceCompliant {
+			log.Printf("[scanner] baseline drift host=%d status=%s unexpected=%v missing=%v", hostID, result.Status, result.Unexpected, result.Missing)
+		}
+	}
+	m.realtime.Publish(realtime.Event{
+		Type:    "compliance_changed",
+		HostID:  hostID,
+		Payload: payload,
+	})
+}
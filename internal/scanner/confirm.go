@@ -0,0 +1,153 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hitushen/portnotepro/internal/models"
+)
+
+const (
+	// defaultMissThreshold 为开放端口改变状态前需要连续未被发现的次数。
+	defaultMissThreshold = 2
+	// probeWorkers 为确认探测时同时连接的端口数上限。
+	probeWorkers = 16
+	// probeRate 为确认探测向共享速率预算申请的速率上限（每秒连接数）。
+	probeRate = 500
+)
+
+// confirmPorts 对扫描未发现的已知端口逐个做 TCP 连接探测，返回端口号到状态的映射。
+// 任一目标连通即为 open；否则有目标拒绝连接为 closed，有目标无响应为 filtered，全部不可达为 unreachable。
+// 探测与 naabu 共用发包速率预算，按取得的速率逐个发起连接；等待预算时 ctx 结束则未探测的端口没有结果。
+func (e *Engine) confirmPorts(ctx context.Context, scanTargets []string, ports []int) map[int]string {
+	results := make(map[int]string, len(ports))
+	if len(ports) == 0 {
+		return results
+	}
+	addrs := make([]string, 0, len(scanTargets))
+	for _, t := range scanTargets {
+		if net.ParseIP(t) != nil {
+			addrs = append(addrs, t)
+		}
+	}
+	// 只有未解析的主机名时直接连接主机名。
+	if len(addrs) == 0 {
+		addrs = scanTargets
+	}
+
+	rate, err := e.budget.acquire(ctx, probeRate)
+	if err != nil {
+		log.Printf("[scanner] skip confirm probes: wait for rate budget: %v", err)
+		return results
+	}
+	defer e.budget.release(rate)
+	pace := time.NewTicker(time.Second / time.Duration(rate))
+	defer pace.Stop()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, probeWorkers)
+	for _, port := range ports {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(port int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			status := models.PortStatusUnreachable
+			for _, addr := range addrs {
+				select {
+				case <-pace.C:
+				case <-ctx.Done():
+					return
+				}
+				s := probePort(ctx, addr, port, e.timeout)
+				if statusRank(s) > statusRank(status) {
+					status = s
+				}
+				if status == models.PortStatusOpen {
+					break
+				}
+			}
+			mu.Lock()
+			results[port] = status
+			mu.Unlock()
+		}(port)
+	}
+	wg.Wait()
+	return results
+}
+
+// probePort 以 timeout 连接 addr:port 并按错误判断端口状态。
+func probePort(ctx context.Context, addr string, port int, timeout time.Duration) string {
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(addr, strconv.Itoa(port)))
+	if err == nil {
+		conn.Close()
+		return models.PortStatusOpen
+	}
+	var dnsErr *net.DNSError
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return models.PortStatusClosed
+	case errors.Is(err, syscall.ENETUNREACH), errors.Is(err, syscall.EHOSTUNREACH), errors.As(err, &dnsErr):
+		return models.PortStatusUnreachable
+	}
+	return models.PortStatusFiltered
+}
+
+// missThresholdFor 返回任务结果适用的未发现次数阈值：用户交互触发的定向复查要立即反映端口的实际状态，不经过阈值。
+func (m *Manager) missThresholdFor(job *models.ScanJob) int {
+	if job.Kind == models.ScanJobPorts && job.Priority == models.ScanPriorityInteractive {
+		return 1
+	}
+	return int(m.missThreshold.Load())
+}
+
+// statusRank 为多个目标探测结果合并时的优先级。
+func statusRank(status string) int {
+	switch status {
+	case models.PortStatusOpen:
+		return 3
+	case models.PortStatusClosed:
+		return 2
+	case models.PortStatusFiltered:
+		return 1
+	}
+	return 0
+}
+
+// settleMissing 按确认探测结果写入扫描未发现的已知端口状态，没有探测结果的端口（如扫描开始后新增的）保持不变。
+// 开放端口在确认探测仍未连通时只累计未发现次数，连续达到 threshold 次才改为探测得到的状态，避免丢包造成的误报；
+// 其余端口直接写入探测结果。返回状态是否有变化。
+func (m *Manager) settleMissing(ctx context.Context, hostID int64, ports []models.Port, confirmed map[int]string, checkedAt time.Time, threshold int) bool {
+	changed := false
+	for _, port := range ports {
+		status, ok := confirmed[port.Number]
+		if !ok {
+			continue
+		}
+		if port.Status == models.PortStatusOpen && status != models.PortStatusOpen {
+			misses, err := m.store.RecordPortMiss(ctx, port.ID, checkedAt)
+			if err != nil {
+				log.Printf("[scanner] record port miss failed host=%d port=%d err=%v", hostID, port.Number, err)
+				continue
+			}
+			if misses < threshold {
+				log.Printf("[scanner] open port not seen host=%d port=%d probe=%s misses=%d/%d", hostID, port.Number, status, misses, threshold)
+				continue
+			}
+		}
+		if port.Status != status {
+			changed = true
+		}
+		_ = m.store.UpdatePortStatus(ctx, port.ID, status, checkedAt)
+		m.publishStatus(hostID, port.ID, port.Labels, port.Status, status, checkedAt)
+	}
+	return changed
+}
@@ -0,0 +1,136 @@
+package scanner
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/hitushen/portnotepro/internal/models"
+)
+
+// closedPort 返回本机上一个当前没有监听的端口。
+func closedPort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	return port
+}
+
+func TestConfirmPortsStatus(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	open := ln.Addr().(*net.TCPAddr).Port
+	closed := closedPort(t)
+
+	e := NewEngine(time.Second, 1000)
+	got := e.confirmPorts(context.Background(), []string{"127.0.0.1"}, []int{open, closed})
+	if got[open] != models.PortStatusOpen || got[closed] != models.PortStatusClosed {
+		t.Errorf("confirmPorts = %v, want %d open and %d closed", got, open, closed)
+	}
+	if n := e.RateInUse(); n != 0 {
+		t.Errorf("rate still allocated after probing: %d", n)
+	}
+}
+
+func TestConfirmPortsPacedByRateBudget(t *testing.T) {
+	closed := closedPort(t)
+	ports := make([]int, 21)
+	for i := range ports {
+		ports[i] = closed
+	}
+
+	// 预算为每秒 100 次，21 次探测至少需要 200ms。
+	e := NewEngine(time.Second, 100)
+	start := time.Now()
+	got := e.confirmPorts(context.Background(), []string{"127.0.0.1"}, ports)
+	if elapsed := time.Since(start); elapsed < 190*time.Millisecond {
+		t.Errorf("21 probes at 100/s took %s", elapsed)
+	}
+	if got[closed] != models.PortStatusClosed {
+		t.Errorf("confirmPorts = %v", got)
+	}
+}
+
+func TestConfirmPortsWaitsForRateBudget(t *testing.T) {
+	e := NewEngine(time.Second, 1000)
+	held, err := e.budget.acquire(context.Background(), 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 预算被其他扫描占满时不发起探测，ctx 结束后端口没有结果。
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if got := e.confirmPorts(ctx, []string{"127.0.0.1"}, []int{closedPort(t)}); len(got) != 0 {
+		t.Errorf("confirmPorts without budget = %v, want no results", got)
+	}
+
+	e.budget.release(held)
+	closed := closedPort(t)
+	if got := e.confirmPorts(context.Background(), []string{"127.0.0.1"}, []int{closed}); got[closed] != models.PortStatusClosed {
+		t.Errorf("confirmPorts after release = %v", got)
+	}
+}
+
+func TestMissThreshold(t *testing.T) {
+	ctx := context.Background()
+	tm := newTestManager(t)
+	hostID := tm.createHost(t, "db", "10.0.0.5")
+	portID, err := tm.store.CreatePort(ctx, hostID, 5432, "postgres", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := func() string {
+		t.Helper()
+		p, err := tm.store.GetPort(ctx, portID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p.Status
+	}
+	reopen := func() {
+		t.Helper()
+		if err := tm.store.UpdatePortStatus(ctx, portID, models.PortStatusOpen, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	missing := &ScanResult{Open: map[int]string{}, Confirmed: map[int]string{5432: models.PortStatusClosed}}
+
+	// 定时的定向复查与全范围扫描：连续达到阈值（默认 2 次）才改变状态。
+	for _, job := range []*models.ScanJob{
+		{HostID: hostID, Kind: models.ScanJobPorts, Priority: models.ScanPriorityScheduled, Ports: []int{5432}},
+		{HostID: hostID, Kind: models.ScanJobFull, Priority: models.ScanPriorityManual},
+	} {
+		reopen()
+		if _, err := tm.apply(ctx, job, missing); err != nil {
+			t.Fatal(err)
+		}
+		if got := status(); got != models.PortStatusOpen {
+			t.Errorf("%s/%s: status after one miss = %s, want open", job.Kind, job.Priority, got)
+		}
+		if _, err := tm.apply(ctx, job, missing); err != nil {
+			t.Fatal(err)
+		}
+		if got := status(); got != models.PortStatusClosed {
+			t.Errorf("%s/%s: status after two misses = %s, want closed", job.Kind, job.Priority, got)
+		}
+	}
+
+	// 用户交互触发的定向复查立即反映实际状态。
+	reopen()
+	interactive := &models.ScanJob{HostID: hostID, Kind: models.ScanJobPorts, Priority: models.ScanPriorityInteractive, Ports: []int{5432}}
+	if _, err := tm.apply(ctx, interactive, missing); err != nil {
+		t.Fatal(err)
+	}
+	if got := status(); got != models.PortStatusClosed {
+		t.Errorf("interactive rescan: status = %s, want closed", got)
+	}
+}
@@ -0,0 +1,151 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	portpkg "github.com/projectdiscovery/naabu/v2/pkg/port"
+
+	"github.com/hitushen/portnotepro/internal/services/fingerprint"
+	"github.com/hitushen/portnotepro/internal/targets"
+)
+
+// ScanRequest 描述一次扫描的执行参数，服务端本地执行与下发给远程 agent 时共用。
+type ScanRequest struct {
+	Address string `json:"address"`
+	// Ports 为定向复查的端口，为空时扫描 1-65535。
+	Ports []int `json:"ports,omitempty"`
+	// Known 为主机已记录的端口，扫描未发现时做确认探测。
+	Known  []int              `json:"known,omitempty"`
+	Policy targets.PolicySpec `json:"policy"`
+}
+
+// ScanResult 为扫描结果：Host 为标准化后的地址，Addresses 为域名解析出的 IP，
+// Open 为开放端口及识别的服务，Confirmed 为未发现的已知端口的确认探测状态。
+type ScanResult struct {
+	Host      string         `json:"host"`
+	Addresses []string       `json:"addresses,omitempty"`
+	Open      map[int]string `json:"open"`
+	Confirmed map[int]string `json:"confirmed,omitempty"`
+}
+
+// Engine 执行扫描但不访问数据库：解析地址、按策略过滤目标、运行 naabu 并确认未发现的已知端口。
+// 并发的扫描共享同一发包速率预算。
+type Engine struct {
+	timeout time.Duration
+	budget  *rateBudget
+}
+
+// NewEngine 创建扫描引擎，timeout 为单端口探测超时，rate 为共享的发包速率预算（每秒）。
+func NewEngine(timeout time.Duration, rate int) *Engine {
+	if rate <= 0 {
+		rate = naabuRate
+	}
+	return &Engine{timeout: timeout, budget: newRateBudget(rate)}
+}
+
+// SetRate 调整共享的发包速率预算。
+func (e *Engine) SetRate(pps int) {
+	if pps > 0 {
+		e.budget.setTotal(pps)
+	}
+}
+
+// RateInUse 返回执行中的扫描已分配的发包速率总和。
+func (e *Engine) RateInUse() int {
+	return e.budget.inUse()
+}
+
+// Scan 执行一次扫描。解析失败或目标被策略拒绝时返回错误，已得到的解析结果仍随 ScanResult 返回。
+func (e *Engine) Scan(ctx context.Context, req ScanRequest) (*ScanResult, error) {
+	policy, err := req.Policy.Policy()
+	if err != nil {
+		return nil, fmt.Errorf("scan policy: %w", err)
+	}
+	res := targets.Resolve(ctx, req.Address)
+	result := &ScanResult{Host: res.Host, Open: map[int]string{}}
+	if net.ParseIP(res.Host) == nil {
+		result.Addresses = res.Addresses
+	}
+	if res.Err != nil {
+		// 策略拒绝优先于解析失败报告。
+		if _, err := policy.Apply(res); err != nil {
+			return result, err
+		}
+		return result, fmt.Errorf("resolve %s: %w", res.Host, res.Err)
+	}
+	scanTargets, err := policy.Apply(res)
+	if err != nil {
+		return result, err
+	}
+
+	ports := req.Ports
+	deadline := e.timeout * 500
+	if deadline < 2*time.Minute {
+		deadline = 2 * time.Minute
+	}
+	if len(req.Ports) > 0 {
+		if ports = policy.FilterPorts(req.Ports); len(ports) == 0 {
+			return result, nil
+		}
+		deadline = e.timeout * 10
+	}
+	scanCtx, cancel := context.WithTimeout(ctx, deadline)
+	defer cancel()
+	found, err := e.runBudgeted(scanCtx, scanTargets, ports, policy)
+	if err != nil {
+		return result, err
+	}
+	for number, info := range found {
+		service := serviceLabel(info)
+		if service == "" {
+			service = fingerprint.NameForPort(number)
+		}
+		result.Open[number] = service
+	}
+
+	missing := make([]int, 0, len(req.Known))
+	for _, number := range req.Known {
+		if _, ok := result.Open[number]; ok || !policy.PortAllowed(number) {
+			continue
+		}
+		missing = append(missing, number)
+	}
+	result.Confirmed = e.confirmPorts(ctx, scanTargets, missing)
+	return result, nil
+}
+
+// runBudgeted 从速率预算中取得份额后执行 naabu，结束后归还。
+func (e *Engine) runBudgeted(ctx context.Context, scanTargets []string, ports []int, policy *targets.Policy) (map[int]*portpkg.Port, error) {
+	rate, err := e.budget.acquire(ctx, naabuRate)
+	if err != nil {
+		return nil, fmt.Errorf("wait for rate budget: %w", err)
+	}
+	defer e.budget.release(rate)
+	return runNaabu(ctx, scanTargets, ports, policy, rate)
+}
+
+func serviceLabel(p *portpkg.Port) string {
+	if p == nil || p.Service == nil {
+		return ""
+	}
+	svc := p.Service
+	if svc.Product != "" && svc.Version != "" {
+		return fmt.Sprintf("%s %s", svc.Product, svc.Version)
+	}
+	if svc.Product != "" {
+		return svc.Product
+	}
+	if svc.Name != "" {
+		return svc.Name
+	}
+	if svc.ServiceFP != "" {
+		return svc.ServiceFP
+	}
+	if svc.ExtraInfo != "" {
+		return svc.ExtraInfo
+	}
+	return ""
+}
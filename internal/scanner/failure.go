@@ -0,0 +1,100 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/hitushen/portnotepro/internal/models"
+	"github.com/hitushen/portnotepro/internal/targets"
+)
+
+const (
+	// defaultScanAttempts 为一个任务最多执行的次数（含首次），defaultRetryBackoff 为首次重试前的等待时长。
+	defaultScanAttempts = 3
+	defaultRetryBackoff = 30 * time.Second
+	// maxRetryBackoff 为指数退避的上限。
+	maxRetryBackoff = 30 * time.Minute
+)
+
+// ScanError 为分类后的扫描失败，Kind 取 models.ScanFailure* 常量，Transient 表示可以重试。
+type ScanError struct {
+	Kind      string
+	Transient bool
+	Err       error
+}
+
+func (e *ScanError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ScanError) Unwrap() error {
+	return e.Err
+}
+
+// runnerInitError 标记 naabu runner 创建阶段的错误。
+type runnerInitError struct {
+	err error
+}
+
+func (e *runnerInitError) Error() string {
+	return "naabu runner init: " + e.err.Error()
+}
+
+func (e *runnerInitError) Unwrap() error {
+	return e.err
+}
+
+// ClassifyError 判断扫描错误的类别：DNS 解析、权限、超时、网络不可达、runner 初始化、策略拒绝或其他。
+func ClassifyError(err error) *ScanError {
+	var se *ScanError
+	if errors.As(err, &se) {
+		return se
+	}
+	var blocked *targets.BlockedError
+	if errors.As(err, &blocked) {
+		return &ScanError{Kind: models.ScanFailureBlocked, Err: err}
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		// 域名不存在不会自行恢复，其余（服务器超时、临时失败）按临时性失败重试。
+		return &ScanError{Kind: models.ScanFailureDNS, Transient: !dnsErr.IsNotFound, Err: err}
+	}
+	msg := strings.ToLower(err.Error())
+	switch {
+	case errors.Is(err, syscall.EPERM), errors.Is(err, syscall.EACCES), errors.Is(err, os.ErrPermission),
+		strings.Contains(msg, "permission denied"), strings.Contains(msg, "operation not permitted"):
+		return &ScanError{Kind: models.ScanFailurePermission, Err: err}
+	case errors.Is(err, context.DeadlineExceeded), isTimeout(err), strings.Contains(msg, "i/o timeout"):
+		return &ScanError{Kind: models.ScanFailureTimeout, Transient: true, Err: err}
+	case errors.Is(err, syscall.ENETUNREACH), errors.Is(err, syscall.EHOSTUNREACH),
+		strings.Contains(msg, "network is unreachable"), strings.Contains(msg, "no route to host"):
+		return &ScanError{Kind: models.ScanFailureUnreachable, Transient: true, Err: err}
+	}
+	var initErr *runnerInitError
+	if errors.As(err, &initErr) {
+		return &ScanError{Kind: models.ScanFailureRunnerInit, Err: err}
+	}
+	return &ScanError{Kind: models.ScanFailureOther, Err: err}
+}
+
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+// retryDelay 返回第 attempt 次执行失败后的等待时长：base·2^(attempt-1)，不超过 maxRetryBackoff。
+func retryDelay(base time.Duration, attempt int) time.Duration {
+	delay := base
+	for i := 1; i < attempt && delay < maxRetryBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryBackoff {
+		delay = maxRetryBackoff
+	}
+	return delay
+}
@@ -0,0 +1,176 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/hitushen/portnotepro/internal/models"
+	"github.com/hitushen/portnotepro/internal/store"
+	"github.com/hitushen/portnotepro/internal/targets"
+)
+
+func TestClassifyError(t *testing.T) {
+	opErr := func(err error) error {
+		return &net.OpError{Op: "dial", Net: "tcp", Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 22}, Err: err}
+	}
+	cases := []struct {
+		name      string
+		err       error
+		kind      string
+		transient bool
+	}{
+		{"context deadline", fmt.Errorf("run naabu: %w", context.DeadlineExceeded), models.ScanFailureTimeout, true},
+		{"dial deadline", opErr(os.ErrDeadlineExceeded), models.ScanFailureTimeout, true},
+		{"i/o timeout text", errors.New("read tcp 10.0.0.1:22: i/o timeout"), models.ScanFailureTimeout, true},
+		{"connection refused", opErr(os.NewSyscallError("connect", syscall.ECONNREFUSED)), models.ScanFailureOther, false},
+		{"network unreachable", opErr(os.NewSyscallError("connect", syscall.ENETUNREACH)), models.ScanFailureUnreachable, true},
+		{"no route to host", opErr(os.NewSyscallError("connect", syscall.EHOSTUNREACH)), models.ScanFailureUnreachable, true},
+		{"no route text", errors.New("sendto: no route to host"), models.ScanFailureUnreachable, true},
+		{"nxdomain", fmt.Errorf("resolve gone.example: %w", &net.DNSError{Err: "no such host", Name: "gone.example", IsNotFound: true}), models.ScanFailureDNS, false},
+		{"dns server timeout", &net.DNSError{Err: "i/o timeout", Name: "slow.example", IsTimeout: true}, models.ScanFailureDNS, true},
+		{"dns temporary", &net.DNSError{Err: "server misbehaving", Name: "flaky.example", IsTemporary: true}, models.ScanFailureDNS, true},
+		{"raw socket EPERM", os.NewSyscallError("socket", syscall.EPERM), models.ScanFailurePermission, false},
+		{"pcap EACCES", &os.PathError{Op: "open", Path: "/dev/bpf0", Err: syscall.EACCES}, models.ScanFailurePermission, false},
+		{"permission text", errors.New("could not open device: operation not permitted"), models.ScanFailurePermission, false},
+		{"runner init", &runnerInitError{errors.New("no interfaces")}, models.ScanFailureRunnerInit, false},
+		{"blocked", &targets.BlockedError{Target: "10.0.0.1", Reason: "denied by 10.0.0.0/8"}, models.ScanFailureBlocked, false},
+		{"already classified", fmt.Errorf("agent: %w", &ScanError{Kind: models.ScanFailureTimeout, Transient: true, Err: errors.New("lease expired")}), models.ScanFailureTimeout, true},
+		{"unknown", errors.New("naabu exited unexpectedly"), models.ScanFailureOther, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			se := ClassifyError(c.err)
+			if se.Kind != c.kind || se.Transient != c.transient {
+				t.Errorf("ClassifyError(%v) = %s transient=%v, want %s transient=%v", c.err, se.Kind, se.Transient, c.kind, c.transient)
+			}
+			// 新分类的错误包装原错误，已分类的错误原样返回。
+			if !errors.Is(se, c.err) && !errors.Is(c.err, se) {
+				t.Errorf("classified error %v is unrelated to %v", se, c.err)
+			}
+		})
+	}
+}
+
+func TestClassifyDialErrors(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	_, err = net.DialTimeout("tcp", addr, time.Second)
+	if err == nil {
+		t.Skip("port was reused before dialing")
+	}
+	if se := ClassifyError(err); se.Kind != models.ScanFailureOther || se.Transient {
+		t.Errorf("refused dial %v classified as %s transient=%v", err, se.Kind, se.Transient)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	_, err = (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	if se := ClassifyError(err); se.Kind != models.ScanFailureTimeout || !se.Transient {
+		t.Errorf("expired dial %v classified as %s transient=%v", err, se.Kind, se.Transient)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	cases := []struct {
+		base    time.Duration
+		attempt int
+		want    time.Duration
+	}{
+		{30 * time.Second, 1, 30 * time.Second},
+		{30 * time.Second, 2, time.Minute},
+		{30 * time.Second, 3, 2 * time.Minute},
+		{30 * time.Second, 6, 16 * time.Minute},
+		{30 * time.Second, 7, maxRetryBackoff},
+		{30 * time.Second, 100, maxRetryBackoff},
+		{time.Hour, 1, maxRetryBackoff},
+		{time.Second, 0, time.Second},
+	}
+	for _, c := range cases {
+		if got := retryDelay(c.base, c.attempt); got != c.want {
+			t.Errorf("retryDelay(%s, %d) = %s, want %s", c.base, c.attempt, got, c.want)
+		}
+	}
+}
+
+func TestCompleteRetryLimit(t *testing.T) {
+	ctx := context.Background()
+	tm := newTestManager(t)
+	tm.SetRetryPolicy(3, time.Minute)
+	hostID := tm.createHost(t, "web", "10.0.0.7")
+	job := &models.ScanJob{HostID: hostID, Kind: models.ScanJobPorts, Priority: models.ScanPriorityScheduled, Ports: []int{80}}
+	if _, err := tm.store.EnqueueScanJob(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	load := func() models.ScanJob {
+		t.Helper()
+		jobs, err := tm.store.ListScanJobs(ctx, store.ScanJobFilter{HostID: hostID})
+		if err != nil || len(jobs) != 1 {
+			t.Fatalf("jobs = %+v, %v", jobs, err)
+		}
+		return jobs[0]
+	}
+	timeout := fmt.Errorf("run naabu: %w", context.DeadlineExceeded)
+
+	// 临时性失败在未用完执行次数前按退避重新排队。
+	for attempt := 1; attempt < 3; attempt++ {
+		job.Attempts = attempt
+		before := time.Now()
+		tm.complete(job, nil, timeout)
+		got := load()
+		if got.Status != models.ScanJobQueued || got.ErrorKind != models.ScanFailureTimeout || got.NextAttemptAt == nil {
+			t.Fatalf("after attempt %d: %+v", attempt, got)
+		}
+		if wait := got.NextAttemptAt.Sub(before); wait < retryDelay(time.Minute, attempt)-time.Second || wait > retryDelay(time.Minute, attempt)+time.Second {
+			t.Errorf("after attempt %d next attempt in %s, want %s", attempt, wait, retryDelay(time.Minute, attempt))
+		}
+	}
+
+	// 用完执行次数后结束任务。
+	job.Attempts = 3
+	tm.complete(job, nil, timeout)
+	if got := load(); got.Status != models.ScanJobFailed || got.ErrorKind != models.ScanFailureTimeout {
+		t.Errorf("after the last attempt: %+v", got)
+	}
+	events := tm.eventsOf("host_scanned")
+	if len(events) != 3 {
+		t.Fatalf("got %d host_scanned events, want 3", len(events))
+	}
+	for i, evt := range events {
+		payload := evt.Payload.(map[string]interface{})
+		if retrying := payload["retrying"].(bool); retrying != (i < 2) {
+			t.Errorf("event %d retrying = %v", i, retrying)
+		}
+	}
+}
+
+func TestCompletePermanentFailure(t *testing.T) {
+	ctx := context.Background()
+	tm := newTestManager(t)
+	hostID := tm.createHost(t, "gone", "gone.portnote.test")
+	job := &models.ScanJob{HostID: hostID, Kind: models.ScanJobPorts, Priority: models.ScanPriorityScheduled, Ports: []int{22}}
+	if _, err := tm.store.EnqueueScanJob(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	job.Attempts = 1
+	tm.complete(job, nil, &net.DNSError{Err: "no such host", Name: "gone.portnote.test", IsNotFound: true})
+
+	jobs, err := tm.store.ListScanJobs(ctx, store.ScanJobFilter{HostID: hostID})
+	if err != nil || len(jobs) != 1 {
+		t.Fatalf("jobs = %+v, %v", jobs, err)
+	}
+	if jobs[0].Status != models.ScanJobFailed || jobs[0].ErrorKind != models.ScanFailureDNS {
+		t.Errorf("NXDOMAIN on the first attempt: %+v", jobs[0])
+	}
+}
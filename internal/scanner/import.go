@@ -0,0 +1,265 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hitushen/portnotepro/internal/models"
+	"github.com/hitushen/portnotepro/internal/realtime"
+	"github.com/hitushen/portnotepro/internal/scanimport"
+	"github.com/hitushen/portnotepro/internal/services/fingerprint"
+	"github.com/hitushen/portnotepro/internal/targets"
+)
+
+// ImportOptions 控制外部扫描结果的导入行为。
+type ImportOptions struct {
+	// CreateHosts 为 true 时为未匹配到主机的地址新建主机（不开启自动扫描）。
+	CreateHosts bool
+}
+
+// importHost 为导入过程中的主机状态，端口在首次命中时加载。
+type importHost struct {
+	host    models.Host
+	created bool
+	ports   map[int]*models.Port
+	changes []models.PortChange
+}
+
+// ImportResults 将外部扫描结果写入资产库，并以一次扫描的形式汇报变化。
+// 主机按 targets.Normalize 后的地址匹配（也匹配历史解析出的活跃 IP）；open 结果新建端口或将其标记为开放并记录服务名称，
+// closed 结果将已有端口标记为关闭，filtered 及非 TCP 结果跳过；新建主机前按扫描策略校验地址，被拒绝的地址跳过并记入警告。
+func (m *Manager) ImportResults(ctx context.Context, source string, results []scanimport.Result, opts ImportOptions) (*models.ScanRun, error) {
+	run := &models.ScanRun{
+		Source:    source,
+		StartedAt: time.Now().UTC(),
+		Results:   len(results),
+		Hosts:     []models.ScanRunHost{},
+		Warnings:  []string{},
+	}
+
+	hosts, err := m.store.ListHosts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byAddress := make(map[string]*importHost)
+	names := make(map[string]bool, len(hosts))
+	refs := make([]*importHost, len(hosts))
+	for i, h := range hosts {
+		names[h.Name] = true
+		refs[i] = &importHost{host: h}
+		if key := targets.Normalize(h.Address); key != "" && byAddress[key] == nil {
+			byAddress[key] = refs[i]
+		}
+	}
+	// 解析出的 IP 优先级低于直接配置的地址，不覆盖已有映射。
+	for i, h := range hosts {
+		addrs, err := m.store.ListHostAddresses(ctx, h.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range addrs {
+			if key := targets.Normalize(a.Address); a.Active && key != "" && byAddress[key] == nil {
+				byAddress[key] = refs[i]
+			}
+		}
+	}
+
+	var order []*importHost
+	seen := make(map[*importHost]bool)
+	unmatched := make(map[string]bool)
+	blocked := make(map[string]bool)
+	checkedAt := time.Now().UTC()
+
+	for _, r := range results {
+		if (r.Protocol != "" && !strings.EqualFold(r.Protocol, "tcp")) || r.Port < 1 || r.Port > maxPort {
+			run.Skipped++
+			continue
+		}
+		if r.State != scanimport.StateOpen && r.State != scanimport.StateClosed {
+			run.Skipped++
+			continue
+		}
+
+		hostname, address := targets.Normalize(r.Hostname), targets.Normalize(r.Address)
+		ih := byAddress[hostname]
+		if ih == nil {
+			ih = byAddress[address]
+		}
+		if ih == nil {
+			target := hostname
+			if target == "" {
+				target = address
+			}
+			if target == "" || !opts.CreateHosts {
+				run.Skipped++
+				if !unmatched[target] {
+					unmatched[target] = true
+					run.Warnings = append(run.Warnings, fmt.Sprintf("no host matches %q", target))
+				}
+				continue
+			}
+			if blocked[target] {
+				run.Skipped++
+				continue
+			}
+			if _, err := m.CheckTarget(ctx, 0, target); err != nil {
+				var be *targets.BlockedError
+				if !errors.As(err, &be) {
+					return nil, fmt.Errorf("check target %q: %w", target, err)
+				}
+				blocked[target] = true
+				run.Skipped++
+				run.Warnings = append(run.Warnings, fmt.Sprintf("%q is blocked by the scan policy: %s", target, be.Reason))
+				continue
+			}
+			name := uniqueName(names, target)
+			id, err := m.store.CreateHost(ctx, name, target, false)
+			if err != nil {
+				return nil, fmt.Errorf("create host %q: %w", name, err)
+			}
+			names[name] = true
+			ih = &importHost{host: models.Host{ID: id, Name: name, Address: target}, created: true}
+			byAddress[target] = ih
+			if address != "" {
+				byAddress[address] = ih
+			}
+			run.HostsCreated++
+			m.realtime.Publish(realtime.Event{
+				Type:   "host_created",
+				HostID: id,
+				Payload: map[string]interface{}{
+					"name":    name,
+					"address": target,
+				},
+			})
+		}
+		if !seen[ih] {
+			seen[ih] = true
+			order = append(order, ih)
+			if !ih.created {
+				run.HostsMatched++
+			}
+		}
+		if err := m.applyResult(ctx, ih, r, checkedAt, run); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, ih := range order {
+		if ih.changes == nil {
+			ih.changes = []models.PortChange{}
+		}
+		run.Hosts = append(run.Hosts, models.ScanRunHost{
+			HostID:  ih.host.ID,
+			Name:    ih.host.Name,
+			Address: ih.host.Address,
+			Created: ih.created,
+			Changes: ih.changes,
+		})
+		m.realtime.Publish(realtime.Event{
+			Type:   "host_scanned",
+			HostID: ih.host.ID,
+			Payload: map[string]interface{}{
+				"changed":   len(ih.changes) > 0,
+				"success":   true,
+				"completed": checkedAt,
+				"source":    source,
+			},
+		})
+		m.EvaluateCompliance(ctx, ih.host.ID)
+	}
+	run.FinishedAt = time.Now().UTC()
+	return run, nil
+}
+
+func (m *Manager) applyResult(ctx context.Context, ih *importHost, r scanimport.Result, checkedAt time.Time, run *models.ScanRun) error {
+	if ih.ports == nil {
+		ports, err := m.store.ListPorts(ctx, ih.host.ID, true)
+		if err != nil {
+			return err
+		}
+		ih.ports = make(map[int]*models.Port, len(ports))
+		for i := range ports {
+			ih.ports[ports[i].Number] = &ports[i]
+		}
+	}
+	hostID := ih.host.ID
+	port, exists := ih.ports[r.Port]
+
+	if r.State == scanimport.StateClosed {
+		if !exists || port.Status == models.PortStatusClosed {
+			return nil
+		}
+		if err := m.store.UpdatePortStatus(ctx, port.ID, models.PortStatusClosed, checkedAt); err != nil {
+			return err
+		}
+		previousStatus := port.Status
+		port.Status = models.PortStatusClosed
+		run.PortsClosed++
+		ih.changes = append(ih.changes, models.PortChange{PortID: port.ID, Number: port.Number, Change: "closed", Status: port.Status})
+		m.publishStatus(hostID, port.ID, port.Labels, previousStatus, models.PortStatusClosed, checkedAt)
+		return nil
+	}
+
+	if !exists {
+		service := r.Service
+		if service == "" {
+			service = fingerprint.NameForPort(r.Port)
+		}
+		note := service
+		if note == "" {
+			note = fmt.Sprintf("Port %d", r.Port)
+		}
+		id, err := m.store.CreateDetectedPort(ctx, hostID, r.Port, note, service, checkedAt)
+		if err != nil {
+			return fmt.Errorf("create port %d: %w", r.Port, err)
+		}
+		if err := m.store.UpdatePortStatus(ctx, id, models.PortStatusOpen, checkedAt); err != nil {
+			return err
+		}
+		ih.ports[r.Port] = &models.Port{ID: id, HostID: hostID, Number: r.Port, Note: note, Fingerprint: service, DetectedService: service, Status: models.PortStatusOpen}
+		run.PortsCreated++
+		ih.changes = append(ih.changes, models.PortChange{PortID: id, Number: r.Port, Change: "created", Status: models.PortStatusOpen, Service: service})
+		m.realtime.Publish(realtime.Event{
+			Type:   "port_created",
+			HostID: hostID,
+			PortID: id,
+			Payload: map[string]interface{}{
+				"number":      r.Port,
+				"fingerprint": service,
+			},
+		})
+		m.publishStatus(hostID, id, nil, "", models.PortStatusOpen, checkedAt)
+		return nil
+	}
+
+	if err := m.store.UpdatePortStatus(ctx, port.ID, models.PortStatusOpen, checkedAt); err != nil {
+		return err
+	}
+	previousStatus := port.Status
+	if port.Status != models.PortStatusOpen {
+		port.Status = models.PortStatusOpen
+		run.PortsOpened++
+		ih.changes = append(ih.changes, models.PortChange{PortID: port.ID, Number: port.Number, Change: "opened", Status: port.Status})
+	}
+	previous := port.DetectedService
+	if m.recordService(ctx, hostID, port, r.Service, checkedAt) {
+		port.DetectedService = r.Service
+		run.ServicesChanged++
+		ih.changes = append(ih.changes, models.PortChange{PortID: port.ID, Number: port.Number, Change: "service", Status: port.Status, Service: r.Service, Previous: previous})
+	}
+	m.publishStatus(hostID, port.ID, port.Labels, previousStatus, models.PortStatusOpen, checkedAt)
+	return nil
+}
+
+// uniqueName 返回未被占用的主机名称，冲突时追加 -2、-3……
+func uniqueName(names map[string]bool, base string) string {
+	name := base
+	for i := 2; names[name]; i++ {
+		name = fmt.Sprintf("%s-%d", base, i)
+	}
+	return name
+}
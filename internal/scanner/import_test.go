@@ -0,0 +1,43 @@
+package scanner
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hitushen/portnotepro/internal/scanimport"
+	"github.com/hitushen/portnotepro/internal/targets"
+)
+
+func TestImportResultsRespectsPolicy(t *testing.T) {
+	ctx := context.Background()
+	tm := newTestManager(t)
+	policy, err := targets.NewPolicy(nil, []string{"192.168.0.0/16"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tm.SetBasePolicy(policy)
+
+	results := []scanimport.Result{
+		{Address: "10.0.0.9", Port: 22, State: scanimport.StateOpen},
+		{Address: "192.168.1.5", Port: 22, State: scanimport.StateOpen},
+		{Address: "192.168.1.5", Port: 80, State: scanimport.StateOpen},
+	}
+	run, err := tm.ImportResults(ctx, "nmap", results, ImportOptions{CreateHosts: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.HostsCreated != 1 || run.PortsCreated != 1 || run.Skipped != 2 {
+		t.Errorf("run = created %d hosts, %d ports, skipped %d; want 1, 1, 2", run.HostsCreated, run.PortsCreated, run.Skipped)
+	}
+	if len(run.Warnings) != 1 || !strings.Contains(run.Warnings[0], "192.168.1.5") {
+		t.Errorf("warnings = %q, want one for the blocked address", run.Warnings)
+	}
+	hosts, err := tm.store.ListHosts(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 1 || hosts[0].Address != "10.0.0.9" {
+		t.Errorf("hosts after import = %+v, want only 10.0.0.9", hosts)
+	}
+}
@@ -2,95 +2,283 @@ package scanner
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	portpkg "github.com/projectdiscovery/naabu/v2/pkg/port"
-
 	"github.com/hitushen/portnotepro/internal/models"
 	"github.com/hitushen/portnotepro/internal/realtime"
-	"github.com/hitushen/portnotepro/internal/services/fingerprint"
 	"github.com/hitushen/portnotepro/internal/store"
+	"github.com/hitushen/portnotepro/internal/targets"
 )
 
 const maxPort = 65535
 
-// Manager 负责协调后台端口扫描任务。
+const (
+	// jobPollInterval 为没有唤醒信号时检查任务队列的间隔（多实例共用数据库时也能领取到其他实例排入的任务）。
+	jobPollInterval = 5 * time.Second
+	// jobRetention 为已结束任务记录的保留时长。
+	jobRetention = 7 * 24 * time.Hour
+	// localLease 为本地执行任务的租约时长，执行期间每次轮询时续约；实例退出或失联后，其他实例在租约到期后收回任务。
+	localLease = time.Minute
+)
+
+// Manager 负责协调后台端口扫描任务。任务持久化在 scan_jobs 表中，由调度协程按空闲工作槽领取执行。
 type Manager struct {
-	store        *store.Store
-	timeout      time.Duration
-	concurrency  int
-	jobs         chan scanJob
+	store        store.Storage
+	engine       *Engine
+	wakeCh       chan struct{}
 	wg           sync.WaitGroup
 	realtime     *realtime.Broker
+	startOnce    sync.Once
 	shutdownOnce sync.Once
 	stopCh       chan struct{}
-}
+	// active 为正在执行的任务数，concurrency 为同时执行的任务数上限，perHost 为单台主机同时执行的任务数上限。
+	active      atomic.Int64
+	concurrency atomic.Int64
+	perHost     atomic.Int64
+	// attempts 为任务最多执行的次数，retryBackoff 为临时性失败后首次重试的等待时长。
+	attempts     atomic.Int64
+	retryBackoff atomic.Int64
+	// missThreshold 为开放端口改为未开放前需要连续未被发现的次数。
+	missThreshold atomic.Int64
+	// agentLease 为 agent 领取任务的租约时长。
+	agentLease atomic.Int64
+	// instanceID 标识本实例，记录在本地领取的任务上，需在 Start 前设置。
+	instanceID string
 
-type scanJob struct {
-	HostID    int64
-	Ports     []models.Port
-	fullRange bool
+	policyMu   sync.RWMutex
+	basePolicy *targets.Policy
 }
 
-// NewManager 按照指定参数启动工作协程执行扫描。
-func NewManager(st *store.Store, timeout time.Duration, concurrency int, broker *realtime.Broker) *Manager {
+// NewManager 创建扫描管理器，concurrency 为同时执行的任务数上限。调用 Start 后才开始执行队列中的任务。
+func NewManager(st store.Storage, timeout time.Duration, concurrency int, broker *realtime.Broker) *Manager {
 	if concurrency <= 0 {
 		concurrency = 1
 	}
 	m := &Manager{
-		store:       st,
-		timeout:     timeout,
-		concurrency: concurrency,
-		jobs:        make(chan scanJob, concurrency*2),
-		realtime:    broker,
-		stopCh:      make(chan struct{}),
-	}
-	for i := 0; i < concurrency; i++ {
-		m.wg.Add(1)
-		go m.worker()
+		store:    st,
+		engine:   NewEngine(timeout, naabuRate),
+		wakeCh:   make(chan struct{}, 1),
+		realtime: broker,
+		stopCh:   make(chan struct{}),
+	}
+	m.concurrency.Store(int64(concurrency))
+	m.perHost.Store(1)
+	m.attempts.Store(defaultScanAttempts)
+	m.retryBackoff.Store(int64(defaultRetryBackoff))
+	m.missThreshold.Store(defaultMissThreshold)
+	m.agentLease.Store(int64(defaultAgentLease))
+	m.instanceID, _ = os.Hostname()
+	if m.instanceID == "" {
+		m.instanceID = "portnote"
 	}
 	return m
 }
 
-// ScheduleHost 为指定主机排入一次全端口扫描任务。
-func (m *Manager) ScheduleHost(_ context.Context, hostID int64, _ bool) bool {
-	return m.ScheduleFullRange(hostID)
+// SetInstanceID 设置本实例的标识，默认为主机名。多个实例共用数据库时各自的标识必须不同，
+// 重启后沿用同一标识才能立即恢复上次未完成的任务。需在 Start 前调用。
+func (m *Manager) SetInstanceID(id string) {
+	if id != "" {
+		m.instanceID = id
+	}
 }
 
-// SchedulePorts 将一组端口加入扫描队列。
+// SetMissThreshold 设置开放端口连续多少次扫描未被发现（且确认探测未连通）后才改变状态，默认为 2。
+func (m *Manager) SetMissThreshold(n int) {
+	if n > 0 {
+		m.missThreshold.Store(int64(n))
+	}
+}
+
+// SetRetryPolicy 设置任务最多执行的次数（含首次）与首次重试前的等待时长，之后每次翻倍，最长 30 分钟。
+func (m *Manager) SetRetryPolicy(attempts int, backoff time.Duration) {
+	if attempts > 0 {
+		m.attempts.Store(int64(attempts))
+	}
+	if backoff > 0 {
+		m.retryBackoff.Store(int64(backoff))
+	}
+}
+
+// SetRateBudget 设置所有并发扫描共享的发包速率预算（每秒），默认为单次扫描的上限 3000。
+func (m *Manager) SetRateBudget(pps int) {
+	m.engine.SetRate(pps)
+}
+
+// SetConcurrency 在运行中调整同时执行的任务数上限。调小时正在执行的任务不受影响，空出工作槽后才按新上限领取。
+func (m *Manager) SetConcurrency(n int) {
+	if n > 0 {
+		m.concurrency.Store(int64(n))
+		m.wake()
+	}
+}
+
+// SetPerHostConcurrency 设置单台主机同时执行的任务数上限，默认为 1。
+func (m *Manager) SetPerHostConcurrency(n int) {
+	if n > 0 {
+		m.perHost.Store(int64(n))
+		m.wake()
+	}
+}
+
+// RateInUse 返回当前执行中的扫描已分配的发包速率总和。
+func (m *Manager) RateInUse() int {
+	return m.engine.RateInUse()
+}
+
+// Start 恢复上次退出时未完成的任务并启动调度协程。
+func (m *Manager) Start() {
+	m.startOnce.Do(func() {
+		requeued, reset, err := m.store.RecoverScanJobs(context.Background(), m.instanceID)
+		if err != nil {
+			log.Printf("[scanner] recover scan jobs failed: %v", err)
+		} else if requeued > 0 || reset > 0 {
+			log.Printf("[scanner] recovered scan queue: requeued=%d stale_scanning_reset=%d", requeued, reset)
+		}
+		m.wg.Add(1)
+		go m.dispatchLoop()
+	})
+}
+
+// SetBasePolicy 设置来自配置的基础扫描策略（白名单与静态拒绝列表）。
+func (m *Manager) SetBasePolicy(policy *targets.Policy) {
+	m.policyMu.Lock()
+	m.basePolicy = policy
+	m.policyMu.Unlock()
+}
+
+// Policy 在基础策略上叠加数据库中的全局与主机级排除规则。
+func (m *Manager) Policy(ctx context.Context, hostID int64) (*targets.Policy, error) {
+	m.policyMu.RLock()
+	policy := m.basePolicy.Clone()
+	m.policyMu.RUnlock()
+
+	rules, err := m.store.ListExclusions(ctx, hostID)
+	if err != nil {
+		return nil, fmt.Errorf("load exclusions: %w", err)
+	}
+	for _, rule := range rules {
+		if err := policy.AddRule(rule.Kind, rule.Value); err != nil {
+			log.Printf("[scanner] skip invalid exclusion id=%d err=%v", rule.ID, err)
+		}
+	}
+	return policy, nil
+}
+
+// CheckTarget 按当前策略校验地址，返回允许扫描的目标；被拒绝时返回 *targets.BlockedError。
+func (m *Manager) CheckTarget(ctx context.Context, hostID int64, address string) ([]string, error) {
+	policy, err := m.Policy(ctx, hostID)
+	if err != nil {
+		return nil, err
+	}
+	return targets.Build(ctx, address, policy)
+}
+
+// ScheduleHost 为指定主机排入一次全端口扫描任务，manual 为 true 时按手动扫描优先执行。
+func (m *Manager) ScheduleHost(_ context.Context, hostID int64, manual bool) bool {
+	if manual {
+		return m.ScheduleFullRange(hostID, models.ScanPriorityManual)
+	}
+	return m.ScheduleFullRange(hostID, models.ScanPriorityScheduled)
+}
+
+// SchedulePorts 将一组端口的定向复查以交互优先级加入扫描队列。
 func (m *Manager) SchedulePorts(hostID int64, ports []models.Port) {
 	if len(ports) == 0 {
 		return
 	}
-	select {
-	case m.jobs <- scanJob{HostID: hostID, Ports: ports}:
-	case <-m.stopCh:
+	set := make(map[int]struct{}, len(ports))
+	for _, p := range ports {
+		set[p.Number] = struct{}{}
+	}
+	numbers := make([]int, 0, len(set))
+	for n := range set {
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+	job := &models.ScanJob{HostID: hostID, Kind: models.ScanJobPorts, Priority: models.ScanPriorityInteractive, Ports: numbers}
+	if _, err := m.store.EnqueueScanJob(context.Background(), job); err != nil {
+		log.Printf("[scanner] enqueue port scan failed host=%d err=%v", hostID, err)
+		return
 	}
+	m.wake()
 }
 
-// ScheduleFullRange 为主机排入 1-65535 全范围扫描。
-func (m *Manager) ScheduleFullRange(hostID int64) bool {
-	ok, err := m.store.BeginScan(context.Background(), hostID)
+// ScheduleFullRange 以指定优先级为主机排入 1-65535 全范围扫描。主机已有排队中的全范围扫描时
+// 将其提升到该优先级（如定时扫描尚未执行时用户手动触发），已在执行时返回 false。
+func (m *Manager) ScheduleFullRange(hostID int64, priority string) bool {
+	ctx := context.Background()
+	ok, err := m.store.BeginScan(ctx, hostID)
 	if err != nil {
 		log.Printf("[scanner] begin scan error host=%d err=%v", hostID, err)
 		return false
 	}
 	if !ok {
-		return false
+		queued, err := m.store.PromoteScanJob(ctx, hostID, models.ScanJobFull, priority)
+		if err != nil {
+			log.Printf("[scanner] promote scan error host=%d err=%v", hostID, err)
+			return false
+		}
+		if queued {
+			m.wake()
+		}
+		return queued
 	}
-	m.publishScanStarted(hostID)
-	select {
-	case m.jobs <- scanJob{HostID: hostID, fullRange: true}:
-		log.Printf("[scanner] enqueued full scan for host=%d", hostID)
-	case <-m.stopCh:
+	job := &models.ScanJob{HostID: hostID, Kind: models.ScanJobFull, Priority: priority}
+	if _, err := m.store.EnqueueScanJob(context.Background(), job); err != nil {
+		log.Printf("[scanner] enqueue full scan failed host=%d err=%v", hostID, err)
 		_ = m.store.EndScan(context.Background(), hostID)
+		return false
 	}
+	log.Printf("[scanner] enqueued full scan for host=%d job=%d", hostID, job.ID)
+	m.publishScanStarted(hostID)
+	m.wake()
 	return true
 }
 
+// ErrScanInProgress 表示主机已有执行中或排队中的全范围扫描。
+var ErrScanInProgress = errors.New("a full scan of this host is already queued or running")
+
+// ScanNow 在当前协程中对主机执行一次全范围扫描并写入结果，不经过任务队列，也不重试，供命令行一次性扫描使用。
+// 返回扫描结果与端口状态是否变化。
+func (m *Manager) ScanNow(ctx context.Context, hostID int64) (*ScanResult, bool, error) {
+	ok, err := m.store.BeginScan(ctx, hostID)
+	if err != nil {
+		return nil, false, err
+	}
+	if !ok {
+		return nil, false, ErrScanInProgress
+	}
+	defer func() { _ = m.store.EndScan(context.Background(), hostID) }()
+
+	job := &models.ScanJob{HostID: hostID, Kind: models.ScanJobFull, Priority: models.ScanPriorityManual}
+	req, err := m.prepare(ctx, job)
+	if err != nil {
+		return nil, false, err
+	}
+	result, err := m.engine.Scan(ctx, *req)
+	if result != nil {
+		m.recordAddresses(ctx, hostID, result)
+	}
+	if err != nil {
+		se := ClassifyError(err)
+		m.recordResult(ctx, hostID, se.Kind, se.Error())
+		return result, false, se
+	}
+	changed, err := m.apply(ctx, job, result)
+	if err != nil {
+		return result, changed, err
+	}
+	m.recordResult(ctx, hostID, "", "")
+	return result, changed, nil
+}
+
 // StartTicker 启动周期任务，定期扫描所有主机。
 func (m *Manager) StartTicker(interval time.Duration) {
 	if interval <= 0 {
@@ -119,101 +307,318 @@ func (m *Manager) scanAll() {
 		return
 	}
 	for _, host := range hosts {
-		m.ScheduleFullRange(host.ID)
+		m.ScheduleFullRange(host.ID, models.ScanPriorityScheduled)
 	}
 }
 
-// Close 优雅停止所有扫描协程。
+// Close 停止领取新任务并等待执行中的任务结束，排队中的任务留在队列里，下次启动后继续执行。
 func (m *Manager) Close() {
 	m.shutdownOnce.Do(func() {
 		close(m.stopCh)
-		close(m.jobs)
 	})
 	m.wg.Wait()
 }
 
-func (m *Manager) worker() {
+// QueueDepth 返回排队等待执行的扫描任务数。
+func (m *Manager) QueueDepth() int {
+	n, err := m.store.CountScanJobs(context.Background(), models.ScanJobQueued)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// ActiveWorkers 返回正在执行扫描任务的工作协程数。
+func (m *Manager) ActiveWorkers() int {
+	return int(m.active.Load())
+}
+
+// Concurrency 返回工作协程总数。
+func (m *Manager) Concurrency() int {
+	return int(m.concurrency.Load())
+}
+
+// wake 通知调度协程检查队列。
+func (m *Manager) wake() {
+	select {
+	case m.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+func (m *Manager) dispatchLoop() {
 	defer m.wg.Done()
-	for job := range m.jobs {
-		m.handleJob(job)
+	poll := time.NewTicker(jobPollInterval)
+	defer poll.Stop()
+	prune := time.NewTicker(time.Hour)
+	defer prune.Stop()
+	m.pruneJobs()
+	for {
+		m.dispatch()
+		select {
+		case <-m.wakeCh:
+		case <-poll.C:
+			m.renewLeases()
+			m.expireLeases()
+		case <-prune.C:
+			m.pruneJobs()
+		case <-m.stopCh:
+			return
+		}
+	}
+}
+
+// dispatch 在有空闲工作槽时持续领取排队的任务。
+func (m *Manager) dispatch() {
+	for m.active.Load() < m.concurrency.Load() {
+		select {
+		case <-m.stopCh:
+			return
+		default:
+		}
+		job, err := m.store.ClaimScanJob(context.Background(), m.instanceID, int(m.perHost.Load()), time.Now().Add(localLease))
+		if err != nil {
+			log.Printf("[scanner] claim scan job failed: %v", err)
+			return
+		}
+		if job == nil {
+			return
+		}
+		m.active.Add(1)
+		m.wg.Add(1)
+		go m.run(job)
 	}
 }
 
-func (m *Manager) handleJob(job scanJob) {
+// run 在本地执行任务并记录结果。
+func (m *Manager) run(job *models.ScanJob) {
+	defer m.wg.Done()
+	defer func() {
+		m.active.Add(-1)
+		m.wake()
+	}()
 	ctx := context.Background()
+	req, err := m.prepare(ctx, job)
+	result := &ScanResult{}
+	if err == nil && req != nil {
+		if job.Kind == models.ScanJobFull {
+			log.Printf("[scanner] starting full scan host=%d addr=%s", job.HostID, req.Address)
+		}
+		start := time.Now()
+		result, err = m.engine.Scan(ctx, *req)
+		if err == nil && job.Kind == models.ScanJobFull {
+			log.Printf("[scanner] completed naabu scan host=%d duration=%s", job.HostID, time.Since(start).Truncate(time.Millisecond))
+		}
+	}
+	// 租约已被收回时任务已重新排队，可能正由其他实例执行，丢弃本次结果以免覆盖。
+	if ok, rerr := m.store.ReleaseScanJob(ctx, job.ID, m.instanceID); rerr != nil || !ok {
+		log.Printf("[scanner] scan job=%d lease lost, discarding result: %v", job.ID, rerr)
+		return
+	}
+	m.complete(job, result, err)
+}
+
+// renewLeases 为本实例正在执行的任务续约。
+func (m *Manager) renewLeases() {
+	if m.active.Load() == 0 {
+		return
+	}
+	if _, err := m.store.RenewScanJobs(context.Background(), m.instanceID, time.Now().Add(localLease)); err != nil {
+		log.Printf("[scanner] renew scan leases failed: %v", err)
+	}
+}
+
+// prepare 生成任务的扫描参数：加载主机、当前策略与已记录的端口。定向复查的端口均已删除时返回 nil。
+func (m *Manager) prepare(ctx context.Context, job *models.ScanJob) (*ScanRequest, error) {
 	host, err := m.store.GetHost(ctx, job.HostID)
 	if err != nil {
-		if job.fullRange {
-			_ = m.store.EndScan(context.Background(), job.HostID)
+		return nil, fmt.Errorf("load host: %w", err)
+	}
+	policy, err := m.Policy(ctx, host.ID)
+	if err != nil {
+		return nil, err
+	}
+	req := &ScanRequest{Address: host.Address, Policy: policy.Spec()}
+	if job.Kind == models.ScanJobFull {
+		existing, err := m.store.ListPorts(ctx, host.ID, true)
+		if err != nil {
+			return nil, err
 		}
-		return
+		for _, p := range existing {
+			req.Known = append(req.Known, p.Number)
+		}
+		return req, nil
+	}
+	ports, err := m.jobPorts(ctx, host.ID, job.Ports)
+	if err != nil {
+		return nil, err
+	}
+	if len(ports) == 0 {
+		return nil, nil
+	}
+	for _, p := range ports {
+		req.Ports = append(req.Ports, p.Number)
 	}
-	if job.fullRange {
-		changed, scanErr := m.scanFullRange(ctx, host)
-		if scanErr != nil {
-			log.Printf("[scanner] scan failed host=%d err=%v", host.ID, scanErr)
+	req.Known = req.Ports
+	return req, nil
+}
+
+// complete 记录任务结果，本地执行与 agent 回传共用。成功时写入端口状态；临时性失败且未用完重试次数时
+// 按退避重新排队，全范围扫描的 scanning 标记保持不变；其余情况结束任务。结果都会记到主机上并推送 host_scanned 事件。
+func (m *Manager) complete(job *models.ScanJob, result *ScanResult, err error) {
+	ctx := context.Background()
+	if result != nil {
+		m.recordAddresses(ctx, job.HostID, result)
+	}
+	changed := false
+	if err == nil {
+		changed, err = m.apply(ctx, job, result)
+	}
+	if err == nil {
+		if ferr := m.store.FinishScanJob(ctx, job.ID, "", ""); ferr != nil {
+			log.Printf("[scanner] finish scan job=%d failed: %v", job.ID, ferr)
 		}
-		if err := m.store.EndScan(context.Background(), host.ID); err != nil {
-			log.Printf("[scanner] end scan error host=%d err=%v", host.ID, err)
+		if job.Kind == models.ScanJobFull {
+			_ = m.store.EndScan(ctx, job.HostID)
+		}
+		failures := m.recordResult(ctx, job.HostID, "", "")
+		if job.Kind == models.ScanJobFull {
+			m.publishScanned(job.HostID, map[string]interface{}{
+				"changed":             changed,
+				"success":             true,
+				"consecutiveFailures": failures,
+			})
 		}
-		m.realtime.Publish(realtime.Event{
-			Type:   "host_scanned",
-			HostID: host.ID,
-			Payload: map[string]interface{}{
-				"changed":   changed,
-				"success":   scanErr == nil,
-				"completed": time.Now().UTC(),
-			},
-		})
 		return
 	}
-	m.scanSpecificPorts(ctx, host, job.Ports)
+
+	se := ClassifyError(err)
+	msg := se.Error()
+	payload := map[string]interface{}{
+		"changed":   changed,
+		"success":   false,
+		"error":     msg,
+		"errorKind": se.Kind,
+		"attempt":   job.Attempts,
+	}
+	if se.Transient && job.Attempts < int(m.attempts.Load()) {
+		next := time.Now().UTC().Add(retryDelay(time.Duration(m.retryBackoff.Load()), job.Attempts))
+		if rerr := m.store.RetryScanJob(ctx, job.ID, se.Kind, msg, next); rerr != nil {
+			log.Printf("[scanner] requeue scan job=%d failed: %v", job.ID, rerr)
+		} else {
+			log.Printf("[scanner] scan failed host=%d job=%d kind=%s attempt=%d, retrying at %s: %v",
+				job.HostID, job.ID, se.Kind, job.Attempts, next.Format(time.RFC3339), err)
+			payload["retrying"] = true
+			payload["nextAttemptAt"] = next
+			payload["consecutiveFailures"] = m.recordResult(ctx, job.HostID, se.Kind, msg)
+			m.publishScanned(job.HostID, payload)
+			return
+		}
+	}
+
+	log.Printf("[scanner] scan failed host=%d job=%d kind=%s attempt=%d: %v", job.HostID, job.ID, se.Kind, job.Attempts, err)
+	if ferr := m.store.FinishScanJob(ctx, job.ID, se.Kind, msg); ferr != nil {
+		log.Printf("[scanner] finish scan job=%d failed: %v", job.ID, ferr)
+	}
+	if job.Kind == models.ScanJobFull {
+		_ = m.store.EndScan(ctx, job.HostID)
+	}
+	payload["retrying"] = false
+	payload["consecutiveFailures"] = m.recordResult(ctx, job.HostID, se.Kind, msg)
+	m.publishScanned(job.HostID, payload)
 }
 
-func (m *Manager) scanFullRange(ctx context.Context, host *models.Host) (bool, error) {
-	start := time.Now()
-	log.Printf("[scanner] starting full scan host=%d addr=%s", host.ID, host.Address)
-	existingPorts, err := m.store.ListPorts(ctx, host.ID, true)
+// recordResult 把扫描结果记到主机上，返回连续失败次数。
+func (m *Manager) recordResult(ctx context.Context, hostID int64, kind, msg string) int {
+	failures, err := m.store.RecordScanResult(ctx, hostID, time.Now(), kind, msg)
 	if err != nil {
-		return false, err
+		log.Printf("[scanner] record scan result failed host=%d err=%v", hostID, err)
 	}
+	return failures
+}
 
-	existing := make(map[int]models.Port, len(existingPorts))
-	for _, p := range existingPorts {
-		existing[p.Number] = p
+func (m *Manager) publishScanned(hostID int64, payload map[string]interface{}) {
+	payload["completed"] = time.Now().UTC()
+	m.realtime.Publish(realtime.Event{
+		Type:    "host_scanned",
+		HostID:  hostID,
+		Payload: payload,
+	})
+}
+
+func (m *Manager) pruneJobs() {
+	n, err := m.store.PruneScanJobs(context.Background(), time.Now().Add(-jobRetention))
+	if err != nil {
+		log.Printf("[scanner] prune scan jobs failed: %v", err)
+	} else if n > 0 {
+		log.Printf("[scanner] pruned %d finished scan jobs", n)
+	}
+}
+
+// apply 将扫描结果写入端口状态并重新评估基线，返回全范围扫描是否发现变化。
+func (m *Manager) apply(ctx context.Context, job *models.ScanJob, result *ScanResult) (bool, error) {
+	host, err := m.store.GetHost(ctx, job.HostID)
+	if err != nil {
+		return false, fmt.Errorf("load host: %w", err)
 	}
+	policy, err := m.Policy(ctx, host.ID)
+	if err != nil {
+		return false, err
+	}
+	changed := false
+	if job.Kind == models.ScanJobFull {
+		changed, err = m.applyFullRange(ctx, host, job, policy, result)
+	} else {
+		err = m.applyPorts(ctx, host, job, policy, result)
+	}
+	if err != nil {
+		return changed, err
+	}
+	m.EvaluateCompliance(ctx, host.ID)
+	return changed, nil
+}
 
-	deadline := m.timeout * 500
-	if deadline < 2*time.Minute {
-		deadline = 2 * time.Minute
+// jobPorts 按端口号读取任务涉及的端口当前记录，已删除的端口跳过。
+func (m *Manager) jobPorts(ctx context.Context, hostID int64, numbers []int) ([]models.Port, error) {
+	all, err := m.store.ListPorts(ctx, hostID, true)
+	if err != nil {
+		return nil, err
+	}
+	wanted := make(map[int]bool, len(numbers))
+	for _, n := range numbers {
+		wanted[n] = true
 	}
-	scanCtx, cancel := context.WithTimeout(ctx, deadline)
-	defer cancel()
+	ports := make([]models.Port, 0, len(numbers))
+	for _, p := range all {
+		if wanted[p.Number] {
+			ports = append(ports, p)
+		}
+	}
+	return ports, nil
+}
 
-	naabuPorts, err := runNaabu(scanCtx, host.Address, nil)
+func (m *Manager) applyFullRange(ctx context.Context, host *models.Host, job *models.ScanJob, policy *targets.Policy, result *ScanResult) (bool, error) {
+	existingPorts, err := m.store.ListPorts(ctx, host.ID, true)
 	if err != nil {
 		return false, err
 	}
 
+	existing := make(map[int]models.Port, len(existingPorts))
+	for _, p := range existingPorts {
+		existing[p.Number] = p
+	}
+
 	checkedAt := time.Now().UTC()
 	changed := false
-	openSet := make(map[int]struct{}, len(naabuPorts))
 
-	for portNum, portInfo := range naabuPorts {
-		openSet[portNum] = struct{}{}
-		serviceName := serviceLabel(portInfo)
-		if serviceName == "" {
-			serviceName = fingerprint.NameForPort(portNum)
-		}
+	for portNum, serviceName := range result.Open {
 		if existingPort, ok := existing[portNum]; ok {
 			if existingPort.Status != models.PortStatusOpen {
 				changed = true
 			}
 			_ = m.store.UpdatePortStatus(ctx, existingPort.ID, models.PortStatusOpen, checkedAt)
-			if serviceName != "" && serviceName != existingPort.Fingerprint {
-				_ = m.store.UpdatePortFingerprint(ctx, existingPort.ID, serviceName)
-			}
-			m.publishStatus(host.ID, existingPort.ID, models.PortStatusOpen, checkedAt)
+			m.recordService(ctx, host.ID, &existingPort, serviceName, checkedAt)
+			m.publishStatus(host.ID, existingPort.ID, existingPort.Labels, existingPort.Status, models.PortStatusOpen, checkedAt)
 			continue
 		}
 
@@ -221,7 +626,7 @@ func (m *Manager) scanFullRange(ctx context.Context, host *models.Host) (bool, e
 		if note == "" {
 			note = fmt.Sprintf("Port %d", portNum)
 		}
-		id, err := m.store.CreatePort(ctx, host.ID, portNum, note, serviceName)
+		id, err := m.store.CreateDetectedPort(ctx, host.ID, portNum, note, serviceName, checkedAt)
 		if err != nil {
 			log.Printf("[scanner] create port failed host=%d port=%d err=%v", host.ID, portNum, err)
 			continue
@@ -237,95 +642,119 @@ func (m *Manager) scanFullRange(ctx context.Context, host *models.Host) (bool, e
 				"fingerprint": serviceName,
 			},
 		})
-		m.publishStatus(host.ID, id, models.PortStatusOpen, checkedAt)
+		m.publishStatus(host.ID, id, nil, "", models.PortStatusOpen, checkedAt)
 	}
 
+	missing := make([]models.Port, 0, len(existingPorts))
 	for _, port := range existingPorts {
-		if _, ok := openSet[port.Number]; ok {
+		if _, ok := result.Open[port.Number]; ok {
 			continue
 		}
-		if port.Status != models.PortStatusClosed {
-			changed = true
+		// 被排除的端口未参与扫描，保留原状态。
+		if !policy.PortAllowed(port.Number) {
+			continue
 		}
-		_ = m.store.UpdatePortStatus(ctx, port.ID, models.PortStatusClosed, checkedAt)
-		m.publishStatus(host.ID, port.ID, models.PortStatusClosed, checkedAt)
+		missing = append(missing, port)
+	}
+	if m.settleMissing(ctx, host.ID, missing, result.Confirmed, checkedAt, m.missThresholdFor(job)) {
+		changed = true
 	}
-
-	log.Printf("[scanner] completed naabu scan host=%d duration=%s", host.ID, time.Since(start).Truncate(time.Millisecond))
 	return changed, nil
 }
 
-func (m *Manager) scanSpecificPorts(ctx context.Context, host *models.Host, ports []models.Port) {
-	if len(ports) == 0 {
-		return
+func (m *Manager) applyPorts(ctx context.Context, host *models.Host, job *models.ScanJob, policy *targets.Policy, result *ScanResult) error {
+	ports, err := m.jobPorts(ctx, host.ID, job.Ports)
+	if err != nil {
+		return err
 	}
-	portNums := make([]int, len(ports))
-	for i, p := range ports {
-		portNums[i] = p.Number
+	checkedAt := time.Now().UTC()
+	missing := make([]models.Port, 0, len(ports))
+	for _, port := range ports {
+		if !policy.PortAllowed(port.Number) {
+			log.Printf("[scanner] skip excluded port host=%d port=%d", host.ID, port.Number)
+			continue
+		}
+		serviceName, ok := result.Open[port.Number]
+		if !ok {
+			missing = append(missing, port)
+			continue
+		}
+		m.recordService(ctx, host.ID, &port, serviceName, checkedAt)
+		_ = m.store.UpdatePortStatus(ctx, port.ID, models.PortStatusOpen, checkedAt)
+		m.publishStatus(host.ID, port.ID, port.Labels, port.Status, models.PortStatusOpen, checkedAt)
 	}
+	m.settleMissing(ctx, host.ID, missing, result.Confirmed, checkedAt, m.missThresholdFor(job))
+	return nil
+}
 
-	scanCtx, cancel := context.WithTimeout(ctx, m.timeout*10)
-	defer cancel()
-
-	naabuPorts, err := runNaabu(scanCtx, host.Address, portNums)
+// recordAddresses 记录域名解析结果，解析结果变化时推送 host_dns_changed 事件。
+func (m *Manager) recordAddresses(ctx context.Context, hostID int64, result *ScanResult) {
+	if len(result.Addresses) == 0 {
+		return
+	}
+	previous, added, removed, err := m.store.RecordHostAddresses(ctx, hostID, result.Addresses, time.Now())
 	if err != nil {
-		log.Printf("[scanner] partial scan failed host=%d err=%v", host.ID, err)
+		log.Printf("[scanner] record addresses failed host=%d err=%v", hostID, err)
 		return
 	}
+	if len(previous) > 0 && (len(added) > 0 || len(removed) > 0) {
+		log.Printf("[scanner] dns changed host=%d added=%v removed=%v", hostID, added, removed)
+		m.realtime.Publish(realtime.Event{
+			Type:   "host_dns_changed",
+			HostID: hostID,
+			Payload: map[string]interface{}{
+				"hostname":  result.Host,
+				"previous":  previous,
+				"addresses": result.Addresses,
+				"added":     added,
+				"removed":   removed,
+			},
+		})
+	}
+}
 
-	checkedAt := time.Now().UTC()
-	for _, port := range ports {
-		status := models.PortStatusClosed
-		if info, ok := naabuPorts[port.Number]; ok {
-			status = models.PortStatusOpen
-			serviceName := serviceLabel(info)
-			if serviceName == "" {
-				serviceName = fingerprint.NameForPort(port.Number)
-			}
-			if serviceName != "" && serviceName != port.Fingerprint {
-				_ = m.store.UpdatePortFingerprint(ctx, port.ID, serviceName)
-			}
-		}
-		_ = m.store.UpdatePortStatus(ctx, port.ID, status, checkedAt)
-		m.publishStatus(host.ID, port.ID, status, checkedAt)
+// recordService 保存扫描识别的服务（人工锁定的指纹不会被覆盖），返回服务是否发生变化。
+func (m *Manager) recordService(ctx context.Context, hostID int64, port *models.Port, service string, ts time.Time) bool {
+	if service == "" || service == port.DetectedService {
+		return false
+	}
+	changed, err := m.store.RecordDetectedService(ctx, port.ID, service, ts)
+	if err != nil {
+		log.Printf("[scanner] record service failed host=%d port=%d err=%v", hostID, port.Number, err)
+		return false
+	}
+	if !changed {
+		return false
 	}
+	m.realtime.Publish(realtime.Event{
+		Type:   "port_service_detected",
+		HostID: hostID,
+		PortID: port.ID,
+		Labels: port.Labels,
+		Payload: map[string]interface{}{
+			"previous": port.DetectedService,
+			"service":  service,
+			"locked":   port.FingerprintLocked,
+		},
+	})
+	return true
 }
 
-func (m *Manager) publishStatus(hostID, portID int64, status string, ts time.Time) {
+// publishStatus 推送端口状态，previous 为本次检测前的状态（新端口为空）。
+func (m *Manager) publishStatus(hostID, portID int64, labels map[string]string, previous, status string, ts time.Time) {
 	m.realtime.Publish(realtime.Event{
 		Type:   "port_status",
 		HostID: hostID,
 		PortID: portID,
+		Labels: labels,
 		Payload: map[string]interface{}{
 			"status":      status,
+			"previous":    previous,
 			"lastChecked": ts,
 		},
 	})
 }
 
-func serviceLabel(p *portpkg.Port) string {
-	if p == nil || p.Service == nil {
-		return ""
-	}
-	svc := p.Service
-	if svc.Product != "" && svc.Version != "" {
-		return fmt.Sprintf("%s %s", svc.Product, svc.Version)
-	}
-	if svc.Product != "" {
-		return svc.Product
-	}
-	if svc.Name != "" {
-		return svc.Name
-	}
-	if svc.ServiceFP != "" {
-		return svc.ServiceFP
-	}
-	if svc.ExtraInfo != "" {
-		return svc.ExtraInfo
-	}
-	return ""
-}
-
 func (m *Manager) publishScanStarted(hostID int64) {
 	m.realtime.Publish(realtime.Event{
 		Type:   "host_scan_started",
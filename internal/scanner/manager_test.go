@@ -0,0 +1,129 @@
+package scanner
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hitushen/portnotepro/internal/realtime"
+	"github.com/hitushen/portnotepro/internal/store"
+)
+
+// testManager 为基于临时 SQLite 数据库的扫描管理器，记录推送的全部事件。
+type testManager struct {
+	*Manager
+	store *store.Store
+
+	mu     sync.Mutex
+	events []realtime.Event
+}
+
+func newTestManager(t *testing.T) *testManager {
+	t.Helper()
+	st, err := store.New(filepath.Join(t.TempDir(), "portnote.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { st.Close() })
+
+	broker := realtime.NewBroker()
+	tm := &testManager{store: st}
+	broker.Observe(func(evt realtime.Event) {
+		tm.mu.Lock()
+		tm.events = append(tm.events, evt)
+		tm.mu.Unlock()
+	})
+	tm.Manager = NewManager(st, time.Second, 1, broker)
+	return tm
+}
+
+// eventsOf 返回指定类型的事件。
+func (tm *testManager) eventsOf(kind string) []realtime.Event {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	var list []realtime.Event
+	for _, evt := range tm.events {
+		if evt.Type == kind {
+			list = append(list, evt)
+		}
+	}
+	return list
+}
+
+func (tm *testManager) createHost(t *testing.T, name, address string) int64 {
+	t.Helper()
+	id, err := tm.store.CreateHost(context.Background(), name, address, false)
+	if err != nil {
+		t.Fatalf("create host: %v", err)
+	}
+	return id
+}
+
+// activeAddresses 返回主机当前有效的解析地址（已排序）。
+func (tm *testManager) activeAddresses(t *testing.T, hostID int64) []string {
+	t.Helper()
+	addrs, err := tm.store.ListHostAddresses(context.Background(), hostID)
+	if err != nil {
+		t.Fatalf("list addresses: %v", err)
+	}
+	var active []string
+	for _, a := range addrs {
+		if a.Active {
+			active = append(active, a.Address)
+		}
+	}
+	sort.Strings(active)
+	return active
+}
+
+func TestRecordAddresses(t *testing.T) {
+	ctx := context.Background()
+	tm := newTestManager(t)
+	hostID := tm.createHost(t, "web", "web.portnote.test")
+
+	// 首次解析得到多条 A 记录：全部记为有效，但没有可比较的历史，不推送变化事件。
+	tm.recordAddresses(ctx, hostID, &ScanResult{Host: "web.portnote.test", Addresses: []string{"10.0.0.1", "10.0.0.2"}})
+	if got, want := tm.activeAddresses(t, hostID), []string{"10.0.0.1", "10.0.0.2"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("active addresses = %v, want %v", got, want)
+	}
+	if n := len(tm.eventsOf("host_dns_changed")); n != 0 {
+		t.Fatalf("first resolution published %d host_dns_changed events", n)
+	}
+
+	// 地址变化：推送新增与移除的地址，旧地址保留为历史记录。
+	tm.recordAddresses(ctx, hostID, &ScanResult{Host: "web.portnote.test", Addresses: []string{"10.0.0.2", "10.0.0.3"}})
+	if got, want := tm.activeAddresses(t, hostID), []string{"10.0.0.2", "10.0.0.3"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("active addresses after change = %v, want %v", got, want)
+	}
+	events := tm.eventsOf("host_dns_changed")
+	if len(events) != 1 {
+		t.Fatalf("got %d host_dns_changed events, want 1", len(events))
+	}
+	payload := events[0].Payload.(map[string]interface{})
+	if added := payload["added"].([]string); !reflect.DeepEqual(added, []string{"10.0.0.3"}) {
+		t.Errorf("added = %v, want [10.0.0.3]", added)
+	}
+	if removed := payload["removed"].([]string); !reflect.DeepEqual(removed, []string{"10.0.0.1"}) {
+		t.Errorf("removed = %v, want [10.0.0.1]", removed)
+	}
+	history, err := tm.store.ListHostAddresses(ctx, hostID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 {
+		t.Errorf("history has %d addresses, want 3", len(history))
+	}
+
+	// NXDOMAIN：Engine.Scan 返回不含地址的结果，上次的有效地址保持不变，也不推送事件。
+	tm.recordAddresses(ctx, hostID, &ScanResult{Host: "web.portnote.test"})
+	if got, want := tm.activeAddresses(t, hostID), []string{"10.0.0.2", "10.0.0.3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("active addresses after NXDOMAIN = %v, want %v", got, want)
+	}
+	if n := len(tm.eventsOf("host_dns_changed")); n != 1 {
+		t.Errorf("NXDOMAIN published a host_dns_changed event")
+	}
+}
@@ -12,15 +12,31 @@ import (
 	"github.com/projectdiscovery/naabu/v2/pkg/result"
 	"github.com/projectdiscovery/naabu/v2/pkg/runner"
 
+	"github.com/hitushen/portnotepro/internal/metrics"
 	"github.com/hitushen/portnotepro/internal/targets"
 )
 
-func runNaabu(ctx context.Context, address string, ports []int) (map[int]*portpkg.Port, error) {
+var (
+	scanDuration = metrics.NewHistogram("portnote_scan_duration_seconds",
+		"Duration of naabu scans by kind (full or ports) and result (ok or error).",
+		[]float64{1, 5, 15, 30, 60, 120, 300, 600, 1200, 1800, 3600}, "kind", "result")
+	naabuErrors = metrics.NewCounter("portnote_naabu_errors_total",
+		"Errors returned by naabu by stage (init or enumeration).", "stage")
+)
+
+// runNaabu 以 rate（每秒发包数）对已解析的目标列表执行扫描，ports 为空时扫描全部端口。
+// 目标应为 targets.Resolve 得到的 IP，naabu 自带的系统解析器不会看到已配置的 DNS 服务器。
+// policy 中的排除网段与端口会再次传给 naabu，避免绕过前置校验。
+func runNaabu(ctx context.Context, targetsList []string, ports []int, policy *targets.Policy, rate int) (map[int]*portpkg.Port, error) {
 	openPorts := make(map[int]*portpkg.Port)
 
-	targetsList := targets.Build(address)
 	if len(targetsList) == 0 {
-		return nil, fmt.Errorf("invalid target address: %q", address)
+		return nil, fmt.Errorf("no scan targets")
+	}
+	if len(ports) > 0 {
+		if ports = policy.FilterPorts(ports); len(ports) == 0 {
+			return openPorts, nil
+		}
 	}
 
 	onResult := func(hr *result.HostResult) {
@@ -46,9 +62,11 @@ func runNaabu(ctx context.Context, address string, ports []int) (map[int]*portpk
 		Stream:           true,
 		Ports:            "1-65535",
 		Retries:          1,
-		Rate:             3000,
+		Rate:             rate,
 		Timeout:          5000 * time.Millisecond,
 		ServiceDiscovery: true,
+		ExcludePorts:     policy.ExcludedPorts(),
+		ExcludeIps:       strings.Join(policy.ExcludedNets(), ","),
 	}
 
 	if len(ports) > 0 {
@@ -59,15 +77,25 @@ func runNaabu(ctx context.Context, address string, ports []int) (map[int]*portpk
 		opts.Ports = strings.Join(str, ",")
 	}
 
+	kind := "full"
+	if len(ports) > 0 {
+		kind = "ports"
+	}
+	start := time.Now()
 	r, err := runner.NewRunner(&opts)
 	if err != nil {
-		return nil, fmt.Errorf("naabu runner init: %w", err)
+		naabuErrors.Inc("init")
+		scanDuration.Observe(time.Since(start).Seconds(), kind, "error")
+		return nil, &runnerInitError{err: err}
 	}
 	defer r.Close()
 
 	if err := r.RunEnumeration(ctx); err != nil {
+		naabuErrors.Inc("enumeration")
+		scanDuration.Observe(time.Since(start).Seconds(), kind, "error")
 		return nil, fmt.Errorf("naabu enumeration: %w", err)
 	}
+	scanDuration.Observe(time.Since(start).Seconds(), kind, "ok")
 
 	return openPorts, nil
 }
@@ -0,0 +1,359 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/hitushen/portnotepro/internal/auth"
+	"github.com/hitushen/portnotepro/internal/models"
+	"github.com/hitushen/portnotepro/internal/realtime"
+	"github.com/hitushen/portnotepro/internal/scanner"
+)
+
+const (
+	// agentPollInterval 为 agent 没有任务时的拉取间隔，agentOnlineWindow 内有请求的 agent 视为在线。
+	agentPollInterval = 10 * time.Second
+	agentOnlineWindow = time.Minute
+	// agentTokenPrefix 便于在日志与配置中识别 agent 令牌。
+	agentTokenPrefix = "pna_"
+	// maxAgentResultBytes 为 agent 回传结果的请求体上限。
+	maxAgentResultBytes = 8 << 20
+)
+
+var zonePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,63}$`)
+
+type agentCtxKey struct{}
+
+type agentBody struct {
+	Name     string `json:"name"`
+	Zone     string `json:"zone"`
+	Disabled *bool  `json:"disabled"`
+}
+
+func (s *Server) apiListAgents(w http.ResponseWriter, r *http.Request) {
+	list, err := s.store.ListAgents(r.Context())
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	for i := range list {
+		markOnline(&list[i])
+	}
+	writeJSON(w, list)
+}
+
+// apiCreateAgent 新增 agent 并返回其令牌，令牌只在创建与轮换时返回一次。
+func (s *Server) apiCreateAgent(w http.ResponseWriter, r *http.Request) {
+	agent := &models.Agent{}
+	if !decodeAgent(w, r, agent) {
+		return
+	}
+	token, err := newAgentToken()
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	id, err := s.store.CreateAgent(r.Context(), agent.Name, agent.Zone, token)
+	if err != nil {
+		writeAlertSaveErr(w, err, "agents")
+		return
+	}
+	created, err := s.store.GetAgent(r.Context(), id)
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]interface{}{"agent": created, "token": token})
+}
+
+func (s *Server) apiUpdateAgent(w http.ResponseWriter, r *http.Request) {
+	agent, ok := s.loadAgent(w, r)
+	if !ok {
+		return
+	}
+	if !decodeAgent(w, r, agent) {
+		return
+	}
+	if err := s.store.UpdateAgent(r.Context(), agent); err != nil {
+		writeAlertSaveErr(w, err, "agents")
+		return
+	}
+	updated, err := s.store.GetAgent(r.Context(), agent.ID)
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	markOnline(updated)
+	writeJSON(w, updated)
+}
+
+// apiRotateAgentToken 生成新令牌，旧令牌立即失效；agent 持有的任务在租约到期后重新排队。
+func (s *Server) apiRotateAgentToken(w http.ResponseWriter, r *http.Request) {
+	agent, ok := s.loadAgent(w, r)
+	if !ok {
+		return
+	}
+	token, err := newAgentToken()
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	if err := s.store.RotateAgentToken(r.Context(), agent.ID, token); err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]string{"token": token})
+}
+
+func (s *Server) apiDeleteAgent(w http.ResponseWriter, r *http.Request) {
+	agent, ok := s.loadAgent(w, r)
+	if !ok {
+		return
+	}
+	if err := s.store.DeleteAgent(r.Context(), agent.ID); err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]string{"status": "ok"})
+}
+
+// apiSetHostAssignment 设置主机由哪个网络区域或指定 agent 扫描，两者均为空时由服务端本地扫描。
+func (s *Server) apiSetHostAssignment(w http.ResponseWriter, r *http.Request) {
+	hostID, err := parseIDParam(chi.URLParam(r, "hostID"))
+	if err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return
+	}
+	var body struct {
+		Zone    string `json:"zone"`
+		AgentID int64  `json:"agentId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return
+	}
+	if _, err := s.store.GetHost(r.Context(), hostID); err != nil {
+		writeMessage(w, "host not found", http.StatusNotFound)
+		return
+	}
+	zone, ok := s.checkAssignment(w, r.Context(), body.Zone, body.AgentID)
+	if !ok {
+		return
+	}
+	if err := s.store.SetHostAssignment(r.Context(), hostID, zone, body.AgentID); err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	host, err := s.store.GetHost(r.Context(), hostID)
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, host)
+	s.broker.Publish(realtime.Event{
+		Type:   "host_updated",
+		HostID: hostID,
+		Payload: map[string]interface{}{
+			"zone":    host.Zone,
+			"agentId": host.AgentID,
+		},
+	})
+}
+
+// checkAssignment 校验主机的扫描归属并返回规范化的区域，失败时已写入响应。
+func (s *Server) checkAssignment(w http.ResponseWriter, ctx context.Context, zone string, agentID int64) (string, bool) {
+	zone = strings.ToLower(strings.TrimSpace(zone))
+	if zone != "" && !zonePattern.MatchString(zone) {
+		writeMessage(w, "invalid zone", http.StatusBadRequest)
+		return "", false
+	}
+	if agentID < 0 {
+		writeMessage(w, "invalid agentId", http.StatusBadRequest)
+		return "", false
+	}
+	if agentID > 0 {
+		if _, err := s.store.GetAgent(ctx, agentID); err != nil {
+			writeMessage(w, "agent not found", http.StatusBadRequest)
+			return "", false
+		}
+	}
+	return zone, true
+}
+
+// agentAuth 校验 agent 的 Bearer 令牌，记录最近请求时间并把 agent 放入请求上下文。
+func (s *Server) agentAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := auth.BearerToken(r)
+		if !ok {
+			writeMessage(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		agent, err := s.store.AgentByToken(r.Context(), token)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				writeMessage(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			writeErr(w, err, http.StatusInternalServerError)
+			return
+		}
+		if agent.Disabled {
+			writeMessage(w, "agent disabled", http.StatusForbidden)
+			return
+		}
+		if err := s.store.TouchAgent(r.Context(), agent.ID, "", "", r.RemoteAddr, time.Now()); err != nil {
+			writeErr(w, err, http.StatusInternalServerError)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), agentCtxKey{}, agent)))
+	})
+}
+
+func agentFromContext(ctx context.Context) *models.Agent {
+	agent, _ := ctx.Value(agentCtxKey{}).(*models.Agent)
+	return agent
+}
+
+// agentRegister 记录 agent 的版本与主机名，返回其区域与轮询参数。
+func (s *Server) agentRegister(w http.ResponseWriter, r *http.Request) {
+	agent := agentFromContext(r.Context())
+	var body struct {
+		Version  string `json:"version"`
+		Hostname string `json:"hostname"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return
+	}
+	if err := s.store.TouchAgent(r.Context(), agent.ID, body.Version, body.Hostname, r.RemoteAddr, time.Now()); err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"id":           agent.ID,
+		"name":         agent.Name,
+		"zone":         agent.Zone,
+		"pollSeconds":  int(agentPollInterval / time.Second),
+		"leaseSeconds": int(s.scanner.AgentLease() / time.Second),
+	})
+}
+
+// agentLeaseJob 领取一个任务，没有任务时返回 204。
+func (s *Server) agentLeaseJob(w http.ResponseWriter, r *http.Request) {
+	job, err := s.scanner.LeaseJob(r.Context(), agentFromContext(r.Context()))
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	if job == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeJSON(w, job)
+}
+
+// agentHeartbeat 续约，租约已被收回时返回 409。
+func (s *Server) agentHeartbeat(w http.ResponseWriter, r *http.Request) {
+	jobID, err := parseIDParam(chi.URLParam(r, "jobID"))
+	if err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return
+	}
+	ok, err := s.scanner.ExtendLease(r.Context(), agentFromContext(r.Context()).ID, jobID)
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		writeMessage(w, "lease lost", http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// agentSubmitResult 接收执行结果，租约已被收回时返回 409。
+func (s *Server) agentSubmitResult(w http.ResponseWriter, r *http.Request) {
+	jobID, err := parseIDParam(chi.URLParam(r, "jobID"))
+	if err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return
+	}
+	var body scanner.AgentResult
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAgentResultBytes)).Decode(&body); err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return
+	}
+	ok, err := s.scanner.SubmitResult(r.Context(), agentFromContext(r.Context()).ID, jobID, body)
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		writeMessage(w, "lease lost", http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func decodeAgent(w http.ResponseWriter, r *http.Request, agent *models.Agent) bool {
+	var body agentBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return false
+	}
+	body.Name = strings.TrimSpace(body.Name)
+	body.Zone = strings.ToLower(strings.TrimSpace(body.Zone))
+	if body.Name == "" {
+		writeMessage(w, "name required", http.StatusBadRequest)
+		return false
+	}
+	if !zonePattern.MatchString(body.Zone) {
+		writeMessage(w, "zone must be 1-64 lowercase letters, digits, '.', '_' or '-'", http.StatusBadRequest)
+		return false
+	}
+	agent.Name, agent.Zone = body.Name, body.Zone
+	if body.Disabled != nil {
+		agent.Disabled = *body.Disabled
+	}
+	return true
+}
+
+func (s *Server) loadAgent(w http.ResponseWriter, r *http.Request) (*models.Agent, bool) {
+	id, err := parseIDParam(chi.URLParam(r, "agentID"))
+	if err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return nil, false
+	}
+	agent, err := s.store.GetAgent(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeMessage(w, "agent not found", http.StatusNotFound)
+			return nil, false
+		}
+		writeErr(w, err, http.StatusInternalServerError)
+		return nil, false
+	}
+	return agent, true
+}
+
+func markOnline(agent *models.Agent) {
+	agent.Online = !agent.Disabled && agent.LastSeenAt != nil && time.Since(*agent.LastSeenAt) < agentOnlineWindow
+}
+
+func newAgentToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return agentTokenPrefix + hex.EncodeToString(b), nil
+}
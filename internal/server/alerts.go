@@ -0,0 +1,390 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/hitushen/portnotepro/internal/alerting"
+	"github.com/hitushen/portnotepro/internal/models"
+	"github.com/hitushen/portnotepro/internal/store"
+)
+
+type alertTargetBody struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+	URL  string `json:"url"`
+	// Secret 为 nil 时更新保留原密钥，空字符串表示清除。
+	Secret  *string `json:"secret"`
+	Enabled *bool   `json:"enabled"`
+	// MinSeverity 与 GroupID 为渠道路由条件：低于该级别或不属于该分组的告警不投递到此目标。
+	MinSeverity string `json:"minSeverity"`
+	GroupID     int64  `json:"groupId"`
+}
+
+type alertRuleBody struct {
+	Name     string   `json:"name"`
+	Events   []string `json:"events"`
+	Ports    string   `json:"ports"`
+	HostID   int64    `json:"hostId"`
+	GroupID  int64    `json:"groupId"`
+	Cooldown int      `json:"cooldownSeconds"`
+	TargetID int64    `json:"targetId"`
+	// Severity 为空时按事件类型取默认级别。
+	Severity string `json:"severity"`
+	Enabled  *bool  `json:"enabled"`
+}
+
+func (s *Server) apiListAlertTargets(w http.ResponseWriter, r *http.Request) {
+	list, err := s.store.ListAlertTargets(r.Context())
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, list)
+}
+
+func (s *Server) apiCreateAlertTarget(w http.ResponseWriter, r *http.Request) {
+	target := &models.AlertTarget{Enabled: true}
+	if !s.decodeAlertTarget(w, r, target) {
+		return
+	}
+	id, err := s.store.CreateAlertTarget(r.Context(), target)
+	if err != nil {
+		writeAlertSaveErr(w, err, "alert_targets")
+		return
+	}
+	created, err := s.store.GetAlertTarget(r.Context(), id)
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, created)
+}
+
+func (s *Server) apiUpdateAlertTarget(w http.ResponseWriter, r *http.Request) {
+	target, ok := s.loadAlertTarget(w, r)
+	if !ok {
+		return
+	}
+	if !s.decodeAlertTarget(w, r, target) {
+		return
+	}
+	if err := s.store.UpdateAlertTarget(r.Context(), target); err != nil {
+		writeAlertSaveErr(w, err, "alert_targets")
+		return
+	}
+	updated, err := s.store.GetAlertTarget(r.Context(), target.ID)
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, updated)
+}
+
+func (s *Server) apiDeleteAlertTarget(w http.ResponseWriter, r *http.Request) {
+	target, ok := s.loadAlertTarget(w, r)
+	if !ok {
+		return
+	}
+	if err := s.store.DeleteAlertTarget(r.Context(), target.ID); err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]string{"status": "ok"})
+}
+
+// apiTestAlertTarget 立即向目标发送一条测试告警，返回本次投递记录（含响应码与错误）。
+func (s *Server) apiTestAlertTarget(w http.ResponseWriter, r *http.Request) {
+	target, ok := s.loadAlertTarget(w, r)
+	if !ok {
+		return
+	}
+	delivery, err := s.alerts.SendTest(r.Context(), target)
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, delivery)
+}
+
+func (s *Server) apiListAlertRules(w http.ResponseWriter, r *http.Request) {
+	list, err := s.store.ListAlertRules(r.Context())
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, list)
+}
+
+func (s *Server) apiCreateAlertRule(w http.ResponseWriter, r *http.Request) {
+	rule := &models.AlertRule{Enabled: true}
+	if !s.decodeAlertRule(w, r, rule) {
+		return
+	}
+	id, err := s.store.CreateAlertRule(r.Context(), rule)
+	if err != nil {
+		writeAlertSaveErr(w, err, "alert_rules")
+		return
+	}
+	created, err := s.store.GetAlertRule(r.Context(), id)
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, created)
+}
+
+func (s *Server) apiUpdateAlertRule(w http.ResponseWriter, r *http.Request) {
+	rule, ok := s.loadAlertRule(w, r)
+	if !ok {
+		return
+	}
+	if !s.decodeAlertRule(w, r, rule) {
+		return
+	}
+	if err := s.store.UpdateAlertRule(r.Context(), rule); err != nil {
+		writeAlertSaveErr(w, err, "alert_rules")
+		return
+	}
+	updated, err := s.store.GetAlertRule(r.Context(), rule.ID)
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, updated)
+}
+
+func (s *Server) apiDeleteAlertRule(w http.ResponseWriter, r *http.Request) {
+	rule, ok := s.loadAlertRule(w, r)
+	if !ok {
+		return
+	}
+	if err := s.store.DeleteAlertRule(r.Context(), rule.ID); err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]string{"status": "ok"})
+}
+
+// apiListAlertDeliveries 返回投递日志，支持 status、ruleId、targetId 与 limit（最大 500）过滤。
+func (s *Server) apiListAlertDeliveries(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	filter := store.DeliveryFilter{
+		Status: params.Get("status"),
+		Limit:  intParam(params.Get("limit"), 100),
+	}
+	switch filter.Status {
+	case "", models.DeliveryPending, models.DeliverySending, models.DeliveryDelivered, models.DeliveryFailed:
+	default:
+		writeMessage(w, "invalid status", http.StatusBadRequest)
+		return
+	}
+	if filter.Limit > 500 {
+		filter.Limit = 500
+	}
+	for key, dst := range map[string]*int64{"ruleId": &filter.RuleID, "targetId": &filter.TargetID} {
+		if raw := params.Get(key); raw != "" {
+			id, err := parseIDParam(raw)
+			if err != nil {
+				writeMessage(w, "invalid "+key, http.StatusBadRequest)
+				return
+			}
+			*dst = id
+		}
+	}
+	list, err := s.store.ListAlertDeliveries(r.Context(), filter)
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, list)
+}
+
+// apiRetryAlertDelivery 将失败的投递重新放回队列并立即尝试。
+func (s *Server) apiRetryAlertDelivery(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDParam(chi.URLParam(r, "deliveryID"))
+	if err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return
+	}
+	ok, err := s.store.RetryAlertDelivery(r.Context(), id)
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		writeMessage(w, "delivery not found or not failed", http.StatusNotFound)
+		return
+	}
+	s.alerts.Wake()
+	delivery, err := s.store.GetAlertDelivery(r.Context(), id)
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, delivery)
+}
+
+func (s *Server) decodeAlertTarget(w http.ResponseWriter, r *http.Request, target *models.AlertTarget) bool {
+	var body alertTargetBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return false
+	}
+	candidate := models.AlertTarget{Name: body.Name, Kind: body.Kind, URL: body.URL, MinSeverity: body.MinSeverity}
+	if err := alerting.NormalizeTarget(&candidate); err != nil {
+		writeMessage(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	if candidate.Kind == models.TargetEmail && !s.alerts.EmailEnabled() {
+		writeMessage(w, "email targets require PORTNOTE_SMTP_HOST", http.StatusBadRequest)
+		return false
+	}
+	body.Name, body.Kind, body.URL, body.MinSeverity = candidate.Name, candidate.Kind, candidate.URL, candidate.MinSeverity
+	if body.GroupID > 0 {
+		if _, err := s.store.GetGroup(r.Context(), body.GroupID); err != nil {
+			writeMessage(w, "group not found", http.StatusBadRequest)
+			return false
+		}
+	}
+	target.Name, target.Kind, target.URL = body.Name, body.Kind, body.URL
+	target.MinSeverity, target.GroupID = body.MinSeverity, body.GroupID
+	if body.Secret != nil {
+		target.Secret = *body.Secret
+	}
+	if body.Enabled != nil {
+		target.Enabled = *body.Enabled
+	}
+	return true
+}
+
+func (s *Server) decodeAlertRule(w http.ResponseWriter, r *http.Request, rule *models.AlertRule) bool {
+	var body alertRuleBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return false
+	}
+	body.Name = strings.TrimSpace(body.Name)
+	if body.Name == "" {
+		writeMessage(w, "name required", http.StatusBadRequest)
+		return false
+	}
+	if len(body.Events) == 0 {
+		writeMessage(w, "events required: "+strings.Join(models.AlertKinds, ", "), http.StatusBadRequest)
+		return false
+	}
+	events := make([]string, 0, len(body.Events))
+	for _, e := range body.Events {
+		e = strings.TrimSpace(e)
+		if !containsAlertKind(e) {
+			writeMessage(w, "unknown event "+e+", expected one of: "+strings.Join(models.AlertKinds, ", "), http.StatusBadRequest)
+			return false
+		}
+		if !containsAlertEvent(events, e) {
+			events = append(events, e)
+		}
+	}
+	ports, err := store.ParsePorts(body.Ports)
+	if err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return false
+	}
+	if body.Cooldown < 0 {
+		writeMessage(w, "cooldownSeconds must not be negative", http.StatusBadRequest)
+		return false
+	}
+	if body.Severity != "" && alerting.SeverityRank(body.Severity) < 0 {
+		writeMessage(w, "severity must be one of: "+strings.Join(models.Severities, ", "), http.StatusBadRequest)
+		return false
+	}
+	ctx := r.Context()
+	if _, err := s.store.GetAlertTarget(ctx, body.TargetID); err != nil {
+		writeMessage(w, "target not found", http.StatusBadRequest)
+		return false
+	}
+	if body.HostID > 0 {
+		if _, err := s.store.GetHost(ctx, body.HostID); err != nil {
+			writeMessage(w, "host not found", http.StatusBadRequest)
+			return false
+		}
+	}
+	if body.GroupID > 0 {
+		if _, err := s.store.GetGroup(ctx, body.GroupID); err != nil {
+			writeMessage(w, "group not found", http.StatusBadRequest)
+			return false
+		}
+	}
+	rule.Name = body.Name
+	rule.Events = events
+	rule.Ports = store.FormatPorts(ports)
+	rule.HostID, rule.GroupID = body.HostID, body.GroupID
+	rule.Cooldown = body.Cooldown
+	rule.TargetID = body.TargetID
+	rule.Severity = body.Severity
+	if body.Enabled != nil {
+		rule.Enabled = *body.Enabled
+	}
+	return true
+}
+
+func (s *Server) loadAlertTarget(w http.ResponseWriter, r *http.Request) (*models.AlertTarget, bool) {
+	id, err := parseIDParam(chi.URLParam(r, "targetID"))
+	if err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return nil, false
+	}
+	target, err := s.store.GetAlertTarget(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeMessage(w, "target not found", http.StatusNotFound)
+			return nil, false
+		}
+		writeErr(w, err, http.StatusInternalServerError)
+		return nil, false
+	}
+	return target, true
+}
+
+func (s *Server) loadAlertRule(w http.ResponseWriter, r *http.Request) (*models.AlertRule, bool) {
+	id, err := parseIDParam(chi.URLParam(r, "ruleID"))
+	if err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return nil, false
+	}
+	rule, err := s.store.GetAlertRule(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeMessage(w, "rule not found", http.StatusNotFound)
+			return nil, false
+		}
+		writeErr(w, err, http.StatusInternalServerError)
+		return nil, false
+	}
+	return rule, true
+}
+
+func writeAlertSaveErr(w http.ResponseWriter, err error, table string) {
+	if store.IsDuplicateName(err, table) {
+		writeMessage(w, "名称已存在，请更换名称", http.StatusConflict)
+		return
+	}
+	writeErr(w, err, http.StatusInternalServerError)
+}
+
+func containsAlertKind(kind string) bool {
+	return containsAlertEvent(models.AlertKinds, kind)
+}
+
+func containsAlertEvent(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
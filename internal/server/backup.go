@@ -0,0 +1,44 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/hitushen/portnotepro/internal/store"
+)
+
+func (s *Server) apiListBackups(w http.ResponseWriter, r *http.Request) {
+	list, err := s.backups.List()
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]interface{}{"dir": s.backups.Dir(), "backups": list})
+}
+
+func (s *Server) apiCreateBackup(w http.ResponseWriter, r *http.Request) {
+	info, err := s.backups.Create(r.Context())
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, store.ErrBackupUnsupported) {
+			status = http.StatusNotImplemented
+		}
+		writeErr(w, err, status)
+		return
+	}
+	writeJSON(w, info)
+}
+
+func (s *Server) apiDownloadBackup(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	path, err := s.backups.Path(name)
+	if err != nil {
+		writeErr(w, err, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/vnd.sqlite3")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	http.ServeFile(w, r, path)
+}
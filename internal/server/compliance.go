@@ -0,0 +1,177 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/hitushen/portnotepro/internal/models"
+	"github.com/hitushen/portnotepro/internal/realtime"
+	"github.com/hitushen/portnotepro/internal/store"
+)
+
+func (s *Server) apiListBaselines(w http.ResponseWriter, r *http.Request) {
+	list, err := s.store.ListBaselines(r.Context())
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, list)
+}
+
+func (s *Server) apiGetHostBaseline(w http.ResponseWriter, r *http.Request) {
+	hostID, ok := s.baselineHost(w, r)
+	if !ok {
+		return
+	}
+	s.writeBaseline(w, r, hostID, 0)
+}
+
+func (s *Server) apiSetHostBaseline(w http.ResponseWriter, r *http.Request) {
+	hostID, ok := s.baselineHost(w, r)
+	if !ok {
+		return
+	}
+	s.setBaseline(w, r, hostID, 0)
+}
+
+func (s *Server) apiDeleteHostBaseline(w http.ResponseWriter, r *http.Request) {
+	hostID, ok := s.baselineHost(w, r)
+	if !ok {
+		return
+	}
+	s.deleteBaseline(w, r, hostID, 0)
+}
+
+func (s *Server) apiGetGroupBaseline(w http.ResponseWriter, r *http.Request) {
+	group, ok := s.loadGroup(w, r)
+	if !ok {
+		return
+	}
+	s.writeBaseline(w, r, 0, group.ID)
+}
+
+func (s *Server) apiSetGroupBaseline(w http.ResponseWriter, r *http.Request) {
+	group, ok := s.loadGroup(w, r)
+	if !ok {
+		return
+	}
+	s.setBaseline(w, r, 0, group.ID)
+}
+
+func (s *Server) apiDeleteGroupBaseline(w http.ResponseWriter, r *http.Request) {
+	group, ok := s.loadGroup(w, r)
+	if !ok {
+		return
+	}
+	s.deleteBaseline(w, r, 0, group.ID)
+}
+
+// apiCompliance 返回基线合规报告，status 可过滤为 compliant / unexpected_open / expected_missing。
+func (s *Server) apiCompliance(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", models.ComplianceCompliant, models.ComplianceUnexpectedOpen, models.ComplianceExpectedMissing:
+	default:
+		writeMessage(w, "invalid status", http.StatusBadRequest)
+		return
+	}
+	report, err := s.store.ComplianceReport(r.Context(), status)
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, report)
+}
+
+func (s *Server) baselineHost(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	hostID, err := parseIDParam(chi.URLParam(r, "hostID"))
+	if err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return 0, false
+	}
+	if _, err := s.store.GetHost(r.Context(), hostID); err != nil {
+		writeMessage(w, "host not found", http.StatusNotFound)
+		return 0, false
+	}
+	return hostID, true
+}
+
+func (s *Server) writeBaseline(w http.ResponseWriter, r *http.Request, hostID, groupID int64) {
+	baseline, err := s.store.GetBaseline(r.Context(), hostID, groupID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeMessage(w, "baseline not set", http.StatusNotFound)
+			return
+		}
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, baseline)
+}
+
+// setBaseline 读取 {"ports": "22,443"} 保存基线，并立即按现有端口状态重新评估受影响的主机。
+func (s *Server) setBaseline(w http.ResponseWriter, r *http.Request, hostID, groupID int64) {
+	var body struct {
+		Ports string `json:"ports"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return
+	}
+	baseline, err := s.store.SetBaseline(r.Context(), hostID, groupID, body.Ports)
+	if err != nil {
+		if errors.Is(err, store.ErrInvalidBaseline) {
+			writeErr(w, err, http.StatusBadRequest)
+			return
+		}
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, baseline)
+	s.publishBaseline("baseline_updated", hostID, groupID, baseline.Ports)
+	s.reevaluateCompliance(r.Context(), hostID, groupID)
+}
+
+func (s *Server) deleteBaseline(w http.ResponseWriter, r *http.Request, hostID, groupID int64) {
+	if err := s.store.DeleteBaseline(r.Context(), hostID, groupID); err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]string{"status": "ok"})
+	s.publishBaseline("baseline_deleted", hostID, groupID, "")
+	s.reevaluateCompliance(r.Context(), hostID, groupID)
+}
+
+func (s *Server) publishBaseline(eventType string, hostID, groupID int64, ports string) {
+	payload := map[string]interface{}{"ports": ports}
+	if groupID > 0 {
+		payload["groupId"] = groupID
+	}
+	s.broker.Publish(realtime.Event{
+		Type:    eventType,
+		HostID:  hostID,
+		Payload: payload,
+	})
+}
+
+// reevaluateCompliance 重新评估主机（hostID > 0）或分组（含子分组）内全部主机的基线合规状态。
+func (s *Server) reevaluateCompliance(ctx context.Context, hostID, groupID int64) {
+	if hostID > 0 {
+		s.scanner.EvaluateCompliance(ctx, hostID)
+		return
+	}
+	hostIDs, err := s.store.GroupHostIDs(ctx, groupID, true)
+	if err != nil {
+		log.Printf("[compliance] list group hosts failed group=%d err=%v", groupID, err)
+		return
+	}
+	for _, id := range hostIDs {
+		s.scanner.EvaluateCompliance(ctx, id)
+	}
+}
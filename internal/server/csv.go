@@ -0,0 +1,143 @@
+package server
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/hitushen/portnotepro/internal/models"
+	"github.com/hitushen/portnotepro/internal/store"
+)
+
+// csvHeader 为 CSV 导出的固定列顺序，新增列只追加在末尾。
+var csvHeader = []string{
+	"host_id", "host", "address", "port_id", "port", "status", "hidden",
+	"fingerprint", "detected_service", "user_fingerprint", "fingerprint_locked",
+	"note", "labels", "last_checked", "updated_at",
+}
+
+// csvFlushEvery 为流式输出时每写出多少行刷新一次。
+const csvFlushEvery = 200
+
+// apiExportHostPortsCSV 以 CSV 导出单个主机的端口，过滤参数与 apiListPorts 一致（不分页）。
+func (s *Server) apiExportHostPortsCSV(w http.ResponseWriter, r *http.Request) {
+	hostID, err := parseIDParam(chi.URLParam(r, "hostID"))
+	if err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return
+	}
+	query := portListQuery(r)
+	query.HostID = hostID
+	s.writePortsCSV(w, r, query, fmt.Sprintf("portnote-host-%d-ports", hostID))
+}
+
+// apiExportPortsCSV 以 CSV 导出全部主机的端口，另支持 sort=host。
+func (s *Server) apiExportPortsCSV(w http.ResponseWriter, r *http.Request) {
+	query := portListQuery(r)
+	if query.SortBy == "" {
+		query.SortBy = "host"
+	}
+	s.writePortsCSV(w, r, query, "portnote-ports")
+}
+
+// writePortsCSV 边查询边写出 CSV（RFC 4180），响应头延迟到首行数据前发送，查询条件错误时仍可返回 JSON 错误。
+func (s *Server) writePortsCSV(w http.ResponseWriter, r *http.Request, query *store.PortQuery, name string) {
+	cw := csv.NewWriter(w)
+	cw.UseCRLF = true
+	flusher, _ := w.(http.Flusher)
+	started := false
+	rows := 0
+	start := func() error {
+		started = true
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`-`+time.Now().UTC().Format("20060102-150405")+`.csv"`)
+		return cw.Write(csvHeader)
+	}
+
+	err := s.store.EachPortMatch(r.Context(), query, func(m models.PortMatch) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		if err := cw.Write(portCSVRecord(m)); err != nil {
+			return err
+		}
+		rows++
+		if rows%csvFlushEvery == 0 {
+			cw.Flush()
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		return cw.Error()
+	})
+	if err != nil && !started {
+		writeQueryErr(w, err)
+		return
+	}
+	if err != nil {
+		// 已开始输出，无法再改写状态码，只能截断并记录。
+		log.Printf("[export] csv export aborted after %d rows: %v", rows, err)
+		return
+	}
+	if !started {
+		if err := start(); err != nil {
+			return
+		}
+	}
+	cw.Flush()
+}
+
+func portCSVRecord(m models.PortMatch) []string {
+	lastChecked := ""
+	if !m.LastChecked.IsZero() {
+		lastChecked = m.LastChecked.UTC().Format(time.RFC3339)
+	}
+	return []string{
+		strconv.FormatInt(m.HostID, 10),
+		csvCell(m.HostName),
+		csvCell(m.HostAddress),
+		strconv.FormatInt(m.ID, 10),
+		strconv.Itoa(m.Number),
+		m.Status,
+		strconv.FormatBool(m.Hidden),
+		csvCell(m.Fingerprint),
+		csvCell(m.DetectedService),
+		csvCell(m.UserFingerprint),
+		strconv.FormatBool(m.FingerprintLocked),
+		csvCell(m.Note),
+		csvCell(formatLabels(m.Labels)),
+		lastChecked,
+		m.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// formatLabels 将标签按键排序拼接为 key=value;key=value。
+func formatLabels(values map[string]string) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k + "=" + values[k]
+	}
+	return strings.Join(parts, ";")
+}
+
+// csvCell 为以公式字符开头的用户文本加上单引号前缀，避免在电子表格中被当作公式执行。
+func csvCell(v string) string {
+	if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
@@ -0,0 +1,345 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/hitushen/portnotepro/internal/models"
+	"github.com/hitushen/portnotepro/internal/realtime"
+	"github.com/hitushen/portnotepro/internal/store"
+)
+
+type groupBody struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	ParentID    int64  `json:"parentId"`
+}
+
+func (s *Server) apiListGroups(w http.ResponseWriter, r *http.Request) {
+	groups, err := s.store.ListGroups(r.Context())
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	if groups == nil {
+		groups = []models.HostGroup{}
+	}
+	writeJSON(w, groups)
+}
+
+func (s *Server) apiCreateGroup(w http.ResponseWriter, r *http.Request) {
+	body, ok := s.decodeGroupBody(w, r)
+	if !ok {
+		return
+	}
+	groupID, err := s.store.CreateGroup(r.Context(), body.Name, body.Description, body.ParentID)
+	if err != nil {
+		if isUniqueGroupNameError(err) {
+			writeMessage(w, "分组名称已存在，请更换名称", http.StatusConflict)
+			return
+		}
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	group, _ := s.store.GetGroup(r.Context(), groupID)
+	writeJSON(w, group)
+	s.broker.Publish(realtime.Event{
+		Type:    "group_created",
+		Payload: group,
+	})
+}
+
+func (s *Server) apiUpdateGroup(w http.ResponseWriter, r *http.Request) {
+	groupID, err := parseIDParam(chi.URLParam(r, "groupID"))
+	if err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return
+	}
+	body, ok := s.decodeGroupBody(w, r)
+	if !ok {
+		return
+	}
+	if err := s.store.UpdateGroup(r.Context(), groupID, body.Name, body.Description, body.ParentID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrGroupCycle):
+			writeMessage(w, "不能将分组移动到自身或其子分组下", http.StatusBadRequest)
+		case isUniqueGroupNameError(err):
+			writeMessage(w, "分组名称已存在，请更换名称", http.StatusConflict)
+		default:
+			writeErr(w, err, http.StatusInternalServerError)
+		}
+		return
+	}
+	group, err := s.store.GetGroup(r.Context(), groupID)
+	if err != nil {
+		writeErr(w, err, http.StatusNotFound)
+		return
+	}
+	writeJSON(w, group)
+	s.broker.Publish(realtime.Event{
+		Type:    "group_updated",
+		Payload: group,
+	})
+	// 上级分组变化会改变继承的基线。
+	s.reevaluateCompliance(r.Context(), 0, groupID)
+}
+
+func (s *Server) apiDeleteGroup(w http.ResponseWriter, r *http.Request) {
+	groupID, err := parseIDParam(chi.URLParam(r, "groupID"))
+	if err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return
+	}
+	// 删除前记下成员，删除后它们可能不再适用该分组的基线。
+	hostIDs, err := s.store.GroupHostIDs(r.Context(), groupID, true)
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	if err := s.store.DeleteGroup(r.Context(), groupID); err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]string{"status": "ok"})
+	s.broker.Publish(realtime.Event{
+		Type:    "group_deleted",
+		Payload: map[string]interface{}{"id": groupID},
+	})
+	for _, hostID := range hostIDs {
+		s.scanner.EvaluateCompliance(r.Context(), hostID)
+	}
+}
+
+func (s *Server) apiListGroupMembers(w http.ResponseWriter, r *http.Request) {
+	group, ok := s.loadGroup(w, r)
+	if !ok {
+		return
+	}
+	hosts, err := s.store.ListHostsWithFilter(r.Context(), &store.HostFilter{
+		GroupID: group.ID,
+		Direct:  r.URL.Query().Get("nested") == "0",
+		Labels:  r.URL.Query().Get("labels"),
+	})
+	if err != nil {
+		writeQueryErr(w, err)
+		return
+	}
+	if hosts == nil {
+		hosts = []models.Host{}
+	}
+	writeJSON(w, hosts)
+}
+
+func (s *Server) apiAddGroupMembers(w http.ResponseWriter, r *http.Request) {
+	group, ok := s.loadGroup(w, r)
+	if !ok {
+		return
+	}
+	var body struct {
+		HostIDs []int64 `json:"hostIds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return
+	}
+	if len(body.HostIDs) == 0 {
+		writeMessage(w, "hostIds required", http.StatusBadRequest)
+		return
+	}
+	for _, hostID := range body.HostIDs {
+		if _, err := s.store.GetHost(r.Context(), hostID); err != nil {
+			writeMessage(w, "host not found", http.StatusNotFound)
+			return
+		}
+	}
+	added, err := s.store.AddGroupMembers(r.Context(), group.ID, body.HostIDs)
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"status": "ok",
+		"added":  added,
+	})
+	s.broker.Publish(realtime.Event{
+		Type: "group_members_changed",
+		Payload: map[string]interface{}{
+			"groupId": group.ID,
+			"added":   body.HostIDs,
+		},
+	})
+	for _, hostID := range body.HostIDs {
+		s.scanner.EvaluateCompliance(r.Context(), hostID)
+	}
+}
+
+func (s *Server) apiRemoveGroupMember(w http.ResponseWriter, r *http.Request) {
+	group, ok := s.loadGroup(w, r)
+	if !ok {
+		return
+	}
+	hostID, err := parseIDParam(chi.URLParam(r, "hostID"))
+	if err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return
+	}
+	if err := s.store.RemoveGroupMember(r.Context(), group.ID, hostID); err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]string{"status": "ok"})
+	s.broker.Publish(realtime.Event{
+		Type:   "group_members_changed",
+		HostID: hostID,
+		Payload: map[string]interface{}{
+			"groupId": group.ID,
+			"removed": []int64{hostID},
+		},
+	})
+	s.scanner.EvaluateCompliance(r.Context(), hostID)
+}
+
+func (s *Server) apiScanGroup(w http.ResponseWriter, r *http.Request) {
+	group, ok := s.loadGroup(w, r)
+	if !ok {
+		return
+	}
+	hostIDs, err := s.store.GroupHostIDs(r.Context(), group.ID, true)
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	scheduled := []int64{}
+	skipped := []map[string]interface{}{}
+	for _, hostID := range hostIDs {
+		host, err := s.store.GetHost(r.Context(), hostID)
+		if err != nil {
+			continue
+		}
+		if _, err := s.scanner.CheckTarget(r.Context(), hostID, host.Address); err != nil {
+			skipped = append(skipped, map[string]interface{}{"hostId": hostID, "reason": err.Error()})
+			continue
+		}
+		if !s.scanner.ScheduleFullRange(hostID, models.ScanPriorityManual) {
+			skipped = append(skipped, map[string]interface{}{"hostId": hostID, "reason": "scanning"})
+			continue
+		}
+		scheduled = append(scheduled, hostID)
+	}
+	writeJSON(w, map[string]interface{}{
+		"status":    "scheduled",
+		"scheduled": scheduled,
+		"skipped":   skipped,
+	})
+}
+
+func (s *Server) apiGroupBulkHidePorts(w http.ResponseWriter, r *http.Request) {
+	group, ok := s.loadGroup(w, r)
+	if !ok {
+		return
+	}
+	var body struct {
+		Start int  `json:"start"`
+		End   int  `json:"end"`
+		Hide  bool `json:"hide"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return
+	}
+	if body.Start <= 0 || body.End <= 0 || body.End > maxPort || body.End < body.Start {
+		writeMessage(w, "invalid range", http.StatusBadRequest)
+		return
+	}
+	affected, err := s.store.BulkSetHiddenForGroup(r.Context(), group.ID, body.Start, body.End, body.Hide)
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	var total int64
+	for _, n := range affected {
+		total += n
+	}
+	writeJSON(w, map[string]interface{}{
+		"status":   "ok",
+		"affected": total,
+		"hosts":    len(affected),
+	})
+	eventType := "ports_unhidden"
+	if body.Hide {
+		eventType = "ports_hidden"
+	}
+	for hostID, n := range affected {
+		s.broker.Publish(realtime.Event{
+			Type:   eventType,
+			HostID: hostID,
+			Payload: map[string]interface{}{
+				"start":    body.Start,
+				"end":      body.End,
+				"affected": n,
+				"groupId":  group.ID,
+			},
+		})
+	}
+}
+
+func (s *Server) apiGroupStats(w http.ResponseWriter, r *http.Request) {
+	group, ok := s.loadGroup(w, r)
+	if !ok {
+		return
+	}
+	stats, err := s.store.GroupStats(r.Context(), group.ID)
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, stats)
+}
+
+func (s *Server) decodeGroupBody(w http.ResponseWriter, r *http.Request) (*groupBody, bool) {
+	var body groupBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return nil, false
+	}
+	body.Name = strings.TrimSpace(body.Name)
+	body.Description = strings.TrimSpace(body.Description)
+	if body.Name == "" {
+		writeMessage(w, "name required", http.StatusBadRequest)
+		return nil, false
+	}
+	if body.ParentID > 0 {
+		if _, err := s.store.GetGroup(r.Context(), body.ParentID); err != nil {
+			writeMessage(w, "parent group not found", http.StatusBadRequest)
+			return nil, false
+		}
+	}
+	return &body, true
+}
+
+func (s *Server) loadGroup(w http.ResponseWriter, r *http.Request) (*models.HostGroup, bool) {
+	groupID, err := parseIDParam(chi.URLParam(r, "groupID"))
+	if err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return nil, false
+	}
+	group, err := s.store.GetGroup(r.Context(), groupID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeMessage(w, "group not found", http.StatusNotFound)
+			return nil, false
+		}
+		writeErr(w, err, http.StatusInternalServerError)
+		return nil, false
+	}
+	return group, true
+}
+
+func isUniqueGroupNameError(err error) bool {
+	return store.IsDuplicateName(err, "host_groups")
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/hitushen/portnotepro/internal/models"
+	"github.com/hitushen/portnotepro/internal/realtime"
+	"github.com/hitushen/portnotepro/internal/scanimport"
+	"github.com/hitushen/portnotepro/internal/scanner"
+	"github.com/hitushen/portnotepro/internal/store"
+)
+
+// maxImportBytes 限制导入文档大小，避免一次请求占用过多内存。
+const maxImportBytes = 64 << 20
+
+func (s *Server) apiExport(w http.ResponseWriter, r *http.Request) {
+	inv, err := s.store.ExportInventory(r.Context())
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="portnote-inventory-`+inv.ExportedAt.Format("20060102-150405")+`.json"`)
+	// 缩进输出，便于纳入 git 后逐行比对。
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(inv)
+}
+
+// apiImport 导入清单：mode=merge|replace，conflict=skip|overwrite（仅合并模式），dryRun=1 仅预览。
+func (s *Server) apiImport(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	opts := store.ImportOptions{
+		Mode:      query.Get("mode"),
+		Overwrite: query.Get("conflict") == "overwrite",
+		DryRun:    query.Get("dryRun") == "1" || query.Get("dryRun") == "true",
+		CheckTarget: func(ctx context.Context, address string) error {
+			_, err := s.scanner.CheckTarget(ctx, 0, address)
+			return err
+		},
+	}
+	if c := query.Get("conflict"); c != "" && c != "skip" && c != "overwrite" {
+		writeMessage(w, "conflict must be skip or overwrite", http.StatusBadRequest)
+		return
+	}
+
+	var inv models.Inventory
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxImportBytes)).Decode(&inv); err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return
+	}
+	report, err := s.store.ImportInventory(r.Context(), &inv, opts)
+	if err != nil {
+		if errors.Is(err, store.ErrInvalidInventory) {
+			writeErr(w, err, http.StatusBadRequest)
+			return
+		}
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, report)
+
+	if !report.DryRun {
+		s.broker.Publish(realtime.Event{
+			Type: "inventory_imported",
+			Payload: map[string]interface{}{
+				"mode":         report.Mode,
+				"hostsCreated": report.HostsCreated,
+				"hostsUpdated": report.HostsUpdated,
+				"hostsDeleted": report.HostsDeleted,
+				"portsCreated": report.PortsCreated,
+				"portsUpdated": report.PortsUpdated,
+			},
+		})
+	}
+}
+
+// apiImportScan 导入外部扫描器输出：format=auto|nmap|masscan|masscan-list|naabu，createHosts=0 时不新建主机。
+func (s *Server) apiImportScan(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportBytes))
+	if err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return
+	}
+	results, format, err := scanimport.Parse(r.URL.Query().Get("format"), data)
+	if err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return
+	}
+	opts := scanner.ImportOptions{CreateHosts: r.URL.Query().Get("createHosts") != "0"}
+	run, err := s.scanner.ImportResults(r.Context(), format, results, opts)
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, run)
+}
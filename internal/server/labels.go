@@ -0,0 +1,99 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/hitushen/portnotepro/internal/labels"
+	"github.com/hitushen/portnotepro/internal/realtime"
+)
+
+func (s *Server) apiSetHostLabels(w http.ResponseWriter, r *http.Request) {
+	hostID, err := parseIDParam(chi.URLParam(r, "hostID"))
+	if err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return
+	}
+	if _, err := s.store.GetHost(r.Context(), hostID); err != nil {
+		writeErr(w, err, http.StatusNotFound)
+		return
+	}
+	values, ok := decodeLabels(w, r)
+	if !ok {
+		return
+	}
+	if err := s.store.SetHostLabels(r.Context(), hostID, values); err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]interface{}{"labels": values})
+	s.broker.Publish(realtime.Event{
+		Type:   "labels_updated",
+		HostID: hostID,
+		Labels: values,
+		Payload: map[string]interface{}{
+			"target": "host",
+		},
+	})
+}
+
+func (s *Server) apiSetPortLabels(w http.ResponseWriter, r *http.Request) {
+	portID, err := parseIDParam(chi.URLParam(r, "portID"))
+	if err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return
+	}
+	port, err := s.store.GetPort(r.Context(), portID)
+	if err != nil {
+		writeErr(w, err, http.StatusNotFound)
+		return
+	}
+	values, ok := decodeLabels(w, r)
+	if !ok {
+		return
+	}
+	if err := s.store.SetPortLabels(r.Context(), portID, values); err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]interface{}{"labels": values})
+	s.broker.Publish(realtime.Event{
+		Type:   "labels_updated",
+		HostID: port.HostID,
+		PortID: portID,
+		Labels: values,
+		Payload: map[string]interface{}{
+			"target": "port",
+			"number": port.Number,
+		},
+	})
+}
+
+// decodeLabels 读取 {"labels": {...}} 请求体并校验标签键值。
+func decodeLabels(w http.ResponseWriter, r *http.Request) (map[string]string, bool) {
+	var body struct {
+		Labels map[string]string `json:"labels"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return nil, false
+	}
+	values, err := labels.Normalize(body.Labels)
+	if err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return nil, false
+	}
+	return values, true
+}
+
+// writeQueryErr 将标签查询语法错误返回为 400，其余视为内部错误。
+func writeQueryErr(w http.ResponseWriter, err error) {
+	if errors.Is(err, labels.ErrSyntax) {
+		writeErr(w, err, http.StatusBadRequest)
+		return
+	}
+	writeErr(w, err, http.StatusInternalServerError)
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/hitushen/portnotepro/internal/auth"
+	"github.com/hitushen/portnotepro/internal/metrics"
+)
+
+var httpDuration = metrics.NewHistogram("portnote_http_request_duration_seconds",
+	"HTTP request latency by method, chi route pattern and status code.",
+	metrics.DefBuckets, "method", "route", "code")
+
+// registerMetrics 注册采集时读取的运行状态与资产暴露指标。
+func (s *Server) registerMetrics() {
+	reg := metrics.Default
+	reg.GaugeFunc("portnote_scan_queue_depth", "Scan jobs waiting in the queue.", func() float64 {
+		return float64(s.scanner.QueueDepth())
+	})
+	reg.GaugeFunc("portnote_scan_workers_active", "Scan workers currently running a job.", func() float64 {
+		return float64(s.scanner.ActiveWorkers())
+	})
+	reg.GaugeFunc("portnote_scan_workers", "Configured number of scan workers.", func() float64 {
+		return float64(s.scanner.Concurrency())
+	})
+	reg.GaugeFunc("portnote_scan_rate_pps", "Packets-per-second budget currently allocated to running scans.", func() float64 {
+		return float64(s.scanner.RateInUse())
+	})
+	reg.GaugeFunc("portnote_sse_subscribers", "Connected SSE clients.", func() float64 {
+		return float64(s.broker.Subscribers())
+	})
+	reg.CounterFunc("portnote_sse_dropped_events_total", "Events dropped because an SSE client was too slow.", func() float64 {
+		return float64(s.broker.Dropped())
+	})
+	reg.GaugeVecFunc("portnote_host_open_ports", "Open ports per host.", []string{"host_id", "host"}, func(ctx context.Context) ([]metrics.Sample, error) {
+		hosts, err := s.store.ListHosts(ctx)
+		if err != nil {
+			return nil, err
+		}
+		counts, err := s.store.OpenPortCounts(ctx)
+		if err != nil {
+			return nil, err
+		}
+		samples := make([]metrics.Sample, 0, len(hosts))
+		for _, h := range hosts {
+			samples = append(samples, metrics.Sample{
+				LabelValues: []string{strconv.FormatInt(h.ID, 10), h.Name},
+				Value:       float64(counts[h.ID]),
+			})
+		}
+		return samples, nil
+	})
+}
+
+// metricsAuth 保护 /metrics：配置了 PORTNOTE_METRICS_TOKEN 时可用 Bearer 该令牌访问，
+// 否则与其他接口一样需要登录会话或个人 API 令牌，未配置令牌时不会公开指标。
+func (s *Server) metricsAuth(next http.Handler) http.Handler {
+	protected := s.auth.Middleware(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := s.config().MetricsToken; token != "" {
+			if bearer, ok := auth.BearerToken(r); ok && subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) == 1 {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		protected.ServeHTTP(w, r)
+	})
+}
+
+// instrumentRequests 按 chi 路由模板记录请求耗时，未匹配路由的请求归为 "other"，SSE 长连接不计入。
+func instrumentRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+		if ww.Header().Get("Content-Type") == "text/event-stream" {
+			return
+		}
+		route := "other"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && len(rctx.RoutePatterns) > 0 {
+			// chi 会去掉模板末尾的 "/"，根路径因此为空。
+			if route = rctx.RoutePattern(); route == "" {
+				route = "/"
+			}
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		httpDuration.Observe(time.Since(start).Seconds(), r.Method, route, strconv.Itoa(status))
+	})
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/hitushen/portnotepro/internal/auth"
+	"github.com/hitushen/portnotepro/internal/config"
+	"github.com/hitushen/portnotepro/internal/store"
+)
+
+// newMetricsServer 创建只含路由所需组件的 Server，其中用户 admin 持有个人 API 令牌 personal-token。
+func newMetricsServer(t *testing.T, metricsToken string) http.Handler {
+	t.Helper()
+	ctx := context.Background()
+	st, err := store.New(filepath.Join(t.TempDir(), "portnote.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { st.Close() })
+	userID, err := st.CreateUser(ctx, "admin", "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.CreateAPIToken(ctx, userID, "ci", "personal-token"); err != nil {
+		t.Fatal(err)
+	}
+
+	key := bytes.Repeat([]byte("k"), 32)
+	s := &Server{store: st, auth: auth.NewManager(st, key)}
+	s.cfg.Store(&config.Config{SessionKey: key, CSRFKey: key, MetricsToken: metricsToken})
+	return s.Handler()
+}
+
+func scrape(h http.Handler, bearer string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMetricsRequireAuthentication(t *testing.T) {
+	cases := []struct {
+		name         string
+		metricsToken string
+		bearer       string
+		want         int
+	}{
+		{name: "no token configured, anonymous", want: http.StatusFound},
+		{name: "no token configured, api token", bearer: "personal-token", want: http.StatusOK},
+		{name: "no token configured, arbitrary bearer", bearer: "guess", want: http.StatusUnauthorized},
+		{name: "token configured, anonymous", metricsToken: "scrape-secret", want: http.StatusFound},
+		{name: "token configured, metrics token", metricsToken: "scrape-secret", bearer: "scrape-secret", want: http.StatusOK},
+		{name: "token configured, api token", metricsToken: "scrape-secret", bearer: "personal-token", want: http.StatusOK},
+		{name: "token configured, wrong token", metricsToken: "scrape-secret", bearer: "scrape-secre", want: http.StatusUnauthorized},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			h := newMetricsServer(t, c.metricsToken)
+			rec := scrape(h, c.bearer)
+			if rec.Code != c.want {
+				t.Fatalf("status = %d, want %d", rec.Code, c.want)
+			}
+			if c.want == http.StatusOK && !bytes.Contains(rec.Body.Bytes(), []byte("# TYPE")) {
+				t.Errorf("body is not Prometheus text:\n%s", rec.Body.String())
+			}
+			if c.want != http.StatusOK && bytes.Contains(rec.Body.Bytes(), []byte("portnote_")) {
+				t.Errorf("rejected request still received metrics:\n%s", rec.Body.String())
+			}
+		})
+	}
+}
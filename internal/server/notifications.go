@@ -0,0 +1,124 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/mail"
+	"strings"
+
+	"github.com/hitushen/portnotepro/internal/alerting"
+	"github.com/hitushen/portnotepro/internal/models"
+)
+
+// apiGetSubscription 返回当前用户的邮件通知偏好。
+func (s *Server) apiGetSubscription(w http.ResponseWriter, r *http.Request) {
+	userID, err := s.auth.RequireUser(w, r)
+	if err != nil {
+		writeErr(w, err, http.StatusUnauthorized)
+		return
+	}
+	sub, err := s.store.GetSubscription(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeMessage(w, "notifications not configured", http.StatusNotFound)
+			return
+		}
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, sub)
+}
+
+// apiSetSubscription 保存当前用户的偏好：{"email": "...", "digest": "off|daily|weekly", "groupId": 0}。
+func (s *Server) apiSetSubscription(w http.ResponseWriter, r *http.Request) {
+	userID, err := s.auth.RequireUser(w, r)
+	if err != nil {
+		writeErr(w, err, http.StatusUnauthorized)
+		return
+	}
+	var body struct {
+		Email   string `json:"email"`
+		Digest  string `json:"digest"`
+		GroupID int64  `json:"groupId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return
+	}
+	addr, err := mail.ParseAddress(strings.TrimSpace(body.Email))
+	if err != nil {
+		writeMessage(w, "invalid email address", http.StatusBadRequest)
+		return
+	}
+	if body.Digest == "" {
+		body.Digest = models.DigestOff
+	}
+	if err := alerting.ValidDigest(body.Digest); err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return
+	}
+	if body.Digest != models.DigestOff && !s.alerts.EmailEnabled() {
+		writeMessage(w, "digests require PORTNOTE_SMTP_HOST", http.StatusBadRequest)
+		return
+	}
+	if body.GroupID > 0 {
+		if _, err := s.store.GetGroup(r.Context(), body.GroupID); err != nil {
+			writeMessage(w, "group not found", http.StatusBadRequest)
+			return
+		}
+	}
+	sub := &models.NotificationSubscription{UserID: userID, Email: addr.Address, Digest: body.Digest, GroupID: body.GroupID}
+	if err := s.store.SetSubscription(r.Context(), sub); err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	saved, err := s.store.GetSubscription(r.Context(), userID)
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, saved)
+}
+
+func (s *Server) apiDeleteSubscription(w http.ResponseWriter, r *http.Request) {
+	userID, err := s.auth.RequireUser(w, r)
+	if err != nil {
+		writeErr(w, err, http.StatusUnauthorized)
+		return
+	}
+	if err := s.store.DeleteSubscription(r.Context(), userID); err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]string{"status": "ok"})
+}
+
+// apiSendDigest 立即向当前用户发送一份摘要（周期取订阅设置，未开启时按天），不影响定时摘要。
+func (s *Server) apiSendDigest(w http.ResponseWriter, r *http.Request) {
+	userID, err := s.auth.RequireUser(w, r)
+	if err != nil {
+		writeErr(w, err, http.StatusUnauthorized)
+		return
+	}
+	if !s.alerts.EmailEnabled() {
+		writeMessage(w, "smtp is not configured", http.StatusBadRequest)
+		return
+	}
+	sub, err := s.store.GetSubscription(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeMessage(w, "notifications not configured", http.StatusNotFound)
+			return
+		}
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	digest, err := s.alerts.SendDigest(r.Context(), sub)
+	if err != nil {
+		writeErr(w, err, http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, digest)
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"log"
+
+	"github.com/hitushen/portnotepro/internal/alerting"
+	"github.com/hitushen/portnotepro/internal/config"
+	"github.com/hitushen/portnotepro/internal/models"
+	"github.com/hitushen/portnotepro/internal/scanner"
+	"github.com/hitushen/portnotepro/internal/targets"
+)
+
+// config 返回当前生效的配置。
+func (s *Server) config() *config.Config {
+	return s.cfg.Load()
+}
+
+// Reload 应用重新加载后的配置：扫描并发与速率、重试、扫描策略、agent 租约、告警重试与 SMTP、
+// 配置文件中的通知目标以及 metrics 令牌立即生效。cfg 中需要重启的配置项应保持为当前值（见 config.WithReloadable）。
+func (s *Server) Reload(cfg *config.Config) error {
+	policy, err := targets.NewPolicy(cfg.ScanAllow, cfg.ScanDeny)
+	if err != nil {
+		return err
+	}
+	applyScanSettings(s.scanner, cfg, policy)
+	s.alerts.SetOptions(alertOptions(cfg))
+	s.syncAlertTargets(context.Background(), cfg.AlertTargets)
+	s.cfg.Store(cfg)
+	return nil
+}
+
+// applyScanSettings 把可热加载的扫描配置应用到扫描管理器。
+func applyScanSettings(m *scanner.Manager, cfg *config.Config, policy *targets.Policy) {
+	m.SetConcurrency(cfg.ScanConcurrency)
+	m.SetBasePolicy(policy)
+	m.SetRateBudget(cfg.ScanRate)
+	m.SetPerHostConcurrency(cfg.ScanPerHost)
+	m.SetRetryPolicy(cfg.ScanRetries, cfg.ScanRetryBackoff)
+	m.SetMissThreshold(cfg.ScanMissThreshold)
+	m.SetAgentLease(cfg.AgentLease)
+}
+
+func alertOptions(cfg *config.Config) alerting.Options {
+	return alerting.Options{
+		MaxAttempts: cfg.AlertMaxAttempts,
+		Timeout:     cfg.AlertTimeout,
+		Backoff:     cfg.AlertBackoff,
+		SMTP: alerting.SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUser,
+			Password: cfg.SMTPPassword,
+			From:     cfg.SMTPFrom,
+			TLS:      cfg.SMTPTLS,
+		},
+	}
+}
+
+// syncAlertTargets 按名称创建或更新配置文件中声明的通知目标，界面中创建的其他目标不受影响。
+func (s *Server) syncAlertTargets(ctx context.Context, declared []config.AlertTarget) {
+	if len(declared) == 0 {
+		return
+	}
+	existing, err := s.store.ListAlertTargets(ctx)
+	if err != nil {
+		log.Printf("[config] load alert targets failed: %v", err)
+		return
+	}
+	byName := make(map[string]models.AlertTarget, len(existing))
+	for _, t := range existing {
+		byName[t.Name] = t
+	}
+	for _, d := range declared {
+		current, found := byName[d.Name]
+		target := current
+		target.Name, target.Kind, target.URL, target.Secret = d.Name, d.Kind, d.URL, d.Secret
+		target.MinSeverity, target.Enabled = d.MinSeverity, d.Enabled
+		switch {
+		case !found:
+			if _, err := s.store.CreateAlertTarget(ctx, &target); err != nil {
+				log.Printf("[config] create alert target %q failed: %v", d.Name, err)
+				continue
+			}
+			log.Printf("[config] created alert target %q", d.Name)
+		case target != current:
+			if err := s.store.UpdateAlertTarget(ctx, &target); err != nil {
+				log.Printf("[config] update alert target %q failed: %v", d.Name, err)
+				continue
+			}
+			log.Printf("[config] updated alert target %q", d.Name)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/hitushen/portnotepro/internal/models"
+	"github.com/hitushen/portnotepro/internal/store"
+)
+
+// apiListScanJobs 返回扫描任务队列，支持 status、hostId、limit 过滤；
+// status 为 queued / running 时按执行顺序排列，否则最新的在前。
+func (s *Server) apiListScanJobs(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	filter := store.ScanJobFilter{
+		Status: params.Get("status"),
+		Limit:  intParam(params.Get("limit"), 100),
+	}
+	switch filter.Status {
+	case "", models.ScanJobQueued, models.ScanJobRunning, models.ScanJobDone, models.ScanJobFailed:
+	default:
+		writeMessage(w, "invalid status", http.StatusBadRequest)
+		return
+	}
+	if filter.Limit > 500 {
+		filter.Limit = 500
+	}
+	if raw := params.Get("hostId"); raw != "" {
+		id, err := parseIDParam(raw)
+		if err != nil {
+			writeMessage(w, "invalid hostId", http.StatusBadRequest)
+			return
+		}
+		filter.HostID = id
+	}
+	list, err := s.store.ListScanJobs(r.Context(), filter)
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, list)
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hitushen/portnotepro/internal/models"
+	"github.com/hitushen/portnotepro/internal/store"
+	"github.com/hitushen/portnotepro/internal/targets"
+)
+
+// apiSearchPorts 跨全部主机检索端口。
+func (s *Server) apiSearchPorts(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	query, err := parsePortSearch(params)
+	if err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return
+	}
+	matches, total, err := s.store.SearchPorts(r.Context(), query)
+	if err != nil {
+		writeQueryErr(w, err)
+		return
+	}
+	if matches == nil {
+		matches = []models.PortMatch{}
+	}
+	writeJSON(w, map[string]interface{}{
+		"ports": matches,
+		"pagination": map[string]interface{}{
+			"page":     query.Page,
+			"pageSize": query.PageSize,
+			"total":    total,
+		},
+	})
+}
+
+func parsePortSearch(params map[string][]string) (*store.PortQuery, error) {
+	get := func(key string) string {
+		if v := params[key]; len(v) > 0 {
+			return strings.TrimSpace(v[0])
+		}
+		return ""
+	}
+	pageSize := intParam(get("pageSize"), 50)
+	if pageSize > 200 {
+		pageSize = 200
+	}
+	if pageSize < 1 {
+		pageSize = 50
+	}
+	query := &store.PortQuery{
+		Search:      get("q"),
+		Status:      get("status"),
+		Labels:      get("labels"),
+		Fingerprint: get("fingerprint"),
+		Note:        get("note"),
+		SortBy:      get("sort"),
+		SortDesc:    strings.EqualFold(get("order"), "desc"),
+		Page:        intParam(get("page"), 1),
+		PageSize:    pageSize,
+	}
+	if raw := get("number"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxPort {
+			return nil, fmt.Errorf("invalid port number %q", raw)
+		}
+		query.Number = n
+	}
+	if raw := get("hostId"); raw != "" {
+		id, err := parseIDParam(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hostId %q", raw)
+		}
+		query.HostID = id
+	}
+	if raw := get("range"); raw != "" {
+		ranges, err := targets.ParsePortRanges(raw)
+		if err != nil {
+			return nil, err
+		}
+		if len(ranges) != 1 {
+			return nil, fmt.Errorf("range must be a single start-end pair")
+		}
+		query.PortMin, query.PortMax = ranges[0].Start, ranges[0].End
+	}
+	switch get("hidden") {
+	case "", "all":
+	case "1", "true":
+		hidden := true
+		query.Hidden = &hidden
+	case "0", "false":
+		hidden := false
+		query.Hidden = &hidden
+	default:
+		return nil, fmt.Errorf("invalid hidden value %q", get("hidden"))
+	}
+	// checkedWithin 为相对窗口（如 24h），与 checkedAfter 同时出现时取较晚者。
+	if raw := get("checkedWithin"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid checkedWithin %q", raw)
+		}
+		query.CheckedAfter = time.Now().Add(-d)
+	}
+	if raw := get("checkedAfter"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid checkedAfter %q", raw)
+		}
+		if t.After(query.CheckedAfter) {
+			query.CheckedAfter = t
+		}
+	}
+	if raw := get("checkedBefore"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid checkedBefore %q", raw)
+		}
+		query.CheckedBefore = t
+	}
+	return query, nil
+}
+
+// apiSearch 在端口备注、指纹与主机名称、地址中做全文检索。
+func (s *Server) apiSearch(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		writeMessage(w, "q required", http.StatusBadRequest)
+		return
+	}
+	results, err := s.store.Search(r.Context(), q, intParam(r.URL.Query().Get("limit"), 50))
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, results)
+}
@@ -2,7 +2,9 @@ package server
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"math/rand"
@@ -10,14 +12,18 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/gorilla/csrf"
 
+	"github.com/hitushen/portnotepro/internal/alerting"
 	"github.com/hitushen/portnotepro/internal/auth"
+	"github.com/hitushen/portnotepro/internal/backup"
 	"github.com/hitushen/portnotepro/internal/config"
+	"github.com/hitushen/portnotepro/internal/metrics"
 	"github.com/hitushen/portnotepro/internal/models"
 	"github.com/hitushen/portnotepro/internal/realtime"
 	"github.com/hitushen/portnotepro/internal/scanner"
@@ -28,11 +34,14 @@ import (
 
 // Server 负责协调 HTTP 路由、模板渲染与业务逻辑。
 type Server struct {
-	cfg       *config.Config
-	store     *store.Store
+	// cfg 为当前生效的配置，收到 SIGHUP 重新加载后被替换。
+	cfg       atomic.Pointer[config.Config]
+	store     store.Storage
 	auth      *auth.Manager
 	scanner   *scanner.Manager
 	broker    *realtime.Broker
+	backups   *backup.Manager
+	alerts    *alerting.Engine
 	templates *template.Template
 }
 
@@ -43,9 +52,18 @@ func init() {
 }
 
 // New 创建并初始化带路由的 Server。
-func New(cfg *config.Config, st *store.Store) (*Server, error) {
+func New(cfg *config.Config, st store.Storage) (*Server, error) {
+	if err := targets.SetResolver(cfg.DNSResolver); err != nil {
+		return nil, err
+	}
+	policy, err := targets.NewPolicy(cfg.ScanAllow, cfg.ScanDeny)
+	if err != nil {
+		return nil, err
+	}
 	broker := realtime.NewBroker()
 	scanManager := scanner.NewManager(st, cfg.ScanTimeout, cfg.ScanConcurrency, broker)
+	scanManager.SetInstanceID(cfg.InstanceID)
+	applyScanSettings(scanManager, cfg, policy)
 
 	tmpl, err := template.ParseGlob(filepath.Join("web", "templates", "*.tmpl"))
 	if err != nil {
@@ -53,19 +71,29 @@ func New(cfg *config.Config, st *store.Store) (*Server, error) {
 	}
 
 	srv := &Server{
-		cfg:       cfg,
 		store:     st,
 		auth:      auth.NewManager(st, cfg.SessionKey),
 		scanner:   scanManager,
 		broker:    broker,
+		backups:   backup.NewManager(st, cfg.BackupDir, cfg.BackupKeep),
+		alerts:    alerting.NewEngine(st, alertOptions(cfg)),
 		templates: tmpl,
 	}
+	srv.cfg.Store(cfg)
+	srv.syncAlertTargets(context.Background(), cfg.AlertTargets)
+	srv.scanner.Start()
+	srv.backups.Start(cfg.BackupInterval)
+	srv.alerts.Start(broker)
+	srv.registerMetrics()
 	return srv, nil
 }
 
 // Close 关闭后台组件。
 func (s *Server) Close() {
+	s.backups.Close()
 	s.scanner.Close()
+	// 扫描器停止后不再产生新事件，再关闭告警投递。
+	s.alerts.Close()
 }
 
 // Handler 返回根 HTTP 处理器。
@@ -73,11 +101,12 @@ func (s *Server) Handler() http.Handler {
 	r := chi.NewRouter()
 	r.Use(middleware.RequestID)
 	r.Use(middleware.Logger)
+	r.Use(instrumentRequests)
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.Heartbeat("/healthz"))
 
 	csrfMiddleware := csrf.Protect(
-		s.cfg.CSRFKey,
+		s.config().CSRFKey,
 		csrf.Secure(false),
 		csrf.Path("/"),
 		csrf.FieldName("csrf_token"),
@@ -88,6 +117,17 @@ func (s *Server) Handler() http.Handler {
 		pub.Post("/login", s.handleLogin)
 	})
 
+	// 远程 agent 使用 Bearer 令牌认证，不经过会话与 CSRF 校验。
+	r.Route("/agent/v1", func(agent chi.Router) {
+		agent.Use(s.agentAuth)
+		agent.Post("/register", s.agentRegister)
+		agent.Post("/jobs/lease", s.agentLeaseJob)
+		agent.Post("/jobs/{jobID}/heartbeat", s.agentHeartbeat)
+		agent.Post("/jobs/{jobID}/result", s.agentSubmitResult)
+	})
+
+	r.With(s.metricsAuth).Get("/metrics", metrics.Default.ServeHTTP)
+
 	fileServer := http.FileServer(http.Dir(filepath.Join("web", "static")))
 	r.Handle("/static/*", http.StripPrefix("/static/", fileServer))
 
@@ -103,21 +143,99 @@ func (s *Server) Handler() http.Handler {
 		api.Put("/hosts/{hostID}", s.apiUpdateHost)
 		api.Delete("/hosts/{hostID}", s.apiDeleteHost)
 		api.Post("/hosts/{hostID}/scan", s.apiScanHost)
+		api.Get("/hosts/{hostID}/addresses", s.apiListHostAddresses)
+		api.Put("/hosts/{hostID}/labels", s.apiSetHostLabels)
+		api.Get("/hosts/{hostID}/exclusions", s.apiListHostExclusions)
+		api.Post("/hosts/{hostID}/exclusions", s.apiCreateExclusion)
+		api.Put("/hosts/{hostID}/assignment", s.apiSetHostAssignment)
+		api.Get("/hosts/{hostID}/baseline", s.apiGetHostBaseline)
+		api.Put("/hosts/{hostID}/baseline", s.apiSetHostBaseline)
+		api.Delete("/hosts/{hostID}/baseline", s.apiDeleteHostBaseline)
 
 		api.Get("/hosts/{hostID}/ports", s.apiListPorts)
+		api.Get("/hosts/{hostID}/ports.csv", s.apiExportHostPortsCSV)
 		api.Post("/hosts/{hostID}/ports", s.apiCreatePort)
 		api.Post("/hosts/{hostID}/ports/bulk_hide", s.apiBulkHidePorts)
 		api.Post("/hosts/{hostID}/ports/bulk_delete", s.apiBulkDeletePorts)
 		api.Get("/hosts/{hostID}/unused_port", s.apiSuggestPort)
 		api.Post("/hosts/{hostID}/ports/bulk_delete", s.apiBulkDeletePorts)
 
+		api.Get("/scan-jobs", s.apiListScanJobs)
+
+		api.Get("/search", s.apiSearch)
+		api.Get("/ports/search", s.apiSearchPorts)
+		api.Get("/ports.csv", s.apiExportPortsCSV)
 		api.Put("/ports/{portID}", s.apiUpdatePort)
+		api.Get("/ports/{portID}/services", s.apiPortServiceHistory)
+		api.Put("/ports/{portID}/labels", s.apiSetPortLabels)
 		api.Post("/ports/{portID}/hide", s.apiHidePort)
 		api.Post("/ports/{portID}/unhide", s.apiUnhidePort)
 		api.Delete("/ports/{portID}", s.apiDeletePort)
+
+		api.Get("/groups", s.apiListGroups)
+		api.Post("/groups", s.apiCreateGroup)
+		api.Put("/groups/{groupID}", s.apiUpdateGroup)
+		api.Delete("/groups/{groupID}", s.apiDeleteGroup)
+		api.Get("/groups/{groupID}/members", s.apiListGroupMembers)
+		api.Post("/groups/{groupID}/members", s.apiAddGroupMembers)
+		api.Delete("/groups/{groupID}/members/{hostID}", s.apiRemoveGroupMember)
+		api.Post("/groups/{groupID}/scan", s.apiScanGroup)
+		api.Post("/groups/{groupID}/ports/bulk_hide", s.apiGroupBulkHidePorts)
+		api.Get("/groups/{groupID}/stats", s.apiGroupStats)
+		api.Get("/groups/{groupID}/baseline", s.apiGetGroupBaseline)
+		api.Put("/groups/{groupID}/baseline", s.apiSetGroupBaseline)
+		api.Delete("/groups/{groupID}/baseline", s.apiDeleteGroupBaseline)
+
+		api.Get("/baselines", s.apiListBaselines)
+		api.Get("/compliance", s.apiCompliance)
+
+		api.Get("/alerts/targets", s.apiListAlertTargets)
+		api.Post("/alerts/targets", s.apiCreateAlertTarget)
+		api.Put("/alerts/targets/{targetID}", s.apiUpdateAlertTarget)
+		api.Delete("/alerts/targets/{targetID}", s.apiDeleteAlertTarget)
+		api.Post("/alerts/targets/{targetID}/test", s.apiTestAlertTarget)
+		api.Get("/alerts/rules", s.apiListAlertRules)
+		api.Post("/alerts/rules", s.apiCreateAlertRule)
+		api.Put("/alerts/rules/{ruleID}", s.apiUpdateAlertRule)
+		api.Delete("/alerts/rules/{ruleID}", s.apiDeleteAlertRule)
+		api.Get("/alerts/deliveries", s.apiListAlertDeliveries)
+		api.Post("/alerts/deliveries/{deliveryID}/retry", s.apiRetryAlertDelivery)
+
+		api.Get("/me/notifications", s.apiGetSubscription)
+		api.Put("/me/notifications", s.apiSetSubscription)
+		api.Delete("/me/notifications", s.apiDeleteSubscription)
+		api.Post("/me/notifications/digest", s.apiSendDigest)
+		api.Get("/me/tokens", s.apiListTokens)
+		api.Post("/me/tokens", s.apiCreateToken)
+		api.Delete("/me/tokens/{tokenID}", s.apiDeleteToken)
+
+		api.Get("/exclusions", s.apiListExclusions)
+		api.Post("/exclusions", s.apiCreateExclusion)
+		api.Delete("/exclusions/{exclusionID}", s.apiDeleteExclusion)
+
+		api.Get("/export", s.apiExport)
+		api.Post("/import", s.apiImport)
+		api.Post("/import/scan", s.apiImportScan)
+
+		api.Get("/agents", s.apiListAgents)
+		api.Post("/agents", s.apiCreateAgent)
+		api.Put("/agents/{agentID}", s.apiUpdateAgent)
+		api.Post("/agents/{agentID}/token", s.apiRotateAgentToken)
+		api.Delete("/agents/{agentID}", s.apiDeleteAgent)
+
+		api.Get("/admin/backups", s.apiListBackups)
+		api.Post("/admin/backups", s.apiCreateBackup)
+		api.Get("/admin/backups/{name}", s.apiDownloadBackup)
 	})
 
-	return csrfMiddleware(r)
+	protected := csrfMiddleware(r)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 浏览器不会自动附带 Authorization 头，Bearer 令牌认证的请求无需 CSRF 校验。
+		if _, ok := auth.BearerToken(r); ok || strings.HasPrefix(r.URL.Path, "/agent/") {
+			r = csrf.UnsafeSkipCheck(r)
+		}
+		protected.ServeHTTP(w, r)
+	})
 }
 
 func (s *Server) showLogin(w http.ResponseWriter, r *http.Request) {
@@ -170,9 +288,20 @@ func (s *Server) dashboard(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) apiListHosts(w http.ResponseWriter, r *http.Request) {
-	hosts, err := s.store.ListHosts(r.Context())
+	filter := &store.HostFilter{}
+	if raw := r.URL.Query().Get("group"); raw != "" {
+		groupID, err := parseIDParam(raw)
+		if err != nil {
+			writeErr(w, err, http.StatusBadRequest)
+			return
+		}
+		filter.GroupID = groupID
+		filter.Direct = r.URL.Query().Get("nested") == "0"
+	}
+	filter.Labels = r.URL.Query().Get("labels")
+	hosts, err := s.store.ListHostsWithFilter(r.Context(), filter)
 	if err != nil {
-		writeErr(w, err, http.StatusInternalServerError)
+		writeQueryErr(w, err)
 		return
 	}
 	if hosts == nil {
@@ -186,6 +315,8 @@ func (s *Server) apiCreateHost(w http.ResponseWriter, r *http.Request) {
 		Name     string `json:"name"`
 		Address  string `json:"address"`
 		AutoScan bool   `json:"autoScan"`
+		Zone     string `json:"zone"`
+		AgentID  int64  `json:"agentId"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		writeErr(w, err, http.StatusBadRequest)
@@ -203,6 +334,14 @@ func (s *Server) apiCreateHost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	body.Address = address
+	if _, err := s.scanner.CheckTarget(r.Context(), 0, address); err != nil {
+		writePolicyErr(w, err)
+		return
+	}
+	zone, ok := s.checkAssignment(w, r.Context(), body.Zone, body.AgentID)
+	if !ok {
+		return
+	}
 	hostID, err := s.store.CreateHost(r.Context(), body.Name, body.Address, body.AutoScan)
 	if err != nil {
 		if isUniqueHostNameError(err) {
@@ -212,6 +351,13 @@ func (s *Server) apiCreateHost(w http.ResponseWriter, r *http.Request) {
 		writeErr(w, err, http.StatusInternalServerError)
 		return
 	}
+	// 先确定扫描归属再安排首次扫描，避免任务被服务端本地领取。
+	if zone != "" || body.AgentID != 0 {
+		if err := s.store.SetHostAssignment(r.Context(), hostID, zone, body.AgentID); err != nil {
+			writeErr(w, err, http.StatusInternalServerError)
+			return
+		}
+	}
 	host, _ := s.store.GetHost(r.Context(), hostID)
 	writeJSON(w, host)
 
@@ -224,7 +370,7 @@ func (s *Server) apiCreateHost(w http.ResponseWriter, r *http.Request) {
 		},
 	})
 
-	s.scanner.ScheduleFullRange(hostID)
+	s.scanner.ScheduleFullRange(hostID, models.ScanPriorityManual)
 }
 
 func (s *Server) apiUpdateHost(w http.ResponseWriter, r *http.Request) {
@@ -253,6 +399,10 @@ func (s *Server) apiUpdateHost(w http.ResponseWriter, r *http.Request) {
 		writeMessage(w, "invalid host address", http.StatusBadRequest)
 		return
 	}
+	if _, err := s.scanner.CheckTarget(r.Context(), hostID, address); err != nil {
+		writePolicyErr(w, err)
+		return
+	}
 	if err := s.store.UpdateHost(r.Context(), hostID, body.Name, address, body.AutoScan); err != nil {
 		if isUniqueHostNameError(err) {
 			writeMessage(w, "主机名称已存在，请更换名称", http.StatusConflict)
@@ -267,6 +417,7 @@ func (s *Server) apiUpdateHost(w http.ResponseWriter, r *http.Request) {
 	s.broker.Publish(realtime.Event{
 		Type:   "host_updated",
 		HostID: hostID,
+		Labels: host.Labels,
 		Payload: map[string]interface{}{
 			"name":     host.Name,
 			"address":  host.Address,
@@ -298,6 +449,15 @@ func (s *Server) apiScanHost(w http.ResponseWriter, r *http.Request) {
 		writeErr(w, err, http.StatusBadRequest)
 		return
 	}
+	host, err := s.store.GetHost(r.Context(), hostID)
+	if err != nil {
+		writeErr(w, err, http.StatusNotFound)
+		return
+	}
+	if _, err := s.scanner.CheckTarget(r.Context(), hostID, host.Address); err != nil {
+		writePolicyErr(w, err)
+		return
+	}
 	if ok := s.scanner.ScheduleHost(context.Background(), hostID, true); !ok {
 		writeJSON(w, map[string]string{"status": "scanning"})
 		return
@@ -305,6 +465,27 @@ func (s *Server) apiScanHost(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, map[string]string{"status": "scheduled"})
 }
 
+func (s *Server) apiListHostAddresses(w http.ResponseWriter, r *http.Request) {
+	hostID, err := parseIDParam(chi.URLParam(r, "hostID"))
+	if err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return
+	}
+	if _, err := s.store.GetHost(r.Context(), hostID); err != nil {
+		writeErr(w, err, http.StatusNotFound)
+		return
+	}
+	addrs, err := s.store.ListHostAddresses(r.Context(), hostID)
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	if addrs == nil {
+		addrs = []models.HostAddress{}
+	}
+	writeJSON(w, addrs)
+}
+
 func (s *Server) apiListPorts(w http.ResponseWriter, r *http.Request) {
 	hostID, err := parseIDParam(chi.URLParam(r, "hostID"))
 	if err != nil {
@@ -312,7 +493,6 @@ func (s *Server) apiListPorts(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	queryParams := r.URL.Query()
-	includeHidden := queryParams.Get("hidden") == "1"
 	page := intParam(queryParams.Get("page"), 1)
 	pageSize := intParam(queryParams.Get("pageSize"), 24)
 	if pageSize > 200 {
@@ -321,24 +501,12 @@ func (s *Server) apiListPorts(w http.ResponseWriter, r *http.Request) {
 	if pageSize < 1 {
 		pageSize = 24
 	}
-	sortBy := queryParams.Get("sort")
-	order := strings.ToLower(queryParams.Get("order"))
-	search := queryParams.Get("q")
-	status := queryParams.Get("status")
-	if strings.TrimSpace(status) == "" {
-		status = models.PortStatusOpen
-	}
-
-	ports, total, err := s.store.ListPortsWithQuery(r.Context(), hostID, includeHidden, &store.PortQuery{
-		Search:   search,
-		Status:   status,
-		SortBy:   sortBy,
-		SortDesc: order == "desc",
-		Page:     page,
-		PageSize: pageSize,
-	})
+	query := portListQuery(r)
+	query.Page, query.PageSize = page, pageSize
+
+	ports, total, err := s.store.ListPortsWithQuery(r.Context(), hostID, query.Hidden == nil, query)
 	if err != nil {
-		writeErr(w, err, http.StatusInternalServerError)
+		writeQueryErr(w, err)
 		return
 	}
 	if ports == nil {
@@ -354,9 +522,9 @@ func (s *Server) apiListPorts(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	response := map[string]interface{}{
-		"ports": ports,
+		"ports":        ports,
 		"visibleCount": visibleCount,
-		"hiddenCount": hiddenCount,
+		"hiddenCount":  hiddenCount,
 		"pagination": map[string]interface{}{
 			"page":     page,
 			"pageSize": pageSize,
@@ -366,6 +534,28 @@ func (s *Server) apiListPorts(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, response)
 }
 
+// portListQuery 解析端口列表的过滤与排序参数（q、status、labels、hidden、sort、order），
+// apiListPorts 与 CSV 导出共用，分页参数由调用方处理。
+func portListQuery(r *http.Request) *store.PortQuery {
+	params := r.URL.Query()
+	query := &store.PortQuery{
+		Search:   params.Get("q"),
+		Status:   params.Get("status"),
+		Labels:   params.Get("labels"),
+		SortBy:   params.Get("sort"),
+		SortDesc: strings.EqualFold(params.Get("order"), "desc"),
+	}
+	// 使用标签查询时由表达式自行约束状态，不再默认只看开放端口。
+	if strings.TrimSpace(query.Status) == "" && strings.TrimSpace(query.Labels) == "" {
+		query.Status = models.PortStatusOpen
+	}
+	if params.Get("hidden") != "1" {
+		hidden := false
+		query.Hidden = &hidden
+	}
+	return query
+}
+
 func (s *Server) apiCreatePort(w http.ResponseWriter, r *http.Request) {
 	hostID, err := parseIDParam(chi.URLParam(r, "hostID"))
 	if err != nil {
@@ -385,14 +575,29 @@ func (s *Server) apiCreatePort(w http.ResponseWriter, r *http.Request) {
 		writeMessage(w, "invalid port number", http.StatusBadRequest)
 		return
 	}
+	// 被排除的端口不会被扫描，建档后只会一直停留在 unknown。
+	policy, err := s.scanner.Policy(r.Context(), hostID)
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	if !policy.PortAllowed(body.Number) {
+		writePolicyErr(w, &targets.BlockedError{
+			Target: fmt.Sprintf("port %d", body.Number),
+			Reason: "matches excluded ports " + policy.ExcludedPorts(),
+		})
+		return
+	}
 	serviceName := fingerprint.NameForPort(body.Number)
 	if strings.TrimSpace(body.Note) == "" {
 		body.Note = serviceName
 	}
-	if strings.TrimSpace(body.Fingerprint) == "" {
+	// 用户显式填写的指纹视为人工标注，后续扫描不会覆盖。
+	userProvided := strings.TrimSpace(body.Fingerprint) != ""
+	if !userProvided {
 		body.Fingerprint = serviceName
 	}
-	portID, err := s.store.CreatePort(r.Context(), hostID, body.Number, body.Note, body.Fingerprint)
+	portID, err := s.store.CreatePort(r.Context(), hostID, body.Number, body.Note, strings.TrimSpace(body.Fingerprint), userProvided)
 	if err != nil {
 		writeErr(w, err, http.StatusBadRequest)
 		return
@@ -425,6 +630,7 @@ func (s *Server) apiUpdatePort(w http.ResponseWriter, r *http.Request) {
 	var body struct {
 		Note        string `json:"note"`
 		Fingerprint string `json:"fingerprint"`
+		Locked      *bool  `json:"locked"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		writeErr(w, err, http.StatusBadRequest)
@@ -439,24 +645,62 @@ func (s *Server) apiUpdatePort(w http.ResponseWriter, r *http.Request) {
 		writeErr(w, err, http.StatusNotFound)
 		return
 	}
-	if strings.TrimSpace(body.Fingerprint) == "" {
-		body.Fingerprint = fingerprint.NameForPort(existing.Number)
+	// 填写指纹即默认锁定；清空指纹则交还给扫描结果。
+	userFingerprint := strings.TrimSpace(body.Fingerprint)
+	locked := userFingerprint != ""
+	if body.Locked != nil {
+		locked = *body.Locked && userFingerprint != ""
 	}
-	if err := s.store.UpdatePortNote(r.Context(), portID, body.Note, body.Fingerprint); err != nil {
+	if err := s.store.UpdatePortNote(r.Context(), portID, body.Note, userFingerprint, locked, fingerprint.NameForPort(existing.Number)); err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	port, err := s.store.GetPort(r.Context(), portID)
+	if err != nil {
 		writeErr(w, err, http.StatusInternalServerError)
 		return
 	}
 	writeJSON(w, map[string]string{"status": "updated"})
 	s.broker.Publish(realtime.Event{
 		Type:   "port_updated",
+		HostID: port.HostID,
 		PortID: portID,
+		Labels: port.Labels,
 		Payload: map[string]interface{}{
-			"note":        body.Note,
-			"fingerprint": body.Fingerprint,
+			"note":              port.Note,
+			"fingerprint":       port.Fingerprint,
+			"userFingerprint":   port.UserFingerprint,
+			"detectedService":   port.DetectedService,
+			"fingerprintLocked": port.FingerprintLocked,
 		},
 	})
 }
 
+func (s *Server) apiPortServiceHistory(w http.ResponseWriter, r *http.Request) {
+	portID, err := parseIDParam(chi.URLParam(r, "portID"))
+	if err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return
+	}
+	port, err := s.store.GetPort(r.Context(), portID)
+	if err != nil {
+		writeErr(w, err, http.StatusNotFound)
+		return
+	}
+	history, err := s.store.ListServiceHistory(r.Context(), portID, intParam(r.URL.Query().Get("limit"), 50))
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	if history == nil {
+		history = []models.ServiceChange{}
+	}
+	writeJSON(w, map[string]interface{}{
+		"port":    port,
+		"history": history,
+	})
+}
+
 func (s *Server) apiUnhidePort(w http.ResponseWriter, r *http.Request) {
 	portID, err := parseIDParam(chi.URLParam(r, "portID"))
 	if err != nil {
@@ -628,6 +872,9 @@ func (s *Server) streamEvents(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "stream unsupported", http.StatusInternalServerError)
 		return
 	}
+	// 立即发送响应头，客户端无需等到第一条事件才确认订阅已建立。
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
 
 	for {
 		select {
@@ -642,15 +889,122 @@ func (s *Server) streamEvents(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (s *Server) apiListExclusions(w http.ResponseWriter, r *http.Request) {
+	rules, err := s.store.ListAllExclusions(r.Context())
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	if rules == nil {
+		rules = []models.Exclusion{}
+	}
+	allow := s.config().ScanAllow
+	if allow == nil {
+		allow = []string{}
+	}
+	deny := s.config().ScanDeny
+	if deny == nil {
+		deny = []string{}
+	}
+	writeJSON(w, map[string]interface{}{
+		"allowList":   allow,
+		"allowListOn": len(allow) > 0,
+		"staticDeny":  deny,
+		"rules":       rules,
+	})
+}
+
+func (s *Server) apiListHostExclusions(w http.ResponseWriter, r *http.Request) {
+	hostID, err := parseIDParam(chi.URLParam(r, "hostID"))
+	if err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return
+	}
+	rules, err := s.store.ListExclusions(r.Context(), hostID)
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	if rules == nil {
+		rules = []models.Exclusion{}
+	}
+	writeJSON(w, rules)
+}
+
+func (s *Server) apiCreateExclusion(w http.ResponseWriter, r *http.Request) {
+	var body models.Exclusion
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return
+	}
+	if raw := chi.URLParam(r, "hostID"); raw != "" {
+		hostID, err := parseIDParam(raw)
+		if err != nil {
+			writeErr(w, err, http.StatusBadRequest)
+			return
+		}
+		body.HostID = hostID
+	}
+	if body.HostID > 0 {
+		if _, err := s.store.GetHost(r.Context(), body.HostID); err != nil {
+			writeErr(w, err, http.StatusNotFound)
+			return
+		}
+	}
+	body.Kind = strings.ToLower(strings.TrimSpace(body.Kind))
+	value, err := targets.ValidateRule(body.Kind, body.Value)
+	if err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return
+	}
+	body.Value = value
+	body.Note = strings.TrimSpace(body.Note)
+	id, err := s.store.CreateExclusion(r.Context(), body)
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	body.ID = id
+	body.CreatedAt = time.Now().UTC()
+	writeJSON(w, body)
+	s.broker.Publish(realtime.Event{
+		Type:   "exclusion_created",
+		HostID: body.HostID,
+		Payload: map[string]interface{}{
+			"id":    id,
+			"kind":  body.Kind,
+			"value": body.Value,
+		},
+	})
+}
+
+func (s *Server) apiDeleteExclusion(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDParam(chi.URLParam(r, "exclusionID"))
+	if err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return
+	}
+	if err := s.store.DeleteExclusion(r.Context(), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeMessage(w, "exclusion not found", http.StatusNotFound)
+			return
+		}
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]string{"status": "deleted"})
+	s.broker.Publish(realtime.Event{
+		Type:    "exclusion_deleted",
+		Payload: map[string]interface{}{"id": id},
+	})
+}
+
 func defaultNote(port int) string {
 	return fingerprint.NameForPort(port)
 }
 
 func isUniqueHostNameError(err error) bool {
-	if err == nil {
-		return false
-	}
-	return strings.Contains(strings.ToLower(err.Error()), "unique constraint failed: hosts.name")
+	return store.IsDuplicateName(err, "hosts")
 }
 
 func parseIDParam(raw string) (int64, error) {
@@ -709,6 +1063,16 @@ func writeJSON(w http.ResponseWriter, payload interface{}) {
 	json.NewEncoder(w).Encode(payload)
 }
 
+// writePolicyErr 将扫描策略拒绝映射为 403，其余错误视为地址无效。
+func writePolicyErr(w http.ResponseWriter, err error) {
+	var blocked *targets.BlockedError
+	if errors.As(err, &blocked) {
+		writeMessage(w, "目标被扫描策略禁止："+blocked.Target+" "+blocked.Reason, http.StatusForbidden)
+		return
+	}
+	writeErr(w, err, http.StatusBadRequest)
+}
+
 func writeErr(w http.ResponseWriter, err error, status int) {
 	writeMessage(w, err.Error(), status)
 }
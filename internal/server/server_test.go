@@ -0,0 +1,72 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hitushen/portnotepro/internal/auth"
+	"github.com/hitushen/portnotepro/internal/config"
+	"github.com/hitushen/portnotepro/internal/models"
+	"github.com/hitushen/portnotepro/internal/realtime"
+	"github.com/hitushen/portnotepro/internal/scanner"
+	"github.com/hitushen/portnotepro/internal/store"
+	"github.com/hitushen/portnotepro/internal/targets"
+)
+
+func TestCreatePortRejectsExcludedPorts(t *testing.T) {
+	ctx := context.Background()
+	st, err := store.New(filepath.Join(t.TempDir(), "portnote.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { st.Close() })
+	userID, err := st.CreateUser(ctx, "admin", "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.CreateAPIToken(ctx, userID, "ci", "personal-token"); err != nil {
+		t.Fatal(err)
+	}
+	hostID, err := st.CreateHost(ctx, "web", "10.0.0.1", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range []models.Exclusion{
+		{Kind: targets.RulePorts, Value: "8000-8100"},
+		{HostID: hostID, Kind: targets.RulePorts, Value: "22"},
+	} {
+		if _, err := st.CreateExclusion(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	broker := realtime.NewBroker()
+	manager := scanner.NewManager(st, time.Second, 1, broker)
+	t.Cleanup(manager.Close)
+	key := bytes.Repeat([]byte("k"), 32)
+	s := &Server{store: st, auth: auth.NewManager(st, key), scanner: manager, broker: broker}
+	s.cfg.Store(&config.Config{SessionKey: key, CSRFKey: key})
+	h := s.Handler()
+
+	for _, number := range []int{8080, 22} {
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/hosts/%d/ports", hostID), strings.NewReader(fmt.Sprintf(`{"number":%d}`, number)))
+		req.Header.Set("Authorization", "Bearer personal-token")
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), fmt.Sprintf("port %d", number)) {
+			t.Errorf("create excluded port %d: status %d, want 403: %s", number, rec.Code, rec.Body.String())
+		}
+	}
+	ports, err := st.ListPorts(ctx, hostID, true)
+	if err != nil || len(ports) != 0 {
+		t.Errorf("ports after rejected requests = %+v, %v", ports, err)
+	}
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// apiTokenPrefix 便于在日志与配置中识别个人 API 令牌。
+const apiTokenPrefix = "pnt_"
+
+// apiListTokens 返回当前用户的 API 令牌（不含令牌本身）。
+func (s *Server) apiListTokens(w http.ResponseWriter, r *http.Request) {
+	userID, err := s.auth.RequireUser(w, r)
+	if err != nil {
+		writeErr(w, err, http.StatusUnauthorized)
+		return
+	}
+	list, err := s.store.ListAPITokens(r.Context(), userID)
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, list)
+}
+
+// apiCreateToken 为当前用户创建 API 令牌：{"name": "laptop"}，令牌只在响应中返回一次。
+func (s *Server) apiCreateToken(w http.ResponseWriter, r *http.Request) {
+	userID, err := s.auth.RequireUser(w, r)
+	if err != nil {
+		writeErr(w, err, http.StatusUnauthorized)
+		return
+	}
+	var body struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return
+	}
+	body.Name = strings.TrimSpace(body.Name)
+	if body.Name == "" || len(body.Name) > 100 {
+		writeMessage(w, "name must be 1-100 characters", http.StatusBadRequest)
+		return
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	token := apiTokenPrefix + hex.EncodeToString(b)
+	id, err := s.store.CreateAPIToken(r.Context(), userID, body.Name, token)
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]interface{}{"id": id, "name": body.Name, "token": token})
+}
+
+// apiDeleteToken 吊销当前用户的 API 令牌。
+func (s *Server) apiDeleteToken(w http.ResponseWriter, r *http.Request) {
+	userID, err := s.auth.RequireUser(w, r)
+	if err != nil {
+		writeErr(w, err, http.StatusUnauthorized)
+		return
+	}
+	id, err := parseIDParam(chi.URLParam(r, "tokenID"))
+	if err != nil {
+		writeErr(w, err, http.StatusBadRequest)
+		return
+	}
+	ok, err := s.store.DeleteAPIToken(r.Context(), userID, id)
+	if err != nil {
+		writeErr(w, err, http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		writeMessage(w, "token not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, map[string]string{"status": "ok"})
+}
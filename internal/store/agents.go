@@ -0,0 +1,117 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"time"
+
+	"github.com/hitushen/portnotepro/internal/models"
+)
+
+const agentColumns = `a.id, a.name, a.zone, a.disabled, a.version, a.hostname, a.remote_addr, a.last_seen_at, a.created_at, a.updated_at,
+	(SELECT COUNT(1) FROM scan_jobs j WHERE j.agent_id = a.id AND j.status = 'running')`
+
+func scanAgent(row rowScanner) (*models.Agent, error) {
+	var a models.Agent
+	var disabled int
+	var lastSeen sql.NullTime
+	if err := row.Scan(&a.ID, &a.Name, &a.Zone, &disabled, &a.Version, &a.Hostname, &a.RemoteAddr, &lastSeen,
+		&a.CreatedAt, &a.UpdatedAt, &a.RunningJobs); err != nil {
+		return nil, err
+	}
+	a.Disabled = disabled == 1
+	if lastSeen.Valid {
+		t := lastSeen.Time
+		a.LastSeenAt = &t
+	}
+	return &a, nil
+}
+
+// HashToken 返回保存在数据库中的令牌摘要，agent 令牌与 API 令牌共用。
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ListAgents 按名称返回全部 agent。
+func (s *Store) ListAgents(ctx context.Context) ([]models.Agent, error) {
+	rows, err := s.DB.QueryContext(ctx, `SELECT `+agentColumns+` FROM agents a ORDER BY a.name ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []models.Agent{}
+	for rows.Next() {
+		a, err := scanAgent(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *a)
+	}
+	return list, rows.Err()
+}
+
+// GetAgent 根据 ID 获取 agent。
+func (s *Store) GetAgent(ctx context.Context, id int64) (*models.Agent, error) {
+	return scanAgent(s.DB.QueryRowContext(ctx, `SELECT `+agentColumns+` FROM agents a WHERE a.id = ?`, id))
+}
+
+// AgentByToken 根据令牌查找 agent，令牌无效时返回 sql.ErrNoRows。
+func (s *Store) AgentByToken(ctx context.Context, token string) (*models.Agent, error) {
+	return scanAgent(s.DB.QueryRowContext(ctx, `SELECT `+agentColumns+` FROM agents a WHERE a.token_hash = ?`, HashToken(token)))
+}
+
+// CreateAgent 新增 agent，只保存令牌摘要。
+func (s *Store) CreateAgent(ctx context.Context, name, zone, token string) (int64, error) {
+	var id int64
+	err := s.DB.QueryRowContext(ctx,
+		`INSERT INTO agents (name, zone, token_hash) VALUES (?, ?, ?) RETURNING id`,
+		name, zone, HashToken(token),
+	).Scan(&id)
+	return id, err
+}
+
+// UpdateAgent 更新 agent 的名称、区域与停用状态。
+func (s *Store) UpdateAgent(ctx context.Context, a *models.Agent) error {
+	_, err := s.DB.ExecContext(ctx,
+		`UPDATE agents SET name = ?, zone = ?, disabled = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		a.Name, a.Zone, boolToInt(a.Disabled), a.ID,
+	)
+	return err
+}
+
+// RotateAgentToken 替换 agent 的令牌，旧令牌立即失效。
+func (s *Store) RotateAgentToken(ctx context.Context, id int64, token string) error {
+	_, err := s.DB.ExecContext(ctx,
+		`UPDATE agents SET token_hash = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, HashToken(token), id)
+	return err
+}
+
+// DeleteAgent 删除 agent，指定到它的主机改回按区域分配。
+func (s *Store) DeleteAgent(ctx context.Context, id int64) error {
+	_, err := s.DB.ExecContext(ctx, `DELETE FROM agents WHERE id = ?`, id)
+	return err
+}
+
+// TouchAgent 记录 agent 最近一次请求的时间与来源。version 与 hostname 为空时保留原值。
+func (s *Store) TouchAgent(ctx context.Context, id int64, version, hostname, remoteAddr string, at time.Time) error {
+	_, err := s.DB.ExecContext(ctx,
+		`UPDATE agents SET last_seen_at = ?, remote_addr = ?,
+		version = CASE WHEN ? = '' THEN version ELSE ? END,
+		hostname = CASE WHEN ? = '' THEN hostname ELSE ? END
+		WHERE id = ?`,
+		at.UTC(), remoteAddr, version, version, hostname, hostname, id,
+	)
+	return err
+}
+
+// SetHostAssignment 设置主机的扫描区域与指定 agent（agentID 为 0 表示不指定）。
+func (s *Store) SetHostAssignment(ctx context.Context, hostID int64, zone string, agentID int64) error {
+	_, err := s.DB.ExecContext(ctx,
+		`UPDATE hosts SET zone = ?, agent_id = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		zone, nullableID(agentID), hostID,
+	)
+	return err
+}
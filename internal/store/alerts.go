@@ -0,0 +1,325 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/hitushen/portnotepro/internal/models"
+)
+
+const alertTargetColumns = `id, name, kind, url, secret, min_severity, group_id, enabled, created_at, updated_at`
+
+func scanAlertTarget(row rowScanner) (*models.AlertTarget, error) {
+	var t models.AlertTarget
+	var groupID sql.NullInt64
+	var enabled int
+	if err := row.Scan(&t.ID, &t.Name, &t.Kind, &t.URL, &t.Secret, &t.MinSeverity, &groupID, &enabled, &t.CreatedAt, &t.UpdatedAt); err != nil {
+		return nil, err
+	}
+	t.GroupID = groupID.Int64
+	t.Enabled = enabled == 1
+	t.HasSecret = t.Secret != ""
+	return &t, nil
+}
+
+// ListAlertTargets 按名称返回全部通知目标。
+func (s *Store) ListAlertTargets(ctx context.Context) ([]models.AlertTarget, error) {
+	rows, err := s.DB.QueryContext(ctx, `SELECT `+alertTargetColumns+` FROM alert_targets ORDER BY name ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []models.AlertTarget{}
+	for rows.Next() {
+		t, err := scanAlertTarget(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *t)
+	}
+	return list, rows.Err()
+}
+
+// GetAlertTarget 根据 ID 获取通知目标。
+func (s *Store) GetAlertTarget(ctx context.Context, id int64) (*models.AlertTarget, error) {
+	return scanAlertTarget(s.DB.QueryRowContext(ctx, `SELECT `+alertTargetColumns+` FROM alert_targets WHERE id = ?`, id))
+}
+
+// CreateAlertTarget 新增通知目标。
+func (s *Store) CreateAlertTarget(ctx context.Context, t *models.AlertTarget) (int64, error) {
+	var id int64
+	err := s.DB.QueryRowContext(ctx,
+		`INSERT INTO alert_targets (name, kind, url, secret, min_severity, group_id, enabled) VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+		t.Name, t.Kind, t.URL, t.Secret, t.MinSeverity, nullableID(t.GroupID), boolToInt(t.Enabled),
+	).Scan(&id)
+	return id, err
+}
+
+// UpdateAlertTarget 更新通知目标的全部字段。
+func (s *Store) UpdateAlertTarget(ctx context.Context, t *models.AlertTarget) error {
+	_, err := s.DB.ExecContext(ctx,
+		`UPDATE alert_targets SET name = ?, kind = ?, url = ?, secret = ?, min_severity = ?, group_id = ?, enabled = ?,
+		updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		t.Name, t.Kind, t.URL, t.Secret, t.MinSeverity, nullableID(t.GroupID), boolToInt(t.Enabled), t.ID,
+	)
+	return err
+}
+
+// DeleteAlertTarget 删除通知目标，引用它的规则与投递记录一并删除。
+func (s *Store) DeleteAlertTarget(ctx context.Context, id int64) error {
+	_, err := s.DB.ExecContext(ctx, `DELETE FROM alert_targets WHERE id = ?`, id)
+	return err
+}
+
+const alertRuleColumns = `r.id, r.name, r.events, r.ports, r.host_id, r.group_id, r.cooldown_seconds, r.severity, r.target_id, r.enabled, r.created_at, r.updated_at`
+
+func scanAlertRule(row rowScanner) (*models.AlertRule, error) {
+	var r models.AlertRule
+	var events string
+	var hostID, groupID sql.NullInt64
+	var enabled int
+	if err := row.Scan(&r.ID, &r.Name, &events, &r.Ports, &hostID, &groupID, &r.Cooldown, &r.Severity, &r.TargetID, &enabled, &r.CreatedAt, &r.UpdatedAt); err != nil {
+		return nil, err
+	}
+	r.Events = []string{}
+	for _, e := range strings.Split(events, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			r.Events = append(r.Events, e)
+		}
+	}
+	r.HostID, r.GroupID = hostID.Int64, groupID.Int64
+	r.Enabled = enabled == 1
+	return &r, nil
+}
+
+func (s *Store) queryAlertRules(ctx context.Context, query string, args ...interface{}) ([]models.AlertRule, error) {
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []models.AlertRule{}
+	for rows.Next() {
+		r, err := scanAlertRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *r)
+	}
+	return list, rows.Err()
+}
+
+// ListAlertRules 按名称返回全部告警规则。
+func (s *Store) ListAlertRules(ctx context.Context) ([]models.AlertRule, error) {
+	return s.queryAlertRules(ctx, `SELECT `+alertRuleColumns+` FROM alert_rules r ORDER BY r.name ASC`)
+}
+
+// ActiveAlertRules 返回启用且通知目标也启用的规则。
+func (s *Store) ActiveAlertRules(ctx context.Context) ([]models.AlertRule, error) {
+	return s.queryAlertRules(ctx, `SELECT `+alertRuleColumns+` FROM alert_rules r
+		JOIN alert_targets t ON t.id = r.target_id
+		WHERE r.enabled = 1 AND t.enabled = 1 ORDER BY r.id ASC`)
+}
+
+// GetAlertRule 根据 ID 获取告警规则。
+func (s *Store) GetAlertRule(ctx context.Context, id int64) (*models.AlertRule, error) {
+	return scanAlertRule(s.DB.QueryRowContext(ctx, `SELECT `+alertRuleColumns+` FROM alert_rules r WHERE r.id = ?`, id))
+}
+
+// CreateAlertRule 新增告警规则。
+func (s *Store) CreateAlertRule(ctx context.Context, r *models.AlertRule) (int64, error) {
+	var id int64
+	err := s.DB.QueryRowContext(ctx,
+		`INSERT INTO alert_rules (name, events, ports, host_id, group_id, cooldown_seconds, severity, target_id, enabled)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+		r.Name, strings.Join(r.Events, ","), r.Ports, nullableID(r.HostID), nullableID(r.GroupID), r.Cooldown, r.Severity, r.TargetID, boolToInt(r.Enabled),
+	).Scan(&id)
+	return id, err
+}
+
+// UpdateAlertRule 更新告警规则的全部字段。
+func (s *Store) UpdateAlertRule(ctx context.Context, r *models.AlertRule) error {
+	_, err := s.DB.ExecContext(ctx,
+		`UPDATE alert_rules SET name = ?, events = ?, ports = ?, host_id = ?, group_id = ?, cooldown_seconds = ?, severity = ?, target_id = ?,
+		enabled = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		r.Name, strings.Join(r.Events, ","), r.Ports, nullableID(r.HostID), nullableID(r.GroupID), r.Cooldown, r.Severity, r.TargetID, boolToInt(r.Enabled), r.ID,
+	)
+	return err
+}
+
+// DeleteAlertRule 删除告警规则，已有投递记录保留。
+func (s *Store) DeleteAlertRule(ctx context.Context, id int64) error {
+	_, err := s.DB.ExecContext(ctx, `DELETE FROM alert_rules WHERE id = ?`, id)
+	return err
+}
+
+const alertDeliveryColumns = `id, rule_id, target_id, event, dedup_key, payload, status, attempts, response_code, last_error, next_attempt_at, created_at, delivered_at`
+
+func scanAlertDelivery(row rowScanner) (*models.AlertDelivery, error) {
+	var d models.AlertDelivery
+	var ruleID sql.NullInt64
+	var payload string
+	var deliveredAt sql.NullTime
+	if err := row.Scan(&d.ID, &ruleID, &d.TargetID, &d.Event, &d.DedupKey, &payload, &d.Status, &d.Attempts,
+		&d.ResponseCode, &d.LastError, &d.NextAttemptAt, &d.CreatedAt, &deliveredAt); err != nil {
+		return nil, err
+	}
+	d.RuleID = ruleID.Int64
+	d.Payload = []byte(payload)
+	if deliveredAt.Valid {
+		t := deliveredAt.Time
+		d.DeliveredAt = &t
+	}
+	return &d, nil
+}
+
+// DeliveryFilter 为投递记录的查询条件，零值表示不过滤；Limit 默认 100。
+type DeliveryFilter struct {
+	Status   string
+	RuleID   int64
+	TargetID int64
+	Limit    int
+}
+
+// ListAlertDeliveries 按时间倒序返回投递记录。
+func (s *Store) ListAlertDeliveries(ctx context.Context, f DeliveryFilter) ([]models.AlertDelivery, error) {
+	var conds []string
+	var args []interface{}
+	if f.Status != "" {
+		conds = append(conds, `status = ?`)
+		args = append(args, f.Status)
+	}
+	if f.RuleID > 0 {
+		conds = append(conds, `rule_id = ?`)
+		args = append(args, f.RuleID)
+	}
+	if f.TargetID > 0 {
+		conds = append(conds, `target_id = ?`)
+		args = append(args, f.TargetID)
+	}
+	query := `SELECT ` + alertDeliveryColumns + ` FROM alert_deliveries`
+	if len(conds) > 0 {
+		query += ` WHERE ` + strings.Join(conds, " AND ")
+	}
+	if f.Limit <= 0 {
+		f.Limit = 100
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, f.Limit)
+	return s.queryAlertDeliveries(ctx, query, args...)
+}
+
+func (s *Store) queryAlertDeliveries(ctx context.Context, query string, args ...interface{}) ([]models.AlertDelivery, error) {
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []models.AlertDelivery{}
+	for rows.Next() {
+		d, err := scanAlertDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *d)
+	}
+	return list, rows.Err()
+}
+
+// GetAlertDelivery 根据 ID 获取投递记录。
+func (s *Store) GetAlertDelivery(ctx context.Context, id int64) (*models.AlertDelivery, error) {
+	return scanAlertDelivery(s.DB.QueryRowContext(ctx, `SELECT `+alertDeliveryColumns+` FROM alert_deliveries WHERE id = ?`, id))
+}
+
+// EnqueueAlertDelivery 写入一条待投递记录，立即可被投递。
+func (s *Store) EnqueueAlertDelivery(ctx context.Context, d *models.AlertDelivery) (int64, error) {
+	now := time.Now().UTC()
+	var id int64
+	err := s.DB.QueryRowContext(ctx,
+		`INSERT INTO alert_deliveries (rule_id, target_id, event, dedup_key, payload, status, next_attempt_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+		nullableID(d.RuleID), d.TargetID, d.Event, d.DedupKey, string(d.Payload), models.DeliveryPending, now, now,
+	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	d.ID, d.Status, d.NextAttemptAt, d.CreatedAt = id, models.DeliveryPending, now, now
+	return id, nil
+}
+
+// LastAlertDeliveryAt 返回规则对 dedupKey 最近一次产生投递的时间，没有记录时 ok 为 false。
+func (s *Store) LastAlertDeliveryAt(ctx context.Context, ruleID int64, dedupKey string) (time.Time, bool, error) {
+	var t time.Time
+	err := s.DB.QueryRowContext(ctx,
+		`SELECT created_at FROM alert_deliveries WHERE rule_id = ? AND dedup_key = ? ORDER BY created_at DESC LIMIT 1`,
+		ruleID, dedupKey,
+	).Scan(&t)
+	if err == sql.ErrNoRows {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return t, true, nil
+}
+
+// DueAlertDeliveries 返回到期待投递的记录。
+func (s *Store) DueAlertDeliveries(ctx context.Context, now time.Time, limit int) ([]models.AlertDelivery, error) {
+	return s.queryAlertDeliveries(ctx,
+		`SELECT `+alertDeliveryColumns+` FROM alert_deliveries WHERE status = ? AND next_attempt_at <= ? ORDER BY next_attempt_at ASC LIMIT ?`,
+		models.DeliveryPending, now.UTC(), limit)
+}
+
+// ClaimAlertDelivery 将待投递记录标记为发送中，返回是否抢占成功（多实例部署时避免重复发送）。
+func (s *Store) ClaimAlertDelivery(ctx context.Context, id int64) (bool, error) {
+	res, err := s.DB.ExecContext(ctx, `UPDATE alert_deliveries SET status = ? WHERE id = ? AND status = ?`,
+		models.DeliverySending, id, models.DeliveryPending)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n == 1, nil
+}
+
+// FinishAlertDelivery 记录一次投递尝试的结果。
+func (s *Store) FinishAlertDelivery(ctx context.Context, d *models.AlertDelivery) error {
+	var deliveredAt interface{}
+	if d.DeliveredAt != nil {
+		deliveredAt = d.DeliveredAt.UTC()
+	}
+	_, err := s.DB.ExecContext(ctx,
+		`UPDATE alert_deliveries SET status = ?, attempts = ?, response_code = ?, last_error = ?, next_attempt_at = ?, delivered_at = ? WHERE id = ?`,
+		d.Status, d.Attempts, d.ResponseCode, d.LastError, d.NextAttemptAt.UTC(), deliveredAt, d.ID,
+	)
+	return err
+}
+
+// RetryAlertDelivery 将失败的投递重新置为待投递并立即到期，返回是否存在这样的记录。
+func (s *Store) RetryAlertDelivery(ctx context.Context, id int64) (bool, error) {
+	res, err := s.DB.ExecContext(ctx,
+		`UPDATE alert_deliveries SET status = ?, next_attempt_at = ?, last_error = '' WHERE id = ? AND status = ?`,
+		models.DeliveryPending, time.Now().UTC(), id, models.DeliveryFailed)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n == 1, nil
+}
+
+// ResetSendingAlertDeliveries 将进程退出时停留在发送中的记录恢复为待投递。
+func (s *Store) ResetSendingAlertDeliveries(ctx context.Context) error {
+	_, err := s.DB.ExecContext(ctx, `UPDATE alert_deliveries SET status = ? WHERE status = ?`, models.DeliveryPending, models.DeliverySending)
+	return err
+}
+
+// PruneAlertDeliveries 删除 before 之前创建且已结束（成功或失败）的投递记录。
+func (s *Store) PruneAlertDeliveries(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM alert_deliveries WHERE created_at < ? AND status IN (?, ?)`,
+		before.UTC(), models.DeliveryDelivered, models.DeliveryFailed)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
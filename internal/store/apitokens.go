@@ -0,0 +1,77 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/hitushen/portnotepro/internal/models"
+)
+
+// apiTokenTouchInterval 内重复使用的令牌不再更新 last_used_at，避免每个请求都写库。
+const apiTokenTouchInterval = time.Minute
+
+// ListAPITokens 返回用户的全部 API 令牌，最新创建的在前。
+func (s *Store) ListAPITokens(ctx context.Context, userID int64) ([]models.APIToken, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT id, name, last_used_at, created_at FROM api_tokens WHERE user_id = ? ORDER BY id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []models.APIToken{}
+	for rows.Next() {
+		var t models.APIToken
+		var lastUsed sql.NullTime
+		if err := rows.Scan(&t.ID, &t.Name, &lastUsed, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		if lastUsed.Valid {
+			at := lastUsed.Time
+			t.LastUsedAt = &at
+		}
+		list = append(list, t)
+	}
+	return list, rows.Err()
+}
+
+// CreateAPIToken 为用户新增 API 令牌，只保存令牌摘要。
+func (s *Store) CreateAPIToken(ctx context.Context, userID int64, name, token string) (int64, error) {
+	var id int64
+	err := s.DB.QueryRowContext(ctx,
+		`INSERT INTO api_tokens (user_id, name, token_hash) VALUES (?, ?, ?) RETURNING id`,
+		userID, name, HashToken(token),
+	).Scan(&id)
+	return id, err
+}
+
+// DeleteAPIToken 吊销用户的 API 令牌，令牌不存在或不属于该用户时返回 false。
+func (s *Store) DeleteAPIToken(ctx context.Context, userID, id int64) (bool, error) {
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM api_tokens WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// UserByAPIToken 根据 API 令牌查找用户并记录使用时间，令牌无效或用户已停用时返回 sql.ErrNoRows。
+func (s *Store) UserByAPIToken(ctx context.Context, token string) (*models.User, error) {
+	var user models.User
+	var tokenID int64
+	err := s.DB.QueryRowContext(ctx,
+		`SELECT t.id, u.id, u.username, u.created_at FROM api_tokens t JOIN users u ON u.id = t.user_id WHERE t.token_hash = ? AND u.disabled = 0`,
+		HashToken(token),
+	).Scan(&tokenID, &user.ID, &user.Username, &user.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if _, err := s.DB.ExecContext(ctx,
+		`UPDATE api_tokens SET last_used_at = ? WHERE id = ? AND (last_used_at IS NULL OR last_used_at < ?)`,
+		now, tokenID, now.Add(-apiTokenTouchInterval),
+	); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
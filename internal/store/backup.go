@@ -0,0 +1,89 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrBackupUnsupported 表示当前后端不支持内置备份（PostgreSQL 请使用 pg_dump）。
+var ErrBackupUnsupported = errors.New("online backup is only supported for SQLite, use pg_dump for PostgreSQL")
+
+// ErrInvalidBackup 表示备份文件不是可用的 PortNote 数据库。
+var ErrInvalidBackup = errors.New("invalid backup file")
+
+// Backup 使用 VACUUM INTO 将数据库一致地快照到 dest，服务运行期间也可执行。dest 必须不存在。
+func (s *Store) Backup(ctx context.Context, dest string) error {
+	if s.dialect != DialectSQLite {
+		return ErrBackupUnsupported
+	}
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("backup target %s already exists", dest)
+	}
+	if _, err := s.DB.ExecContext(ctx, `VACUUM INTO ?`, dest); err != nil {
+		return fmt.Errorf("vacuum into: %w", err)
+	}
+	return nil
+}
+
+// InspectBackup 以只读方式打开 SQLite 备份文件，校验完整性并返回其结构版本。
+// 早于迁移机制的数据库没有 schema_migrations 表，此时版本为 0。
+func InspectBackup(ctx context.Context, path string) (int, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	if info.IsDir() {
+		return 0, fmt.Errorf("%w: %s is a directory", ErrInvalidBackup, path)
+	}
+
+	db, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	var result string
+	if err := db.QueryRowContext(ctx, `PRAGMA quick_check`).Scan(&result); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	if !strings.EqualFold(result, "ok") {
+		return 0, fmt.Errorf("%w: integrity check failed: %s", ErrInvalidBackup, result)
+	}
+
+	tables := make(map[string]bool)
+	rows, err := db.QueryContext(ctx, `SELECT name FROM sqlite_master WHERE type = 'table'`)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		tables[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if !tables["hosts"] || !tables["ports"] {
+		return 0, fmt.Errorf("%w: missing hosts/ports tables", ErrInvalidBackup)
+	}
+	if !tables["schema_migrations"] {
+		return 0, nil
+	}
+
+	var version int
+	if err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	if latest := LatestSchemaVersion(DialectSQLite); version > latest {
+		return version, fmt.Errorf("%w: backup at version %d, binary supports up to %d", ErrSchemaTooNew, version, latest)
+	}
+	return version, nil
+}
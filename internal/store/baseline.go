@@ -0,0 +1,367 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hitushen/portnotepro/internal/models"
+	"github.com/hitushen/portnotepro/internal/targets"
+)
+
+// ErrInvalidBaseline 表示基线端口列表不合法。
+var ErrInvalidBaseline = errors.New("invalid baseline")
+
+// 基线来源。
+const (
+	BaselineSourceHost  = "host"
+	BaselineSourceGroup = "group"
+)
+
+// ParsePorts 解析形如 "22,443,8000-8010" 的端口列表，返回去重排序后的端口号；空列表表示不应开放任何端口。
+func ParsePorts(spec string) ([]int, error) {
+	if strings.TrimSpace(spec) == "" {
+		return []int{}, nil
+	}
+	ranges, err := targets.ParsePortRanges(spec)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBaseline, err)
+	}
+	set := make(map[int]struct{})
+	for _, r := range ranges {
+		for n := r.Start; n <= r.End; n++ {
+			set[n] = struct{}{}
+		}
+	}
+	return sortedPorts(set), nil
+}
+
+// FormatPorts 将端口号合并为逗号分隔的列表，连续端口写作范围。ports 须已排序去重。
+func FormatPorts(ports []int) string {
+	var parts []string
+	for i := 0; i < len(ports); {
+		j := i
+		for j+1 < len(ports) && ports[j+1] == ports[j]+1 {
+			j++
+		}
+		if j == i {
+			parts = append(parts, strconv.Itoa(ports[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", ports[i], ports[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+func sortedPorts(set map[int]struct{}) []int {
+	ports := make([]int, 0, len(set))
+	for n := range set {
+		ports = append(ports, n)
+	}
+	sort.Ints(ports)
+	return ports
+}
+
+// baselineOwner 返回基线所属的列名与 ID，hostID 优先。
+func baselineOwner(hostID, groupID int64) (string, int64) {
+	if hostID > 0 {
+		return "host_id", hostID
+	}
+	return "group_id", groupID
+}
+
+const baselineColumns = `id, host_id, group_id, ports, created_at, updated_at`
+
+func scanBaseline(row rowScanner) (*models.PortBaseline, error) {
+	var b models.PortBaseline
+	var hostID, groupID sql.NullInt64
+	if err := row.Scan(&b.ID, &hostID, &groupID, &b.Ports, &b.CreatedAt, &b.UpdatedAt); err != nil {
+		return nil, err
+	}
+	b.HostID, b.GroupID = hostID.Int64, groupID.Int64
+	return &b, nil
+}
+
+// GetBaseline 返回主机（hostID > 0）或分组的基线，未设置时返回 sql.ErrNoRows。
+func (s *Store) GetBaseline(ctx context.Context, hostID, groupID int64) (*models.PortBaseline, error) {
+	column, id := baselineOwner(hostID, groupID)
+	return scanBaseline(s.DB.QueryRowContext(ctx, `SELECT `+baselineColumns+` FROM port_baselines WHERE `+column+` = ?`, id))
+}
+
+// ListBaselines 返回全部基线，主机基线在前。
+func (s *Store) ListBaselines(ctx context.Context) ([]models.PortBaseline, error) {
+	rows, err := s.DB.QueryContext(ctx, `SELECT `+baselineColumns+` FROM port_baselines ORDER BY host_id IS NULL, host_id, group_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []models.PortBaseline{}
+	for rows.Next() {
+		b, err := scanBaseline(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *b)
+	}
+	return list, rows.Err()
+}
+
+// SetBaseline 设置主机（hostID > 0）或分组的基线，spec 会被规范化后保存。
+func (s *Store) SetBaseline(ctx context.Context, hostID, groupID int64, spec string) (*models.PortBaseline, error) {
+	ports, err := ParsePorts(spec)
+	if err != nil {
+		return nil, err
+	}
+	normalized := FormatPorts(ports)
+	column, id := baselineOwner(hostID, groupID)
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx, `UPDATE port_baselines SET ports = ?, updated_at = CURRENT_TIMESTAMP WHERE `+column+` = ?`, normalized, id)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO port_baselines (`+column+`, ports) VALUES (?, ?)`, id, normalized); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.GetBaseline(ctx, hostID, groupID)
+}
+
+// DeleteBaseline 删除主机（hostID > 0）或分组的基线。
+func (s *Store) DeleteBaseline(ctx context.Context, hostID, groupID int64) error {
+	column, id := baselineOwner(hostID, groupID)
+	_, err := s.DB.ExecContext(ctx, `DELETE FROM port_baselines WHERE `+column+` = ?`, id)
+	return err
+}
+
+// effectiveBaseline 返回主机适用的预期端口：主机自身的基线优先；
+// 否则对主机所在的每个分组向上查找最近的分组基线，取并集。ok 为 false 表示没有适用的基线。
+func (s *Store) effectiveBaseline(ctx context.Context, hostID int64) (expected []int, source string, ok bool, err error) {
+	own, err := s.GetBaseline(ctx, hostID, 0)
+	switch {
+	case err == nil:
+		ports, err := ParsePorts(own.Ports)
+		if err != nil {
+			return nil, "", false, err
+		}
+		return ports, BaselineSourceHost, true, nil
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, "", false, err
+	}
+
+	groupIDs, err := s.queryIDs(ctx, `SELECT group_id FROM host_group_members WHERE host_id = ?`, hostID)
+	if err != nil || len(groupIDs) == 0 {
+		return nil, "", false, err
+	}
+	parents := make(map[int64]int64)
+	specs := make(map[int64]string)
+	rows, err := s.DB.QueryContext(ctx, `SELECT g.id, g.parent_id, b.ports FROM host_groups g LEFT JOIN port_baselines b ON b.group_id = g.id`)
+	if err != nil {
+		return nil, "", false, err
+	}
+	for rows.Next() {
+		var id int64
+		var parentID sql.NullInt64
+		var spec sql.NullString
+		if err := rows.Scan(&id, &parentID, &spec); err != nil {
+			rows.Close()
+			return nil, "", false, err
+		}
+		parents[id] = parentID.Int64
+		if spec.Valid {
+			specs[id] = spec.String
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, "", false, err
+	}
+
+	set := make(map[int]struct{})
+	for _, groupID := range groupIDs {
+		visited := make(map[int64]bool)
+		for id := groupID; id > 0 && !visited[id]; id = parents[id] {
+			visited[id] = true
+			spec, found := specs[id]
+			if !found {
+				continue
+			}
+			ports, err := ParsePorts(spec)
+			if err != nil {
+				return nil, "", false, err
+			}
+			for _, n := range ports {
+				set[n] = struct{}{}
+			}
+			ok = true
+			break
+		}
+	}
+	if !ok {
+		return nil, "", false, nil
+	}
+	return sortedPorts(set), BaselineSourceGroup, true, nil
+}
+
+// EvaluateCompliance 将主机当前开放的端口（含隐藏端口）与适用基线比对并保存结果。
+// 返回本次结果（无适用基线时为 nil）、上次的状态，以及结果是否较上次发生变化。
+func (s *Store) EvaluateCompliance(ctx context.Context, hostID int64) (*models.HostCompliance, string, bool, error) {
+	var previous, prevExpected, prevUnexpected, prevMissing string
+	err := s.DB.QueryRowContext(ctx, `SELECT status, expected, unexpected, missing FROM host_compliance WHERE host_id = ?`, hostID).
+		Scan(&previous, &prevExpected, &prevUnexpected, &prevMissing)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, "", false, err
+	}
+
+	expected, source, ok, err := s.effectiveBaseline(ctx, hostID)
+	if err != nil {
+		return nil, "", false, err
+	}
+	if !ok {
+		if previous == "" {
+			return nil, "", false, nil
+		}
+		_, err := s.DB.ExecContext(ctx, `DELETE FROM host_compliance WHERE host_id = ?`, hostID)
+		return nil, previous, err == nil, err
+	}
+
+	rows, err := s.DB.QueryContext(ctx, `SELECT number FROM ports WHERE host_id = ? AND status = ?`, hostID, models.PortStatusOpen)
+	if err != nil {
+		return nil, "", false, err
+	}
+	open := make(map[int]struct{})
+	for rows.Next() {
+		var n int
+		if err := rows.Scan(&n); err != nil {
+			rows.Close()
+			return nil, "", false, err
+		}
+		open[n] = struct{}{}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, "", false, err
+	}
+
+	want := make(map[int]struct{}, len(expected))
+	missing := make(map[int]struct{})
+	for _, n := range expected {
+		want[n] = struct{}{}
+		if _, found := open[n]; !found {
+			missing[n] = struct{}{}
+		}
+	}
+	unexpected := make(map[int]struct{})
+	for n := range open {
+		if _, found := want[n]; !found {
+			unexpected[n] = struct{}{}
+		}
+	}
+
+	result := &models.HostCompliance{
+		HostID:     hostID,
+		Status:     models.ComplianceCompliant,
+		Source:     source,
+		Expected:   FormatPorts(expected),
+		Unexpected: sortedPorts(unexpected),
+		Missing:    sortedPorts(missing),
+		CheckedAt:  time.Now().UTC(),
+	}
+	switch {
+	case len(result.Unexpected) > 0:
+		result.Status = models.ComplianceUnexpectedOpen
+	case len(result.Missing) > 0:
+		result.Status = models.ComplianceExpectedMissing
+	}
+	unexpectedSpec, missingSpec := FormatPorts(result.Unexpected), FormatPorts(result.Missing)
+	changed := previous != result.Status || prevExpected != result.Expected ||
+		prevUnexpected != unexpectedSpec || prevMissing != missingSpec
+
+	if previous == "" {
+		_, err = s.DB.ExecContext(ctx,
+			`INSERT INTO host_compliance (host_id, status, source, expected, unexpected, missing, checked_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			hostID, result.Status, source, result.Expected, unexpectedSpec, missingSpec, result.CheckedAt)
+	} else {
+		_, err = s.DB.ExecContext(ctx,
+			`UPDATE host_compliance SET status = ?, source = ?, expected = ?, unexpected = ?, missing = ?, checked_at = ? WHERE host_id = ?`,
+			result.Status, source, result.Expected, unexpectedSpec, missingSpec, result.CheckedAt, hostID)
+	}
+	if err != nil {
+		return nil, "", false, err
+	}
+	return result, previous, changed, nil
+}
+
+// ComplianceReport 返回全部已评估主机的最近结果，status 非空时只返回该状态的主机。
+func (s *Store) ComplianceReport(ctx context.Context, status string) (*models.ComplianceReport, error) {
+	report := &models.ComplianceReport{
+		GeneratedAt: time.Now().UTC(),
+		Summary: map[string]int{
+			models.ComplianceCompliant:       0,
+			models.ComplianceUnexpectedOpen:  0,
+			models.ComplianceExpectedMissing: 0,
+		},
+		Hosts: []models.HostCompliance{},
+	}
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT h.id, h.name, h.address, c.status, c.source, c.expected, c.unexpected, c.missing, c.checked_at
+		FROM host_compliance c JOIN hosts h ON h.id = c.host_id
+		ORDER BY h.name ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c models.HostCompliance
+		var unexpected, missing string
+		if err := rows.Scan(&c.HostID, &c.Name, &c.Address, &c.Status, &c.Source, &c.Expected, &unexpected, &missing, &c.CheckedAt); err != nil {
+			return nil, err
+		}
+		report.Summary[c.Status]++
+		if status != "" && c.Status != status {
+			continue
+		}
+		if c.Unexpected, err = ParsePorts(unexpected); err != nil {
+			return nil, err
+		}
+		if c.Missing, err = ParsePorts(missing); err != nil {
+			return nil, err
+		}
+		c.Address = targets.Normalize(c.Address)
+		report.Hosts = append(report.Hosts, c)
+	}
+	return report, rows.Err()
+}
+
+func (s *Store) complianceStatuses(ctx context.Context) (map[int64]string, error) {
+	rows, err := s.DB.QueryContext(ctx, `SELECT host_id, status FROM host_compliance`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	statuses := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var status string
+		if err := rows.Scan(&id, &status); err != nil {
+			return nil, err
+		}
+		statuses[id] = status
+	}
+	return statuses, rows.Err()
+}
@@ -0,0 +1,283 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+
+	"github.com/hitushen/portnotepro/internal/models"
+)
+
+// SQLite 的全文索引 ports_fts / hosts_fts 由迁移 0002 创建，并通过触发器与源表保持同步；
+// PostgreSQL 则在源表上建立 to_tsvector 表达式索引，无需额外同步。
+
+// RebuildSearchIndex 依据源表重新生成全文索引，用于恢复数据或索引损坏后的修复。PostgreSQL 下无需重建。
+func (s *Store) RebuildSearchIndex(ctx context.Context) error {
+	if s.dialect == DialectPostgres {
+		return nil
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmts := []string{
+		`DELETE FROM ports_fts`,
+		`DELETE FROM hosts_fts`,
+		`INSERT INTO ports_fts(rowid, note, fingerprint, host_name, host_address)
+			SELECT p.id, p.note, p.fingerprint, h.name, h.address FROM ports p JOIN hosts h ON h.id = p.host_id`,
+		`INSERT INTO hosts_fts(rowid, name, address) SELECT id, name, address FROM hosts`,
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("rebuild fts: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// searchTerm 为解析后的检索项：单个词、双引号短语或 OR 运算符。
+type searchTerm struct {
+	tokens []string
+	phrase bool
+	prefix bool
+	or     bool
+}
+
+// parseSearch 解析用户输入：双引号内为短语，词尾 * 表示前缀匹配，其余标点一律视为分隔符，
+// 多个词之间默认为 AND 关系，大写 OR 表示或。prefixAll 为 true 时每个词都按前缀匹配。
+func parseSearch(input string, prefixAll bool) []searchTerm {
+	var parts []searchTerm
+	rest := input
+	for {
+		open := strings.IndexByte(rest, '"')
+		if open < 0 {
+			break
+		}
+		closing := strings.IndexByte(rest[open+1:], '"')
+		if closing < 0 {
+			break
+		}
+		parts = append(parts, ftsTerms(rest[:open], prefixAll)...)
+		if tokens := ftsTokens(rest[open+1 : open+1+closing]); len(tokens) > 0 {
+			parts = append(parts, searchTerm{tokens: tokens, phrase: true})
+		}
+		rest = rest[open+closing+2:]
+	}
+	parts = append(parts, ftsTerms(strings.ReplaceAll(rest, `"`, " "), prefixAll)...)
+
+	// 大写 OR 作为运算符保留，去掉首尾或连续出现的 OR 以保证语法有效。
+	cleaned := make([]searchTerm, 0, len(parts))
+	for _, part := range parts {
+		if part.or && (len(cleaned) == 0 || cleaned[len(cleaned)-1].or) {
+			continue
+		}
+		cleaned = append(cleaned, part)
+	}
+	if n := len(cleaned); n > 0 && cleaned[n-1].or {
+		cleaned = cleaned[:n-1]
+	}
+	return cleaned
+}
+
+// ftsQuery 将用户输入转换为安全的 FTS5 查询。
+func ftsQuery(input string, prefixAll bool) string {
+	terms := parseSearch(input, prefixAll)
+	out := make([]string, len(terms))
+	for i, t := range terms {
+		switch {
+		case t.or:
+			out[i] = "OR"
+		case t.prefix:
+			out[i] = `"` + strings.Join(t.tokens, " ") + `"*`
+		default:
+			out[i] = `"` + strings.Join(t.tokens, " ") + `"`
+		}
+	}
+	return strings.Join(out, " ")
+}
+
+// tsQuery 将用户输入转换为 PostgreSQL to_tsquery 语法：前缀为 :*，短语用 <->，AND 为 &，OR 为 |。
+func tsQuery(input string, prefixAll bool) string {
+	terms := parseSearch(input, prefixAll)
+	var sb strings.Builder
+	for i, t := range terms {
+		if t.or {
+			sb.WriteString(" | ")
+			continue
+		}
+		if i > 0 && !terms[i-1].or {
+			sb.WriteString(" & ")
+		}
+		term := strings.Join(t.tokens, " <-> ")
+		if t.prefix {
+			term += ":*"
+		}
+		if len(t.tokens) > 1 {
+			term = "(" + term + ")"
+		}
+		sb.WriteString(term)
+	}
+	return sb.String()
+}
+
+func ftsTerms(text string, prefixAll bool) []searchTerm {
+	var terms []searchTerm
+	for _, field := range strings.Fields(text) {
+		if field == "OR" {
+			terms = append(terms, searchTerm{or: true})
+			continue
+		}
+		prefix := prefixAll || strings.HasSuffix(field, "*")
+		tokens := ftsTokens(field)
+		for i, token := range tokens {
+			// 仅对最后一个子词应用前缀，例如 "nginx/1.2*" 变为 "nginx" "1" "2"*。
+			terms = append(terms, searchTerm{tokens: []string{token}, prefix: prefix && i == len(tokens)-1})
+		}
+	}
+	return terms
+}
+
+func ftsTokens(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// 高亮标记先用控制字符占位，转义 HTML 后再替换为 <mark>，避免用户内容注入标签。
+const (
+	markOpen  = "\x02"
+	markClose = "\x03"
+)
+
+// ts_headline 选项：headlineAll 高亮整个字段，headlineSnippet 截取匹配附近的片段。
+const (
+	headlineAll     = `StartSel="` + markOpen + `", StopSel="` + markClose + `", HighlightAll=true`
+	headlineSnippet = `StartSel="` + markOpen + `", StopSel="` + markClose + `", MaxWords=16, MinWords=4`
+)
+
+func renderHighlight(raw string) string {
+	escaped := html.EscapeString(raw)
+	return strings.NewReplacer(markOpen, "<mark>", markClose, "</mark>").Replace(escaped)
+}
+
+// Search 在端口与主机全文索引中检索，按相关度排序（SQLite 为 bm25，PostgreSQL 为 ts_rank），返回带 <mark> 高亮的片段。
+func (s *Store) Search(ctx context.Context, input string, limit int) (*models.SearchResults, error) {
+	results := &models.SearchResults{Hosts: []models.HostHit{}, Ports: []models.PortHit{}}
+	match := ftsQuery(input, false)
+	if s.dialect == DialectPostgres {
+		match = tsQuery(input, false)
+	}
+	if match == "" {
+		return results, nil
+	}
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	hostSQL, hostArgs := `
+		SELECT rowid,
+			highlight(hosts_fts, 0, ?, ?),
+			highlight(hosts_fts, 1, ?, ?),
+			bm25(hosts_fts)
+		FROM hosts_fts WHERE hosts_fts MATCH ?
+		ORDER BY bm25(hosts_fts) LIMIT ?`,
+		[]interface{}{markOpen, markClose, markOpen, markClose, match, limit}
+	if s.dialect == DialectPostgres {
+		// ts_rank 越大越相关，取负值以与 bm25 的排序方向一致。
+		hostSQL, hostArgs = `
+		SELECT h.id,
+			ts_headline('simple', h.name, q, ?),
+			ts_headline('simple', h.address, q, ?),
+			-ts_rank(to_tsvector('simple', h.name || ' ' || h.address), q)
+		FROM hosts h, to_tsquery('simple', ?) q
+		WHERE to_tsvector('simple', h.name || ' ' || h.address) @@ q
+		ORDER BY 4 LIMIT ?`,
+			[]interface{}{headlineAll, headlineAll, match, limit}
+	}
+	hostRows, err := s.DB.QueryContext(ctx, hostSQL, hostArgs...)
+	if err != nil {
+		return nil, err
+	}
+	for hostRows.Next() {
+		var hit models.HostHit
+		var name, address string
+		if err := hostRows.Scan(&hit.ID, &name, &address, &hit.Rank); err != nil {
+			hostRows.Close()
+			return nil, err
+		}
+		hit.Name = stripMarks(name)
+		hit.Address = stripMarks(address)
+		hit.Highlights = map[string]string{"name": renderHighlight(name), "address": renderHighlight(address)}
+		results.Hosts = append(results.Hosts, hit)
+	}
+	hostRows.Close()
+	if err := hostRows.Err(); err != nil {
+		return nil, err
+	}
+
+	portSQL, portArgs := `
+		SELECT ports_fts.rowid, p.host_id, p.number, p.status, p.hidden,
+			highlight(ports_fts, 0, ?, ?),
+			highlight(ports_fts, 1, ?, ?),
+			highlight(ports_fts, 2, ?, ?),
+			snippet(ports_fts, 0, ?, ?, '…', 16),
+			bm25(ports_fts)
+		FROM ports_fts JOIN ports p ON p.id = ports_fts.rowid
+		WHERE ports_fts MATCH ?
+		ORDER BY bm25(ports_fts) LIMIT ?`,
+		[]interface{}{markOpen, markClose, markOpen, markClose, markOpen, markClose, markOpen, markClose, match, limit}
+	if s.dialect == DialectPostgres {
+		// 与 ports_fts 一致，端口检索同时覆盖所属主机的名称与地址。候选端口分两路取得，
+		// 表达式分别与 idx_ports_fts、idx_hosts_fts 一致以便走索引；因此检索词需全部落在端口的备注 / 指纹中，
+		// 或全部落在主机的名称 / 地址中，不会像 SQLite 那样跨两侧字段组合匹配。
+		portSQL, portArgs = `
+		SELECT p.id, p.host_id, p.number, p.status, p.hidden,
+			ts_headline('simple', p.note, q, ?),
+			ts_headline('simple', p.fingerprint, q, ?),
+			ts_headline('simple', h.name, q, ?),
+			ts_headline('simple', p.note, q, ?),
+			-ts_rank(to_tsvector('simple', p.note || ' ' || p.fingerprint || ' ' || h.name || ' ' || h.address), q)
+		FROM ports p JOIN hosts h ON h.id = p.host_id, to_tsquery('simple', ?) q
+		WHERE p.id IN (
+			SELECT id FROM ports WHERE to_tsvector('simple', note || ' ' || fingerprint) @@ to_tsquery('simple', ?)
+			UNION
+			SELECT hp.id FROM hosts hh JOIN ports hp ON hp.host_id = hh.id
+			WHERE to_tsvector('simple', hh.name || ' ' || hh.address) @@ to_tsquery('simple', ?)
+		)
+		ORDER BY 10 LIMIT ?`,
+			[]interface{}{headlineAll, headlineAll, headlineAll, headlineSnippet, match, match, match, limit}
+	}
+	portRows, err := s.DB.QueryContext(ctx, portSQL, portArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer portRows.Close()
+	for portRows.Next() {
+		var hit models.PortHit
+		var hidden int
+		var note, fp, hostName, snippet string
+		if err := portRows.Scan(&hit.ID, &hit.HostID, &hit.Number, &hit.Status, &hidden, &note, &fp, &hostName, &snippet, &hit.Rank); err != nil {
+			return nil, err
+		}
+		hit.Hidden = hidden == 1
+		hit.Note = stripMarks(note)
+		hit.Fingerprint = stripMarks(fp)
+		hit.HostName = stripMarks(hostName)
+		hit.Highlights = map[string]string{
+			"note":        renderHighlight(note),
+			"fingerprint": renderHighlight(fp),
+			"hostName":    renderHighlight(hostName),
+		}
+		hit.Snippet = renderHighlight(snippet)
+		results.Ports = append(results.Ports, hit)
+	}
+	return results, portRows.Err()
+}
+
+func stripMarks(s string) string {
+	return strings.NewReplacer(markOpen, "", markClose, "").Replace(s)
+}
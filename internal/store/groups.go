@@ -0,0 +1,323 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/hitushen/portnotepro/internal/models"
+)
+
+// ErrGroupCycle 表示设置父分组会形成环。
+var ErrGroupCycle = errors.New("group parent would create a cycle")
+
+// HostFilter 用于列举主机时提供可选过滤条件。
+type HostFilter struct {
+	GroupID int64
+	// Direct 为 true 时仅返回直接成员，不包含子分组中的主机。
+	Direct bool
+	// Labels 为标签查询表达式，如 `owner=payments AND exposure=public`。
+	Labels string
+}
+
+const groupTreeCTE = `WITH RECURSIVE group_tree(id) AS (
+		SELECT CAST(? AS BIGINT)
+		UNION
+		SELECT g.id FROM host_groups g JOIN group_tree t ON g.parent_id = t.id
+	)`
+
+// ListGroups 按名称返回全部分组，并汇总每个分组（含子分组）的主机数与开放端口数。
+func (s *Store) ListGroups(ctx context.Context) ([]models.HostGroup, error) {
+	rows, err := s.DB.QueryContext(ctx, `SELECT id, name, description, parent_id, created_at, updated_at FROM host_groups ORDER BY name ASC`)
+	if err != nil {
+		return nil, err
+	}
+	var groups []models.HostGroup
+	for rows.Next() {
+		g, err := scanGroup(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		groups = append(groups, *g)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 {
+		return groups, nil
+	}
+
+	members, err := s.groupMembers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	openCounts, err := s.OpenPortCounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	children := make(map[int64][]int64)
+	for _, g := range groups {
+		children[g.ParentID] = append(children[g.ParentID], g.ID)
+	}
+	for i := range groups {
+		hostSet := make(map[int64]struct{})
+		collectGroupHosts(groups[i].ID, children, members, hostSet, make(map[int64]bool))
+		groups[i].HostCount = len(hostSet)
+		for hostID := range hostSet {
+			groups[i].OpenPortCount += openCounts[hostID]
+		}
+	}
+	return groups, nil
+}
+
+func collectGroupHosts(groupID int64, children map[int64][]int64, members map[int64][]int64, hosts map[int64]struct{}, visited map[int64]bool) {
+	if visited[groupID] {
+		return
+	}
+	visited[groupID] = true
+	for _, hostID := range members[groupID] {
+		hosts[hostID] = struct{}{}
+	}
+	for _, child := range children[groupID] {
+		collectGroupHosts(child, children, members, hosts, visited)
+	}
+}
+
+func (s *Store) groupMembers(ctx context.Context) (map[int64][]int64, error) {
+	rows, err := s.DB.QueryContext(ctx, `SELECT group_id, host_id FROM host_group_members`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := make(map[int64][]int64)
+	for rows.Next() {
+		var groupID, hostID int64
+		if err := rows.Scan(&groupID, &hostID); err != nil {
+			return nil, err
+		}
+		members[groupID] = append(members[groupID], hostID)
+	}
+	return members, rows.Err()
+}
+
+// hostGroupIDs 返回每台主机直接所属的分组。
+func (s *Store) hostGroupIDs(ctx context.Context) (map[int64][]int64, error) {
+	members, err := s.groupMembers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[int64][]int64)
+	for groupID, hostIDs := range members {
+		for _, hostID := range hostIDs {
+			result[hostID] = append(result[hostID], groupID)
+		}
+	}
+	return result, nil
+}
+
+// OpenPortCounts 返回各主机的开放端口数，没有开放端口的主机不在结果中。
+func (s *Store) OpenPortCounts(ctx context.Context) (map[int64]int, error) {
+	rows, err := s.DB.QueryContext(ctx, `SELECT host_id, COUNT(1) FROM ports WHERE status = ? GROUP BY host_id`, models.PortStatusOpen)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int64]int)
+	for rows.Next() {
+		var hostID int64
+		var count int
+		if err := rows.Scan(&hostID, &count); err != nil {
+			return nil, err
+		}
+		counts[hostID] = count
+	}
+	return counts, rows.Err()
+}
+
+// GetGroup 根据 ID 获取分组。
+func (s *Store) GetGroup(ctx context.Context, id int64) (*models.HostGroup, error) {
+	return scanGroup(s.DB.QueryRowContext(ctx, `SELECT id, name, description, parent_id, created_at, updated_at FROM host_groups WHERE id = ?`, id))
+}
+
+// CreateGroup 创建分组，parentID 为 0 表示顶层分组。
+func (s *Store) CreateGroup(ctx context.Context, name, description string, parentID int64) (int64, error) {
+	var id int64
+	err := s.DB.QueryRowContext(ctx,
+		`INSERT INTO host_groups (name, description, parent_id) VALUES (?, ?, ?) RETURNING id`,
+		name, description, nullableID(parentID),
+	).Scan(&id)
+	return id, err
+}
+
+// UpdateGroup 更新分组字段，拒绝把分组挂到自身或其子孙之下。
+func (s *Store) UpdateGroup(ctx context.Context, id int64, name, description string, parentID int64) error {
+	if parentID > 0 {
+		descendants, err := s.groupTree(ctx, id)
+		if err != nil {
+			return err
+		}
+		for _, descendant := range descendants {
+			if descendant == parentID {
+				return ErrGroupCycle
+			}
+		}
+	}
+	_, err := s.DB.ExecContext(ctx,
+		`UPDATE host_groups SET name = ?, description = ?, parent_id = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		name, description, nullableID(parentID), id,
+	)
+	return err
+}
+
+// DeleteGroup 删除分组，子分组提升为顶层分组，主机本身不受影响。
+func (s *Store) DeleteGroup(ctx context.Context, id int64) error {
+	_, err := s.DB.ExecContext(ctx, `DELETE FROM host_groups WHERE id = ?`, id)
+	return err
+}
+
+// AddGroupMembers 将主机加入分组，已是成员的主机会被忽略。
+func (s *Store) AddGroupMembers(ctx context.Context, groupID int64, hostIDs []int64) (int64, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var added int64
+	for _, hostID := range hostIDs {
+		res, err := tx.ExecContext(ctx,
+			`INSERT INTO host_group_members (group_id, host_id) VALUES (?, ?) ON CONFLICT(group_id, host_id) DO NOTHING`,
+			groupID, hostID,
+		)
+		if err != nil {
+			return 0, err
+		}
+		n, _ := res.RowsAffected()
+		added += n
+	}
+	return added, tx.Commit()
+}
+
+// RemoveGroupMember 将主机移出分组。
+func (s *Store) RemoveGroupMember(ctx context.Context, groupID, hostID int64) error {
+	_, err := s.DB.ExecContext(ctx, `DELETE FROM host_group_members WHERE group_id = ? AND host_id = ?`, groupID, hostID)
+	return err
+}
+
+// GroupHostIDs 返回分组内的主机 ID，recursive 为 true 时包含子分组成员。
+func (s *Store) GroupHostIDs(ctx context.Context, groupID int64, recursive bool) ([]int64, error) {
+	query := `SELECT DISTINCT host_id FROM host_group_members WHERE group_id = ? ORDER BY host_id`
+	if recursive {
+		query = groupTreeCTE + ` SELECT DISTINCT host_id FROM host_group_members WHERE group_id IN (SELECT id FROM group_tree) ORDER BY host_id`
+	}
+	return s.queryIDs(ctx, query, groupID)
+}
+
+// groupTree 返回分组自身及全部子孙分组的 ID。
+func (s *Store) groupTree(ctx context.Context, groupID int64) ([]int64, error) {
+	return s.queryIDs(ctx, groupTreeCTE+` SELECT id FROM group_tree`, groupID)
+}
+
+// BulkSetHiddenForGroup 对分组（含子分组）内全部主机批量修改端口隐藏标记，返回每台主机受影响的端口数。
+func (s *Store) BulkSetHiddenForGroup(ctx context.Context, groupID int64, start, end int, hidden bool) (map[int64]int64, error) {
+	hostIDs, err := s.GroupHostIDs(ctx, groupID, true)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	affected := make(map[int64]int64, len(hostIDs))
+	for _, hostID := range hostIDs {
+		res, err := tx.ExecContext(ctx,
+			`UPDATE ports SET hidden = ?, updated_at = CURRENT_TIMESTAMP WHERE host_id = ? AND number BETWEEN ? AND ?`,
+			boolToInt(hidden), hostID, start, end,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			affected[hostID] = n
+		}
+	}
+	return affected, tx.Commit()
+}
+
+func (s *Store) queryIDs(ctx context.Context, query string, args ...interface{}) ([]int64, error) {
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func scanGroup(row rowScanner) (*models.HostGroup, error) {
+	var g models.HostGroup
+	var parentID sql.NullInt64
+	if err := row.Scan(&g.ID, &g.Name, &g.Description, &parentID, &g.CreatedAt, &g.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if parentID.Valid {
+		g.ParentID = parentID.Int64
+	}
+	return &g, nil
+}
+
+func nullableID(id int64) interface{} {
+	if id > 0 {
+		return id
+	}
+	return nil
+}
+
+// GroupStats 汇总分组（含子分组）内每台主机的端口数量。
+func (s *Store) GroupStats(ctx context.Context, groupID int64) (*models.GroupStats, error) {
+	rows, err := s.DB.QueryContext(ctx, groupTreeCTE+`
+		SELECT h.id, h.name,
+			COALESCE(SUM(CASE WHEN p.status = ? THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN p.status = ? THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN p.hidden = 1 THEN 1 ELSE 0 END), 0)
+		FROM hosts h
+		LEFT JOIN ports p ON p.host_id = h.id
+		WHERE h.id IN (SELECT host_id FROM host_group_members WHERE group_id IN (SELECT id FROM group_tree))
+		GROUP BY h.id, h.name
+		ORDER BY h.name ASC`,
+		groupID, models.PortStatusOpen, models.PortStatusClosed,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := &models.GroupStats{GroupID: groupID, Hosts: []models.HostPortCounts{}}
+	for rows.Next() {
+		var c models.HostPortCounts
+		if err := rows.Scan(&c.HostID, &c.Name, &c.Open, &c.Closed, &c.Hidden); err != nil {
+			return nil, err
+		}
+		stats.HostCount++
+		stats.OpenPorts += c.Open
+		stats.ClosedPorts += c.Closed
+		stats.HiddenPorts += c.Hidden
+		stats.Hosts = append(stats.Hosts, c)
+	}
+	return stats, rows.Err()
+}
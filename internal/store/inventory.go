@@ -0,0 +1,351 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hitushen/portnotepro/internal/labels"
+	"github.com/hitushen/portnotepro/internal/models"
+	"github.com/hitushen/portnotepro/internal/targets"
+)
+
+// 清单导入模式：merge 按主机名合并到现有数据，replace 先清空全部主机再导入。
+const (
+	ImportMerge   = "merge"
+	ImportReplace = "replace"
+)
+
+// ErrInvalidInventory 表示导入文档格式或内容不合法。
+var ErrInvalidInventory = errors.New("invalid inventory")
+
+// ImportOptions 控制清单导入行为。
+type ImportOptions struct {
+	Mode string
+	// Overwrite 为 true 时，合并模式下以导入内容覆盖存在冲突的同名主机，否则跳过这些主机。
+	Overwrite bool
+	// DryRun 为 true 时在事务中执行后回滚，仅返回预览结果。
+	DryRun bool
+	// CheckTarget 不为空时校验新建主机与被覆盖的地址，返回 *targets.BlockedError 的主机记为冲突并跳过。
+	CheckTarget func(ctx context.Context, address string) error
+}
+
+// ExportInventory 导出全部主机与端口，主机按名称、端口按端口号排序，便于纳入版本管理后比对。
+func (s *Store) ExportInventory(ctx context.Context) (*models.Inventory, error) {
+	inv := &models.Inventory{Version: models.InventoryVersion, ExportedAt: time.Now().UTC(), Hosts: []models.InventoryHost{}}
+
+	hostLabels, err := s.hostLabelMap(ctx)
+	if err != nil {
+		return nil, err
+	}
+	portLabels, err := s.labelsByOwner(ctx, `SELECT port_id, key, value FROM labels WHERE port_id IS NOT NULL`)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.DB.QueryContext(ctx, `SELECT id, name, address, auto_scan FROM hosts ORDER BY name ASC`)
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[int64]int)
+	for rows.Next() {
+		var id int64
+		var autoScan int
+		var h models.InventoryHost
+		if err := rows.Scan(&id, &h.Name, &h.Address, &autoScan); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		h.AutoScan = autoScan == 1
+		h.Labels = hostLabels[id]
+		h.Ports = []models.InventoryPort{}
+		index[id] = len(inv.Hosts)
+		inv.Hosts = append(inv.Hosts, h)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	portRows, err := s.DB.QueryContext(ctx, `SELECT `+portColumns+` FROM ports ORDER BY host_id ASC, number ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer portRows.Close()
+	for portRows.Next() {
+		p, err := scanPort(portRows)
+		if err != nil {
+			return nil, err
+		}
+		i, ok := index[p.HostID]
+		if !ok {
+			continue
+		}
+		ip := models.InventoryPort{
+			Number:            p.Number,
+			Note:              p.Note,
+			Fingerprint:       p.Fingerprint,
+			DetectedService:   p.DetectedService,
+			UserFingerprint:   p.UserFingerprint,
+			FingerprintLocked: p.FingerprintLocked,
+			Hidden:            p.Hidden,
+			Status:            p.Status,
+			Labels:            portLabels[p.ID],
+		}
+		if !p.LastChecked.IsZero() {
+			checked := p.LastChecked.UTC()
+			ip.LastChecked = &checked
+		}
+		inv.Hosts[i].Ports = append(inv.Hosts[i].Ports, ip)
+	}
+	return inv, portRows.Err()
+}
+
+// validateInventory 规范化导入文档（地址、标签、默认状态），并一次性返回全部问题。
+func validateInventory(inv *models.Inventory) error {
+	if inv == nil {
+		return fmt.Errorf("%w: empty document", ErrInvalidInventory)
+	}
+	var problems []string
+	if inv.Version != models.InventoryVersion {
+		problems = append(problems, fmt.Sprintf("unsupported version %d (expected %d)", inv.Version, models.InventoryVersion))
+	}
+	names := make(map[string]bool)
+	for i := range inv.Hosts {
+		h := &inv.Hosts[i]
+		h.Name = strings.TrimSpace(h.Name)
+		where := fmt.Sprintf("hosts[%d]", i)
+		if h.Name == "" {
+			problems = append(problems, where+": name required")
+		} else if names[h.Name] {
+			problems = append(problems, fmt.Sprintf("%s: duplicate host name %q", where, h.Name))
+		}
+		names[h.Name] = true
+		if h.Address = targets.Normalize(h.Address); h.Address == "" {
+			problems = append(problems, where+": invalid address")
+		}
+		if h.Labels != nil {
+			normalized, err := labels.Normalize(h.Labels)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", where, err))
+			}
+			h.Labels = normalized
+		}
+
+		numbers := make(map[int]bool)
+		for j := range h.Ports {
+			p := &h.Ports[j]
+			where := fmt.Sprintf("hosts[%d].ports[%d]", i, j)
+			if p.Number < 1 || p.Number > 65535 {
+				problems = append(problems, fmt.Sprintf("%s: port %d out of range", where, p.Number))
+			} else if numbers[p.Number] {
+				problems = append(problems, fmt.Sprintf("%s: duplicate port %d", where, p.Number))
+			}
+			numbers[p.Number] = true
+			switch p.Status {
+			case "":
+				p.Status = models.PortStatusUnknown
+			case models.PortStatusUnknown, models.PortStatusOpen, models.PortStatusClosed, models.PortStatusFiltered, models.PortStatusUnreachable:
+			default:
+				problems = append(problems, fmt.Sprintf("%s: invalid status %q", where, p.Status))
+			}
+			p.FingerprintLocked = p.FingerprintLocked && p.UserFingerprint != ""
+			if p.Labels != nil {
+				normalized, err := labels.Normalize(p.Labels)
+				if err != nil {
+					problems = append(problems, fmt.Sprintf("%s: %v", where, err))
+				}
+				p.Labels = normalized
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidInventory, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// ImportInventory 在单个事务中导入清单，文档中省略的标签保持不变。
+// 合并模式下同名主机的地址或自动扫描设置不一致视为冲突，按 Overwrite 决定覆盖或跳过；
+// 其余同名主机合并端口（按端口号更新备注、指纹、隐藏标记与标签）。
+// 端口状态与最近检测时间仅在新建端口时采用导入值，已有端口保留本实例的扫描结果。
+func (s *Store) ImportInventory(ctx context.Context, inv *models.Inventory, opts ImportOptions) (*models.ImportReport, error) {
+	if opts.Mode == "" {
+		opts.Mode = ImportMerge
+	}
+	if opts.Mode != ImportMerge && opts.Mode != ImportReplace {
+		return nil, fmt.Errorf("%w: unknown mode %q", ErrInvalidInventory, opts.Mode)
+	}
+	if err := validateInventory(inv); err != nil {
+		return nil, err
+	}
+	report := &models.ImportReport{Mode: opts.Mode, DryRun: opts.DryRun, Conflicts: []models.ImportConflict{}}
+	blocked, err := s.blockedInventoryHosts(ctx, inv, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if opts.Mode == ImportReplace {
+		res, err := tx.ExecContext(ctx, `DELETE FROM hosts`)
+		if err != nil {
+			return nil, err
+		}
+		deleted, _ := res.RowsAffected()
+		report.HostsDeleted = int(deleted)
+	}
+
+	for _, h := range inv.Hosts {
+		var hostID int64
+		var address string
+		var autoScan int
+		err := tx.QueryRowContext(ctx, `SELECT id, address, auto_scan FROM hosts WHERE name = ?`, h.Name).Scan(&hostID, &address, &autoScan)
+		if blocked[h.Name] && (errors.Is(err, sql.ErrNoRows) || targets.Normalize(address) != h.Address) {
+			report.Conflicts = append(report.Conflicts, models.ImportConflict{Host: h.Name, Fields: []string{"address"}, Resolution: "blocked"})
+			report.HostsSkipped++
+			continue
+		}
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			if err := tx.QueryRowContext(ctx,
+				`INSERT INTO hosts (name, address, auto_scan, scanning) VALUES (?, ?, ?, 0) RETURNING id`,
+				h.Name, h.Address, boolToInt(h.AutoScan),
+			).Scan(&hostID); err != nil {
+				return nil, fmt.Errorf("create host %q: %w", h.Name, err)
+			}
+			report.HostsCreated++
+		case err != nil:
+			return nil, err
+		default:
+			var fields []string
+			if targets.Normalize(address) != h.Address {
+				fields = append(fields, "address")
+			}
+			if (autoScan == 1) != h.AutoScan {
+				fields = append(fields, "autoScan")
+			}
+			if len(fields) > 0 {
+				conflict := models.ImportConflict{Host: h.Name, Fields: fields, Resolution: "skipped"}
+				if !opts.Overwrite {
+					report.Conflicts = append(report.Conflicts, conflict)
+					report.HostsSkipped++
+					continue
+				}
+				conflict.Resolution = "overwritten"
+				report.Conflicts = append(report.Conflicts, conflict)
+				if _, err := tx.ExecContext(ctx,
+					`UPDATE hosts SET address = ?, auto_scan = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+					h.Address, boolToInt(h.AutoScan), hostID,
+				); err != nil {
+					return nil, err
+				}
+				if targets.Normalize(address) != h.Address {
+					if _, err := tx.ExecContext(ctx, `DELETE FROM host_addresses WHERE host_id = ?`, hostID); err != nil {
+						return nil, err
+					}
+				}
+			}
+			report.HostsUpdated++
+		}
+
+		if h.Labels != nil {
+			if err := replaceLabels(ctx, tx, hostID, 0, h.Labels); err != nil {
+				return nil, err
+			}
+		}
+		for _, p := range h.Ports {
+			created, err := importPort(ctx, tx, hostID, p)
+			if err != nil {
+				return nil, fmt.Errorf("import port %s:%d: %w", h.Name, p.Number, err)
+			}
+			if created {
+				report.PortsCreated++
+			} else {
+				report.PortsUpdated++
+			}
+		}
+	}
+
+	if opts.DryRun {
+		return report, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// blockedInventoryHosts 在导入事务开始前按 opts.CheckTarget 校验会写入新地址的主机（新建主机或覆盖地址），
+// 返回被扫描策略拒绝的主机名。校验可能查询数据库，不能在事务中进行。
+func (s *Store) blockedInventoryHosts(ctx context.Context, inv *models.Inventory, opts ImportOptions) (map[string]bool, error) {
+	blocked := make(map[string]bool)
+	if opts.CheckTarget == nil {
+		return blocked, nil
+	}
+	for _, h := range inv.Hosts {
+		if opts.Mode == ImportMerge {
+			var address string
+			err := s.DB.QueryRowContext(ctx, `SELECT address FROM hosts WHERE name = ?`, h.Name).Scan(&address)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return nil, err
+			}
+			if err == nil && (!opts.Overwrite || targets.Normalize(address) == h.Address) {
+				continue
+			}
+		}
+		err := opts.CheckTarget(ctx, h.Address)
+		var be *targets.BlockedError
+		switch {
+		case errors.As(err, &be):
+			blocked[h.Name] = true
+		case err != nil:
+			return nil, fmt.Errorf("check host %q: %w", h.Name, err)
+		}
+	}
+	return blocked, nil
+}
+
+// importPort 按端口号新建或更新端口，返回是否为新建。
+func importPort(ctx context.Context, tx *sql.Tx, hostID int64, p models.InventoryPort) (bool, error) {
+	var portID int64
+	err := tx.QueryRowContext(ctx, `SELECT id FROM ports WHERE host_id = ? AND number = ?`, hostID, p.Number).Scan(&portID)
+	created := errors.Is(err, sql.ErrNoRows)
+	switch {
+	case created:
+		var lastChecked interface{}
+		if p.LastChecked != nil {
+			lastChecked = p.LastChecked.UTC()
+		}
+		if err := tx.QueryRowContext(ctx,
+			`INSERT INTO ports (host_id, number, note, fingerprint, detected_service, user_fingerprint, fingerprint_locked, hidden, status, last_checked)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+			hostID, p.Number, p.Note, p.Fingerprint, p.DetectedService, p.UserFingerprint,
+			boolToInt(p.FingerprintLocked), boolToInt(p.Hidden), p.Status, lastChecked,
+		).Scan(&portID); err != nil {
+			return false, err
+		}
+	case err != nil:
+		return false, err
+	default:
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE ports SET note = ?, fingerprint = ?, user_fingerprint = ?, fingerprint_locked = ?, hidden = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+			p.Note, p.Fingerprint, p.UserFingerprint, boolToInt(p.FingerprintLocked), boolToInt(p.Hidden), portID,
+		); err != nil {
+			return false, err
+		}
+	}
+	if p.Labels != nil {
+		if err := replaceLabels(ctx, tx, hostID, portID, p.Labels); err != nil {
+			return false, err
+		}
+	}
+	return created, nil
+}
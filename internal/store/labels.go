@@ -0,0 +1,149 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/hitushen/portnotepro/internal/labels"
+)
+
+// 主机标签 port_id 为空；端口标签同时记录所属主机，便于随主机级联删除。
+var (
+	hostLabelScope = labels.Scope{
+		Match: "l.host_id = h.id AND l.port_id IS NULL",
+		Fields: map[string]string{
+			"name":     "h.name",
+			"address":  "h.address",
+			"autoscan": "h.auto_scan",
+			"scanning": "h.scanning",
+		},
+		BoolFields: map[string]bool{"autoscan": true, "scanning": true},
+	}
+	portLabelScope = labels.Scope{
+		Match: "l.port_id = ports.id",
+		Fields: map[string]string{
+			"number":      "ports.number",
+			"status":      "ports.status",
+			"hidden":      "ports.hidden",
+			"fingerprint": "ports.fingerprint",
+			"service":     "ports.detected_service",
+			"note":        "ports.note",
+		},
+		BoolFields: map[string]bool{"hidden": true},
+	}
+)
+
+// HostLabels 返回主机自身的标签。
+func (s *Store) HostLabels(ctx context.Context, hostID int64) (map[string]string, error) {
+	return s.queryLabels(ctx, `SELECT key, value FROM labels WHERE host_id = ? AND port_id IS NULL`, hostID)
+}
+
+// PortLabels 返回端口的标签。
+func (s *Store) PortLabels(ctx context.Context, portID int64) (map[string]string, error) {
+	return s.queryLabels(ctx, `SELECT key, value FROM labels WHERE port_id = ?`, portID)
+}
+
+// SetHostLabels 以给定集合整体替换主机标签。
+func (s *Store) SetHostLabels(ctx context.Context, hostID int64, values map[string]string) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := replaceLabels(ctx, tx, hostID, 0, values); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetPortLabels 以给定集合整体替换端口标签。
+func (s *Store) SetPortLabels(ctx context.Context, portID int64, values map[string]string) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var hostID int64
+	if err := tx.QueryRowContext(ctx, `SELECT host_id FROM ports WHERE id = ?`, portID).Scan(&hostID); err != nil {
+		return err
+	}
+	if err := replaceLabels(ctx, tx, hostID, portID, values); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// replaceLabels 在事务内整体替换主机（portID 为 0）或端口的标签。
+func replaceLabels(ctx context.Context, tx *sql.Tx, hostID, portID int64, values map[string]string) error {
+	if portID == 0 {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM labels WHERE host_id = ? AND port_id IS NULL`, hostID); err != nil {
+			return err
+		}
+	} else if _, err := tx.ExecContext(ctx, `DELETE FROM labels WHERE port_id = ?`, portID); err != nil {
+		return err
+	}
+	for key, value := range values {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO labels (host_id, port_id, key, value) VALUES (?, ?, ?, ?)`, hostID, nullableID(portID), key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) queryLabels(ctx context.Context, query string, args ...interface{}) (map[string]string, error) {
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		result[key] = value
+	}
+	return result, rows.Err()
+}
+
+// labelsByOwner 按 owner 列（host_id 或 port_id）分组返回标签。
+func (s *Store) labelsByOwner(ctx context.Context, query string, args ...interface{}) (map[int64]map[string]string, error) {
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[int64]map[string]string)
+	for rows.Next() {
+		var owner int64
+		var key, value string
+		if err := rows.Scan(&owner, &key, &value); err != nil {
+			return nil, err
+		}
+		if result[owner] == nil {
+			result[owner] = make(map[string]string)
+		}
+		result[owner][key] = value
+	}
+	return result, rows.Err()
+}
+
+func (s *Store) hostLabelMap(ctx context.Context) (map[int64]map[string]string, error) {
+	return s.labelsByOwner(ctx, `SELECT host_id, key, value FROM labels WHERE port_id IS NULL`)
+}
+
+func (s *Store) portLabelMap(ctx context.Context, hostID int64) (map[int64]map[string]string, error) {
+	return s.labelsByOwner(ctx, `SELECT port_id, key, value FROM labels WHERE host_id = ? AND port_id IS NOT NULL`, hostID)
+}
+
+func labelsOrEmpty(m map[string]string) map[string]string {
+	if m == nil {
+		return map[string]string{}
+	}
+	return m
+}
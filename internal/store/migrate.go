@@ -0,0 +1,245 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/sqlite/*.sql migrations/postgres/*.sql
+var migrationFiles embed.FS
+
+// ErrSchemaTooNew 表示数据库由更新版本的程序迁移过，当前程序拒绝在其上运行。
+var ErrSchemaTooNew = errors.New("database schema is newer than this binary supports")
+
+// Migration 描述一次编号的结构迁移，要么是内置的 Go 函数，要么是嵌入的 SQL 文件。
+type Migration struct {
+	Version int
+	Name    string
+	sql     string
+	up      func(ctx context.Context, tx *sql.Tx) error
+}
+
+// MigrationState 为迁移的应用情况，未应用时 AppliedAt 为零值。
+type MigrationState struct {
+	Version   int       `json:"version"`
+	Name      string    `json:"name"`
+	Applied   bool      `json:"applied"`
+	AppliedAt time.Time `json:"appliedAt,omitempty"`
+}
+
+// dataMigrations 为两种后端共用、需要借助 Go 代码完成的数据迁移，与 SQL 文件共享版本序列。
+var dataMigrations = []Migration{
+	{Version: 15, Name: "lock_user_fingerprints", up: migrateUserFingerprints},
+}
+
+// Migrations 返回指定后端按版本排序的全部迁移。
+// SQL 文件位于 migrations/<dialect>/，命名为 NNNN_name.sql，版本号需从 1 开始连续递增；
+// SQLite 的版本 1 为内置基线（兼容早期自动建表的数据库），PostgreSQL 的基线则是 0001_baseline.sql。
+func Migrations(d Dialect) ([]Migration, error) {
+	var list []Migration
+	if d == DialectSQLite {
+		list = append(list, Migration{Version: 1, Name: "baseline", up: migrateBaseline})
+	}
+	list = append(list, dataMigrations...)
+
+	dir := path.Join("migrations", string(d))
+	entries, err := migrationFiles.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".sql") {
+			continue
+		}
+		prefix, rest, ok := strings.Cut(strings.TrimSuffix(name, ".sql"), "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("invalid migration file name %q", name)
+		}
+		body, err := migrationFiles.ReadFile(path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, Migration{Version: version, Name: rest, sql: string(body)})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	for i, m := range list {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("migration versions must be contiguous, found %d at position %d", m.Version, i+1)
+		}
+	}
+	return list, nil
+}
+
+// LatestSchemaVersion 返回当前程序对指定后端支持的最高结构版本。
+func LatestSchemaVersion(d Dialect) int {
+	list, err := Migrations(d)
+	if err != nil || len(list) == 0 {
+		return 0
+	}
+	return list[len(list)-1].Version
+}
+
+func (s *Store) ensureMigrationTable(ctx context.Context) error {
+	_, err := s.DB.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+	return err
+}
+
+func (s *Store) appliedMigrations(ctx context.Context) (map[int]time.Time, error) {
+	if err := s.ensureMigrationTable(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := s.DB.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+// SchemaVersion 返回数据库已应用的最高迁移版本，全新数据库为 0。
+func (s *Store) SchemaVersion(ctx context.Context) (int, error) {
+	applied, err := s.appliedMigrations(ctx)
+	if err != nil {
+		return 0, err
+	}
+	version := 0
+	for v := range applied {
+		if v > version {
+			version = v
+		}
+	}
+	return version, nil
+}
+
+// CheckSchema 在数据库版本高于程序支持的版本时返回 ErrSchemaTooNew。
+func (s *Store) CheckSchema(ctx context.Context) error {
+	version, err := s.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if latest := LatestSchemaVersion(s.dialect); version > latest {
+		return fmt.Errorf("%w: database at version %d, binary supports up to %d", ErrSchemaTooNew, version, latest)
+	}
+	return nil
+}
+
+// MigrationStatus 列出全部迁移及其应用情况。
+func (s *Store) MigrationStatus(ctx context.Context) ([]MigrationState, error) {
+	list, err := Migrations(s.dialect)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := s.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	states := make([]MigrationState, len(list))
+	for i, m := range list {
+		at, ok := applied[m.Version]
+		states[i] = MigrationState{Version: m.Version, Name: m.Name, Applied: ok, AppliedAt: at}
+	}
+	return states, nil
+}
+
+// migrationLockKey 为 PostgreSQL 上迁移使用的 advisory lock 键。
+const migrationLockKey int64 = 0x706f72746e6f7465 // "portnote"
+
+// lockMigrations 在 PostgreSQL 上以专用连接获取会话级 advisory lock，返回释放函数。
+// 多个副本同时启动时依次执行迁移，后获得锁的副本会看到已应用的迁移而直接跳过。
+// SQLite 只由单个进程访问，无需加锁。
+func (s *Store) lockMigrations(ctx context.Context) (func(), error) {
+	if s.dialect != DialectPostgres {
+		return func() {}, nil
+	}
+	conn, err := s.DB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock(?)`, migrationLockKey); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("acquire migration lock: %w", err)
+	}
+	return func() {
+		_, _ = conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock(?)`, migrationLockKey)
+		conn.Close()
+	}, nil
+}
+
+// Migrate 依次在独立事务中应用尚未执行的迁移，返回本次应用的迁移。
+// PostgreSQL 上整个过程持有 advisory lock，已应用的迁移在取得锁之后读取。
+func (s *Store) Migrate(ctx context.Context) ([]Migration, error) {
+	unlock, err := s.lockMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if err := s.CheckSchema(ctx); err != nil {
+		return nil, err
+	}
+	list, err := Migrations(s.dialect)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := s.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, m := range list {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if err := s.applyMigration(ctx, m); err != nil {
+			return done, fmt.Errorf("migrate %04d_%s: %w", m.Version, m.Name, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+func (s *Store) applyMigration(ctx context.Context, m Migration) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if m.up != nil {
+		err = m.up(ctx, tx)
+	} else {
+		_, err = tx.ExecContext(ctx, m.sql)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, m.Version, m.Name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
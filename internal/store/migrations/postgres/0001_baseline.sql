@@ -0,0 +1,91 @@
+-- PostgreSQL 基线结构，与 SQLite 的 0001_baseline 对应。
+-- 布尔字段沿用 INTEGER（0/1）以保持两种后端的查询一致；时间统一使用 TIMESTAMPTZ。
+CREATE TABLE IF NOT EXISTS users (
+	id BIGSERIAL PRIMARY KEY,
+	username TEXT UNIQUE NOT NULL,
+	password_hash TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS hosts (
+	id BIGSERIAL PRIMARY KEY,
+	name TEXT NOT NULL,
+	address TEXT NOT NULL,
+	auto_scan INTEGER NOT NULL DEFAULT 1,
+	scanning INTEGER NOT NULL DEFAULT 0,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_hosts_name ON hosts(name);
+
+CREATE TABLE IF NOT EXISTS ports (
+	id BIGSERIAL PRIMARY KEY,
+	host_id BIGINT NOT NULL REFERENCES hosts(id) ON DELETE CASCADE,
+	number INTEGER NOT NULL,
+	note TEXT NOT NULL DEFAULT '',
+	fingerprint TEXT NOT NULL DEFAULT '',
+	detected_service TEXT NOT NULL DEFAULT '',
+	user_fingerprint TEXT NOT NULL DEFAULT '',
+	fingerprint_locked INTEGER NOT NULL DEFAULT 0,
+	hidden INTEGER NOT NULL DEFAULT 0,
+	status TEXT NOT NULL DEFAULT 'unknown',
+	last_checked TIMESTAMPTZ,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE(host_id, number)
+);
+
+CREATE TABLE IF NOT EXISTS port_service_history (
+	id BIGSERIAL PRIMARY KEY,
+	port_id BIGINT NOT NULL REFERENCES ports(id) ON DELETE CASCADE,
+	previous TEXT NOT NULL DEFAULT '',
+	service TEXT NOT NULL DEFAULT '',
+	detected_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_port_service_history_port ON port_service_history(port_id, detected_at);
+
+CREATE TABLE IF NOT EXISTS host_addresses (
+	id BIGSERIAL PRIMARY KEY,
+	host_id BIGINT NOT NULL REFERENCES hosts(id) ON DELETE CASCADE,
+	address TEXT NOT NULL,
+	active INTEGER NOT NULL DEFAULT 1,
+	first_seen TIMESTAMPTZ NOT NULL,
+	last_seen TIMESTAMPTZ NOT NULL,
+	UNIQUE(host_id, address)
+);
+
+CREATE TABLE IF NOT EXISTS host_groups (
+	id BIGSERIAL PRIMARY KEY,
+	name TEXT NOT NULL,
+	description TEXT NOT NULL DEFAULT '',
+	parent_id BIGINT REFERENCES host_groups(id) ON DELETE SET NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_host_groups_name ON host_groups(name);
+
+CREATE TABLE IF NOT EXISTS host_group_members (
+	group_id BIGINT NOT NULL REFERENCES host_groups(id) ON DELETE CASCADE,
+	host_id BIGINT NOT NULL REFERENCES hosts(id) ON DELETE CASCADE,
+	PRIMARY KEY (group_id, host_id)
+);
+
+CREATE TABLE IF NOT EXISTS scan_exclusions (
+	id BIGSERIAL PRIMARY KEY,
+	host_id BIGINT REFERENCES hosts(id) ON DELETE CASCADE,
+	kind TEXT NOT NULL,
+	value TEXT NOT NULL,
+	note TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS labels (
+	id BIGSERIAL PRIMARY KEY,
+	host_id BIGINT NOT NULL REFERENCES hosts(id) ON DELETE CASCADE,
+	port_id BIGINT REFERENCES ports(id) ON DELETE CASCADE,
+	key TEXT NOT NULL,
+	value TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_labels_host ON labels(host_id, key) WHERE port_id IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_labels_port ON labels(port_id, key) WHERE port_id IS NOT NULL;
@@ -0,0 +1,6 @@
+-- PostgreSQL 使用表达式 GIN 索引实现全文检索，无需触发器同步。
+-- 表达式需与 store 中检索语句的 to_tsvector 调用保持一致。
+CREATE INDEX IF NOT EXISTS idx_ports_fts ON ports
+	USING GIN (to_tsvector('simple', note || ' ' || fingerprint));
+CREATE INDEX IF NOT EXISTS idx_hosts_fts ON hosts
+	USING GIN (to_tsvector('simple', name || ' ' || address));
@@ -0,0 +1,23 @@
+-- 端口基线：声明主机或分组预期开放的端口，host_id 与 group_id 恰好一个非空。
+CREATE TABLE IF NOT EXISTS port_baselines (
+	id BIGSERIAL PRIMARY KEY,
+	host_id BIGINT REFERENCES hosts(id) ON DELETE CASCADE,
+	group_id BIGINT REFERENCES host_groups(id) ON DELETE CASCADE,
+	ports TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	CHECK ((host_id IS NULL) <> (group_id IS NULL))
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_port_baselines_host ON port_baselines(host_id) WHERE host_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_port_baselines_group ON port_baselines(group_id) WHERE group_id IS NOT NULL;
+
+-- 最近一次基线评估结果，仅适用基线的主机有记录。端口列表形如 22,8000-8010。
+CREATE TABLE IF NOT EXISTS host_compliance (
+	host_id BIGINT PRIMARY KEY REFERENCES hosts(id) ON DELETE CASCADE,
+	status TEXT NOT NULL,
+	source TEXT NOT NULL DEFAULT '',
+	expected TEXT NOT NULL DEFAULT '',
+	unexpected TEXT NOT NULL DEFAULT '',
+	missing TEXT NOT NULL DEFAULT '',
+	checked_at TIMESTAMPTZ NOT NULL
+);
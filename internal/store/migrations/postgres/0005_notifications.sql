@@ -0,0 +1,21 @@
+-- 告警事件历史：记录每个归类后的扫描事件（不论是否命中规则），供邮件摘要统计。
+CREATE TABLE IF NOT EXISTS alert_events (
+	id BIGSERIAL PRIMARY KEY,
+	event TEXT NOT NULL,
+	host_id BIGINT NOT NULL REFERENCES hosts(id) ON DELETE CASCADE,
+	port_number INTEGER NOT NULL DEFAULT 0,
+	detail TEXT NOT NULL DEFAULT '',
+	occurred_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_alert_events_time ON alert_events(occurred_at);
+
+-- 用户通知偏好：digest 为 off / daily / weekly，group_id 为可选的摘要范围（含子分组）。
+CREATE TABLE IF NOT EXISTS notification_subscriptions (
+	user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+	email TEXT NOT NULL,
+	digest TEXT NOT NULL DEFAULT 'off',
+	group_id BIGINT REFERENCES host_groups(id) ON DELETE SET NULL,
+	last_digest_at TIMESTAMPTZ,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,7 @@
+-- 通知渠道路由：min_severity 为渠道接收的最低严重级别，group_id 非空时只接收该分组（含子分组）主机的告警。
+-- group_id 不设外键：分组删除后渠道不再匹配任何主机，而不是退化为接收全部告警。
+ALTER TABLE alert_targets ADD COLUMN min_severity TEXT NOT NULL DEFAULT 'info';
+ALTER TABLE alert_targets ADD COLUMN group_id BIGINT;
+
+-- 规则可指定告警的严重级别，为空时按告警类型取默认值。
+ALTER TABLE alert_rules ADD COLUMN severity TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,15 @@
+-- 扫描任务队列：kind 为 full / ports，ports 为定向复查的端口列表（如 22,443），
+-- status 为 queued / running / done / failed，进程重启时 running 的任务重新排队。
+CREATE TABLE IF NOT EXISTS scan_jobs (
+	id BIGSERIAL PRIMARY KEY,
+	host_id BIGINT NOT NULL REFERENCES hosts(id) ON DELETE CASCADE,
+	kind TEXT NOT NULL,
+	ports TEXT NOT NULL DEFAULT '',
+	status TEXT NOT NULL DEFAULT 'queued',
+	error TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	started_at TIMESTAMPTZ,
+	finished_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_scan_jobs_status ON scan_jobs(status, id);
+CREATE INDEX IF NOT EXISTS idx_scan_jobs_host ON scan_jobs(host_id, id);
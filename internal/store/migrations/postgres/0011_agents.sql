@@ -0,0 +1,24 @@
+-- 远程扫描 agent：token_hash 为令牌的 SHA-256，zone 为 agent 所在的网络区域。
+CREATE TABLE IF NOT EXISTS agents (
+	id BIGSERIAL PRIMARY KEY,
+	name TEXT NOT NULL,
+	zone TEXT NOT NULL,
+	token_hash TEXT NOT NULL,
+	disabled INTEGER NOT NULL DEFAULT 0,
+	version TEXT NOT NULL DEFAULT '',
+	hostname TEXT NOT NULL DEFAULT '',
+	remote_addr TEXT NOT NULL DEFAULT '',
+	last_seen_at TIMESTAMPTZ,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_agents_name ON agents(name);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_agents_token ON agents(token_hash);
+
+-- 主机的扫描归属：zone 非空时由该区域的 agent 扫描，agent_id 指定时只由该 agent 扫描，均为空时由服务端本地扫描。
+ALTER TABLE hosts ADD COLUMN zone TEXT NOT NULL DEFAULT '';
+ALTER TABLE hosts ADD COLUMN agent_id BIGINT REFERENCES agents(id) ON DELETE SET NULL;
+
+-- agent 领取的任务记录租约，到期未续约的任务重新排队。
+ALTER TABLE scan_jobs ADD COLUMN agent_id BIGINT REFERENCES agents(id) ON DELETE SET NULL;
+ALTER TABLE scan_jobs ADD COLUMN lease_expires_at TIMESTAMPTZ;
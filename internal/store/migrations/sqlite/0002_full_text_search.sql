@@ -0,0 +1,38 @@
+-- 全文索引：ports_fts 以端口 ID 为 rowid，冗余保存主机名称与地址以便一并检索；
+-- hosts_fts 以主机 ID 为 rowid。两者均由触发器与源表保持同步。
+CREATE VIRTUAL TABLE IF NOT EXISTS ports_fts USING fts5(note, fingerprint, host_name, host_address, tokenize = 'unicode61');
+CREATE VIRTUAL TABLE IF NOT EXISTS hosts_fts USING fts5(name, address, tokenize = 'unicode61');
+
+CREATE TRIGGER IF NOT EXISTS ports_fts_insert AFTER INSERT ON ports BEGIN
+	INSERT INTO ports_fts(rowid, note, fingerprint, host_name, host_address)
+	SELECT new.id, new.note, new.fingerprint, h.name, h.address FROM hosts h WHERE h.id = new.host_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS ports_fts_update AFTER UPDATE OF note, fingerprint ON ports BEGIN
+	UPDATE ports_fts SET note = new.note, fingerprint = new.fingerprint WHERE rowid = new.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS ports_fts_delete AFTER DELETE ON ports BEGIN
+	DELETE FROM ports_fts WHERE rowid = old.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS hosts_fts_insert AFTER INSERT ON hosts BEGIN
+	INSERT INTO hosts_fts(rowid, name, address) VALUES (new.id, new.name, new.address);
+END;
+
+CREATE TRIGGER IF NOT EXISTS hosts_fts_update AFTER UPDATE OF name, address ON hosts BEGIN
+	UPDATE hosts_fts SET name = new.name, address = new.address WHERE rowid = new.id;
+	UPDATE ports_fts SET host_name = new.name, host_address = new.address
+		WHERE rowid IN (SELECT id FROM ports WHERE host_id = new.id);
+END;
+
+CREATE TRIGGER IF NOT EXISTS hosts_fts_delete AFTER DELETE ON hosts BEGIN
+	DELETE FROM hosts_fts WHERE rowid = old.id;
+END;
+
+-- 为已有数据建立索引。
+DELETE FROM ports_fts;
+DELETE FROM hosts_fts;
+INSERT INTO ports_fts(rowid, note, fingerprint, host_name, host_address)
+	SELECT p.id, p.note, p.fingerprint, h.name, h.address FROM ports p JOIN hosts h ON h.id = p.host_id;
+INSERT INTO hosts_fts(rowid, name, address) SELECT id, name, address FROM hosts;
@@ -0,0 +1,48 @@
+-- 告警通知目标：kind 目前为 webhook，secret 用于 HMAC 签名。
+CREATE TABLE IF NOT EXISTS alert_targets (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	kind TEXT NOT NULL DEFAULT 'webhook',
+	url TEXT NOT NULL,
+	secret TEXT NOT NULL DEFAULT '',
+	enabled INTEGER NOT NULL DEFAULT 1,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_alert_targets_name ON alert_targets(name);
+
+-- 告警规则：events 为逗号分隔的告警类型，ports 为可选的端口过滤（如 22,3306），
+-- host_id / group_id 为可选的主机或分组（含子分组）范围，cooldown_seconds 内同一对象只通知一次。
+CREATE TABLE IF NOT EXISTS alert_rules (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	events TEXT NOT NULL,
+	ports TEXT NOT NULL DEFAULT '',
+	host_id INTEGER REFERENCES hosts(id) ON DELETE CASCADE,
+	group_id INTEGER REFERENCES host_groups(id) ON DELETE CASCADE,
+	cooldown_seconds INTEGER NOT NULL DEFAULT 0,
+	target_id INTEGER NOT NULL REFERENCES alert_targets(id) ON DELETE CASCADE,
+	enabled INTEGER NOT NULL DEFAULT 1,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_alert_rules_name ON alert_rules(name);
+
+-- 投递记录：status 为 pending / sending / delivered / failed，pending 记录在 next_attempt_at 后重试。
+CREATE TABLE IF NOT EXISTS alert_deliveries (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	rule_id INTEGER REFERENCES alert_rules(id) ON DELETE SET NULL,
+	target_id INTEGER NOT NULL REFERENCES alert_targets(id) ON DELETE CASCADE,
+	event TEXT NOT NULL,
+	dedup_key TEXT NOT NULL DEFAULT '',
+	payload TEXT NOT NULL,
+	status TEXT NOT NULL DEFAULT 'pending',
+	attempts INTEGER NOT NULL DEFAULT 0,
+	response_code INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	next_attempt_at TIMESTAMP NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	delivered_at TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_alert_deliveries_due ON alert_deliveries(status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_alert_deliveries_dedup ON alert_deliveries(rule_id, dedup_key, created_at);
@@ -0,0 +1,15 @@
+-- 扫描任务队列：kind 为 full / ports，ports 为定向复查的端口列表（如 22,443），
+-- status 为 queued / running / done / failed，进程重启时 running 的任务重新排队。
+CREATE TABLE IF NOT EXISTS scan_jobs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	host_id INTEGER NOT NULL REFERENCES hosts(id) ON DELETE CASCADE,
+	kind TEXT NOT NULL,
+	ports TEXT NOT NULL DEFAULT '',
+	status TEXT NOT NULL DEFAULT 'queued',
+	error TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	started_at TIMESTAMP,
+	finished_at TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_scan_jobs_status ON scan_jobs(status, id);
+CREATE INDEX IF NOT EXISTS idx_scan_jobs_host ON scan_jobs(host_id, id);
@@ -0,0 +1,3 @@
+-- 扫描任务优先级：2 为交互式定向复查，1 为手动全范围扫描，0 为定时扫描。
+ALTER TABLE scan_jobs ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_scan_jobs_queue ON scan_jobs(status, priority, id);
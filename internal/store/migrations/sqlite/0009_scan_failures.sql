@@ -0,0 +1,11 @@
+-- 最近一次扫描结果：last_scan_error_kind 为失败分类（dns / permission / timeout / unreachable / runner_init / blocked / other），
+-- consecutive_failures 为连续失败次数，成功后清零。
+ALTER TABLE hosts ADD COLUMN last_scan_at TIMESTAMP;
+ALTER TABLE hosts ADD COLUMN last_scan_error TEXT NOT NULL DEFAULT '';
+ALTER TABLE hosts ADD COLUMN last_scan_error_kind TEXT NOT NULL DEFAULT '';
+ALTER TABLE hosts ADD COLUMN consecutive_failures INTEGER NOT NULL DEFAULT 0;
+
+-- 扫描任务的尝试次数与下次重试时间，临时性失败按退避重新排队。
+ALTER TABLE scan_jobs ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE scan_jobs ADD COLUMN error_kind TEXT NOT NULL DEFAULT '';
+ALTER TABLE scan_jobs ADD COLUMN next_attempt_at TIMESTAMP;
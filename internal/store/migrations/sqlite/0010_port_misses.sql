@@ -0,0 +1,2 @@
+-- 开放端口在扫描中连续未被发现（且确认探测也未连通）的次数，达到阈值才改变状态，写入状态时清零。
+ALTER TABLE ports ADD COLUMN missed_scans INTEGER NOT NULL DEFAULT 0;
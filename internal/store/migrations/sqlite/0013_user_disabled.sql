@@ -0,0 +1,2 @@
+-- 停用的用户无法登录，已有会话与 API 令牌同时失效。
+ALTER TABLE users ADD COLUMN disabled INTEGER NOT NULL DEFAULT 0;
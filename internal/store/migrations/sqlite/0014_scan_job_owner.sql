@@ -0,0 +1,3 @@
+-- 本地执行的任务记录领取它的服务端实例，并与 agent 任务一样带租约、由该实例定期续约；
+-- 启动时只恢复本实例的任务，其他实例的任务在租约到期后才收回。
+ALTER TABLE scan_jobs ADD COLUMN owner TEXT NOT NULL DEFAULT '';
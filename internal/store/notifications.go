@@ -0,0 +1,123 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/hitushen/portnotepro/internal/models"
+)
+
+// RecordAlertEvent 写入一条告警事件历史。
+func (s *Store) RecordAlertEvent(ctx context.Context, e *models.AlertEvent) error {
+	_, err := s.DB.ExecContext(ctx,
+		`INSERT INTO alert_events (event, host_id, port_number, detail, occurred_at) VALUES (?, ?, ?, ?, ?)`,
+		e.Event, e.HostID, e.Port, e.Detail, e.OccurredAt.UTC(),
+	)
+	return err
+}
+
+// AlertEventsBetween 返回 [since, until) 内的告警事件，按时间排序并带上主机名称与地址。
+func (s *Store) AlertEventsBetween(ctx context.Context, since, until time.Time) ([]models.AlertEvent, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT e.id, e.event, e.host_id, h.name, h.address, e.port_number, e.detail, e.occurred_at
+		FROM alert_events e JOIN hosts h ON h.id = e.host_id
+		WHERE e.occurred_at >= ? AND e.occurred_at < ?
+		ORDER BY e.occurred_at ASC, e.id ASC`, since.UTC(), until.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []models.AlertEvent
+	for rows.Next() {
+		var e models.AlertEvent
+		if err := rows.Scan(&e.ID, &e.Event, &e.HostID, &e.HostName, &e.HostAddress, &e.Port, &e.Detail, &e.OccurredAt); err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+	}
+	return list, rows.Err()
+}
+
+// PruneAlertEvents 删除 before 之前的告警事件历史。
+func (s *Store) PruneAlertEvents(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM alert_events WHERE occurred_at < ?`, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+const subscriptionColumns = `user_id, email, digest, group_id, last_digest_at, created_at, updated_at`
+
+func scanSubscription(row rowScanner) (*models.NotificationSubscription, error) {
+	var sub models.NotificationSubscription
+	var groupID sql.NullInt64
+	var last sql.NullTime
+	if err := row.Scan(&sub.UserID, &sub.Email, &sub.Digest, &groupID, &last, &sub.CreatedAt, &sub.UpdatedAt); err != nil {
+		return nil, err
+	}
+	sub.GroupID = groupID.Int64
+	if last.Valid {
+		t := last.Time
+		sub.LastDigestAt = &t
+	}
+	return &sub, nil
+}
+
+// GetSubscription 返回用户的通知偏好，未设置时返回 sql.ErrNoRows。
+func (s *Store) GetSubscription(ctx context.Context, userID int64) (*models.NotificationSubscription, error) {
+	return scanSubscription(s.DB.QueryRowContext(ctx,
+		`SELECT `+subscriptionColumns+` FROM notification_subscriptions WHERE user_id = ?`, userID))
+}
+
+// SetSubscription 创建或更新用户的通知偏好，保留上次发送摘要的时间。
+func (s *Store) SetSubscription(ctx context.Context, sub *models.NotificationSubscription) error {
+	res, err := s.DB.ExecContext(ctx,
+		`UPDATE notification_subscriptions SET email = ?, digest = ?, group_id = ?, updated_at = CURRENT_TIMESTAMP WHERE user_id = ?`,
+		sub.Email, sub.Digest, nullableID(sub.GroupID), sub.UserID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		return nil
+	}
+	_, err = s.DB.ExecContext(ctx,
+		`INSERT INTO notification_subscriptions (user_id, email, digest, group_id) VALUES (?, ?, ?, ?)`,
+		sub.UserID, sub.Email, sub.Digest, nullableID(sub.GroupID),
+	)
+	return err
+}
+
+// DeleteSubscription 删除用户的通知偏好。
+func (s *Store) DeleteSubscription(ctx context.Context, userID int64) error {
+	_, err := s.DB.ExecContext(ctx, `DELETE FROM notification_subscriptions WHERE user_id = ?`, userID)
+	return err
+}
+
+// DigestSubscriptions 返回开启了摘要的全部订阅。
+func (s *Store) DigestSubscriptions(ctx context.Context) ([]models.NotificationSubscription, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT `+subscriptionColumns+` FROM notification_subscriptions WHERE digest <> ? ORDER BY user_id ASC`, models.DigestOff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []models.NotificationSubscription
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *sub)
+	}
+	return list, rows.Err()
+}
+
+// MarkDigestSent 记录用户摘要的统计截止时间，下一期从该时间开始。
+func (s *Store) MarkDigestSent(ctx context.Context, userID int64, until time.Time) error {
+	_, err := s.DB.ExecContext(ctx,
+		`UPDATE notification_subscriptions SET last_digest_at = ? WHERE user_id = ?`, until.UTC(), userID)
+	return err
+}
@@ -0,0 +1,326 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/hitushen/portnotepro/internal/models"
+)
+
+const scanJobColumns = `j.id, j.host_id, h.name, j.kind, j.priority, j.ports, j.status, j.attempts, j.error, j.error_kind,
+	j.created_at, j.started_at, j.finished_at, j.next_attempt_at, j.agent_id, j.owner, j.lease_expires_at`
+
+func scanScanJob(row rowScanner) (*models.ScanJob, error) {
+	var j models.ScanJob
+	var priority int
+	var ports string
+	var startedAt, finishedAt, nextAttemptAt, leaseExpiresAt sql.NullTime
+	var agentID sql.NullInt64
+	if err := row.Scan(&j.ID, &j.HostID, &j.HostName, &j.Kind, &priority, &ports, &j.Status, &j.Attempts, &j.Error, &j.ErrorKind,
+		&j.CreatedAt, &startedAt, &finishedAt, &nextAttemptAt, &agentID, &j.Owner, &leaseExpiresAt); err != nil {
+		return nil, err
+	}
+	j.Priority = models.ScanPriorityName(priority)
+	if ports != "" {
+		parsed, err := ParsePorts(ports)
+		if err != nil {
+			return nil, err
+		}
+		j.Ports = parsed
+	}
+	if startedAt.Valid {
+		t := startedAt.Time
+		j.StartedAt = &t
+	}
+	if finishedAt.Valid {
+		t := finishedAt.Time
+		j.FinishedAt = &t
+	}
+	if nextAttemptAt.Valid {
+		t := nextAttemptAt.Time
+		j.NextAttemptAt = &t
+	}
+	if agentID.Valid {
+		id := agentID.Int64
+		j.AgentID = &id
+	}
+	if leaseExpiresAt.Valid {
+		t := leaseExpiresAt.Time
+		j.LeaseExpiresAt = &t
+	}
+	return &j, nil
+}
+
+// EnqueueScanJob 写入一条排队中的扫描任务，回填 ID、状态与创建时间。
+func (s *Store) EnqueueScanJob(ctx context.Context, j *models.ScanJob) (int64, error) {
+	now := time.Now().UTC()
+	err := s.DB.QueryRowContext(ctx,
+		`INSERT INTO scan_jobs (host_id, kind, priority, ports, status, created_at) VALUES (?, ?, ?, ?, ?, ?) RETURNING id`,
+		j.HostID, j.Kind, models.ScanPriorityRank(j.Priority), FormatPorts(j.Ports), models.ScanJobQueued, now,
+	).Scan(&j.ID)
+	if err != nil {
+		return 0, err
+	}
+	j.Priority = models.ScanPriorityName(models.ScanPriorityRank(j.Priority))
+	j.Status, j.CreatedAt = models.ScanJobQueued, now
+	return j.ID, nil
+}
+
+// PromoteScanJob 将主机排队中的某类任务提升到 priority（只升不降），返回是否存在排队中的任务。
+func (s *Store) PromoteScanJob(ctx context.Context, hostID int64, kind, priority string) (bool, error) {
+	rank := models.ScanPriorityRank(priority)
+	if _, err := s.DB.ExecContext(ctx, `UPDATE scan_jobs SET priority = ? WHERE host_id = ? AND kind = ? AND status = ? AND priority < ?`,
+		rank, hostID, kind, models.ScanJobQueued, rank); err != nil {
+		return false, err
+	}
+	var n int
+	err := s.DB.QueryRowContext(ctx, `SELECT COUNT(1) FROM scan_jobs WHERE host_id = ? AND kind = ? AND status = ?`,
+		hostID, kind, models.ScanJobQueued).Scan(&n)
+	return n > 0, err
+}
+
+// ClaimScanJob 为服务端实例 owner 领取一个本地执行的任务（主机未分配区域或 agent），没有可执行的任务时返回 nil。
+// 任务带租约，执行期间需由 RenewScanJobs 续约，否则由 ExpireScanLeases 收回。
+func (s *Store) ClaimScanJob(ctx context.Context, owner string, perHost int, until time.Time) (*models.ScanJob, error) {
+	return s.claimScanJob(ctx, perHost, `h.zone = '' AND h.agent_id IS NULL`, nil, nil, owner, until)
+}
+
+// LeaseScanJob 为 agent 领取一个任务：主机指定给该 agent，或未指定 agent 且区域与之相同。
+// 任务带租约，到期前需续约，否则由 ExpireScanLeases 收回。
+func (s *Store) LeaseScanJob(ctx context.Context, agent *models.Agent, perHost int, until time.Time) (*models.ScanJob, error) {
+	return s.claimScanJob(ctx, perHost,
+		`(h.agent_id = ? OR (h.agent_id IS NULL AND h.zone <> '' AND h.zone = ?))`, []interface{}{agent.ID, agent.Zone},
+		&agent.ID, "", until)
+}
+
+// claimScanJob 按优先级（同级按排队顺序）取出 scope 范围内的一个任务并标记为运行中、累加执行次数。
+// 已有 perHost 个任务在运行的主机与尚未到重试时间的任务暂不领取。
+// 多实例共用数据库时以条件更新抢占，失败则换下一条。
+func (s *Store) claimScanJob(ctx context.Context, perHost int, scope string, scopeArgs []interface{}, agentID *int64, owner string, until time.Time) (*models.ScanJob, error) {
+	if perHost <= 0 {
+		perHost = 1
+	}
+	for {
+		now := time.Now().UTC()
+		args := append([]interface{}{models.ScanJobQueued, now}, scopeArgs...)
+		args = append(args, models.ScanJobRunning, perHost)
+		job, err := scanScanJob(s.DB.QueryRowContext(ctx,
+			`SELECT `+scanJobColumns+` FROM scan_jobs j JOIN hosts h ON h.id = j.host_id
+			WHERE j.status = ? AND (j.next_attempt_at IS NULL OR j.next_attempt_at <= ?) AND `+scope+`
+			AND (SELECT COUNT(1) FROM scan_jobs r WHERE r.host_id = j.host_id AND r.status = ?) < ?
+			ORDER BY j.priority DESC, j.id ASC LIMIT 1`,
+			args...))
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		lease := until.UTC()
+		res, err := s.DB.ExecContext(ctx,
+			`UPDATE scan_jobs SET status = ?, started_at = ?, attempts = attempts + 1, next_attempt_at = NULL,
+			agent_id = ?, owner = ?, lease_expires_at = ? WHERE id = ? AND status = ?`,
+			models.ScanJobRunning, now, agentID, owner, lease, job.ID, models.ScanJobQueued)
+		if err != nil {
+			return nil, err
+		}
+		if n, _ := res.RowsAffected(); n == 1 {
+			job.Status, job.StartedAt, job.NextAttemptAt = models.ScanJobRunning, &now, nil
+			job.Attempts++
+			job.AgentID, job.Owner, job.LeaseExpiresAt = agentID, owner, &lease
+			return job, nil
+		}
+	}
+}
+
+// ExtendScanLease 延长 agent 持有的任务租约，租约已被收回或任务已结束时返回 false。
+func (s *Store) ExtendScanLease(ctx context.Context, jobID, agentID int64, until time.Time) (bool, error) {
+	res, err := s.DB.ExecContext(ctx,
+		`UPDATE scan_jobs SET lease_expires_at = ? WHERE id = ? AND agent_id = ? AND status = ? AND lease_expires_at IS NOT NULL`,
+		until.UTC(), jobID, agentID, models.ScanJobRunning)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n == 1, nil
+}
+
+// ReleaseScanLease 在 agent 提交结果时结束租约并返回任务，租约已被收回时返回 nil。
+// 结束租约后任务不会再被 ExpireScanLeases 收回，由调用方记录结果。
+func (s *Store) ReleaseScanLease(ctx context.Context, jobID, agentID int64) (*models.ScanJob, error) {
+	res, err := s.DB.ExecContext(ctx,
+		`UPDATE scan_jobs SET lease_expires_at = NULL WHERE id = ? AND agent_id = ? AND status = ? AND lease_expires_at IS NOT NULL`,
+		jobID, agentID, models.ScanJobRunning)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		return nil, nil
+	}
+	return scanScanJob(s.DB.QueryRowContext(ctx,
+		`SELECT `+scanJobColumns+` FROM scan_jobs j JOIN hosts h ON h.id = j.host_id WHERE j.id = ?`, jobID))
+}
+
+// RenewScanJobs 延长服务端实例 owner 正在执行的全部本地任务的租约，返回续约的任务数。
+// 租约已被收回的任务不再续约。
+func (s *Store) RenewScanJobs(ctx context.Context, owner string, until time.Time) (int64, error) {
+	res, err := s.DB.ExecContext(ctx,
+		`UPDATE scan_jobs SET lease_expires_at = ? WHERE owner = ? AND agent_id IS NULL AND status = ? AND lease_expires_at IS NOT NULL`,
+		until.UTC(), owner, models.ScanJobRunning)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// ReleaseScanJob 在本地执行结束时结束实例 owner 持有的租约，租约已被收回（任务可能已由其他实例重新执行）时返回 false，
+// 调用方应丢弃结果。
+func (s *Store) ReleaseScanJob(ctx context.Context, jobID int64, owner string) (bool, error) {
+	res, err := s.DB.ExecContext(ctx,
+		`UPDATE scan_jobs SET lease_expires_at = NULL WHERE id = ? AND owner = ? AND agent_id IS NULL AND status = ? AND lease_expires_at IS NOT NULL`,
+		jobID, owner, models.ScanJobRunning)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n == 1, nil
+}
+
+// ExpireScanLeases 收回 now 之前到期的租约（agent 与本地任务）并返回对应任务，由调用方按失败处理。
+func (s *Store) ExpireScanLeases(ctx context.Context, now time.Time) ([]models.ScanJob, error) {
+	candidates, err := s.ListScanJobs(ctx, ScanJobFilter{Status: models.ScanJobRunning, Limit: 500})
+	if err != nil {
+		return nil, err
+	}
+	now = now.UTC()
+	var expired []models.ScanJob
+	for _, job := range candidates {
+		if job.LeaseExpiresAt == nil || job.LeaseExpiresAt.After(now) {
+			continue
+		}
+		res, err := s.DB.ExecContext(ctx,
+			`UPDATE scan_jobs SET lease_expires_at = NULL WHERE id = ? AND status = ? AND lease_expires_at IS NOT NULL AND lease_expires_at <= ?`,
+			job.ID, models.ScanJobRunning, now)
+		if err != nil {
+			return expired, err
+		}
+		if n, _ := res.RowsAffected(); n == 1 {
+			expired = append(expired, job)
+		}
+	}
+	return expired, nil
+}
+
+// FinishScanJob 记录任务结束：errMsg 为空时为 done，否则为 failed，kind 为失败分类。
+func (s *Store) FinishScanJob(ctx context.Context, id int64, kind, errMsg string) error {
+	status := models.ScanJobDone
+	if errMsg != "" {
+		status = models.ScanJobFailed
+	}
+	_, err := s.DB.ExecContext(ctx, `UPDATE scan_jobs SET status = ?, error = ?, error_kind = ?, finished_at = ? WHERE id = ?`,
+		status, errMsg, kind, time.Now().UTC(), id)
+	return err
+}
+
+// RetryScanJob 将失败的任务重新排队，到 next 之后才会再次被领取（可能由其他 agent 执行）。
+func (s *Store) RetryScanJob(ctx context.Context, id int64, kind, errMsg string, next time.Time) error {
+	_, err := s.DB.ExecContext(ctx,
+		`UPDATE scan_jobs SET status = ?, error = ?, error_kind = ?, next_attempt_at = ?, started_at = NULL,
+		agent_id = NULL, owner = '', lease_expires_at = NULL WHERE id = ?`,
+		models.ScanJobQueued, errMsg, kind, next.UTC(), id)
+	return err
+}
+
+// RecoverScanJobs 在实例 owner 启动时恢复队列：该实例上次退出时运行中的本地任务与升级前未记录实例的任务重新排队，
+// 其他实例与 agent 持有的任务由租约到期处理；并清除没有未完成全范围任务的主机上残留的 scanning 标记。
+// 返回重新排队的任务数与清除的标记数。
+func (s *Store) RecoverScanJobs(ctx context.Context, owner string) (int64, int64, error) {
+	res, err := s.DB.ExecContext(ctx,
+		`UPDATE scan_jobs SET status = ?, started_at = NULL, owner = '', lease_expires_at = NULL
+		WHERE status = ? AND agent_id IS NULL AND (owner = ? OR (owner = '' AND lease_expires_at IS NULL))`,
+		models.ScanJobQueued, models.ScanJobRunning, owner)
+	if err != nil {
+		return 0, 0, err
+	}
+	requeued, _ := res.RowsAffected()
+	res, err = s.DB.ExecContext(ctx,
+		`UPDATE hosts SET scanning = 0 WHERE scanning = 1 AND id NOT IN (SELECT host_id FROM scan_jobs WHERE kind = ? AND status IN (?, ?))`,
+		models.ScanJobFull, models.ScanJobQueued, models.ScanJobRunning)
+	if err != nil {
+		return requeued, 0, err
+	}
+	reset, _ := res.RowsAffected()
+	return requeued, reset, nil
+}
+
+// ScanJobFilter 为扫描任务的查询条件，零值表示不过滤；Limit 默认 100。
+type ScanJobFilter struct {
+	Status string
+	HostID int64
+	Limit  int
+}
+
+// ListScanJobs 按条件列出扫描任务。排队中与运行中的任务按执行顺序返回，其余按时间倒序。
+func (s *Store) ListScanJobs(ctx context.Context, f ScanJobFilter) ([]models.ScanJob, error) {
+	var conds []string
+	var args []interface{}
+	if f.Status != "" {
+		conds = append(conds, `j.status = ?`)
+		args = append(args, f.Status)
+	}
+	if f.HostID > 0 {
+		conds = append(conds, `j.host_id = ?`)
+		args = append(args, f.HostID)
+	}
+	query := `SELECT ` + scanJobColumns + ` FROM scan_jobs j JOIN hosts h ON h.id = j.host_id`
+	if len(conds) > 0 {
+		query += ` WHERE ` + strings.Join(conds, " AND ")
+	}
+	if f.Limit <= 0 {
+		f.Limit = 100
+	}
+	switch f.Status {
+	case models.ScanJobQueued:
+		query += ` ORDER BY j.priority DESC, j.id ASC LIMIT ?`
+	case models.ScanJobRunning:
+		query += ` ORDER BY j.id ASC LIMIT ?`
+	default:
+		query += ` ORDER BY j.id DESC LIMIT ?`
+	}
+	args = append(args, f.Limit)
+
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []models.ScanJob{}
+	for rows.Next() {
+		j, err := scanScanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *j)
+	}
+	return list, rows.Err()
+}
+
+// CountScanJobs 返回指定状态的任务数。
+func (s *Store) CountScanJobs(ctx context.Context, status string) (int, error) {
+	var n int
+	err := s.DB.QueryRowContext(ctx, `SELECT COUNT(1) FROM scan_jobs WHERE status = ?`, status).Scan(&n)
+	return n, err
+}
+
+// PruneScanJobs 删除 before 之前结束的任务记录。
+func (s *Store) PruneScanJobs(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM scan_jobs WHERE finished_at < ? AND status IN (?, ?)`,
+		before.UTC(), models.ScanJobDone, models.ScanJobFailed)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
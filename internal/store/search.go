@@ -0,0 +1,273 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hitushen/portnotepro/internal/labels"
+	"github.com/hitushen/portnotepro/internal/models"
+)
+
+// qualifiedPortColumns 为带表名前缀的端口列，用于与 hosts 联表查询。
+var qualifiedPortColumns = "ports." + strings.ReplaceAll(portColumns, ", ", ", ports.")
+
+// conditions 将查询条件转换为 SQL 片段，列名均以 ports. 限定。
+func (q *PortQuery) conditions(d Dialect) ([]string, []interface{}, error) {
+	var conds []string
+	var args []interface{}
+	like := d.like()
+
+	if q.HostID > 0 {
+		conds = append(conds, `ports.host_id = ?`)
+		args = append(args, q.HostID)
+	}
+	if search := strings.TrimSpace(q.Search); search != "" {
+		// 端口号仍按子串匹配，备注与指纹走全文索引（每个词按前缀匹配）。
+		numberCond := `CAST(ports.number AS TEXT) ` + like + ` ?`
+		switch {
+		case d == DialectPostgres && tsQuery(search, true) != "":
+			conds = append(conds, `(`+numberCond+` OR to_tsvector('simple', ports.note || ' ' || ports.fingerprint) @@ to_tsquery('simple', ?))`)
+			args = append(args, "%"+search+"%", tsQuery(search, true))
+		case d == DialectSQLite && ftsQuery(search, true) != "":
+			conds = append(conds, `(`+numberCond+` OR ports.id IN (SELECT rowid FROM ports_fts WHERE ports_fts MATCH ?))`)
+			args = append(args, "%"+search+"%", "{note fingerprint} : ("+ftsQuery(search, true)+")")
+		default:
+			conds = append(conds, numberCond)
+			args = append(args, "%"+search+"%")
+		}
+	}
+	if status := strings.TrimSpace(q.Status); status != "" {
+		conds = append(conds, `ports.status = ?`)
+		args = append(args, status)
+	}
+	if q.Number > 0 {
+		conds = append(conds, `ports.number = ?`)
+		args = append(args, q.Number)
+	}
+	if q.PortMin > 0 {
+		conds = append(conds, `ports.number >= ?`)
+		args = append(args, q.PortMin)
+	}
+	if q.PortMax > 0 {
+		conds = append(conds, `ports.number <= ?`)
+		args = append(args, q.PortMax)
+	}
+	if fp := strings.TrimSpace(q.Fingerprint); fp != "" {
+		conds = append(conds, `ports.fingerprint `+like+` ?`)
+		args = append(args, "%"+fp+"%")
+	}
+	if note := strings.TrimSpace(q.Note); note != "" {
+		conds = append(conds, `ports.note `+like+` ?`)
+		args = append(args, "%"+note+"%")
+	}
+	if q.Hidden != nil {
+		conds = append(conds, `ports.hidden = ?`)
+		args = append(args, boolToInt(*q.Hidden))
+	}
+	if !q.CheckedAfter.IsZero() {
+		conds = append(conds, `ports.last_checked >= ?`)
+		args = append(args, q.CheckedAfter.UTC())
+	}
+	if !q.CheckedBefore.IsZero() {
+		conds = append(conds, `ports.last_checked <= ?`)
+		args = append(args, q.CheckedBefore.UTC())
+	}
+	where, labelArgs, err := labels.Compile(q.Labels, portLabelScope)
+	if err != nil {
+		return nil, nil, err
+	}
+	if where != "" {
+		conds = append(conds, where)
+		args = append(args, labelArgs...)
+	}
+	return conds, args, nil
+}
+
+// orderBy 返回排序表达式，未知字段按端口号排序。
+func (q *PortQuery) orderBy() string {
+	column := "ports.number"
+	switch strings.ToLower(q.SortBy) {
+	case "last_checked":
+		column = "ports.last_checked"
+	case "updated_at":
+		column = "ports.updated_at"
+	case "fingerprint":
+		column = "ports.fingerprint"
+	}
+	if q.SortDesc {
+		return column + " DESC"
+	}
+	return column + " ASC"
+}
+
+// SearchPorts 跨主机检索端口，分页与排序规则与 ListPortsWithQuery 一致，另支持按主机名排序（SortBy=host）。
+func (s *Store) SearchPorts(ctx context.Context, q *PortQuery) ([]models.PortMatch, int, error) {
+	query := &PortQuery{}
+	if q != nil {
+		*query = *q
+	}
+	if query.Page <= 0 {
+		query.Page = 1
+	}
+	if query.PageSize < 0 {
+		query.PageSize = 0
+	}
+
+	conds, args, err := query.conditions(s.dialect)
+	if err != nil {
+		return nil, 0, err
+	}
+	base := `FROM ports JOIN hosts h ON h.id = ports.host_id`
+	if len(conds) > 0 {
+		base += ` WHERE ` + strings.Join(conds, " AND ")
+	}
+
+	var total int
+	if err := s.DB.QueryRowContext(ctx, `SELECT COUNT(1) `+base, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	selectSQL := `SELECT ` + qualifiedPortColumns + `, h.name, h.address ` + base + ` ORDER BY ` + query.matchOrderBy()
+	if query.PageSize > 0 {
+		selectSQL += fmt.Sprintf(" LIMIT %d OFFSET %d", query.PageSize, (query.Page-1)*query.PageSize)
+	}
+
+	rows, err := s.DB.QueryContext(ctx, selectSQL, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var matches []models.PortMatch
+	for rows.Next() {
+		var m models.PortMatch
+		p, err := scanPort(rowWithExtra{rows, []interface{}{&m.HostName, &m.HostAddress}})
+		if err != nil {
+			return nil, 0, err
+		}
+		m.Port = *p
+		matches = append(matches, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	rows.Close()
+
+	ids := make([]int64, len(matches))
+	for i := range matches {
+		ids[i] = matches[i].ID
+	}
+	portLabels, err := s.portLabelsByID(ctx, ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range matches {
+		matches[i].Labels = labelsOrEmpty(portLabels[matches[i].ID])
+	}
+	return matches, total, nil
+}
+
+// matchOrderBy 为联表查询的排序表达式，在 orderBy 基础上支持按主机名排序。
+func (q *PortQuery) matchOrderBy() string {
+	if !strings.EqualFold(q.SortBy, "host") {
+		return q.orderBy()
+	}
+	if q.SortDesc {
+		return "h.name DESC, ports.number ASC"
+	}
+	return "h.name ASC, ports.number ASC"
+}
+
+// exportBatchSize 为 EachPortMatch 每批读取的行数。
+const exportBatchSize = 500
+
+// EachPortMatch 按 SearchPorts 的过滤与排序规则逐条回调全部匹配端口（忽略分页），fn 返回错误时停止。
+// 结果分批读取，每批读完即释放连接，回调中写出较慢时也不会长期占用数据库。
+func (s *Store) EachPortMatch(ctx context.Context, q *PortQuery, fn func(models.PortMatch) error) error {
+	query := &PortQuery{}
+	if q != nil {
+		*query = *q
+	}
+	conds, args, err := query.conditions(s.dialect)
+	if err != nil {
+		return err
+	}
+	base := `FROM ports JOIN hosts h ON h.id = ports.host_id`
+	if len(conds) > 0 {
+		base += ` WHERE ` + strings.Join(conds, " AND ")
+	}
+	// 追加 id 保证分批之间顺序稳定。
+	selectSQL := `SELECT ` + qualifiedPortColumns + `, h.name, h.address ` + base + ` ORDER BY ` + query.matchOrderBy() + `, ports.id ASC`
+
+	for offset := 0; ; offset += exportBatchSize {
+		batch, err := s.portMatchBatch(ctx, fmt.Sprintf("%s LIMIT %d OFFSET %d", selectSQL, exportBatchSize, offset), args)
+		if err != nil {
+			return err
+		}
+		for _, m := range batch {
+			if err := fn(m); err != nil {
+				return err
+			}
+		}
+		if len(batch) < exportBatchSize {
+			return nil
+		}
+	}
+}
+
+func (s *Store) portMatchBatch(ctx context.Context, selectSQL string, args []interface{}) ([]models.PortMatch, error) {
+	rows, err := s.DB.QueryContext(ctx, selectSQL, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []models.PortMatch
+	ids := []int64{}
+	for rows.Next() {
+		var m models.PortMatch
+		p, err := scanPort(rowWithExtra{rows, []interface{}{&m.HostName, &m.HostAddress}})
+		if err != nil {
+			return nil, err
+		}
+		m.Port = *p
+		matches = append(matches, m)
+		ids = append(ids, p.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	portLabels, err := s.portLabelsByID(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range matches {
+		matches[i].Labels = labelsOrEmpty(portLabels[matches[i].ID])
+	}
+	return matches, nil
+}
+
+// rowWithExtra 在 scanPort 的列之后追加额外的扫描目标。
+type rowWithExtra struct {
+	row   rowScanner
+	extra []interface{}
+}
+
+func (r rowWithExtra) Scan(dest ...interface{}) error {
+	return r.row.Scan(append(dest, r.extra...)...)
+}
+
+func (s *Store) portLabelsByID(ctx context.Context, ids []int64) (map[int64]map[string]string, error) {
+	if len(ids) == 0 {
+		return map[int64]map[string]string{}, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return s.labelsByOwner(ctx, `SELECT port_id, key, value FROM labels WHERE port_id IN (`+placeholders+`)`, args...)
+}
@@ -0,0 +1,201 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/hitushen/portnotepro/internal/models"
+)
+
+// Storage 为服务端与扫描器依赖的持久化接口，SQLite 与 PostgreSQL 均由 *Store 实现，
+// 两者的分页、检索与批量操作语义一致。按领域拆分为若干小接口，便于只依赖其中一部分。
+type Storage interface {
+	UserStore
+	HostStore
+	PortStore
+	GroupStore
+	LabelStore
+	BaselineStore
+	ScanJobStore
+	AgentStore
+	AlertStore
+	NotificationStore
+	InventoryStore
+	AdminStore
+}
+
+var _ Storage = (*Store)(nil)
+
+// UserStore 管理用户、登录校验与个人 API 令牌。
+type UserStore interface {
+	EnsureAdmin(ctx context.Context, username, password string) (bool, error)
+	Authenticate(ctx context.Context, username, password string) (*models.User, error)
+	ListUsers(ctx context.Context) ([]models.User, error)
+	CreateUser(ctx context.Context, username, password string) (int64, error)
+	SetUserPassword(ctx context.Context, username, password string) error
+	SetUserDisabled(ctx context.Context, username string, disabled bool) error
+	UserActive(ctx context.Context, id int64) (bool, error)
+	ListAPITokens(ctx context.Context, userID int64) ([]models.APIToken, error)
+	CreateAPIToken(ctx context.Context, userID int64, name, token string) (int64, error)
+	DeleteAPIToken(ctx context.Context, userID, id int64) (bool, error)
+	UserByAPIToken(ctx context.Context, token string) (*models.User, error)
+}
+
+// HostStore 管理主机、解析地址、扫描状态与扫描排除规则。
+type HostStore interface {
+	ListHosts(ctx context.Context) ([]models.Host, error)
+	ListHostsWithFilter(ctx context.Context, filter *HostFilter) ([]models.Host, error)
+	GetHost(ctx context.Context, id int64) (*models.Host, error)
+	CreateHost(ctx context.Context, name, address string, autoScan bool) (int64, error)
+	UpdateHost(ctx context.Context, id int64, name, address string, autoScan bool) error
+	DeleteHost(ctx context.Context, id int64) error
+	RecordHostAddresses(ctx context.Context, hostID int64, addresses []string, seenAt time.Time) (previous, added, removed []string, err error)
+	ListHostAddresses(ctx context.Context, hostID int64) ([]models.HostAddress, error)
+	BeginScan(ctx context.Context, hostID int64) (bool, error)
+	EndScan(ctx context.Context, hostID int64) error
+	RecordScanResult(ctx context.Context, hostID int64, at time.Time, kind, errMsg string) (int, error)
+	ListExclusions(ctx context.Context, hostID int64) ([]models.Exclusion, error)
+	ListAllExclusions(ctx context.Context) ([]models.Exclusion, error)
+	CreateExclusion(ctx context.Context, e models.Exclusion) (int64, error)
+	DeleteExclusion(ctx context.Context, id int64) error
+}
+
+// PortStore 管理端口、服务识别历史与检索。
+type PortStore interface {
+	ListPorts(ctx context.Context, hostID int64, includeHidden bool) ([]models.Port, error)
+	ListPortsWithQuery(ctx context.Context, hostID int64, includeHidden bool, q *PortQuery) ([]models.Port, int, error)
+	CreatePort(ctx context.Context, hostID int64, number int, note, fingerprint string, locked bool) (int64, error)
+	CreateDetectedPort(ctx context.Context, hostID int64, number int, note, service string, detectedAt time.Time) (int64, error)
+	DeletePort(ctx context.Context, portID int64) error
+	GetPort(ctx context.Context, portID int64) (*models.Port, error)
+	FindPortByNumber(ctx context.Context, hostID int64, number int) (*models.Port, error)
+	UpdatePortNote(ctx context.Context, portID int64, note, userFingerprint string, locked bool, fallback string) error
+	RecordDetectedService(ctx context.Context, portID int64, service string, detectedAt time.Time) (bool, error)
+	ListServiceHistory(ctx context.Context, portID int64, limit int) ([]models.ServiceChange, error)
+	SetPortHidden(ctx context.Context, portID int64, hidden bool) error
+	BulkSetHidden(ctx context.Context, hostID int64, start, end int, hidden bool) (int64, error)
+	BulkDeletePorts(ctx context.Context, hostID int64, start, end int) (int64, error)
+	UpdatePortStatus(ctx context.Context, portID int64, status string, t time.Time) error
+	RecordPortMiss(ctx context.Context, portID int64, t time.Time) (int, error)
+	SearchPorts(ctx context.Context, q *PortQuery) ([]models.PortMatch, int, error)
+	EachPortMatch(ctx context.Context, q *PortQuery, fn func(models.PortMatch) error) error
+	Search(ctx context.Context, input string, limit int) (*models.SearchResults, error)
+	RebuildSearchIndex(ctx context.Context) error
+}
+
+// GroupStore 管理主机分组与分组级操作。
+type GroupStore interface {
+	ListGroups(ctx context.Context) ([]models.HostGroup, error)
+	GetGroup(ctx context.Context, id int64) (*models.HostGroup, error)
+	CreateGroup(ctx context.Context, name, description string, parentID int64) (int64, error)
+	UpdateGroup(ctx context.Context, id int64, name, description string, parentID int64) error
+	DeleteGroup(ctx context.Context, id int64) error
+	AddGroupMembers(ctx context.Context, groupID int64, hostIDs []int64) (int64, error)
+	RemoveGroupMember(ctx context.Context, groupID, hostID int64) error
+	GroupHostIDs(ctx context.Context, groupID int64, recursive bool) ([]int64, error)
+	BulkSetHiddenForGroup(ctx context.Context, groupID int64, start, end int, hidden bool) (map[int64]int64, error)
+	GroupStats(ctx context.Context, groupID int64) (*models.GroupStats, error)
+	OpenPortCounts(ctx context.Context) (map[int64]int, error)
+}
+
+// LabelStore 管理主机与端口的键值标签。
+type LabelStore interface {
+	HostLabels(ctx context.Context, hostID int64) (map[string]string, error)
+	PortLabels(ctx context.Context, portID int64) (map[string]string, error)
+	SetHostLabels(ctx context.Context, hostID int64, values map[string]string) error
+	SetPortLabels(ctx context.Context, portID int64, values map[string]string) error
+}
+
+// BaselineStore 管理端口基线与合规评估。
+type BaselineStore interface {
+	GetBaseline(ctx context.Context, hostID, groupID int64) (*models.PortBaseline, error)
+	ListBaselines(ctx context.Context) ([]models.PortBaseline, error)
+	SetBaseline(ctx context.Context, hostID, groupID int64, spec string) (*models.PortBaseline, error)
+	DeleteBaseline(ctx context.Context, hostID, groupID int64) error
+	EvaluateCompliance(ctx context.Context, hostID int64) (*models.HostCompliance, string, bool, error)
+	ComplianceReport(ctx context.Context, status string) (*models.ComplianceReport, error)
+}
+
+// ScanJobStore 管理持久化的扫描任务队列与任务租约。
+type ScanJobStore interface {
+	EnqueueScanJob(ctx context.Context, j *models.ScanJob) (int64, error)
+	PromoteScanJob(ctx context.Context, hostID int64, kind, priority string) (bool, error)
+	ClaimScanJob(ctx context.Context, owner string, perHost int, until time.Time) (*models.ScanJob, error)
+	LeaseScanJob(ctx context.Context, agent *models.Agent, perHost int, until time.Time) (*models.ScanJob, error)
+	ExtendScanLease(ctx context.Context, jobID, agentID int64, until time.Time) (bool, error)
+	ReleaseScanLease(ctx context.Context, jobID, agentID int64) (*models.ScanJob, error)
+	RenewScanJobs(ctx context.Context, owner string, until time.Time) (int64, error)
+	ReleaseScanJob(ctx context.Context, jobID int64, owner string) (bool, error)
+	ExpireScanLeases(ctx context.Context, now time.Time) ([]models.ScanJob, error)
+	FinishScanJob(ctx context.Context, id int64, kind, errMsg string) error
+	RetryScanJob(ctx context.Context, id int64, kind, errMsg string, next time.Time) error
+	RecoverScanJobs(ctx context.Context, owner string) (int64, int64, error)
+	ListScanJobs(ctx context.Context, f ScanJobFilter) ([]models.ScanJob, error)
+	CountScanJobs(ctx context.Context, status string) (int, error)
+	PruneScanJobs(ctx context.Context, before time.Time) (int64, error)
+}
+
+// AgentStore 管理远程扫描 agent 与主机的分配。
+type AgentStore interface {
+	ListAgents(ctx context.Context) ([]models.Agent, error)
+	GetAgent(ctx context.Context, id int64) (*models.Agent, error)
+	AgentByToken(ctx context.Context, token string) (*models.Agent, error)
+	CreateAgent(ctx context.Context, name, zone, token string) (int64, error)
+	UpdateAgent(ctx context.Context, a *models.Agent) error
+	RotateAgentToken(ctx context.Context, id int64, token string) error
+	DeleteAgent(ctx context.Context, id int64) error
+	TouchAgent(ctx context.Context, id int64, version, hostname, remoteAddr string, at time.Time) error
+	SetHostAssignment(ctx context.Context, hostID int64, zone string, agentID int64) error
+}
+
+// AlertStore 管理通知目标、告警规则与投递记录。
+type AlertStore interface {
+	ListAlertTargets(ctx context.Context) ([]models.AlertTarget, error)
+	GetAlertTarget(ctx context.Context, id int64) (*models.AlertTarget, error)
+	CreateAlertTarget(ctx context.Context, t *models.AlertTarget) (int64, error)
+	UpdateAlertTarget(ctx context.Context, t *models.AlertTarget) error
+	DeleteAlertTarget(ctx context.Context, id int64) error
+	ListAlertRules(ctx context.Context) ([]models.AlertRule, error)
+	ActiveAlertRules(ctx context.Context) ([]models.AlertRule, error)
+	GetAlertRule(ctx context.Context, id int64) (*models.AlertRule, error)
+	CreateAlertRule(ctx context.Context, r *models.AlertRule) (int64, error)
+	UpdateAlertRule(ctx context.Context, r *models.AlertRule) error
+	DeleteAlertRule(ctx context.Context, id int64) error
+	ListAlertDeliveries(ctx context.Context, f DeliveryFilter) ([]models.AlertDelivery, error)
+	GetAlertDelivery(ctx context.Context, id int64) (*models.AlertDelivery, error)
+	EnqueueAlertDelivery(ctx context.Context, d *models.AlertDelivery) (int64, error)
+	LastAlertDeliveryAt(ctx context.Context, ruleID int64, dedupKey string) (time.Time, bool, error)
+	DueAlertDeliveries(ctx context.Context, now time.Time, limit int) ([]models.AlertDelivery, error)
+	ClaimAlertDelivery(ctx context.Context, id int64) (bool, error)
+	FinishAlertDelivery(ctx context.Context, d *models.AlertDelivery) error
+	RetryAlertDelivery(ctx context.Context, id int64) (bool, error)
+	ResetSendingAlertDeliveries(ctx context.Context) error
+	PruneAlertDeliveries(ctx context.Context, before time.Time) (int64, error)
+}
+
+// NotificationStore 管理告警事件记录与用户的摘要订阅。
+type NotificationStore interface {
+	RecordAlertEvent(ctx context.Context, e *models.AlertEvent) error
+	AlertEventsBetween(ctx context.Context, since, until time.Time) ([]models.AlertEvent, error)
+	PruneAlertEvents(ctx context.Context, before time.Time) (int64, error)
+	GetSubscription(ctx context.Context, userID int64) (*models.NotificationSubscription, error)
+	SetSubscription(ctx context.Context, sub *models.NotificationSubscription) error
+	DeleteSubscription(ctx context.Context, userID int64) error
+	DigestSubscriptions(ctx context.Context) ([]models.NotificationSubscription, error)
+	MarkDigestSent(ctx context.Context, userID int64, until time.Time) error
+}
+
+// InventoryStore 导出与导入主机清单。
+type InventoryStore interface {
+	ExportInventory(ctx context.Context) (*models.Inventory, error)
+	ImportInventory(ctx context.Context, inv *models.Inventory, opts ImportOptions) (*models.ImportReport, error)
+}
+
+// AdminStore 为后端信息、结构迁移与备份等运维操作。
+type AdminStore interface {
+	Dialect() Dialect
+	SchemaVersion(ctx context.Context) (int, error)
+	MigrationStatus(ctx context.Context) ([]MigrationState, error)
+	Backup(ctx context.Context, dest string) error
+	Close() error
+}
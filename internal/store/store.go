@@ -12,43 +12,83 @@ import (
 
 	_ "modernc.org/sqlite"
 
+	"github.com/hitushen/portnotepro/internal/labels"
 	"github.com/hitushen/portnotepro/internal/models"
+	"github.com/hitushen/portnotepro/internal/services/fingerprint"
 	"github.com/hitushen/portnotepro/internal/targets"
 	"golang.org/x/crypto/bcrypt"
 )
 
-// Store 封装了对 SQLite 数据库的持久化访问。
+// Store 封装了对 SQLite 或 PostgreSQL 数据库的持久化访问。
 type Store struct {
-	DB *sql.DB
+	DB      *sql.DB
+	dialect Dialect
 }
 
 // PortQuery 用于列举端口时提供可选过滤条件。
 type PortQuery struct {
-	Search   string
-	Status   string
-	SortBy   string
-	SortDesc bool
-	Page     int
-	PageSize int
+	// HostID 非 0 时仅查询该主机的端口。
+	HostID int64
+	Search string
+	Status string
+	// Labels 为标签查询表达式，如 `owner=payments AND status=open`。
+	Labels string
+	// Number 精确匹配端口号；PortMin/PortMax 限定端口范围，为 0 表示不限制。
+	Number  int
+	PortMin int
+	PortMax int
+	// Fingerprint 与 Note 为包含匹配（不区分大小写）。
+	Fingerprint string
+	Note        string
+	// Hidden 为 nil 时不按隐藏标记过滤。
+	Hidden *bool
+	// CheckedAfter/CheckedBefore 限定最近检测时间窗口。
+	CheckedAfter  time.Time
+	CheckedBefore time.Time
+	SortBy        string
+	SortDesc      bool
+	Page          int
+	PageSize      int
 }
 
-// New 根据给定的 SQLite 文件路径初始化 Store。
-func New(dbPath string) (*Store, error) {
-	if err := os.MkdirAll(filepath.Dir(dbPath), 0o755); err != nil {
+// New 打开数据库并执行全部待应用的结构迁移。dsn 为 SQLite 文件路径或 postgres:// 连接串。
+func New(dsn string) (*Store, error) {
+	s, err := Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.Migrate(context.Background()); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Open 打开数据库但不执行迁移，供迁移命令查看状态使用。
+func Open(dsn string) (*Store, error) {
+	if DialectForDSN(dsn) == DialectPostgres {
+		db, err := sql.Open(postgresDriverName, dsn)
+		if err != nil {
+			return nil, fmt.Errorf("open db: %w", err)
+		}
+		if err := db.Ping(); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("connect db: %w", err)
+		}
+		return &Store{DB: db, dialect: DialectPostgres}, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dsn), 0o755); err != nil {
 		return nil, fmt.Errorf("create db dir: %w", err)
 	}
 
-	db, err := sql.Open("sqlite", dbPath)
+	// 启用外键约束，确保删除主机或端口时级联清理关联记录。
+	db, err := sql.Open("sqlite", dsn+"?_pragma=foreign_keys(1)")
 	if err != nil {
 		return nil, fmt.Errorf("open db: %w", err)
 	}
 	db.SetMaxOpenConns(1) // SQLite 更适合单写入，这里保持简单配置。
-
-	s := &Store{DB: db}
-	if err := s.migrate(); err != nil {
-		return nil, err
-	}
-	return s, nil
+	return &Store{DB: db, dialect: DialectSQLite}, nil
 }
 
 // Close 释放数据库资源。
@@ -56,7 +96,9 @@ func (s *Store) Close() error {
 	return s.DB.Close()
 }
 
-func (s *Store) migrate() error {
+// migrateBaseline 为 0001 号迁移：建立引入版本化迁移之前的完整结构。
+// 语句均可重复执行，旧库缺失的列通过 ensureColumn 补齐，因此既适用于新库也适用于历史数据库。
+func migrateBaseline(ctx context.Context, tx *sql.Tx) error {
 	schema := []string{
 		`CREATE TABLE IF NOT EXISTS users (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -86,77 +128,165 @@ func (s *Store) migrate() error {
 			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
 			UNIQUE(host_id, number)
 		);`,
+		`CREATE TABLE IF NOT EXISTS port_service_history (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			port_id INTEGER NOT NULL REFERENCES ports(id) ON DELETE CASCADE,
+			previous TEXT NOT NULL DEFAULT '',
+			service TEXT NOT NULL DEFAULT '',
+			detected_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_port_service_history_port ON port_service_history(port_id, detected_at);`,
+		`CREATE TABLE IF NOT EXISTS host_addresses (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			host_id INTEGER NOT NULL REFERENCES hosts(id) ON DELETE CASCADE,
+			address TEXT NOT NULL,
+			active INTEGER NOT NULL DEFAULT 1,
+			first_seen TIMESTAMP NOT NULL,
+			last_seen TIMESTAMP NOT NULL,
+			UNIQUE(host_id, address)
+		);`,
+		`CREATE TABLE IF NOT EXISTS host_groups (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			parent_id INTEGER REFERENCES host_groups(id) ON DELETE SET NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_host_groups_name ON host_groups(name);`,
+		`CREATE TABLE IF NOT EXISTS host_group_members (
+			group_id INTEGER NOT NULL REFERENCES host_groups(id) ON DELETE CASCADE,
+			host_id INTEGER NOT NULL REFERENCES hosts(id) ON DELETE CASCADE,
+			PRIMARY KEY (group_id, host_id)
+		);`,
+		`CREATE TABLE IF NOT EXISTS scan_exclusions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			host_id INTEGER REFERENCES hosts(id) ON DELETE CASCADE,
+			kind TEXT NOT NULL,
+			value TEXT NOT NULL,
+			note TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);`,
+		`CREATE TABLE IF NOT EXISTS labels (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			host_id INTEGER NOT NULL REFERENCES hosts(id) ON DELETE CASCADE,
+			port_id INTEGER REFERENCES ports(id) ON DELETE CASCADE,
+			key TEXT NOT NULL,
+			value TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_labels_host ON labels(host_id, key) WHERE port_id IS NULL;`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_labels_port ON labels(port_id, key) WHERE port_id IS NOT NULL;`,
 	}
 	for _, stmt := range schema {
-		if _, err := s.DB.Exec(stmt); err != nil {
-			return fmt.Errorf("migrate: %w", err)
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
 		}
 	}
-	if err := s.ensureHostColumns(); err != nil {
-		return err
+	columns := []struct {
+		table, name, ddl string
+	}{
+		{"hosts", "scanning", `INTEGER NOT NULL DEFAULT 0`},
+		{"ports", "detected_service", `TEXT NOT NULL DEFAULT ''`},
+		{"ports", "user_fingerprint", `TEXT NOT NULL DEFAULT ''`},
+		{"ports", "fingerprint_locked", `INTEGER NOT NULL DEFAULT 0`},
+	}
+	for _, col := range columns {
+		if err := ensureColumn(ctx, tx, col.table, col.name, col.ddl); err != nil {
+			return fmt.Errorf("%s.%s: %w", col.table, col.name, err)
+		}
 	}
 	return nil
 }
 
-func (s *Store) ensureHostColumns() error {
-	rows, err := s.DB.Query(`PRAGMA table_info(hosts)`)
+// ensureColumn 在旧版数据库缺少字段时补齐列定义。
+func ensureColumn(ctx context.Context, tx *sql.Tx, table, column, ddl string) error {
+	rows, err := tx.QueryContext(ctx, `PRAGMA table_info(`+table+`)`)
 	if err != nil {
 		return err
 	}
-	defer rows.Close()
-
+	found := false
 	for rows.Next() {
 		var cid int
 		var name, ctype string
 		var notnull, pk int
 		var dflt sql.NullString
 		if err := rows.Scan(&cid, &name, &ctype, &notnull, &dflt, &pk); err != nil {
+			rows.Close()
 			return err
 		}
-		if strings.EqualFold(name, "scanning") {
-			return nil
+		if strings.EqualFold(name, column) {
+			found = true
 		}
 	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if found {
+		return nil
+	}
 
-	_, err = s.DB.Exec(`ALTER TABLE hosts ADD COLUMN scanning INTEGER NOT NULL DEFAULT 0`)
+	_, err = tx.ExecContext(ctx, `ALTER TABLE `+table+` ADD COLUMN `+column+` `+ddl)
 	return err
 }
 
-// EnsureAdmin 根据给定凭证创建或更新管理员账号，确保其存在。
-func (s *Store) EnsureAdmin(ctx context.Context, username, password string) error {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return fmt.Errorf("hash password: %w", err)
-	}
-
-	tx, err := s.DB.BeginTx(ctx, nil)
+// migrateUserFingerprints 将升级前人工修改过的指纹转为锁定的人工指纹。
+// 早期版本只有 fingerprint 一列，与端口默认名称不同的值视为人工标注；
+// 已有扫描结果或人工指纹的端口保持不变。
+func migrateUserFingerprints(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id, number, fingerprint FROM ports
+		WHERE fingerprint_locked = 0 AND user_fingerprint = '' AND detected_service = '' AND fingerprint != ''`)
 	if err != nil {
 		return err
 	}
-	defer func() { _ = tx.Rollback() }()
-
-	var existingID int64
-	err = tx.QueryRowContext(ctx, `SELECT id FROM users WHERE username = ?`, username).Scan(&existingID)
-	if errors.Is(err, sql.ErrNoRows) {
-		if _, err := tx.ExecContext(ctx, `INSERT INTO users (username, password_hash) VALUES (?, ?)`, username, string(hash)); err != nil {
-			return fmt.Errorf("create admin: %w", err)
+	edited := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var number int
+		var fp string
+		if err := rows.Scan(&id, &number, &fp); err != nil {
+			rows.Close()
+			return err
 		}
-	} else if err == nil {
-		if _, err := tx.ExecContext(ctx, `UPDATE users SET password_hash = ?, created_at = CURRENT_TIMESTAMP WHERE id = ?`, string(hash), existingID); err != nil {
-			return fmt.Errorf("update admin: %w", err)
+		if fp != fingerprint.NameForPort(number) {
+			edited[id] = fp
 		}
-	} else {
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
 		return err
 	}
+	for id, fp := range edited {
+		if _, err := tx.ExecContext(ctx, `UPDATE ports SET user_fingerprint = ?, fingerprint_locked = 1 WHERE id = ?`, fp, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	return tx.Commit()
+// EnsureAdmin 在尚无任何用户时按给定凭证创建管理员，返回是否新建。已有用户时不做任何修改，
+// 之后的密码变更通过 `server user passwd` 完成。
+func (s *Store) EnsureAdmin(ctx context.Context, username, password string) (bool, error) {
+	var count int
+	if err := s.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM users`).Scan(&count); err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return false, nil
+	}
+	if _, err := s.CreateUser(ctx, username, password); err != nil {
+		return false, fmt.Errorf("create admin: %w", err)
+	}
+	return true, nil
 }
 
-// Authenticate 校验登录凭证，成功时返回用户 ID。
+// Authenticate 校验登录凭证，成功时返回用户。停用的用户与密码错误返回相同的错误。
 func (s *Store) Authenticate(ctx context.Context, username, password string) (*models.User, error) {
 	var user models.User
-	err := s.DB.QueryRowContext(ctx, `SELECT id, username, password_hash, created_at FROM users WHERE username = ?`, username).
-		Scan(&user.ID, &user.Username, &user.PasswordHash, &user.CreatedAt)
+	var disabled int
+	err := s.DB.QueryRowContext(ctx, `SELECT id, username, password_hash, disabled, created_at FROM users WHERE username = ?`, username).
+		Scan(&user.ID, &user.Username, &user.PasswordHash, &disabled, &user.CreatedAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, errors.New("invalid credentials")
@@ -164,7 +294,7 @@ func (s *Store) Authenticate(ctx context.Context, username, password string) (*m
 		return nil, err
 	}
 
-	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+	if disabled == 1 || bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
 		return nil, errors.New("invalid credentials")
 	}
 	return &user, nil
@@ -172,66 +302,246 @@ func (s *Store) Authenticate(ctx context.Context, username, password string) (*m
 
 // ListHosts 按名称排序返回全部主机记录。
 func (s *Store) ListHosts(ctx context.Context) ([]models.Host, error) {
-rows, err := s.DB.QueryContext(ctx, `
+	return s.ListHostsWithFilter(ctx, nil)
+}
+
+// ListHostsWithFilter 按名称排序返回主机，可按分组过滤（默认包含子分组成员）。
+func (s *Store) ListHostsWithFilter(ctx context.Context, filter *HostFilter) ([]models.Host, error) {
+	query := `
         SELECT 
             h.id, h.name, h.address, h.auto_scan, h.scanning, h.created_at, h.updated_at,
+            h.last_scan_at, h.last_scan_error, h.last_scan_error_kind, h.consecutive_failures, h.zone, h.agent_id,
             (SELECT COUNT(1) FROM ports p WHERE p.host_id = h.id AND p.hidden = 0) AS open_count,
             (SELECT COUNT(1) FROM ports p WHERE p.host_id = h.id AND p.hidden = 1) AS hidden_count
-        FROM hosts h
-        ORDER BY h.name ASC`)
+        FROM hosts h`
+	var args []interface{}
+	var conds []string
+	if filter != nil && filter.GroupID > 0 {
+		if filter.Direct {
+			conds = append(conds, `h.id IN (SELECT host_id FROM host_group_members WHERE group_id = ?)`)
+		} else {
+			query = groupTreeCTE + query
+			conds = append(conds, `h.id IN (SELECT host_id FROM host_group_members WHERE group_id IN (SELECT id FROM group_tree))`)
+		}
+		args = append(args, filter.GroupID)
+	}
+	if filter != nil {
+		where, labelArgs, err := labels.Compile(filter.Labels, hostLabelScope)
+		if err != nil {
+			return nil, err
+		}
+		if where != "" {
+			conds = append(conds, where)
+			args = append(args, labelArgs...)
+		}
+	}
+	if len(conds) > 0 {
+		query += ` WHERE ` + strings.Join(conds, " AND ")
+	}
+	query += ` ORDER BY h.name ASC`
+
+	rows, err := s.DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
 	var hosts []models.Host
 	for rows.Next() {
 		var h models.Host
 		var autoScan, scanning int
-		if err := rows.Scan(&h.ID, &h.Name, &h.Address, &autoScan, &scanning, &h.CreatedAt, &h.UpdatedAt, &h.OpenCount, &h.HiddenCount); err != nil {
+		var lastScanAt sql.NullTime
+		var agentID sql.NullInt64
+		if err := rows.Scan(&h.ID, &h.Name, &h.Address, &autoScan, &scanning, &h.CreatedAt, &h.UpdatedAt,
+			&lastScanAt, &h.LastScanError, &h.LastScanErrorKind, &h.ConsecutiveFailures, &h.Zone, &agentID, &h.OpenCount, &h.HiddenCount); err != nil {
+			rows.Close()
 			return nil, err
 		}
 		h.Address = targets.Normalize(h.Address)
 		h.AutoScan = autoScan == 1
 		h.Scanning = scanning == 1
+		if lastScanAt.Valid {
+			t := lastScanAt.Time
+			h.LastScanAt = &t
+		}
+		if agentID.Valid {
+			id := agentID.Int64
+			h.AgentID = &id
+		}
 		hosts = append(hosts, h)
 	}
-	return hosts, rows.Err()
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	groups, err := s.hostGroupIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	hostLabels, err := s.hostLabelMap(ctx)
+	if err != nil {
+		return nil, err
+	}
+	compliance, err := s.complianceStatuses(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range hosts {
+		hosts[i].GroupIDs = groups[hosts[i].ID]
+		hosts[i].Labels = labelsOrEmpty(hostLabels[hosts[i].ID])
+		hosts[i].Compliance = compliance[hosts[i].ID]
+	}
+	return hosts, nil
 }
 
 // GetHost 根据 ID 获取主机信息。
 func (s *Store) GetHost(ctx context.Context, id int64) (*models.Host, error) {
 	var h models.Host
 	var autoScan, scanning int
+	var lastScanAt sql.NullTime
+	var agentID sql.NullInt64
 err := s.DB.QueryRowContext(ctx, `
         SELECT 
             id, name, address, auto_scan, scanning, created_at, updated_at,
+            last_scan_at, last_scan_error, last_scan_error_kind, consecutive_failures, zone, agent_id,
             (SELECT COUNT(1) FROM ports p WHERE p.host_id = hosts.id AND p.hidden = 0) AS open_count,
             (SELECT COUNT(1) FROM ports p WHERE p.host_id = hosts.id AND p.hidden = 1) AS hidden_count
         FROM hosts WHERE id = ?`, id).
-        Scan(&h.ID, &h.Name, &h.Address, &autoScan, &scanning, &h.CreatedAt, &h.UpdatedAt, &h.OpenCount, &h.HiddenCount)
+        Scan(&h.ID, &h.Name, &h.Address, &autoScan, &scanning, &h.CreatedAt, &h.UpdatedAt,
+            &lastScanAt, &h.LastScanError, &h.LastScanErrorKind, &h.ConsecutiveFailures, &h.Zone, &agentID, &h.OpenCount, &h.HiddenCount)
 	if err != nil {
 		return nil, err
 	}
 	h.Address = targets.Normalize(h.Address)
 	h.AutoScan = autoScan == 1
 	h.Scanning = scanning == 1
+	if lastScanAt.Valid {
+		t := lastScanAt.Time
+		h.LastScanAt = &t
+	}
+	if agentID.Valid {
+		id := agentID.Int64
+		h.AgentID = &id
+	}
+	if h.Labels, err = s.HostLabels(ctx, h.ID); err != nil {
+		return nil, err
+	}
+	err = s.DB.QueryRowContext(ctx, `SELECT status FROM host_compliance WHERE host_id = ?`, h.ID).Scan(&h.Compliance)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
 	return &h, nil
 }
 
 // CreateHost 创建新的主机记录。
 func (s *Store) CreateHost(ctx context.Context, name, address string, autoScan bool) (int64, error) {
-    res, err := s.DB.ExecContext(ctx, `INSERT INTO hosts (name, address, auto_scan, scanning) VALUES (?, ?, ?, 0)`, name, address, boolToInt(autoScan))
+	var id int64
+	err := s.DB.QueryRowContext(ctx, `INSERT INTO hosts (name, address, auto_scan, scanning) VALUES (?, ?, ?, 0) RETURNING id`, name, address, boolToInt(autoScan)).Scan(&id)
+	return id, err
+}
+
+// UpdateHost 更新主机字段；地址变更时清空旧地址的解析记录。
+func (s *Store) UpdateHost(ctx context.Context, id int64, name, address string, autoScan bool) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
 	if err != nil {
-		return 0, err
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var previous string
+	if err := tx.QueryRowContext(ctx, `SELECT address FROM hosts WHERE id = ?`, id).Scan(&previous); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
 	}
-	return res.LastInsertId()
+	if _, err := tx.ExecContext(ctx, `UPDATE hosts SET name = ?, address = ?, auto_scan = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, name, address, boolToInt(autoScan), id); err != nil {
+		return err
+	}
+	if targets.Normalize(previous) != targets.Normalize(address) {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM host_addresses WHERE host_id = ?`, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
-// UpdateHost 更新主机字段。
-func (s *Store) UpdateHost(ctx context.Context, id int64, name, address string, autoScan bool) error {
-	_, err := s.DB.ExecContext(ctx, `UPDATE hosts SET name = ?, address = ?, auto_scan = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, name, address, boolToInt(autoScan), id)
-	return err
+// RecordHostAddresses 写入一次成功的 DNS 解析结果，返回相对上次解析新增与消失的地址。
+// 首次解析时 previous 为空，调用方可据此区分初始记录与真正的变化。
+func (s *Store) RecordHostAddresses(ctx context.Context, hostID int64, addresses []string, seenAt time.Time) (previous, added, removed []string, err error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	rows, err := tx.QueryContext(ctx, `SELECT address FROM host_addresses WHERE host_id = ? AND active = 1 ORDER BY address`, hostID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	active := make(map[string]struct{})
+	for rows.Next() {
+		var addr string
+		if err := rows.Scan(&addr); err != nil {
+			rows.Close()
+			return nil, nil, nil, err
+		}
+		active[addr] = struct{}{}
+		previous = append(previous, addr)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	ts := seenAt.UTC()
+	current := make(map[string]struct{}, len(addresses))
+	for _, addr := range addresses {
+		current[addr] = struct{}{}
+		if _, ok := active[addr]; !ok {
+			added = append(added, addr)
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO host_addresses (host_id, address, active, first_seen, last_seen) VALUES (?, ?, 1, ?, ?)
+			ON CONFLICT(host_id, address) DO UPDATE SET active = 1, last_seen = excluded.last_seen`,
+			hostID, addr, ts, ts,
+		); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	for _, addr := range previous {
+		if _, ok := current[addr]; ok {
+			continue
+		}
+		removed = append(removed, addr)
+		if _, err := tx.ExecContext(ctx, `UPDATE host_addresses SET active = 0 WHERE host_id = ? AND address = ?`, hostID, addr); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, nil, err
+	}
+	return previous, added, removed, nil
+}
+
+// ListHostAddresses 返回主机的历史解析地址，当前有效的地址排在前面。
+func (s *Store) ListHostAddresses(ctx context.Context, hostID int64) ([]models.HostAddress, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT address, active, first_seen, last_seen FROM host_addresses WHERE host_id = ? ORDER BY active DESC, last_seen DESC, address ASC`,
+		hostID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var addrs []models.HostAddress
+	for rows.Next() {
+		var a models.HostAddress
+		var active int
+		if err := rows.Scan(&a.Address, &active, &a.FirstSeen, &a.LastSeen); err != nil {
+			return nil, err
+		}
+		a.Active = active == 1
+		addrs = append(addrs, a)
+	}
+	return addrs, rows.Err()
 }
 
 // DeleteHost 删除主机及其关联端口。
@@ -257,6 +567,81 @@ func (s *Store) EndScan(ctx context.Context, hostID int64) error {
 	return err
 }
 
+// RecordScanResult 记录主机最近一次扫描的结果：kind 为空表示成功并清零连续失败次数，
+// 否则保存失败分类与原因并累加。返回记录后的连续失败次数。
+func (s *Store) RecordScanResult(ctx context.Context, hostID int64, at time.Time, kind, errMsg string) (int, error) {
+	var err error
+	if kind == "" {
+		_, err = s.DB.ExecContext(ctx,
+			`UPDATE hosts SET last_scan_at = ?, last_scan_error = '', last_scan_error_kind = '', consecutive_failures = 0 WHERE id = ?`,
+			at.UTC(), hostID)
+	} else {
+		_, err = s.DB.ExecContext(ctx,
+			`UPDATE hosts SET last_scan_at = ?, last_scan_error = ?, last_scan_error_kind = ?, consecutive_failures = consecutive_failures + 1 WHERE id = ?`,
+			at.UTC(), errMsg, kind, hostID)
+	}
+	if err != nil {
+		return 0, err
+	}
+	var failures int
+	err = s.DB.QueryRowContext(ctx, `SELECT consecutive_failures FROM hosts WHERE id = ?`, hostID).Scan(&failures)
+	return failures, err
+}
+
+// ListExclusions 返回全局排除规则；hostID 大于 0 时一并返回该主机的规则。
+func (s *Store) ListExclusions(ctx context.Context, hostID int64) ([]models.Exclusion, error) {
+	return s.queryExclusions(ctx, `WHERE host_id IS NULL OR host_id = ?`, hostID)
+}
+
+// ListAllExclusions 返回全部排除规则。
+func (s *Store) ListAllExclusions(ctx context.Context) ([]models.Exclusion, error) {
+	return s.queryExclusions(ctx, ``)
+}
+
+func (s *Store) queryExclusions(ctx context.Context, where string, args ...interface{}) ([]models.Exclusion, error) {
+	rows, err := s.DB.QueryContext(ctx, `SELECT id, host_id, kind, value, note, created_at FROM scan_exclusions `+where+` ORDER BY host_id IS NOT NULL, host_id, id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []models.Exclusion
+	for rows.Next() {
+		var e models.Exclusion
+		var hostID sql.NullInt64
+		if err := rows.Scan(&e.ID, &hostID, &e.Kind, &e.Value, &e.Note, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if hostID.Valid {
+			e.HostID = hostID.Int64
+		}
+		result = append(result, e)
+	}
+	return result, rows.Err()
+}
+
+// CreateExclusion 新增排除规则，HostID 为 0 表示全局规则。
+func (s *Store) CreateExclusion(ctx context.Context, e models.Exclusion) (int64, error) {
+	var id int64
+	err := s.DB.QueryRowContext(ctx,
+		`INSERT INTO scan_exclusions (host_id, kind, value, note) VALUES (?, ?, ?, ?) RETURNING id`,
+		nullableID(e.HostID), e.Kind, e.Value, e.Note,
+	).Scan(&id)
+	return id, err
+}
+
+// DeleteExclusion 删除排除规则。
+func (s *Store) DeleteExclusion(ctx context.Context, id int64) error {
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM scan_exclusions WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
 // ListPorts 返回指定主机的端口，可选择包含隐藏记录。
 func (s *Store) ListPorts(ctx context.Context, hostID int64, includeHidden bool) ([]models.Port, error) {
 	ports, _, err := s.ListPortsWithQuery(ctx, hostID, includeHidden, nil)
@@ -276,21 +661,16 @@ func (s *Store) ListPortsWithQuery(ctx context.Context, hostID int64, includeHid
 		query.PageSize = 0
 	}
 
-	base := `FROM ports WHERE host_id = ?`
-	args := []interface{}{hostID}
-
-	if !includeHidden {
-		base += ` AND hidden = 0`
-	}
-	if search := strings.TrimSpace(query.Search); search != "" {
-		base += ` AND (CAST(number AS TEXT) LIKE ? OR fingerprint LIKE ? OR note LIKE ?)`
-		pattern := "%" + search + "%"
-		args = append(args, pattern, pattern, pattern)
+	conds, args, err := query.conditions(s.dialect)
+	if err != nil {
+		return nil, 0, err
 	}
-	if status := strings.TrimSpace(query.Status); status != "" {
-		base += ` AND status = ?`
-		args = append(args, status)
+	conds = append([]string{`ports.host_id = ?`}, conds...)
+	args = append([]interface{}{hostID}, args...)
+	if !includeHidden {
+		conds = append(conds, `ports.hidden = 0`)
 	}
+	base := `FROM ports WHERE ` + strings.Join(conds, " AND ")
 
 	countSQL := `SELECT COUNT(1) ` + base
 	var total int
@@ -298,27 +678,8 @@ func (s *Store) ListPortsWithQuery(ctx context.Context, hostID int64, includeHid
 		return nil, 0, err
 	}
 
-	sortColumn := "number"
-	switch strings.ToLower(query.SortBy) {
-	case "number":
-		sortColumn = "number"
-	case "last_checked":
-		sortColumn = "last_checked"
-	case "updated_at":
-		sortColumn = "updated_at"
-	case "fingerprint":
-		sortColumn = "fingerprint"
-	default:
-		sortColumn = "number"
-	}
-	orderExpr := sortColumn
-	if query.SortDesc {
-		orderExpr += " DESC"
-	} else {
-		orderExpr += " ASC"
-	}
-
-	selectSQL := `SELECT id, host_id, number, note, fingerprint, hidden, status, last_checked, created_at, updated_at ` + base + ` ORDER BY ` + orderExpr
+	orderExpr := query.orderBy()
+	selectSQL := `SELECT ` + portColumns + ` ` + base + ` ORDER BY ` + orderExpr
 	if query.PageSize > 0 {
 		offset := (query.Page - 1) * query.PageSize
 		selectSQL += fmt.Sprintf(" LIMIT %d OFFSET %d", query.PageSize, offset)
@@ -332,34 +693,65 @@ func (s *Store) ListPortsWithQuery(ctx context.Context, hostID int64, includeHid
 
 	var ports []models.Port
 	for rows.Next() {
-		var p models.Port
-		var hidden int
-		var lastChecked sql.NullTime
-		if err := rows.Scan(&p.ID, &p.HostID, &p.Number, &p.Note, &p.Fingerprint, &hidden, &p.Status, &lastChecked, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		p, err := scanPort(rows)
+		if err != nil {
 			return nil, 0, err
 		}
-		p.Hidden = hidden == 1
-		if lastChecked.Valid {
-			p.LastChecked = lastChecked.Time
-		}
-		ports = append(ports, p)
+		ports = append(ports, *p)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, 0, err
 	}
+	rows.Close()
+
+	portLabels, err := s.portLabelMap(ctx, hostID)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range ports {
+		ports[i].Labels = labelsOrEmpty(portLabels[ports[i].ID])
+	}
 	return ports, total, nil
 }
 
-// CreatePort 新增端口记录。
-func (s *Store) CreatePort(ctx context.Context, hostID int64, number int, note, fingerprint string) (int64, error) {
-	res, err := s.DB.ExecContext(ctx,
-		`INSERT INTO ports (host_id, number, note, fingerprint, status) VALUES (?, ?, ?, ?, ?)`,
-		hostID, number, note, fingerprint, models.PortStatusUnknown,
-	)
+// CreatePort 新增端口记录；locked 为 true 时指纹视为人工标注并锁定。
+func (s *Store) CreatePort(ctx context.Context, hostID int64, number int, note, fingerprint string, locked bool) (int64, error) {
+	userFingerprint := ""
+	if locked {
+		userFingerprint = fingerprint
+	}
+	var id int64
+	err := s.DB.QueryRowContext(ctx,
+		`INSERT INTO ports (host_id, number, note, fingerprint, user_fingerprint, fingerprint_locked, status) VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+		hostID, number, note, fingerprint, userFingerprint, boolToInt(locked && userFingerprint != ""), models.PortStatusUnknown,
+	).Scan(&id)
+	return id, err
+}
+
+// CreateDetectedPort 为扫描器发现的新端口建档，服务名称记为扫描结果。
+func (s *Store) CreateDetectedPort(ctx context.Context, hostID int64, number int, note, service string, detectedAt time.Time) (int64, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return 0, err
 	}
-	return res.LastInsertId()
+	defer func() { _ = tx.Rollback() }()
+
+	var id int64
+	if err := tx.QueryRowContext(ctx,
+		`INSERT INTO ports (host_id, number, note, fingerprint, detected_service, status) VALUES (?, ?, ?, ?, ?, ?) RETURNING id`,
+		hostID, number, note, service, service, models.PortStatusUnknown,
+	).Scan(&id); err != nil {
+		return 0, err
+	}
+	if service != "" {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO port_service_history (port_id, previous, service, detected_at) VALUES (?, '', ?, ?)`,
+			id, service, detectedAt.UTC(),
+		); err != nil {
+			return 0, err
+		}
+	}
+	return id, tx.Commit()
 }
 
 // DeletePort 删除端口记录。
@@ -370,37 +762,100 @@ func (s *Store) DeletePort(ctx context.Context, portID int64) error {
 
 // GetPort 根据端口 ID 查询端口。
 func (s *Store) GetPort(ctx context.Context, portID int64) (*models.Port, error) {
-	var p models.Port
-	var lastChecked sql.NullTime
-	var hidden int
-	err := s.DB.QueryRowContext(ctx, `SELECT id, host_id, number, note, fingerprint, hidden, status, last_checked, created_at, updated_at FROM ports WHERE id = ?`, portID).
-		Scan(&p.ID, &p.HostID, &p.Number, &p.Note, &p.Fingerprint, &hidden, &p.Status, &lastChecked, &p.CreatedAt, &p.UpdatedAt)
+	p, err := scanPort(s.DB.QueryRowContext(ctx, `SELECT `+portColumns+` FROM ports WHERE id = ?`, portID))
 	if err != nil {
 		return nil, err
 	}
-	if lastChecked.Valid {
-		p.LastChecked = lastChecked.Time
+	if p.Labels, err = s.PortLabels(ctx, portID); err != nil {
+		return nil, err
 	}
-	p.Hidden = hidden == 1
-	return &p, nil
+	return p, nil
 }
 
-// UpdatePortNote 更新端口备注与指纹。
-func (s *Store) UpdatePortNote(ctx context.Context, portID int64, note, fingerprint string) error {
+// UpdatePortNote 更新端口备注与人工指纹。
+// userFingerprint 为空时恢复使用扫描结果，扫描结果也为空则回退到 fallback。
+func (s *Store) UpdatePortNote(ctx context.Context, portID int64, note, userFingerprint string, locked bool, fallback string) error {
+	locked = locked && userFingerprint != ""
 	_, err := s.DB.ExecContext(ctx,
-		`UPDATE ports SET note = ?, fingerprint = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
-		note, fingerprint, portID,
+		`UPDATE ports SET
+			note = ?,
+			user_fingerprint = ?,
+			fingerprint_locked = ?,
+			fingerprint = CASE
+				WHEN CAST(? AS INTEGER) = 1 THEN CAST(? AS TEXT)
+				WHEN detected_service != '' THEN detected_service
+				WHEN CAST(? AS TEXT) != '' THEN CAST(? AS TEXT)
+				ELSE CAST(? AS TEXT)
+			END,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`,
+		note, userFingerprint, boolToInt(locked),
+		boolToInt(locked), userFingerprint,
+		userFingerprint, userFingerprint,
+		fallback,
+		portID,
 	)
 	return err
 }
 
-// UpdatePortFingerprint 仅更新指纹信息。
-func (s *Store) UpdatePortFingerprint(ctx context.Context, portID int64, fingerprint string) error {
-	_, err := s.DB.ExecContext(ctx,
-		`UPDATE ports SET fingerprint = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
-		fingerprint, portID,
+// RecordDetectedService 写入扫描器识别的服务名称。
+// 服务发生变化时记录历史；若指纹已被人工锁定，则仅更新 detected_service。
+func (s *Store) RecordDetectedService(ctx context.Context, portID int64, service string, detectedAt time.Time) (bool, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var previous string
+	if err := tx.QueryRowContext(ctx, `SELECT detected_service FROM ports WHERE id = ?`, portID).Scan(&previous); err != nil {
+		return false, err
+	}
+	if previous == service {
+		return false, nil
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE ports SET
+			detected_service = ?,
+			fingerprint = CASE WHEN fingerprint_locked = 1 THEN fingerprint ELSE CAST(? AS TEXT) END,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`,
+		service, service, portID,
+	); err != nil {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO port_service_history (port_id, previous, service, detected_at) VALUES (?, ?, ?, ?)`,
+		portID, previous, service, detectedAt.UTC(),
+	); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// ListServiceHistory 按时间倒序返回端口的服务识别历史。
+func (s *Store) ListServiceHistory(ctx context.Context, portID int64, limit int) ([]models.ServiceChange, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT id, port_id, previous, service, detected_at FROM port_service_history WHERE port_id = ? ORDER BY detected_at DESC, id DESC LIMIT ?`,
+		portID, limit,
 	)
-	return err
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []models.ServiceChange
+	for rows.Next() {
+		var c models.ServiceChange
+		if err := rows.Scan(&c.ID, &c.PortID, &c.Previous, &c.Service, &c.DetectedAt); err != nil {
+			return nil, err
+		}
+		history = append(history, c)
+	}
+	return history, rows.Err()
 }
 
 // SetPortHidden 设置端口隐藏标记。
@@ -436,27 +891,47 @@ func (s *Store) BulkDeletePorts(ctx context.Context, hostID int64, start, end in
 	return res.RowsAffected()
 }
 
-// UpdatePortStatus 写入最新的端口状态检查结果。
+// UpdatePortStatus 写入最新的端口状态检查结果，并清零连续未发现次数。
 func (s *Store) UpdatePortStatus(ctx context.Context, portID int64, status string, t time.Time) error {
 	_, err := s.DB.ExecContext(ctx,
-		`UPDATE ports SET status = ?, last_checked = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		`UPDATE ports SET status = ?, missed_scans = 0, last_checked = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
 		status, t.UTC(), portID,
 	)
 	return err
 }
 
+// RecordPortMiss 记录一次开放端口未被扫描发现（状态不变），返回累计的连续未发现次数。
+func (s *Store) RecordPortMiss(ctx context.Context, portID int64, t time.Time) (int, error) {
+	if _, err := s.DB.ExecContext(ctx,
+		`UPDATE ports SET missed_scans = missed_scans + 1, last_checked = ? WHERE id = ?`, t.UTC(), portID); err != nil {
+		return 0, err
+	}
+	var misses int
+	err := s.DB.QueryRowContext(ctx, `SELECT missed_scans FROM ports WHERE id = ?`, portID).Scan(&misses)
+	return misses, err
+}
+
 // FindPortByNumber 根据主机与端口号查询端口。
 func (s *Store) FindPortByNumber(ctx context.Context, hostID int64, number int) (*models.Port, error) {
+	return scanPort(s.DB.QueryRowContext(ctx, `SELECT `+portColumns+` FROM ports WHERE host_id = ? AND number = ?`, hostID, number))
+}
+
+const portColumns = `id, host_id, number, note, fingerprint, detected_service, user_fingerprint, fingerprint_locked, hidden, status, missed_scans, last_checked, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanPort 按 portColumns 的列顺序读取一行端口记录。
+func scanPort(row rowScanner) (*models.Port, error) {
 	var p models.Port
 	var lastChecked sql.NullTime
-	var hidden int
-	err := s.DB.QueryRowContext(ctx, `SELECT id, host_id, number, note, fingerprint, hidden, status, last_checked, created_at, updated_at FROM ports WHERE host_id = ? AND number = ?`,
-		hostID, number).
-		Scan(&p.ID, &p.HostID, &p.Number, &p.Note, &p.Fingerprint, &hidden, &p.Status, &lastChecked, &p.CreatedAt, &p.UpdatedAt)
-	if err != nil {
+	var hidden, locked int
+	if err := row.Scan(&p.ID, &p.HostID, &p.Number, &p.Note, &p.Fingerprint, &p.DetectedService, &p.UserFingerprint, &locked, &hidden, &p.Status, &p.MissedScans, &lastChecked, &p.CreatedAt, &p.UpdatedAt); err != nil {
 		return nil, err
 	}
 	p.Hidden = hidden == 1
+	p.FingerprintLocked = locked == 1
 	if lastChecked.Valid {
 		p.LastChecked = lastChecked.Time
 	}
@@ -0,0 +1,96 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/hitushen/portnotepro/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrUserExists 表示用户名已被占用。
+var ErrUserExists = errors.New("user already exists")
+
+// ListUsers 按用户名排序返回全部用户。
+func (s *Store) ListUsers(ctx context.Context) ([]models.User, error) {
+	rows, err := s.DB.QueryContext(ctx, `SELECT id, username, disabled, created_at FROM users ORDER BY username`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []models.User{}
+	for rows.Next() {
+		var u models.User
+		var disabled int
+		if err := rows.Scan(&u.ID, &u.Username, &disabled, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		u.Disabled = disabled == 1
+		list = append(list, u)
+	}
+	return list, rows.Err()
+}
+
+// CreateUser 新增用户，用户名已存在时返回 ErrUserExists。
+func (s *Store) CreateUser(ctx context.Context, username, password string) (int64, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return 0, fmt.Errorf("hash password: %w", err)
+	}
+	var existing int64
+	err = s.DB.QueryRowContext(ctx, `SELECT id FROM users WHERE username = ?`, username).Scan(&existing)
+	if err == nil {
+		return 0, ErrUserExists
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+	var id int64
+	err = s.DB.QueryRowContext(ctx,
+		`INSERT INTO users (username, password_hash) VALUES (?, ?) RETURNING id`, username, string(hash)).Scan(&id)
+	return id, err
+}
+
+// SetUserPassword 修改用户密码，用户不存在时返回 sql.ErrNoRows。
+func (s *Store) SetUserPassword(ctx context.Context, username, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("hash password: %w", err)
+	}
+	return s.updateUser(ctx, `UPDATE users SET password_hash = ? WHERE username = ?`, string(hash), username)
+}
+
+// SetUserDisabled 停用或重新启用用户，用户不存在时返回 sql.ErrNoRows。
+func (s *Store) SetUserDisabled(ctx context.Context, username string, disabled bool) error {
+	return s.updateUser(ctx, `UPDATE users SET disabled = ? WHERE username = ?`, boolToInt(disabled), username)
+}
+
+// UserActive 判断用户存在且未停用，会话认证在每个请求上检查。
+func (s *Store) UserActive(ctx context.Context, id int64) (bool, error) {
+	var disabled int
+	err := s.DB.QueryRowContext(ctx, `SELECT disabled FROM users WHERE id = ?`, id).Scan(&disabled)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return disabled == 0, nil
+}
+
+func (s *Store) updateUser(ctx context.Context, query string, args ...interface{}) error {
+	res, err := s.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
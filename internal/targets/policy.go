@@ -0,0 +1,365 @@
+package targets
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 排除规则类型。
+const (
+	RuleCIDR  = "cidr"
+	RuleHost  = "host"
+	RulePorts = "ports"
+)
+
+// PortRange 表示闭区间端口范围。
+type PortRange struct {
+	Start int
+	End   int
+}
+
+// BlockedError 表示目标或端口被扫描策略拒绝。
+type BlockedError struct {
+	Target string
+	Reason string
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("target %s blocked: %s", e.Target, e.Reason)
+}
+
+// Policy 汇总扫描安全规则：允许网段（非空时启用白名单模式）、拒绝网段、拒绝主机名与排除端口。
+type Policy struct {
+	AllowNets []*net.IPNet
+	DenyNets  []*net.IPNet
+	DenyHosts []string
+	DenyPorts []PortRange
+}
+
+// NewPolicy 根据允许与拒绝列表构建策略，拒绝列表可混合 CIDR/IP 与主机名。
+func NewPolicy(allow, deny []string) (*Policy, error) {
+	p := &Policy{}
+	for _, value := range allow {
+		ipNet, err := ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("allow %q: %w", value, err)
+		}
+		p.AllowNets = append(p.AllowNets, ipNet)
+	}
+	for _, value := range deny {
+		kind := RuleHost
+		if _, err := ParseCIDR(value); err == nil {
+			kind = RuleCIDR
+		}
+		if err := p.AddRule(kind, value); err != nil {
+			return nil, fmt.Errorf("deny %q: %w", value, err)
+		}
+	}
+	return p, nil
+}
+
+// Clone 返回策略的浅拷贝，便于在基础策略上叠加规则。
+func (p *Policy) Clone() *Policy {
+	if p == nil {
+		return &Policy{}
+	}
+	return &Policy{
+		AllowNets: append([]*net.IPNet(nil), p.AllowNets...),
+		DenyNets:  append([]*net.IPNet(nil), p.DenyNets...),
+		DenyHosts: append([]string(nil), p.DenyHosts...),
+		DenyPorts: append([]PortRange(nil), p.DenyPorts...),
+	}
+}
+
+// PolicySpec 为策略的可序列化形式，用于把扫描策略下发给远程 agent。
+type PolicySpec struct {
+	Allow     []string `json:"allow,omitempty"`
+	DenyNets  []string `json:"denyNets,omitempty"`
+	DenyHosts []string `json:"denyHosts,omitempty"`
+	DenyPorts string   `json:"denyPorts,omitempty"`
+}
+
+// Spec 返回策略的可序列化形式。
+func (p *Policy) Spec() PolicySpec {
+	if p == nil {
+		return PolicySpec{}
+	}
+	spec := PolicySpec{
+		DenyNets:  p.ExcludedNets(),
+		DenyHosts: append([]string(nil), p.DenyHosts...),
+		DenyPorts: p.ExcludedPorts(),
+	}
+	for _, n := range p.AllowNets {
+		spec.Allow = append(spec.Allow, n.String())
+	}
+	return spec
+}
+
+// Policy 由可序列化形式重建策略。
+func (s PolicySpec) Policy() (*Policy, error) {
+	p, err := NewPolicy(s.Allow, s.DenyNets)
+	if err != nil {
+		return nil, err
+	}
+	for _, host := range s.DenyHosts {
+		if err := p.AddRule(RuleHost, host); err != nil {
+			return nil, fmt.Errorf("deny host %q: %w", host, err)
+		}
+	}
+	if s.DenyPorts != "" {
+		if err := p.AddRule(RulePorts, s.DenyPorts); err != nil {
+			return nil, fmt.Errorf("deny ports: %w", err)
+		}
+	}
+	return p, nil
+}
+
+// AddRule 追加一条排除规则。
+func (p *Policy) AddRule(kind, value string) error {
+	switch kind {
+	case RuleCIDR:
+		ipNet, err := ParseCIDR(value)
+		if err != nil {
+			return err
+		}
+		p.DenyNets = append(p.DenyNets, ipNet)
+	case RuleHost:
+		host, err := normalizeHostPattern(value)
+		if err != nil {
+			return err
+		}
+		p.DenyHosts = append(p.DenyHosts, host)
+	case RulePorts:
+		ranges, err := ParsePortRanges(value)
+		if err != nil {
+			return err
+		}
+		p.DenyPorts = append(p.DenyPorts, ranges...)
+	default:
+		return fmt.Errorf("unknown rule kind %q", kind)
+	}
+	return nil
+}
+
+// ValidateRule 校验规则并返回规范化后的值。
+func ValidateRule(kind, value string) (string, error) {
+	switch kind {
+	case RuleCIDR:
+		ipNet, err := ParseCIDR(value)
+		if err != nil {
+			return "", err
+		}
+		return ipNet.String(), nil
+	case RuleHost:
+		return normalizeHostPattern(value)
+	case RulePorts:
+		ranges, err := ParsePortRanges(value)
+		if err != nil {
+			return "", err
+		}
+		return FormatPortRanges(ranges), nil
+	default:
+		return "", fmt.Errorf("unknown rule kind %q", kind)
+	}
+}
+
+// Apply 校验解析结果并返回允许扫描的目标。
+// 解析出地址时只返回通过校验的 IP，不再交出主机名，以免扫描器重新解析出策略之外的地址。
+func (p *Policy) Apply(res Resolution) ([]string, error) {
+	if res.Host == "" {
+		return nil, fmt.Errorf("invalid target address")
+	}
+	if p == nil {
+		return res.Targets(), nil
+	}
+	if pattern, ok := p.hostDenied(res.Host); ok {
+		return nil, &BlockedError{Target: res.Host, Reason: fmt.Sprintf("matches excluded host %s", pattern)}
+	}
+	if len(res.Addresses) == 0 {
+		if len(p.AllowNets) > 0 {
+			reason := "allow-list mode requires a resolvable address"
+			if res.Err != nil {
+				reason += ": " + res.Err.Error()
+			}
+			return nil, &BlockedError{Target: res.Host, Reason: reason}
+		}
+		return res.Targets(), nil
+	}
+
+	allowed := make([]string, 0, len(res.Addresses))
+	var lastReason string
+	for _, addr := range res.Addresses {
+		if reason := p.ipBlocked(net.ParseIP(addr)); reason != "" {
+			lastReason = addr + " " + reason
+			continue
+		}
+		allowed = append(allowed, addr)
+	}
+	if len(allowed) == 0 {
+		return nil, &BlockedError{Target: res.Host, Reason: lastReason}
+	}
+	return allowed, nil
+}
+
+// PortAllowed 判断端口是否可被扫描。
+func (p *Policy) PortAllowed(port int) bool {
+	if p == nil {
+		return true
+	}
+	for _, r := range p.DenyPorts {
+		if port >= r.Start && port <= r.End {
+			return false
+		}
+	}
+	return true
+}
+
+// FilterPorts 过滤掉被排除的端口。
+func (p *Policy) FilterPorts(ports []int) []int {
+	result := make([]int, 0, len(ports))
+	for _, port := range ports {
+		if p.PortAllowed(port) {
+			result = append(result, port)
+		}
+	}
+	return result
+}
+
+// ExcludedPorts 返回排除端口的 naabu 格式描述（如 "22,8000-8100"）。
+func (p *Policy) ExcludedPorts() string {
+	if p == nil {
+		return ""
+	}
+	return FormatPortRanges(p.DenyPorts)
+}
+
+// ExcludedNets 返回拒绝网段的字符串形式。
+func (p *Policy) ExcludedNets() []string {
+	if p == nil {
+		return nil
+	}
+	result := make([]string, len(p.DenyNets))
+	for i, n := range p.DenyNets {
+		result[i] = n.String()
+	}
+	return result
+}
+
+func (p *Policy) hostDenied(host string) (string, bool) {
+	host = strings.ToLower(host)
+	for _, pattern := range p.DenyHosts {
+		if pattern == host {
+			return pattern, true
+		}
+		if strings.HasPrefix(pattern, "*.") && strings.HasSuffix(host, pattern[1:]) {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+func (p *Policy) ipBlocked(ip net.IP) string {
+	if ip == nil {
+		return "is not a valid IP"
+	}
+	for _, n := range p.DenyNets {
+		if n.Contains(ip) {
+			return "is in excluded network " + n.String()
+		}
+	}
+	if len(p.AllowNets) == 0 {
+		return ""
+	}
+	for _, n := range p.AllowNets {
+		if n.Contains(ip) {
+			return ""
+		}
+	}
+	return "is outside the allowed networks"
+}
+
+// ParseCIDR 解析 CIDR，单个 IP 视为 /32 或 /128。
+func ParseCIDR(value string) (*net.IPNet, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, fmt.Errorf("empty network")
+	}
+	if !strings.Contains(value, "/") {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP %q", value)
+		}
+		bits := 128
+		if ip.To4() != nil {
+			ip = ip.To4()
+			bits = 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, ipNet, err := net.ParseCIDR(value)
+	if err != nil {
+		return nil, err
+	}
+	return ipNet, nil
+}
+
+// ParsePortRanges 解析形如 "22,80,8000-8100" 的端口范围。
+func ParsePortRanges(spec string) ([]PortRange, error) {
+	var ranges []PortRange
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		startStr, endStr, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(strings.TrimSpace(startStr))
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q", part)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(strings.TrimSpace(endStr)); err != nil {
+				return nil, fmt.Errorf("invalid port range %q", part)
+			}
+		}
+		if start < 1 || end > 65535 || end < start {
+			return nil, fmt.Errorf("invalid port range %q", part)
+		}
+		ranges = append(ranges, PortRange{Start: start, End: end})
+	}
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("empty port range")
+	}
+	return ranges, nil
+}
+
+// FormatPortRanges 将端口范围排序后输出为逗号分隔的字符串。
+func FormatPortRanges(ranges []PortRange) string {
+	sorted := append([]PortRange(nil), ranges...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+	parts := make([]string, 0, len(sorted))
+	for _, r := range sorted {
+		if r.Start == r.End {
+			parts = append(parts, strconv.Itoa(r.Start))
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%d-%d", r.Start, r.End))
+	}
+	return strings.Join(parts, ",")
+}
+
+func normalizeHostPattern(value string) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	wildcard := strings.HasPrefix(value, "*.")
+	host := Normalize(strings.TrimPrefix(value, "*."))
+	if host == "" || strings.ContainsAny(host, "* ") {
+		return "", fmt.Errorf("invalid host %q", value)
+	}
+	if wildcard {
+		return "*." + host, nil
+	}
+	return host, nil
+}
@@ -1,11 +1,100 @@
 package targets
 
 import (
+	"context"
+	"fmt"
 	"net"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 )
 
+var (
+	resolverMu sync.RWMutex
+	resolver   = net.DefaultResolver
+)
+
+// SetResolver 指定解析域名使用的 DNS 服务器（host:port），为空时恢复系统默认解析器。
+func SetResolver(addr string) error {
+	addr = strings.TrimSpace(addr)
+	resolverMu.Lock()
+	defer resolverMu.Unlock()
+	if addr == "" {
+		resolver = net.DefaultResolver
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return fmt.Errorf("invalid resolver address %q: %w", addr, err)
+	}
+	resolver = &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			d := net.Dialer{Timeout: 5 * time.Second}
+			return d.DialContext(ctx, network, addr)
+		},
+	}
+	return nil
+}
+
+func currentResolver() *net.Resolver {
+	resolverMu.RLock()
+	defer resolverMu.RUnlock()
+	return resolver
+}
+
+// Resolution 描述一次地址解析的结果。
+type Resolution struct {
+	// Host 为标准化后的主机名或 IP。
+	Host string
+	// Addresses 为解析得到的 IP 列表；Host 本身为 IP 时即为其自身。
+	Addresses []string
+	// Err 记录域名解析失败的原因。
+	Err error
+}
+
+// Resolve 标准化地址并解析出全部 IP。
+func Resolve(ctx context.Context, address string) Resolution {
+	res := Resolution{Host: Normalize(address)}
+	if res.Host == "" {
+		return res
+	}
+	if net.ParseIP(res.Host) != nil {
+		res.Addresses = []string{res.Host}
+		return res
+	}
+
+	ips, err := currentResolver().LookupHost(ctx, res.Host)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	seen := make(map[string]struct{}, len(ips))
+	for _, ip := range ips {
+		if net.ParseIP(ip) == nil {
+			continue
+		}
+		if _, ok := seen[ip]; ok {
+			continue
+		}
+		seen[ip] = struct{}{}
+		res.Addresses = append(res.Addresses, ip)
+	}
+	return res
+}
+
+// Targets 返回扫描目标：解析出 IP 时只返回这些 IP，扫描器不会绕过已配置的解析器重新解析主机名；
+// 尚无解析结果时返回标准化主机名。
+func (r Resolution) Targets() []string {
+	if r.Host == "" {
+		return nil
+	}
+	if len(r.Addresses) > 0 {
+		return append([]string(nil), r.Addresses...)
+	}
+	return []string{r.Host}
+}
+
 // Normalize 对用户输入的地址进行裁剪并提取主机部分。
 func Normalize(address string) string {
 	addr := strings.TrimSpace(address)
@@ -58,38 +147,8 @@ func Normalize(address string) string {
 	return strings.ToLower(strings.Trim(addr, "[] "))
 }
 
-// Build 将输入解析为唯一的扫描目标列表。
-// 返回内容包括标准化主机名以及解析得到的 IP。
-func Build(address string) []string {
-	normalized := Normalize(address)
-	if normalized == "" {
-		return nil
-	}
-
-	seen := make(map[string]struct{})
-	result := make([]string, 0, 4)
-	appendUnique := func(val string) {
-		if val == "" {
-			return
-		}
-		if _, ok := seen[val]; ok {
-			return
-		}
-		seen[val] = struct{}{}
-		result = append(result, val)
-	}
-
-	appendUnique(normalized)
-
-	if net.ParseIP(normalized) == nil {
-		if ips, err := net.LookupHost(normalized); err == nil {
-			for _, ip := range ips {
-				if net.ParseIP(ip) != nil {
-					appendUnique(ip)
-				}
-			}
-		}
-	}
-
-	return result
+// Build 将输入解析为唯一的扫描目标列表，并按 policy 过滤被排除的目标。
+// 返回内容为解析得到的 IP，无法解析时为标准化主机名；policy 为 nil 时不做限制。
+func Build(ctx context.Context, address string, policy *Policy) ([]string, error) {
+	return policy.Apply(Resolve(ctx, address))
 }
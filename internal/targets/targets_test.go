@@ -0,0 +1,202 @@
+package targets
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// fakeDNS 为本地 DNS 替身：按名称返回 A 记录，未登记的名称回复 NXDOMAIN。
+type fakeDNS struct {
+	conn net.PacketConn
+
+	mu      sync.Mutex
+	records map[string][]string
+}
+
+func startFakeDNS(t *testing.T) *fakeDNS {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	d := &fakeDNS{conn: conn, records: map[string][]string{}}
+	go d.serve()
+	if err := SetResolver(conn.LocalAddr().String()); err != nil {
+		t.Fatalf("set resolver: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = SetResolver("")
+		conn.Close()
+	})
+	return d
+}
+
+func (d *fakeDNS) set(name string, ips ...string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.records[strings.ToLower(name)+"."] = ips
+}
+
+func (d *fakeDNS) serve() {
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := d.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if reply, ok := d.answer(buf[:n]); ok {
+			_, _ = d.conn.WriteTo(reply, addr)
+		}
+	}
+}
+
+func (d *fakeDNS) answer(packet []byte) ([]byte, bool) {
+	var p dnsmessage.Parser
+	header, err := p.Start(packet)
+	if err != nil {
+		return nil, false
+	}
+	q, err := p.Question()
+	if err != nil {
+		return nil, false
+	}
+
+	d.mu.Lock()
+	ips, known := d.records[strings.ToLower(q.Name.String())]
+	d.mu.Unlock()
+
+	resp := dnsmessage.Header{ID: header.ID, Response: true, Authoritative: true, RecursionDesired: header.RecursionDesired}
+	if !known {
+		resp.RCode = dnsmessage.RCodeNameError
+	}
+	b := dnsmessage.NewBuilder(nil, resp)
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil, false
+	}
+	if err := b.Question(q); err != nil {
+		return nil, false
+	}
+	if err := b.StartAnswers(); err != nil {
+		return nil, false
+	}
+	if q.Type == dnsmessage.TypeA {
+		for _, ip := range ips {
+			var a dnsmessage.AResource
+			copy(a.A[:], net.ParseIP(ip).To4())
+			rh := dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET, TTL: 1}
+			if err := b.AResource(rh, a); err != nil {
+				return nil, false
+			}
+		}
+	}
+	reply, err := b.Finish()
+	return reply, err == nil
+}
+
+func resolve(t *testing.T, address string) Resolution {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	res := Resolve(ctx, address)
+	sort.Strings(res.Addresses)
+	return res
+}
+
+func TestResolveMultipleARecords(t *testing.T) {
+	dns := startFakeDNS(t)
+	dns.set("web.portnote.test", "10.0.0.2", "10.0.0.1", "10.0.0.2")
+
+	res := resolve(t, "https://WEB.portnote.test:8443/login")
+	if res.Err != nil {
+		t.Fatalf("resolve: %v", res.Err)
+	}
+	if res.Host != "web.portnote.test" {
+		t.Errorf("host = %q, want web.portnote.test", res.Host)
+	}
+	if want := []string{"10.0.0.1", "10.0.0.2"}; !reflect.DeepEqual(res.Addresses, want) {
+		t.Errorf("addresses = %v, want %v", res.Addresses, want)
+	}
+	if got := res.Targets(); !reflect.DeepEqual(got, []string{"10.0.0.1", "10.0.0.2"}) {
+		t.Errorf("targets = %v, want only the resolved addresses", got)
+	}
+}
+
+func TestResolveAddressChange(t *testing.T) {
+	dns := startFakeDNS(t)
+	dns.set("db.portnote.test", "10.0.1.1")
+	if res := resolve(t, "db.portnote.test"); !reflect.DeepEqual(res.Addresses, []string{"10.0.1.1"}) {
+		t.Fatalf("first answer = %v, %v", res.Addresses, res.Err)
+	}
+
+	dns.set("db.portnote.test", "10.0.1.9")
+	if res := resolve(t, "db.portnote.test"); !reflect.DeepEqual(res.Addresses, []string{"10.0.1.9"}) {
+		t.Fatalf("answer after change = %v, %v", res.Addresses, res.Err)
+	}
+}
+
+func TestResolveNXDOMAIN(t *testing.T) {
+	startFakeDNS(t)
+
+	res := resolve(t, "gone.portnote.test")
+	if res.Err == nil {
+		t.Fatalf("expected an error, got addresses %v", res.Addresses)
+	}
+	var dnsErr *net.DNSError
+	if !errors.As(res.Err, &dnsErr) || !dnsErr.IsNotFound {
+		t.Errorf("error = %v, want a not-found DNS error", res.Err)
+	}
+	if len(res.Addresses) != 0 {
+		t.Errorf("addresses = %v, want none", res.Addresses)
+	}
+}
+
+func TestResolveIPSkipsLookup(t *testing.T) {
+	startFakeDNS(t)
+
+	res := resolve(t, "[2001:db8::1]:22")
+	if res.Err != nil || !reflect.DeepEqual(res.Addresses, []string{"2001:db8::1"}) {
+		t.Errorf("resolve IPv6 literal = %v, %v", res.Addresses, res.Err)
+	}
+}
+
+func TestBuildAllowListHostname(t *testing.T) {
+	dns := startFakeDNS(t)
+	dns.set("app.portnote.test", "10.0.0.2", "10.0.0.1")
+	policy, err := NewPolicy([]string{"10.0.0.0/24"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 全部地址位于白名单内时也只交出 IP，扫描器不会再次解析主机名。
+	got, err := Build(ctx, "app.portnote.test", policy)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	sort.Strings(got)
+	if want := []string{"10.0.0.1", "10.0.0.2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("targets = %v, want %v", got, want)
+	}
+
+	// 解析结果移出白名单后目标被拒绝。
+	dns.set("app.portnote.test", "10.0.0.1", "192.168.7.7")
+	if got, err = Build(ctx, "app.portnote.test", policy); err != nil || !reflect.DeepEqual(got, []string{"10.0.0.1"}) {
+		t.Errorf("partially allowed targets = %v, %v; want [10.0.0.1]", got, err)
+	}
+	dns.set("app.portnote.test", "192.168.7.7")
+	var blocked *BlockedError
+	if _, err = Build(ctx, "app.portnote.test", policy); !errors.As(err, &blocked) {
+		t.Errorf("build outside the allow-list = %v, want BlockedError", err)
+	}
+}
@@ -163,6 +163,10 @@
         return '开放';
       case 'closed':
         return '关闭';
+      case 'filtered':
+        return '过滤';
+      case 'unreachable':
+        return '不可达';
       case 'unknown':
       default:
         return '未知';
@@ -314,15 +318,29 @@
       case 'host_scan_started':
         setHostScanning(data.hostId, true);
         break;
-      case 'host_scanned':
-        setHostScanning(data.hostId, false);
+      case 'host_scanned': {
+        const payload = data.payload || {};
+        const host = state.hosts.find((item) => item.id === data.hostId);
+        if (host) {
+          host.lastScanError = payload.success ? '' : payload.error;
+          host.lastScanErrorKind = payload.success ? '' : payload.errorKind;
+          host.consecutiveFailures = payload.consecutiveFailures ?? 0;
+        }
+        // 临时性失败会自动重试，期间保持扫描中状态。
+        setHostScanning(data.hostId, !!payload.retrying);
         if (data.hostId === state.selectedHostId) {
-          showToast('扫描完成', data.payload?.success ? 'success' : 'info');
+          if (payload.success) {
+            showToast('扫描完成', 'success');
+          } else {
+            showToast(`扫描失败${payload.retrying ? '，稍后重试' : ''}：${payload.error || '未知错误'}`, 'error');
+          }
           loadPorts(state.selectedHostId);
         }
         break;
+      }
       case 'port_created':
       case 'port_updated':
+      case 'port_service_detected':
       case 'port_deleted':
       case 'port_hidden':
       case 'port_visible':
@@ -430,6 +448,9 @@
       li.className = `host-item${host.id === state.selectedHostId ? ' active' : ''}`;
       li.dataset.hostId = String(host.id);
       const scanningBadge = host.scanning ? '<span class="host-badge">扫描中</span>' : '';
+      const failureBadge = host.consecutiveFailures > 0
+        ? `<span class="host-badge error" title="${escapeHTML(host.lastScanError || '')}">扫描失败 ×${host.consecutiveFailures}</span>`
+        : '';
       li.innerHTML = `
         <span class="name">${escapeHTML(host.name)}</span>
         <span class="address">${escapeHTML(host.address)}</span>
@@ -437,6 +458,7 @@
           <span>在用 ${host.openCount ?? 0}</span>
           <span>隐藏 ${host.hiddenCount ?? 0}</span>
           ${scanningBadge}
+          ${failureBadge}
         </div>
       `;
       li.addEventListener('click', () => selectHost(host.id));
@@ -802,7 +824,11 @@
         <input type="text" name="note" required value="${escapeHTML(port.note || '')}">
       </label>
       <label>指纹
-        <input type="text" name="fingerprint" value="${escapeHTML(port.fingerprint || '')}">
+        <input type="text" name="fingerprint" value="${escapeHTML(port.userFingerprint || '')}" placeholder="${escapeHTML(port.detectedService || port.fingerprint || '留空则使用扫描结果')}">
+      </label>
+      <label class="checkbox">
+        <input type="checkbox" name="locked" ${port.fingerprintLocked || !port.userFingerprint ? 'checked' : ''}>
+        锁定指纹，扫描结果不覆盖
       </label>
       <div class="actions">
         <button type="button" class="btn-secondary" data-action="cancel">取消</button>
@@ -815,6 +841,7 @@
       const formData = new FormData(form);
       const note = formData.get('note')?.toString().trim() || '';
       const fingerprint = formData.get('fingerprint')?.toString().trim() || '';
+      const locked = formData.get('locked') === 'on';
       if (!note) {
         notify('备注不能为空');
         return;
@@ -822,7 +849,7 @@
       try {
         await fetchJSON(`/api/ports/${port.id}`, {
           method: 'PUT',
-          body: JSON.stringify({ note, fingerprint }),
+          body: JSON.stringify({ note, fingerprint, locked }),
         });
         closeModal();
         showToast('端口已更新', 'success');